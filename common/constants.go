@@ -241,6 +241,8 @@ const (
 	ChannelTypeXinference     = 47
 	ChannelTypeXai            = 48
 	ChannelTypeCoze           = 49
+	ChannelTypeKling          = 50
+	ChannelTypeRunway         = 51
 	ChannelTypeDummy          // this one is only for count, do not add any channel after this
 
 )
@@ -296,4 +298,6 @@ var ChannelBaseURLs = []string{
 	"",                                          //47
 	"https://api.x.ai",                          //48
 	"https://api.coze.cn",                       //49
+	"https://api.klingai.com",                   //50
+	"https://api.dev.runwayml.com",              //51
 }
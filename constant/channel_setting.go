@@ -1,7 +1,12 @@
 package constant
 
 var (
-	ForceFormat                     = "force_format"        // ForceFormat 强制格式化为OpenAI格式
-	ChanelSettingProxy              = "proxy"               // Proxy 代理
-	ChannelSettingThinkingToContent = "thinking_to_content" // ThinkingToContent
+	ForceFormat                     = "force_format"                // ForceFormat 强制格式化为OpenAI格式
+	ChanelSettingProxy              = "proxy"                       // Proxy 代理
+	ChannelSettingThinkingToContent = "thinking_to_content"         // ThinkingToContent
+	ChannelSettingResponsesToChat   = "responses_to_chat"           // ResponsesToChat 渠道不支持 Responses API，/v1/responses 转为 Chat Completions
+	ChannelSettingStructuredOutput  = "structured_output_emulation" // StructuredOutput 渠道不支持 json_schema，由网关模拟 Structured Outputs
+	ChannelSettingToolCallEmulation = "tool_call_emulation"         // ToolCallEmulation 渠道不支持函数调用，由网关在提示词中模拟工具调用
+	ChannelSettingNFanOut           = "n_fan_out"                   // NFanOut 渠道不支持 n 参数，n>1 时由网关并发请求后合并
+	ChannelSettingWebSearch         = "web_search_emulation"        // WebSearch 渠道不支持联网搜索，由网关执行 web_search 工具
 )
@@ -5,6 +5,10 @@ type TaskPlatform string
 const (
 	TaskPlatformSuno       TaskPlatform = "suno"
 	TaskPlatformMidjourney              = "mj"
+	TaskPlatformKling      TaskPlatform = "kling"
+	TaskPlatformRunway     TaskPlatform = "runway"
+	TaskPlatformVolcEngine TaskPlatform = "volcengine"
+	TaskPlatformMiniMax    TaskPlatform = "minimax"
 )
 
 const (
@@ -16,3 +20,9 @@ var SunoModel2Action = map[string]string{
 	"suno_music":  SunoActionMusic,
 	"suno_lyrics": SunoActionLyrics,
 }
+
+// 视频生成任务的操作类型
+const (
+	TaskActionTextGenerate = "textGenerate" // 文生视频
+	TaskActionGenerate     = "generate"     // 图生视频
+)
@@ -8,6 +8,7 @@ var (
 	UserSettingNotificationEmail     = "notification_email"             // NotificationEmail 通知邮箱地址
 	UserAcceptUnsetRatioModel        = "accept_unset_model_ratio_model" // AcceptUnsetRatioModel 是否接受未设置价格的模型
 	UserSettingRecordIpLog          = "record_ip_log"                   // 是否记录请求和错误日志IP
+	UserSettingMediaRetentionDays    = "media_retention_days"           // 生成结果的保留天数，0 表示使用系统默认
 )
 
 var (
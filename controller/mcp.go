@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	mcpErrorParse          = -32700
+	mcpErrorMethodNotFound = -32601
+	mcpErrorInvalidParams  = -32602
+	mcpErrorInternal       = -32603
+	mcpErrorQuota          = -32000
+)
+
+func mcpResult(c *gin.Context, id json.RawMessage, result any) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		mcpError(c, id, mcpErrorInternal, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.McpMessage{JsonRPC: "2.0", Id: id, Result: data})
+}
+
+func mcpError(c *gin.Context, id json.RawMessage, code int, message string) {
+	c.JSON(http.StatusOK, service.McpMessage{
+		JsonRPC: "2.0",
+		Id:      id,
+		Error:   &service.McpError{Code: code, Message: message},
+	})
+}
+
+// getMcpRequestGroup 与分发中间件一致：令牌指定了可用分组时使用令牌分组，否则使用用户分组
+func getMcpRequestGroup(c *gin.Context) (string, error) {
+	userGroup := c.GetString(constant.ContextKeyUserGroup)
+	tokenGroup := c.GetString("token_group")
+	if tokenGroup != "" {
+		if _, ok := setting.GetUserUsableGroups(userGroup)[tokenGroup]; !ok {
+			return "", fmt.Errorf("令牌分组 %s 已被禁用", tokenGroup)
+		}
+		if !setting.ContainsGroupRatio(tokenGroup) {
+			return "", fmt.Errorf("分组 %s 已被弃用", tokenGroup)
+		}
+		userGroup = tokenGroup
+	}
+	return userGroup, nil
+}
+
+// RelayMcp 以 Streamable HTTP 方式代理 MCP 服务器：客户端使用令牌鉴权，
+// 网关维护与上游服务器的会话，每次工具调用按服务器价格计费
+func RelayMcp(c *gin.Context) {
+	if c.Request.Method == http.MethodGet {
+		// 不提供服务器主动推送的消息流
+		c.Status(http.StatusMethodNotAllowed)
+		return
+	}
+	if c.Request.Method == http.MethodDelete {
+		c.Status(http.StatusOK)
+		return
+	}
+	allowIpsMap := c.GetStringMap("allow_ips")
+	if len(allowIpsMap) != 0 {
+		if _, ok := allowIpsMap[c.ClientIP()]; !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": gin.H{"message": "您的 IP 不在令牌允许访问的列表中"}})
+			return
+		}
+	}
+	group, err := getMcpRequestGroup(c)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": gin.H{"message": err.Error()}})
+		return
+	}
+	c.Set("group", group)
+	server, err := model.GetEnabledMcpServerByName(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"message": fmt.Sprintf("mcp server %s not found", c.Param("name"))}})
+		return
+	}
+	if err := service.CheckMcpServerPermission(c, server, group); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": gin.H{"message": err.Error()}})
+		return
+	}
+
+	var message service.McpMessage
+	requestBody, err := common.GetRequestBody(c)
+	if err == nil {
+		err = common.DecodeJson(requestBody, &message)
+	}
+	if err != nil {
+		mcpError(c, nil, mcpErrorParse, "parse error: "+err.Error())
+		return
+	}
+	if message.Method == "" {
+		// 客户端对服务器请求的响应，网关不会发起这类请求
+		c.Status(http.StatusAccepted)
+		return
+	}
+	if len(message.Id) == 0 {
+		// 通知不需要响应，上游会话由网关自行初始化
+		c.Status(http.StatusAccepted)
+		return
+	}
+
+	switch message.Method {
+	case "initialize":
+		mcpResult(c, message.Id, gin.H{
+			"protocolVersion": "2025-03-26",
+			"capabilities": gin.H{
+				"tools": gin.H{},
+			},
+			"serverInfo": gin.H{
+				"name":    server.Name,
+				"version": common.Version,
+			},
+			"instructions": server.Description,
+		})
+	case "ping":
+		mcpResult(c, message.Id, gin.H{})
+	case "tools/list":
+		client, err := service.GetMcpClient(c.Request.Context(), server)
+		if err != nil {
+			mcpError(c, message.Id, mcpErrorInternal, err.Error())
+			return
+		}
+		tools, err := client.ListTools(c.Request.Context(), false)
+		if err != nil {
+			mcpError(c, message.Id, mcpErrorInternal, err.Error())
+			return
+		}
+		mcpResult(c, message.Id, gin.H{"tools": tools})
+	case "tools/call":
+		relayMcpToolCall(c, server, group, &message)
+	default:
+		mcpError(c, message.Id, mcpErrorMethodNotFound, "method not found: "+message.Method)
+	}
+}
+
+func relayMcpToolCall(c *gin.Context, server *model.McpServer, group string, message *service.McpMessage) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := common.DecodeJson(message.Params, &params); err != nil || params.Name == "" {
+		mcpError(c, message.Id, mcpErrorInvalidParams, "invalid params: tool name is required")
+		return
+	}
+	quota, _ := service.GetMcpCallQuota(server, group)
+	if quota > 0 {
+		if c.GetInt(constant.ContextKeyUserQuota) < quota {
+			mcpError(c, message.Id, mcpErrorQuota, "user quota is not enough")
+			return
+		}
+		if !c.GetBool("token_unlimited_quota") && c.GetInt("token_quota") < quota {
+			mcpError(c, message.Id, mcpErrorQuota, "token quota is not enough")
+			return
+		}
+	}
+	client, err := service.GetMcpClient(c.Request.Context(), server)
+	if err != nil {
+		mcpError(c, message.Id, mcpErrorInternal, err.Error())
+		return
+	}
+	startTime := time.Now()
+	result, err := client.CallTool(c.Request.Context(), params.Name, params.Arguments)
+	service.ConsumeMcpToolCall(c, server, params.Name, group, startTime, err == nil)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("mcp tool call %s/%s failed: %s", server.Name, params.Name, err.Error()))
+		var mcpErr *service.McpError
+		if errors.As(err, &mcpErr) {
+			mcpError(c, message.Id, mcpErr.Code, mcpErr.Message)
+			return
+		}
+		mcpError(c, message.Id, mcpErrorInternal, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.McpMessage{JsonRPC: "2.0", Id: message.Id, Result: result})
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeMedia 通过签名地址读取保存的生成结果
+func ServeMedia(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if key == "" || !service.VerifyMediaSignature(key, c.Query("expires"), c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "invalid_or_expired_signature",
+		})
+		return
+	}
+	object, err := model.GetMediaObjectByKey(key)
+	if err != nil || (object.ExpiresAt > 0 && object.ExpiresAt < time.Now().Unix()) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "media_not_found",
+		})
+		return
+	}
+	reader, err := service.OpenMedia(c.Request.Context(), object)
+	if err != nil {
+		common.SysError("open media failed: " + err.Error())
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "media_not_found",
+		})
+		return
+	}
+	defer reader.Close()
+	c.Header("Content-Type", object.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(object.Size, 10))
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Status(http.StatusOK)
+	_, _ = io.Copy(c.Writer, reader)
+}
+
+func getMediaObjects(c *gin.Context, userId int) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 1 {
+		p = 1
+	}
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	if pageSize <= 0 {
+		pageSize = common.ItemsPerPage
+	}
+	objects, total, err := model.GetMediaObjects(userId, c.Query("source"), c.Query("task_id"), (p-1)*pageSize, pageSize)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	items := make([]gin.H, 0, len(objects))
+	for _, object := range objects {
+		items = append(items, gin.H{
+			"id":           object.Id,
+			"user_id":      object.UserId,
+			"source":       object.Source,
+			"task_id":      object.TaskId,
+			"content_type": object.ContentType,
+			"size":         object.Size,
+			"backend":      object.Backend,
+			"created_time": object.CreatedTime,
+			"expires_at":   object.ExpiresAt,
+			"url":          service.SignMediaURL(object),
+		})
+	}
+	data := gin.H{
+		"items":     items,
+		"total":     total,
+		"page":      p,
+		"page_size": pageSize,
+	}
+	if userId != 0 {
+		usage, err := model.GetUserMediaUsage(userId)
+		if err == nil {
+			data["usage"] = usage
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
+
+// GetUserMedia 用户查询自己保存的生成结果及存储用量
+func GetUserMedia(c *gin.Context) {
+	getMediaObjects(c, c.GetInt("id"))
+}
+
+// GetAllMedia 管理员查询保存的生成结果，可按 user_id 过滤
+func GetAllMedia(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	getMediaObjects(c, userId)
+}
+
+func deleteMedia(c *gin.Context, userId int) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	object, err := model.GetMediaObjectById(id)
+	if err != nil || (userId != 0 && object.UserId != userId) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "记录不存在",
+		})
+		return
+	}
+	if err := service.DeleteMedia(c.Request.Context(), object); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// DeleteUserMedia 用户删除自己保存的生成结果
+func DeleteUserMedia(c *gin.Context) {
+	deleteMedia(c, c.GetInt("id"))
+}
+
+// DeleteMedia 管理员删除保存的生成结果
+func DeleteMedia(c *gin.Context) {
+	deleteMedia(c, 0)
+}
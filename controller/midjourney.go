@@ -11,6 +11,7 @@ import (
 	"one-api/common"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/relay"
 	"one-api/service"
 	"one-api/setting"
 	"strconv"
@@ -23,6 +24,7 @@ func UpdateMidjourneyTaskBulk() {
 	for {
 		time.Sleep(time.Duration(15) * time.Second)
 
+		reapTimeoutMidjourneyTasks(ctx)
 		tasks := model.GetAllUnFinishTasks()
 		if len(tasks) == 0 {
 			continue
@@ -127,6 +129,7 @@ func UpdateMidjourneyTaskBulk() {
 				if !checkMjTaskNeedUpdate(task, responseItem) {
 					continue
 				}
+				oldStatus := task.Status
 				task.Code = 1
 				task.Progress = responseItem.Progress
 				task.PromptEn = responseItem.PromptEn
@@ -153,17 +156,17 @@ func UpdateMidjourneyTaskBulk() {
 						shouldReturnQuota = true
 					}
 				}
-				err = task.Update()
+				// 任务可能已被管理员退款等流程终结，只有本次写入成功时才退款和通知
+				updated, err := task.UpdateUnfinished()
 				if err != nil {
 					common.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
-				} else {
+				} else if updated {
 					if shouldReturnQuota {
-						err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
-						if err != nil {
-							common.LogError(ctx, "fail to increase user quota: "+err.Error())
-						}
-						logContent := fmt.Sprintf("构图失败 %s，补偿 %s", task.MjId, common.LogQuota(task.Quota))
-						model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+						refundMidjourneyQuota(ctx, task, "构图失败")
+					}
+					if task.Status != oldStatus {
+						relay.ArchiveMidjourneyMedia(task)
+						relay.NotifyMidjourneyTaskWebhook(task)
 					}
 				}
 			}
@@ -171,6 +174,19 @@ func UpdateMidjourneyTaskBulk() {
 	}
 }
 
+// refundMidjourneyQuota 任务执行失败时退还预扣的额度，reason 写入退款日志
+func refundMidjourneyQuota(ctx context.Context, task *model.Midjourney, reason string) {
+	if task.Quota == 0 {
+		return
+	}
+	err := model.IncreaseUserQuota(task.UserId, task.Quota, false)
+	if err != nil {
+		common.LogError(ctx, "fail to increase user quota: "+err.Error())
+	}
+	logContent := fmt.Sprintf("%s %s，补偿 %s", reason, task.MjId, common.LogQuota(task.Quota))
+	model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+}
+
 func checkMjTaskNeedUpdate(oldTask *model.Midjourney, newTask dto.MidjourneyDto) bool {
 	if oldTask.Code != 1 {
 		return true
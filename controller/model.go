@@ -23,6 +23,9 @@ var openAIModels []dto.OpenAIModels
 var openAIModelsMap map[string]dto.OpenAIModels
 var channelId2Models map[int][]string
 
+// 支持视频生成任务的渠道类型
+var videoChannelTypes = []int{common.ChannelTypeKling, common.ChannelTypeRunway, common.ChannelTypeVolcEngine, common.ChannelTypeMiniMax}
+
 func getPermission() []dto.OpenAIModelPermission {
 	var permission []dto.OpenAIModelPermission
 	permission = append(permission, dto.OpenAIModelPermission{
@@ -119,6 +122,20 @@ func init() {
 			Parent:     nil,
 		})
 	}
+	for _, channelType := range videoChannelTypes {
+		adaptor := relay.GetTaskAdaptor(relay.GetVideoTaskPlatform(channelType))
+		for _, modelName := range adaptor.GetModelList() {
+			openAIModels = append(openAIModels, dto.OpenAIModels{
+				Id:         modelName,
+				Object:     "model",
+				Created:    1626777600,
+				OwnedBy:    adaptor.GetChannelName(),
+				Permission: permission,
+				Root:       modelName,
+				Parent:     nil,
+			})
+		}
+	}
 	openAIModelsMap = make(map[string]dto.OpenAIModels)
 	for _, aiModel := range openAIModels {
 		openAIModelsMap[aiModel.Id] = aiModel
@@ -134,6 +151,12 @@ func init() {
 		adaptor.Init(meta)
 		channelId2Models[i] = adaptor.GetModelList()
 	}
+	// 支持视频生成的渠道加上视频模型
+	for _, channelType := range videoChannelTypes {
+		adaptor := relay.GetTaskAdaptor(relay.GetVideoTaskPlatform(channelType))
+		models := append([]string{}, channelId2Models[channelType]...)
+		channelId2Models[channelType] = append(models, adaptor.GetModelList()...)
+	}
 }
 
 func ListModels(c *gin.Context) {
@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay/helper"
+	"one-api/service"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ollamaError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, dto.OllamaError{
+		Error: message,
+	})
+}
+
+// ollamaErrorMessage 从 OpenAI 格式的错误响应中提取错误信息
+func ollamaErrorMessage(body []byte) string {
+	var errResponse struct {
+		Error dto.OpenAIError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errResponse); err == nil && errResponse.Error.Message != "" {
+		return errResponse.Error.Message
+	}
+	return string(body)
+}
+
+// convertOllamaRequest 将 Ollama 请求改写为内部 OpenAI 请求：替换请求体缓存与请求路径，
+// 之后交给通用的 Relay 流程处理，计费、重试与日志保持不变
+func convertOllamaRequest(c *gin.Context) (stream bool, info *service.OllamaStreamInfo, err error) {
+	var request any
+	path := c.Request.URL.Path
+	switch {
+	case strings.HasSuffix(path, "/api/chat"):
+		var ollamaRequest dto.OllamaChatRequest
+		if err = common.UnmarshalBodyReusable(c, &ollamaRequest); err != nil {
+			return
+		}
+		request, err = service.OllamaChatToOpenAIRequest(&ollamaRequest)
+		stream = ollamaRequest.IsStream()
+		info = service.NewOllamaStreamInfo(ollamaRequest.Model, false)
+		c.Request.URL.Path = "/v1/chat/completions"
+	case strings.HasSuffix(path, "/api/generate"):
+		var ollamaRequest dto.OllamaGenerateRequest
+		if err = common.UnmarshalBodyReusable(c, &ollamaRequest); err != nil {
+			return
+		}
+		request, err = service.OllamaGenerateToOpenAIRequest(&ollamaRequest)
+		stream = ollamaRequest.IsStream()
+		info = service.NewOllamaStreamInfo(ollamaRequest.Model, true)
+		c.Request.URL.Path = "/v1/chat/completions"
+	case strings.HasSuffix(path, "/api/embeddings"), strings.HasSuffix(path, "/api/embed"):
+		var ollamaRequest dto.OllamaEmbeddingRequest
+		if err = common.UnmarshalBodyReusable(c, &ollamaRequest); err != nil {
+			return
+		}
+		request = service.OllamaEmbeddingToOpenAIRequest(&ollamaRequest)
+		c.Request.URL.Path = "/v1/embeddings"
+	default:
+		err = errors.New("unsupported ollama api: " + path)
+		return
+	}
+	if err != nil {
+		return
+	}
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return
+	}
+	c.Set(common.KeyRequestBody, jsonData)
+	return
+}
+
+// RelayOllama 处理 Ollama 兼容接口 /api/chat、/api/generate、/api/embeddings、/api/embed
+func RelayOllama(c *gin.Context) {
+	legacyEmbedding := strings.HasSuffix(c.Request.URL.Path, "/api/embeddings")
+	stream, info, err := convertOllamaRequest(c)
+	if err != nil {
+		ollamaError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	originWriter := c.Writer
+	defer func() {
+		c.Writer = originWriter
+	}()
+
+	if stream {
+		var writer *helper.ConvertWriter
+		writer = helper.NewLineConvertWriter(originWriter, "application/x-ndjson", func(data string) {
+			if data == "[DONE]" {
+				return
+			}
+			var streamResponse dto.ChatCompletionsStreamResponse
+			if err := common.DecodeJsonStr(data, &streamResponse); err != nil {
+				common.SysError("error unmarshalling stream response: " + err.Error())
+				return
+			}
+			for _, response := range service.StreamResponseOpenAI2Ollama(&streamResponse, info) {
+				writeOllamaLine(writer, response)
+			}
+		})
+		c.Writer = writer
+		Relay(c)
+		// 出错时 Relay 直接写出 JSON 错误，不构成 SSE 事件
+		if pending := bytes.TrimSpace(writer.Pending()); len(pending) > 0 {
+			message := ollamaErrorMessage(pending)
+			if writer.Started() {
+				writeOllamaLine(writer, dto.OllamaError{Error: message})
+			} else {
+				c.Writer = originWriter
+				ollamaError(c, writer.Status(), message)
+			}
+			return
+		}
+		for _, response := range service.FinishStreamResponseOpenAI2Ollama(info, nil) {
+			writeOllamaLine(writer, response)
+		}
+		return
+	}
+
+	writer := helper.NewBufferedConvertWriter(originWriter)
+	c.Writer = writer
+	Relay(c)
+	c.Writer = originWriter
+	if writer.Status() != http.StatusOK {
+		ollamaError(c, writer.Status(), ollamaErrorMessage(writer.Body()))
+		return
+	}
+	if info == nil {
+		var embeddingResponse dto.OpenAIEmbeddingResponse
+		if err := common.DecodeJson(writer.Body(), &embeddingResponse); err != nil {
+			ollamaError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, service.EmbeddingResponseOpenAI2Ollama(&embeddingResponse, legacyEmbedding))
+		return
+	}
+	var openAIResponse dto.OpenAITextResponse
+	if err := common.DecodeJson(writer.Body(), &openAIResponse); err != nil {
+		ollamaError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.ResponseOpenAI2Ollama(&openAIResponse, info))
+}
+
+func writeOllamaLine(writer *helper.ConvertWriter, object any) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		common.SysError("error marshalling ollama response: " + err.Error())
+		return
+	}
+	writer.WriteLine(data)
+}
+
+// OllamaListModels 对应 /api/tags，返回当前令牌可用的模型
+func OllamaListModels(c *gin.Context) {
+	var models []string
+	if c.GetBool("token_model_limit_enabled") {
+		if tokenModelLimit, ok := c.Get("token_model_limit"); ok {
+			for modelName := range tokenModelLimit.(map[string]bool) {
+				models = append(models, modelName)
+			}
+		}
+	} else {
+		userGroup, err := model.GetUserGroup(c.GetInt("id"), true)
+		if err != nil {
+			ollamaError(c, http.StatusInternalServerError, "get user group failed")
+			return
+		}
+		group := userGroup
+		if tokenGroup := c.GetString("token_group"); tokenGroup != "" {
+			group = tokenGroup
+		}
+		models = model.GetGroupModels(group)
+	}
+	modifiedAt := time.Now().UTC().Format(time.RFC3339Nano)
+	ollamaModels := make([]dto.OllamaModel, 0, len(models))
+	for _, modelName := range models {
+		ollamaModels = append(ollamaModels, dto.OllamaModel{
+			Name:       modelName,
+			Model:      modelName,
+			ModifiedAt: modifiedAt,
+			Details: dto.OllamaModelDetails{
+				Format:   "gguf",
+				Families: []string{},
+			},
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"models": ollamaModels,
+	})
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"one-api/common"
 	"one-api/model"
@@ -126,15 +127,25 @@ func AddRedemption(c *gin.Context) {
 		})
 		return
 	}
+	if err := validateRedemptionOptions(&redemption); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	var keys []string
 	for i := 0; i < redemption.Count; i++ {
 		key := common.GetUUID()
 		cleanRedemption := model.Redemption{
-			UserId:      c.GetInt("id"),
-			Name:        redemption.Name,
-			Key:         key,
-			CreatedTime: common.GetTimestamp(),
-			Quota:       redemption.Quota,
+			UserId:               c.GetInt("id"),
+			Name:                 redemption.Name,
+			Key:                  key,
+			CreatedTime:          common.GetTimestamp(),
+			Quota:                redemption.Quota,
+			SubscriptionPlanId:   redemption.SubscriptionPlanId,
+			SubscriptionDuration: redemption.SubscriptionDuration,
+			ExpiredTime:          redemption.ExpiredTime,
 		}
 		err = cleanRedemption.Insert()
 		if err != nil {
@@ -194,9 +205,19 @@ func UpdateRedemption(c *gin.Context) {
 	if statusOnly != "" {
 		cleanRedemption.Status = redemption.Status
 	} else {
+		if err := validateRedemptionOptions(&redemption); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 		// If you add more fields, please also update redemption.Update()
 		cleanRedemption.Name = redemption.Name
 		cleanRedemption.Quota = redemption.Quota
+		cleanRedemption.SubscriptionPlanId = redemption.SubscriptionPlanId
+		cleanRedemption.SubscriptionDuration = redemption.SubscriptionDuration
+		cleanRedemption.ExpiredTime = redemption.ExpiredTime
 	}
 	err = cleanRedemption.Update()
 	if err != nil {
@@ -213,3 +234,25 @@ func UpdateRedemption(c *gin.Context) {
 	})
 	return
 }
+
+// validateRedemptionOptions 校验订阅兑换码的套餐与有效期，以及兑换码的过期时间
+func validateRedemptionOptions(redemption *model.Redemption) error {
+	if redemption.ExpiredTime != 0 && redemption.ExpiredTime < common.GetTimestamp() {
+		return errors.New("过期时间不能早于当前时间")
+	}
+	if redemption.SubscriptionPlanId == 0 {
+		redemption.SubscriptionDuration = 0
+		return nil
+	}
+	// 兑换订阅套餐时不会增加余额，同时设置额度会被忽略，直接拒绝
+	if redemption.Quota != 0 {
+		return errors.New("订阅套餐兑换码不能同时设置额度")
+	}
+	if _, err := model.GetSubscriptionPlanById(redemption.SubscriptionPlanId); err != nil {
+		return errors.New("订阅套餐不存在")
+	}
+	if redemption.SubscriptionDuration < 0 || redemption.SubscriptionDuration > 3650 {
+		return errors.New("订阅有效期必须在 0 到 3650 天之间")
+	}
+	return nil
+}
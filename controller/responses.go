@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func abortWithResponsesError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": dto.OpenAIError{
+			Message: message,
+			Type:    "invalid_request_error",
+			Param:   "",
+			Code:    "",
+		},
+	})
+}
+
+// getStoredResponse 获取当前令牌所属用户保存的响应，不存在时直接写入错误
+func getStoredResponse(c *gin.Context) *model.ResponseRecord {
+	responseId := c.Param("id")
+	record, err := model.GetResponseRecord(c.GetInt("id"), responseId)
+	if err != nil {
+		common.LogError(c, "failed to get stored response: "+err.Error())
+		abortWithResponsesError(c, http.StatusInternalServerError, err.Error())
+		return nil
+	}
+	if record == nil {
+		abortWithResponsesError(c, http.StatusNotFound, fmt.Sprintf("Response with id '%s' not found.", responseId))
+		return nil
+	}
+	return record
+}
+
+func GetResponse(c *gin.Context) {
+	record := getStoredResponse(c)
+	if record == nil {
+		return
+	}
+	c.Data(http.StatusOK, "application/json", []byte(record.Response))
+}
+
+func DeleteResponse(c *gin.Context) {
+	responseId := c.Param("id")
+	deleted, err := model.DeleteResponseRecord(c.GetInt("id"), responseId)
+	if err != nil {
+		abortWithResponsesError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !deleted {
+		abortWithResponsesError(c, http.StatusNotFound, fmt.Sprintf("Response with id '%s' not found.", responseId))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      responseId,
+		"object":  "response",
+		"deleted": true,
+	})
+}
+
+func ListResponseInputItems(c *gin.Context) {
+	record := getStoredResponse(c)
+	if record == nil {
+		return
+	}
+	items, err := service.GetResponsesRecordInput(record)
+	if err != nil {
+		abortWithResponsesError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// 默认按时间倒序，与 OpenAI 保持一致
+	if c.DefaultQuery("order", "desc") == "desc" {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	if after := c.Query("after"); after != "" {
+		for idx, item := range items {
+			if item.ID == after {
+				items = items[idx+1:]
+				break
+			}
+		}
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+	var firstId, lastId string
+	if len(items) > 0 {
+		firstId = items[0].ID
+		lastId = items[len(items)-1].ID
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":   "list",
+		"data":     items,
+		"first_id": firstId,
+		"last_id":  lastId,
+		"has_more": hasMore,
+	})
+}
@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSemanticCacheStats 获取语义缓存的条目数与命中统计
+func GetSemanticCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    service.GetSemanticCacheStats(),
+	})
+}
+
+// ClearSemanticCache 清空语义缓存
+func ClearSemanticCache(c *gin.Context) {
+	service.ClearSemanticCache()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "语义缓存已清空",
+	})
+}
@@ -2,11 +2,14 @@ package controller
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"one-api/common"
+	"one-api/service"
 	"one-api/model"
+	"one-api/setting/operation_setting"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -16,10 +19,42 @@ import (
 type SubscriptionPlanRequest struct {
 	Name        string                   `json:"name" binding:"required"`
 	Description string                   `json:"description"`
-	Price       float64                  `json:"price" binding:"required,min=0"`
+	Price       float64                  `json:"price" binding:"min=0"`
 	Duration    int                      `json:"duration" binding:"required,min=1"`
 	Status      int                      `json:"status"`
 	ModelQuotas model.ModelQuotaMap      `json:"model_quotas" binding:"required"`
+	QuotaUnits  model.QuotaUnitMap       `json:"quota_units"`   // 各模型配额的计量单位，未配置的模型按请求次数计
+	ResetPeriods model.ResetPeriodMap    `json:"reset_periods"` // 各模型配额的重置周期，未配置的模型不重置
+	ModelGroups model.ModelGroupMap      `json:"model_groups"`  // 模型组，组名需同时配置在模型配额中，组内模型共享该配额
+	IsTrial       bool     `json:"is_trial"`       // 试用套餐，每个用户限购一次
+	PurchaseLimit int      `json:"purchase_limit"` // 每个用户最多购买次数，0表示不限
+	AllowedGroups []string `json:"allowed_groups"` // 允许购买的用户分组，为空表示不限
+	NewUserDays   int      `json:"new_user_days"`  // 仅限注册不超过该天数的用户购买，0表示不限
+	RequireEmail  bool     `json:"require_email"`  // 需要已绑定邮箱
+	RequireOAuth  bool     `json:"require_oauth"`  // 需要已绑定第三方账号
+}
+
+// applySubscriptionPlanRestrictions 校验并设置套餐的购买限制
+func applySubscriptionPlanRestrictions(plan *model.SubscriptionPlan, req *SubscriptionPlanRequest) error {
+	if req.PurchaseLimit < 0 {
+		return errors.New("限购次数不能为负数")
+	}
+	if req.NewUserDays < 0 {
+		return errors.New("新用户天数不能为负数")
+	}
+	groups := make([]string, 0, len(req.AllowedGroups))
+	for _, group := range req.AllowedGroups {
+		group = strings.TrimSpace(group)
+		if group != "" && !common.StringsContains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	plan.IsTrial = req.IsTrial
+	plan.PurchaseLimit = req.PurchaseLimit
+	plan.NewUserDays = req.NewUserDays
+	plan.RequireEmail = req.RequireEmail
+	plan.RequireOAuth = req.RequireOAuth
+	return plan.SetAllowedGroups(groups)
 }
 
 // PurchaseSubscriptionRequest 购买订阅请求结构
@@ -28,6 +63,16 @@ type PurchaseSubscriptionRequest struct {
 	PaymentMethod string `json:"payment_method" binding:"required"`
 }
 
+// SubscriptionAutoRenewRequest 设置订阅自动续费请求结构
+type SubscriptionAutoRenewRequest struct {
+	AutoRenew bool `json:"auto_renew"`
+}
+
+// SubscriptionPlanChangeRequest 变更订阅套餐请求结构
+type SubscriptionPlanChangeRequest struct {
+	PlanId int `json:"plan_id" binding:"required"`
+}
+
 // GetAllSubscriptionPlans 获取所有订阅套餐
 func GetAllSubscriptionPlans(c *gin.Context) {
 	status := -1 // 默认获取所有状态
@@ -38,6 +83,10 @@ func GetAllSubscriptionPlans(c *gin.Context) {
 	}
 	
 	plans, err := model.GetAllSubscriptionPlans(status)
+	if err == nil && c.GetInt("id") != 0 {
+		// 已登录时返回当前用户对每个套餐的购买资格
+		err = service.FillSubscriptionEligibility(c.GetInt("id"), plans)
+	}
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -96,6 +145,9 @@ func GetSubscriptionPlan(c *gin.Context) {
 	}
 	
 	plan, err := model.GetSubscriptionPlanById(id)
+	if err == nil && c.GetInt("id") != 0 {
+		plan.Eligibility, err = service.CheckSubscriptionEligibility(c.GetInt("id"), plan)
+	}
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -131,6 +183,30 @@ func CreateSubscriptionPlan(c *gin.Context) {
 		return
 	}
 	
+	if err := model.ValidateQuotaUnits(req.QuotaUnits, req.ModelQuotas); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	if err := model.ValidateResetPeriods(req.ResetPeriods, req.ModelQuotas); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	if err := model.ValidateModelGroups(req.ModelGroups, req.ModelQuotas); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	
 	plan := &model.SubscriptionPlan{
 		Name:        req.Name,
 		Description: req.Description,
@@ -139,6 +215,34 @@ func CreateSubscriptionPlan(c *gin.Context) {
 		Status:      req.Status,
 		ModelQuotas: string(modelQuotasJSON),
 	}
+	if err := plan.SetQuotaUnitsMap(req.QuotaUnits); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := plan.SetResetPeriodsMap(req.ResetPeriods); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := plan.SetModelGroupsMap(req.ModelGroups); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := applySubscriptionPlanRestrictions(plan, &req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	
 	err = plan.Insert()
 	if err != nil {
@@ -195,12 +299,64 @@ func UpdateSubscriptionPlan(c *gin.Context) {
 		return
 	}
 	
+	if err := model.ValidateQuotaUnits(req.QuotaUnits, req.ModelQuotas); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	if err := model.ValidateResetPeriods(req.ResetPeriods, req.ModelQuotas); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	if err := model.ValidateModelGroups(req.ModelGroups, req.ModelQuotas); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	
 	plan.Name = req.Name
 	plan.Description = req.Description
 	plan.Price = req.Price
 	plan.Duration = req.Duration
 	plan.Status = req.Status
 	plan.ModelQuotas = string(modelQuotasJSON)
+	if err := plan.SetQuotaUnitsMap(req.QuotaUnits); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := plan.SetResetPeriodsMap(req.ResetPeriods); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := plan.SetModelGroupsMap(req.ModelGroups); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := applySubscriptionPlanRestrictions(plan, &req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	
 	err = plan.Update()
 	if err != nil {
@@ -291,30 +447,19 @@ func PurchaseSubscription(c *gin.Context) {
 		return
 	}
 	
-	// 生成支付订单ID（这里简化处理，实际应该集成支付系统）
-	paymentId := fmt.Sprintf("SUB_%d_%d_%d", userId, req.PlanId, time.Now().Unix())
-	
-	// 创建用户订阅
-	subscription, err := model.CreateUserSubscription(userId, req.PlanId, req.PaymentMethod, paymentId)
-	if err != nil {
+	if err := service.EnsureSubscriptionEligibility(userId, plan); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
-			"message": "创建订阅失败: " + err.Error(),
+			"message": err.Error(),
 		})
 		return
 	}
-	
-	// 记录日志
-	model.RecordLog(userId, model.LogTypeSystem, fmt.Sprintf("购买订阅套餐: %s，价格: %.2f元", plan.Name, plan.Price))
-	
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "购买订阅成功",
-		"data": gin.H{
-			"subscription": subscription,
-			"payment_id":   paymentId,
-		},
-	})
+	// 免费套餐无需支付，直接按余额购买的流程开通
+	if req.PaymentMethod == model.SubscriptionPaymentBalance || plan.Price <= 0 {
+		purchaseSubscriptionWithBalance(c, userId, plan)
+		return
+	}
+	purchaseSubscriptionWithEpay(c, userId, plan, req.PaymentMethod)
 }
 
 // GetUserSubscriptions 获取用户订阅列表
@@ -351,6 +496,173 @@ func GetUserSubscriptions(c *gin.Context) {
 	})
 }
 
+// UpdateSubscriptionAutoRenew 开启或关闭订阅到期自动续费
+func UpdateSubscriptionAutoRenew(c *gin.Context) {
+	userId := c.GetInt("id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的订阅ID",
+		})
+		return
+	}
+	
+	var req SubscriptionAutoRenewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	if req.AutoRenew && !operation_setting.GetSubscriptionSetting().AutoRenewEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "当前未开启订阅自动续费",
+		})
+		return
+	}
+	
+	err = model.SetUserSubscriptionAutoRenew(id, userId, req.AutoRenew)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "设置自动续费失败: " + err.Error(),
+		})
+		return
+	}
+	
+	message := "已关闭自动续费"
+	if req.AutoRenew {
+		message = "已开启自动续费，到期前将从账户余额扣费续期"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+	})
+}
+
+// GetSubscriptionPlanChangeQuote 预览将订阅变更为指定套餐的折算与补差价
+func GetSubscriptionPlanChangeQuote(c *gin.Context) {
+	userId := c.GetInt("id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的订阅ID",
+		})
+		return
+	}
+	planId, err := strconv.Atoi(c.Query("plan_id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的套餐ID",
+		})
+		return
+	}
+	
+	subscription, err := model.GetUserSubscriptionById(id)
+	if err != nil || subscription.UserId != userId {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "订阅不存在",
+		})
+		return
+	}
+	plan, err := model.GetSubscriptionPlanById(planId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "获取订阅套餐失败: " + err.Error(),
+		})
+		return
+	}
+	
+	quote, err := service.QuoteSubscriptionPlanChange(subscription, plan)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quote,
+	})
+}
+
+// ChangeSubscriptionPlan 升级或降级订阅套餐，按折算结果从余额补差价或退还
+func ChangeSubscriptionPlan(c *gin.Context) {
+	userId := c.GetInt("id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的订阅ID",
+		})
+		return
+	}
+	
+	var req SubscriptionPlanChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	subscription, change, err := service.ChangeSubscriptionPlan(userId, id, req.PlanId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "变更套餐失败: " + err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "变更套餐成功",
+		"data": gin.H{
+			"subscription": subscription,
+			"change":       change,
+		},
+	})
+}
+
+// GetSubscriptionPlanChanges 获取订阅的套餐变更历史
+func GetSubscriptionPlanChanges(c *gin.Context) {
+	userId := c.GetInt("id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的订阅ID",
+		})
+		return
+	}
+	
+	changes, err := model.GetSubscriptionPlanChanges(userId, id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "获取套餐变更记录失败: " + err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    changes,
+	})
+}
+
 // GetActiveUserSubscriptions 获取用户激活的订阅
 func GetActiveUserSubscriptions(c *gin.Context) {
 	userId := c.GetInt("id")
@@ -397,8 +709,9 @@ func GetSubscriptionQuotas(c *gin.Context) {
 		return
 	}
 
-	// 汇总所有激活订阅的配额
+	// 汇总所有激活订阅的配额，同时返回各订阅当前周期的用量
 	totalQuotas := make(map[string]*model.ModelQuotaInfo)
+	subscriptionQuotas := make([]gin.H, 0, len(subscriptions))
 
 	for _, subscription := range subscriptions {
 		quotas, err := subscription.GetModelQuotasMap()
@@ -406,26 +719,44 @@ func GetSubscriptionQuotas(c *gin.Context) {
 			continue
 		}
 
-		for modelName, remaining := range quotas {
+		modelQuotas := make(map[string]*model.ModelQuotaInfo)
+		for modelName := range quotas {
 			quotaInfo, err := subscription.GetModelQuotaInfo(modelName)
 			if err != nil {
 				continue
 			}
+			modelQuotas[modelName] = quotaInfo
 
 			if existing, exists := totalQuotas[modelName]; exists {
 				existing.Total += quotaInfo.Total
 				existing.Used += quotaInfo.Used
 				existing.Remaining += quotaInfo.Remaining
 			} else {
-				totalQuotas[modelName] = quotaInfo
+				copied := *quotaInfo
+				totalQuotas[modelName] = &copied
 			}
 		}
+
+		planName := ""
+		if subscription.SubscriptionPlan != nil {
+			planName = subscription.SubscriptionPlan.Name
+		}
+		subscriptionQuotas = append(subscriptionQuotas, gin.H{
+			"id":                   subscription.Id,
+			"plan_id":              subscription.SubscriptionPlanId,
+			"plan_name":            planName,
+			"end_time":             subscription.EndTime,
+			"auto_renew":           subscription.AutoRenew,
+			"cancel_at_period_end": subscription.CancelAtPeriodEnd,
+			"model_quotas":         modelQuotas,
+		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
 			"quotas":       totalQuotas,
+			"subscriptions": subscriptionQuotas,
 			"subscription_count": len(subscriptions),
 		},
 	})
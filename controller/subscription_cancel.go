@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubscriptionCancelRequest 取消订阅请求结构
+type SubscriptionCancelRequest struct {
+	Immediate    bool     `json:"immediate"`     // 是否立即取消，否则到期后取消
+	RefundMethod string   `json:"refund_method"` // 立即取消时的退款方式：balance、provider，管理员还可以使用 none、manual
+	RefundMoney  *float64 `json:"refund_money"`  // 管理员指定的退款金额（元），为空时按比例计算
+	Reason       string   `json:"reason"`
+}
+
+// GetSubscriptionCancelQuote 预览立即取消订阅可退还的金额
+func GetSubscriptionCancelQuote(c *gin.Context) {
+	getSubscriptionCancelQuote(c, c.GetInt("id"))
+}
+
+// AdminGetSubscriptionCancelQuote 预览立即取消用户订阅可退还的金额（管理员）
+func AdminGetSubscriptionCancelQuote(c *gin.Context) {
+	getSubscriptionCancelQuote(c, 0)
+}
+
+func getSubscriptionCancelQuote(c *gin.Context, userId int) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的订阅ID",
+		})
+		return
+	}
+	subscription, err := model.GetUserSubscriptionById(id)
+	if err != nil || (userId != 0 && subscription.UserId != userId) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "订阅不存在",
+		})
+		return
+	}
+	quote, err := service.QuoteSubscriptionRefund(subscription)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quote,
+	})
+}
+
+// CancelSubscription 用户取消自己的订阅
+func CancelSubscription(c *gin.Context) {
+	cancelSubscription(c, c.GetInt("id"), 0)
+}
+
+// AdminCancelSubscription 管理员取消用户订阅，可以指定退款金额或记录线下退款
+func AdminCancelSubscription(c *gin.Context) {
+	cancelSubscription(c, 0, c.GetInt("id"))
+}
+
+func cancelSubscription(c *gin.Context, userId int, operatorId int) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的订阅ID",
+		})
+		return
+	}
+	var req SubscriptionCancelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if utf8.RuneCountInString(req.Reason) > 200 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "取消原因不能超过200个字符",
+		})
+		return
+	}
+	opts := service.SubscriptionCancelOptions{
+		Immediate:    req.Immediate,
+		RefundMethod: req.RefundMethod,
+		Reason:       req.Reason,
+		OperatorId:   operatorId,
+	}
+	if operatorId != 0 {
+		opts.RefundMoney = req.RefundMoney
+	}
+
+	subscription, record, err := service.CancelSubscription(userId, id, opts)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "取消订阅失败: " + err.Error(),
+		})
+		return
+	}
+
+	message := "订阅将在到期后取消"
+	if req.Immediate {
+		message = "订阅已取消"
+		if record.RefundStatus == model.SubscriptionRefundStatusPending {
+			message = "订阅已取消，退款将原路退回"
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+		"data": gin.H{
+			"subscription": subscription,
+			"cancellation": record,
+		},
+	})
+}
+
+// ResumeSubscription 撤销订阅的到期取消
+func ResumeSubscription(c *gin.Context) {
+	resumeSubscription(c, c.GetInt("id"), 0)
+}
+
+// AdminResumeSubscription 撤销用户订阅的到期取消（管理员）
+func AdminResumeSubscription(c *gin.Context) {
+	resumeSubscription(c, 0, c.GetInt("id"))
+}
+
+func resumeSubscription(c *gin.Context, userId int, operatorId int) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的订阅ID",
+		})
+		return
+	}
+	subscription, err := service.ResumeSubscription(userId, id, operatorId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "撤销取消失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "已撤销到期取消",
+		"data":    subscription,
+	})
+}
+
+// GetSubscriptionCancellations 获取订阅的取消记录
+func GetSubscriptionCancellations(c *gin.Context) {
+	userId := c.GetInt("id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的订阅ID",
+		})
+		return
+	}
+	records, err := model.GetSubscriptionCancellations(userId, id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "获取取消记录失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    records,
+	})
+}
+
+// GetAllSubscriptionCancellations 分页获取取消与退款记录（管理员），可按退款状态筛选
+func GetAllSubscriptionCancellations(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	records, total, err := model.GetSubscriptionCancellationsByPage(c.Query("refund_status"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "获取取消记录失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"cancellations": records,
+			"total":         total,
+			"page":          page,
+			"page_size":     pageSize,
+		},
+	})
+}
+
+// CompleteSubscriptionRefund 管理员在支付平台完成原路退款后确认退款
+func CompleteSubscriptionRefund(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的记录ID",
+		})
+		return
+	}
+	record, err := model.CompleteSubscriptionRefund(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "确认退款失败: " + err.Error(),
+		})
+		return
+	}
+	model.RecordLog(record.UserId, model.LogTypeManage, fmt.Sprintf("管理员(ID: %d)已确认订阅套餐 %s 的原路退款 %.2f 元",
+		c.GetInt("id"), record.PlanName, record.RefundMoney))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "已确认退款",
+		"data":    record,
+	})
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubscriptionGiftRequest 购买订阅礼品码请求结构
+type SubscriptionGiftRequest struct {
+	PlanId int `json:"plan_id" binding:"required"`
+}
+
+// PurchaseSubscriptionGift 使用账户余额购买订阅礼品码，兑换码可以转交给其他用户在充值页兑换
+func PurchaseSubscriptionGift(c *gin.Context) {
+	userId := c.GetInt("id")
+	if !operation_setting.GetSubscriptionSetting().GiftEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "当前未开启订阅赠送",
+		})
+		return
+	}
+	var req SubscriptionGiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	plan, err := model.GetSubscriptionPlanById(req.PlanId)
+	if err != nil || !plan.IsActive() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "订阅套餐不存在或未启用",
+		})
+		return
+	}
+	if plan.HasPurchaseRestriction() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "该套餐设有购买限制，不支持赠送",
+		})
+		return
+	}
+	if plan.Price > 0 && setting.Price <= 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "当前管理员未配置充值价格，无法使用余额购买",
+		})
+		return
+	}
+	quota := service.GetSubscriptionQuotaPrice(plan)
+	redemption, err := model.CreateSubscriptionGift(userId, plan, quota)
+	if err != nil {
+		message := "购买礼品码失败: " + err.Error()
+		if errors.Is(err, model.ErrInsufficientUserQuota) {
+			message = fmt.Sprintf("余额不足，购买该套餐需要 %s", common.LogQuota(quota))
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+
+	model.RecordLog(userId, model.LogTypeSystem, fmt.Sprintf("使用余额购买订阅套餐礼品码: %s，价格: %.2f元，扣除额度: %s，兑换码ID %d",
+		plan.Name, plan.Price, common.LogQuota(quota), redemption.Id))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "购买礼品码成功",
+		"data":    redemption,
+	})
+}
+
+// GetSubscriptionGifts 分页获取用户购买的订阅礼品码及兑换状态
+func GetSubscriptionGifts(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	if p < 1 {
+		p = 1
+	}
+	if pageSize < 1 {
+		pageSize = common.ItemsPerPage
+	}
+	gifts, total, err := model.GetUserSubscriptionGifts(c.GetInt("id"), (p-1)*pageSize, pageSize)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "获取礼品码失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"items":     gifts,
+			"total":     total,
+			"page":      p,
+			"page_size": pageSize,
+		},
+	})
+}
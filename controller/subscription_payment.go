@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Calcium-Ion/go-epay/epay"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// subscriptionTradeNoPrefix 订阅订单号前缀，用于在支付回调中区分充值订单
+const subscriptionTradeNoPrefix = "SUB"
+
+// purchaseSubscriptionWithBalance 使用账户余额购买订阅，扣除额度后立即激活
+func purchaseSubscriptionWithBalance(c *gin.Context, userId int, plan *model.SubscriptionPlan) {
+	if plan.Price > 0 && !operation_setting.GetSubscriptionSetting().BalancePaymentEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "当前未开启余额购买订阅",
+		})
+		return
+	}
+	if plan.Price > 0 && setting.Price <= 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "当前管理员未配置充值价格，无法使用余额购买",
+		})
+		return
+	}
+	quota := service.GetSubscriptionQuotaPrice(plan)
+	paymentId := fmt.Sprintf("%sBAL%dNO%s%d", subscriptionTradeNoPrefix, userId, common.GetRandomString(6), time.Now().Unix())
+	subscription, err := model.CreateUserSubscriptionWithQuota(userId, plan.Id, quota, paymentId)
+	if err != nil {
+		message := "创建订阅失败: " + err.Error()
+		if errors.Is(err, model.ErrInsufficientUserQuota) {
+			message = fmt.Sprintf("余额不足，购买该套餐需要 %s", common.LogQuota(quota))
+		} else if errors.Is(err, model.ErrSubscriptionPurchaseLimit) {
+			message = plan.GetPurchaseLimitReason()
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+
+	model.RecordLog(userId, model.LogTypeSystem, fmt.Sprintf("使用余额购买订阅套餐: %s，价格: %.2f元，扣除额度: %s", plan.Name, plan.Price, common.LogQuota(quota)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "购买订阅成功",
+		"data": gin.H{
+			"subscription": subscription,
+			"payment_id":   paymentId,
+		},
+	})
+}
+
+// purchaseSubscriptionWithEpay 创建待支付订阅并拉起在线支付，支付回调验证通过后激活
+func purchaseSubscriptionWithEpay(c *gin.Context, userId int, plan *model.SubscriptionPlan, paymentMethod string) {
+	if plan.Price < 0.01 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "套餐金额过低，无法在线支付",
+		})
+		return
+	}
+	client := GetEpayClient()
+	if client == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "当前管理员未配置支付信息",
+		})
+		return
+	}
+	payType := "wxpay"
+	if paymentMethod == "zfb" || paymentMethod == "alipay" {
+		payType = "alipay"
+	}
+	tradeNo := fmt.Sprintf("%s%dNO%s%d", subscriptionTradeNoPrefix, userId, common.GetRandomString(6), time.Now().Unix())
+	subscription, err := model.CreatePendingUserSubscription(userId, plan.Id, payType, tradeNo)
+	if err != nil {
+		message := "创建订阅失败: " + err.Error()
+		if errors.Is(err, model.ErrSubscriptionPurchaseLimit) {
+			message = plan.GetPurchaseLimitReason()
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	returnUrl, _ := url.Parse(setting.ServerAddress + "/subscription/purchase")
+	notifyUrl, _ := url.Parse(service.GetCallbackAddress() + "/api/user/epay/notify")
+	uri, params, err := client.Purchase(&epay.PurchaseArgs{
+		Type:           payType,
+		ServiceTradeNo: tradeNo,
+		Name:           fmt.Sprintf("SUB%d", plan.Id),
+		Money:          strconv.FormatFloat(plan.Price, 'f', 2, 64),
+		Device:         epay.PC,
+		NotifyUrl:      notifyUrl,
+		ReturnUrl:      returnUrl,
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "拉起支付失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"subscription": subscription,
+			"payment_id":   tradeNo,
+			"url":          uri,
+			"params":       params,
+		},
+	})
+}
+
+// isSubscriptionTradeNo 判断支付订单是否为订阅订单
+func isSubscriptionTradeNo(tradeNo string) bool {
+	return strings.HasPrefix(tradeNo, subscriptionTradeNoPrefix)
+}
+
+// handleSubscriptionEpayNotify 处理订阅订单的支付成功回调
+func handleSubscriptionEpayNotify(verifyInfo *epay.VerifyRes) {
+	LockOrder(verifyInfo.ServiceTradeNo)
+	defer UnlockOrder(verifyInfo.ServiceTradeNo)
+	subscription, err := model.GetUserSubscriptionByPaymentId(verifyInfo.ServiceTradeNo)
+	if err != nil {
+		log.Printf("易支付回调未找到订阅订单: %v", verifyInfo)
+		return
+	}
+	paid, err := decimal.NewFromString(verifyInfo.Money)
+	if err != nil || paid.LessThan(decimal.NewFromFloat(subscription.PurchasePrice).Round(2)) {
+		log.Printf("易支付回调订阅订单金额不符: %v", verifyInfo)
+		return
+	}
+	ok, err := subscription.Activate()
+	if errors.Is(err, model.ErrSubscriptionPurchaseLimit) {
+		log.Printf("易支付回调订阅订单超出限购次数，已转为待退款: %v", subscription)
+		model.RecordLog(subscription.UserId, model.LogTypeSystem, fmt.Sprintf("在线支付购买订阅套餐: %s 时已超出限购次数，订单未激活，支付金额 %s元 将原路退还", subscription.SubscriptionPlan.Name, verifyInfo.Money))
+		return
+	}
+	if err != nil {
+		log.Printf("易支付回调激活订阅失败: %v", subscription)
+		return
+	}
+	if !ok {
+		return
+	}
+	log.Printf("易支付回调激活订阅成功 %v", subscription)
+	model.RecordLog(subscription.UserId, model.LogTypeSystem, fmt.Sprintf("在线支付购买订阅套餐: %s，支付金额: %s元", subscription.SubscriptionPlan.Name, verifyInfo.Money))
+}
@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	taskTimeoutReason     = "任务超时未完成，已自动标记为失败"
+	taskTimeoutLogReason  = "异步任务超时"
+	taskAdminRefundReason = "管理员手动退款"
+)
+
+type taskRefundSummary struct {
+	count int
+	quota int
+}
+
+// taskRefundStat 按用户汇总一次处理中退还的任务数与额度，用于通知用户
+type taskRefundStat map[int]*taskRefundSummary
+
+func (stat taskRefundStat) add(userId int, quota int) {
+	summary, ok := stat[userId]
+	if !ok {
+		summary = &taskRefundSummary{}
+		stat[userId] = summary
+	}
+	summary.count++
+	summary.quota += quota
+}
+
+func (stat taskRefundStat) notify(reason string) {
+	for userId, summary := range stat {
+		if summary.quota == 0 {
+			continue
+		}
+		service.NotifyTaskRefund(userId, summary.count, summary.quota, reason)
+	}
+}
+
+// failAndRefundTask 将未完成的任务标记为失败并退还额度，任务已结束时返回 false
+func failAndRefundTask(ctx context.Context, task *model.Task, failReason string, logReason string) (bool, error) {
+	ok, err := model.TaskFailUnfinished(task, failReason)
+	if err != nil || !ok {
+		return false, err
+	}
+	refundTaskQuota(ctx, task, logReason)
+	relay.NotifyTaskWebhook(task)
+	return true, nil
+}
+
+// failAndRefundMidjourneyTask 将未完成的 Midjourney 任务标记为失败并退还额度，任务已结束时返回 false
+func failAndRefundMidjourneyTask(ctx context.Context, task *model.Midjourney, failReason string, logReason string) (bool, error) {
+	ok, err := model.MjFailUnfinished(task, failReason)
+	if err != nil || !ok {
+		return false, err
+	}
+	refundMidjourneyQuota(ctx, task, logReason)
+	relay.NotifyMidjourneyTaskWebhook(task)
+	return true, nil
+}
+
+// reapTimeoutTasks 处理超过平台超时时间仍未完成的任务
+func reapTimeoutTasks(ctx context.Context) {
+	timeoutSetting := operation_setting.GetTaskTimeoutSetting()
+	if !timeoutSetting.Enabled {
+		return
+	}
+	minTimeout := timeoutSetting.GetMinTimeout()
+	if minTimeout == 0 {
+		return
+	}
+	now := time.Now()
+	tasks := model.GetUnFinishSyncTasksCreatedBefore(now.Add(-minTimeout).Unix(), 500)
+	stat := make(taskRefundStat)
+	for _, task := range tasks {
+		timeout := timeoutSetting.GetTimeout(string(task.Platform))
+		if timeout == 0 || now.Sub(time.Unix(task.CreatedAt, 0)) < timeout {
+			continue
+		}
+		ok, err := failAndRefundTask(ctx, task, taskTimeoutReason, taskTimeoutLogReason)
+		if err != nil {
+			common.LogError(ctx, fmt.Sprintf("fail timeout task %d error: %s", task.ID, err.Error()))
+			continue
+		}
+		if ok {
+			common.LogInfo(ctx, fmt.Sprintf("任务 %s 超时，已标记为失败", task.TaskID))
+			stat.add(task.UserId, task.Quota)
+		}
+	}
+	if timeoutSetting.NotifyUser {
+		stat.notify("超时未完成")
+	}
+}
+
+// reapTimeoutMidjourneyTasks 处理超过超时时间仍未完成的 Midjourney 任务
+func reapTimeoutMidjourneyTasks(ctx context.Context) {
+	timeoutSetting := operation_setting.GetTaskTimeoutSetting()
+	if !timeoutSetting.Enabled {
+		return
+	}
+	timeout := timeoutSetting.GetTimeout(constant.TaskPlatformMidjourney)
+	if timeout == 0 {
+		return
+	}
+	// Midjourney 任务的提交时间为毫秒
+	tasks := model.GetUnFinishTasksSubmittedBefore(time.Now().Add(-timeout).UnixMilli(), 500)
+	stat := make(taskRefundStat)
+	for _, task := range tasks {
+		ok, err := failAndRefundMidjourneyTask(ctx, task, taskTimeoutReason, taskTimeoutLogReason)
+		if err != nil {
+			common.LogError(ctx, fmt.Sprintf("fail timeout midjourney task %d error: %s", task.Id, err.Error()))
+			continue
+		}
+		if ok {
+			common.LogInfo(ctx, fmt.Sprintf("任务 %s 超时，已标记为失败", task.MjId))
+			stat.add(task.UserId, task.Quota)
+		}
+	}
+	if timeoutSetting.NotifyUser {
+		stat.notify("超时未完成")
+	}
+}
+
+type taskRefundRequest struct {
+	Ids []int64 `json:"ids"`
+}
+
+func writeTaskRefundResult(c *gin.Context, refunded int, skipped int) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"refunded": refunded,
+			"skipped":  skipped,
+		},
+	})
+}
+
+// RefundTasks 管理员批量将未完成的任务标记为失败并退款，已结束的任务会被跳过
+func RefundTasks(c *gin.Context) {
+	var req taskRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Ids) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "参数错误",
+		})
+		return
+	}
+	tasks, err := model.GetTasksByIDs(req.Ids)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx := c.Request.Context()
+	stat := make(taskRefundStat)
+	refunded := 0
+	for _, task := range tasks {
+		ok, err := failAndRefundTask(ctx, task, taskAdminRefundReason, taskAdminRefundReason)
+		if err != nil {
+			common.LogError(ctx, fmt.Sprintf("refund task %d error: %s", task.ID, err.Error()))
+			continue
+		}
+		if ok {
+			refunded++
+			stat.add(task.UserId, task.Quota)
+		}
+	}
+	stat.notify("已由管理员处理")
+	writeTaskRefundResult(c, refunded, len(req.Ids)-refunded)
+}
+
+// RefundMidjourneyTasks 管理员批量将未完成的 Midjourney 任务标记为失败并退款，已结束的任务会被跳过
+func RefundMidjourneyTasks(c *gin.Context) {
+	var req taskRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Ids) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "参数错误",
+		})
+		return
+	}
+	ids := make([]int, 0, len(req.Ids))
+	for _, id := range req.Ids {
+		ids = append(ids, int(id))
+	}
+	tasks, err := model.GetMjByIds(ids)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx := c.Request.Context()
+	stat := make(taskRefundStat)
+	refunded := 0
+	for _, task := range tasks {
+		ok, err := failAndRefundMidjourneyTask(ctx, task, taskAdminRefundReason, taskAdminRefundReason)
+		if err != nil {
+			common.LogError(ctx, fmt.Sprintf("refund midjourney task %d error: %s", task.Id, err.Error()))
+			continue
+		}
+		if ok {
+			refunded++
+			stat.add(task.UserId, task.Quota)
+		}
+	}
+	stat.notify("已由管理员处理")
+	writeTaskRefundResult(c, refunded, len(req.Ids)-refunded)
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/relay/channel"
+	"time"
+)
+
+func UpdateVideoTaskAll(ctx context.Context, platform constant.TaskPlatform, taskChannelM map[int][]string, taskM map[string]*model.Task) error {
+	for channelId, taskIds := range taskChannelM {
+		err := updateVideoTaskAll(ctx, platform, channelId, taskIds, taskM)
+		if err != nil {
+			common.LogError(ctx, fmt.Sprintf("渠道 #%d 更新视频任务失败: %s", channelId, err.Error()))
+		}
+	}
+	return nil
+}
+
+func updateVideoTaskAll(ctx context.Context, platform constant.TaskPlatform, channelId int, taskIds []string, taskM map[string]*model.Task) error {
+	common.LogInfo(ctx, fmt.Sprintf("渠道 #%d 未完成的视频任务有: %d", channelId, len(taskIds)))
+	if len(taskIds) == 0 {
+		return nil
+	}
+	ch, err := model.CacheGetChannel(channelId)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("CacheGetChannel: %v", err))
+		for _, taskId := range taskIds {
+			task := taskM[taskId]
+			failed, err := model.TaskFailUnfinished(task, fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", channelId))
+			if err != nil {
+				common.SysError("UpdateVideoTask task error: " + err.Error())
+				continue
+			}
+			if !failed {
+				continue
+			}
+			refundTaskQuota(ctx, task, "异步任务执行失败")
+			relay.NotifyTaskWebhook(task)
+		}
+		return err
+	}
+	adaptor, ok := relay.GetTaskAdaptor(platform).(channel.VideoTaskAdaptor)
+	if !ok {
+		return fmt.Errorf("video adaptor not found for platform %s", platform)
+	}
+	baseURL := ch.GetBaseURL()
+	if baseURL == "" {
+		baseURL = common.ChannelBaseURLs[ch.Type]
+	}
+	for _, taskId := range taskIds {
+		if err := updateVideoTask(ctx, adaptor, baseURL, ch.Key, taskM[taskId]); err != nil {
+			common.LogError(ctx, fmt.Sprintf("视频任务 %s 更新失败: %s", taskId, err.Error()))
+		}
+	}
+	return nil
+}
+
+func updateVideoTask(ctx context.Context, adaptor channel.VideoTaskAdaptor, baseURL string, key string, task *model.Task) error {
+	resp, err := adaptor.FetchTask(baseURL, key, map[string]any{
+		"task_id": task.TaskID,
+		"action":  task.Action,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get task status code: %d, body: %s", resp.StatusCode, string(responseBody))
+	}
+	taskInfo, err := adaptor.ParseTaskResult(responseBody)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	oldStatus := task.Status
+	oldProgress := task.Progress
+	task.Status = model.TaskStatus(taskInfo.Status)
+	switch task.Status {
+	case model.TaskStatusSubmitted, model.TaskStatusQueued:
+		task.Progress = "10%"
+	case model.TaskStatusInProgress:
+		if task.StartTime == 0 {
+			task.StartTime = now
+		}
+		task.Progress = "30%"
+		if taskInfo.Progress != "" {
+			task.Progress = taskInfo.Progress
+		}
+	case model.TaskStatusSuccess:
+		if task.StartTime == 0 {
+			task.StartTime = now
+		}
+		task.Progress = "100%"
+		task.FinishTime = now
+		task.ResultURL = taskInfo.Url
+	case model.TaskStatusFailure:
+		task.Progress = "100%"
+		task.FinishTime = now
+		task.FailReason = taskInfo.Reason
+		if task.FailReason == "" {
+			task.FailReason = "视频生成失败"
+		}
+	default:
+		// 无法识别的状态保持原样，等待下次轮询
+		task.Status = oldStatus
+		return nil
+	}
+	if task.Status == oldStatus && task.Progress == oldProgress {
+		return nil
+	}
+	task.Data = responseBody
+	// 任务可能已被管理员退款等流程终结，只有本次写入成功时才退款和通知
+	updated, err := task.UpdateUnfinished()
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return nil
+	}
+	if task.Status == model.TaskStatusFailure {
+		common.LogInfo(ctx, task.TaskID+" 视频生成失败，"+task.FailReason)
+		refundTaskQuota(ctx, task, "异步任务执行失败")
+	}
+	if task.Status != oldStatus {
+		relay.ArchiveTaskMedia(task)
+		relay.NotifyTaskWebhook(task)
+	}
+	return nil
+}
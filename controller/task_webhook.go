@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getTaskWebhookDeliveries(c *gin.Context, userId int) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 1 {
+		p = 1
+	}
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	if pageSize <= 0 {
+		pageSize = common.ItemsPerPage
+	}
+	items, total, err := model.GetTaskWebhookDeliveries(userId, c.Query("task_id"), c.Query("status"), (p-1)*pageSize, pageSize)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"items":     items,
+			"total":     total,
+			"page":      p,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// GetAllTaskWebhookDeliveries 管理员查询所有任务回调投递记录
+func GetAllTaskWebhookDeliveries(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	getTaskWebhookDeliveries(c, userId)
+}
+
+// GetUserTaskWebhookDeliveries 用户查询自己的任务回调投递记录
+func GetUserTaskWebhookDeliveries(c *gin.Context) {
+	getTaskWebhookDeliveries(c, c.GetInt("id"))
+}
+
+// RetryTaskWebhookDelivery 管理员手动重新投递任务回调
+func RetryTaskWebhookDelivery(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	delivery, err := model.GetTaskWebhookDeliveryById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := service.RetryTaskWebhookDelivery(delivery); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
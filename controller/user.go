@@ -8,6 +8,7 @@ import (
 	"one-api/common"
 	"one-api/model"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"strconv"
 	"strings"
 	"sync"
@@ -920,7 +921,7 @@ func TopUp(c *gin.Context) {
 		return
 	}
 	id := c.GetInt("id")
-	quota, err := model.Redeem(req.Key, id)
+	quota, subscription, err := model.Redeem(req.Key, id)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -928,10 +929,12 @@ func TopUp(c *gin.Context) {
 		})
 		return
 	}
+	// 订阅兑换码的 data 为0，兑换到的订阅通过 subscription 返回
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "",
-		"data":    quota,
+		"success":      true,
+		"message":      "",
+		"data":         quota,
+		"subscription": subscription,
 	})
 	return
 }
@@ -944,6 +947,7 @@ type UpdateUserSettingRequest struct {
 	NotificationEmail          string  `json:"notification_email,omitempty"`
 	AcceptUnsetModelRatioModel bool    `json:"accept_unset_model_ratio_model"`
 	RecordIpLog                bool    `json:"record_ip_log"`
+	MediaRetentionDays         int     `json:"media_retention_days"`
 }
 
 func UpdateUserSetting(c *gin.Context) {
@@ -1005,6 +1009,22 @@ func UpdateUserSetting(c *gin.Context) {
 		}
 	}
 
+	// 验证生成结果保留天数
+	if req.MediaRetentionDays < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "保留天数不能小于0",
+		})
+		return
+	}
+	if maxDays := operation_setting.GetMediaStorageSetting().MaxRetentionDays; maxDays > 0 && req.MediaRetentionDays > maxDays {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("保留天数不能超过%d天", maxDays),
+		})
+		return
+	}
+
 	userId := c.GetInt("id")
 	user, err := model.GetUserById(userId, true)
 	if err != nil {
@@ -1021,6 +1041,7 @@ func UpdateUserSetting(c *gin.Context) {
 		constant.UserSettingQuotaWarningThreshold: req.QuotaWarningThreshold,
 		"accept_unset_model_ratio_model":          req.AcceptUnsetModelRatioModel,
 		constant.UserSettingRecordIpLog:           req.RecordIpLog,
+		constant.UserSettingMediaRetentionDays:    req.MediaRetentionDays,
 	}
 
 	// 如果是webhook类型,添加webhook相关设置
@@ -1,6 +1,10 @@
 package dto
 
-import "encoding/json"
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
 
 type ImageRequest struct {
 	Model          string          `json:"model"`
@@ -15,6 +19,25 @@ type ImageRequest struct {
 	Background     string          `json:"background,omitempty"`
 	Moderation     string          `json:"moderation,omitempty"`
 	OutputFormat   string          `json:"output_format,omitempty"`
+	// 图像编辑 / 变体请求上传的图片与蒙版，由 multipart 表单解析得到
+	Images []*ImageFile `json:"-"`
+	Mask   *ImageFile   `json:"-"`
+}
+
+// ImageFile 为请求中上传的图片文件
+type ImageFile struct {
+	Filename string
+	MimeType string
+	Data     []byte
+}
+
+func (f *ImageFile) Base64() string {
+	return base64.StdEncoding.EncodeToString(f.Data)
+}
+
+// DataUrl 返回 data:{mime};base64,{data} 格式的图片
+func (f *ImageFile) DataUrl() string {
+	return fmt.Sprintf("data:%s;base64,%s", f.MimeType, f.Base64())
 }
 
 type ImageResponse struct {
@@ -0,0 +1,126 @@
+package dto
+
+import "encoding/json"
+
+// 以下为网关对外提供的 Ollama 兼容接口 (/api/*) 使用的请求与响应格式
+
+type OllamaOptions struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             float64  `json:"top_p,omitempty"`
+	TopK             int      `json:"top_k,omitempty"`
+	NumPredict       int      `json:"num_predict,omitempty"`
+	Stop             any      `json:"stop,omitempty"`
+	Seed             float64  `json:"seed,omitempty"`
+	FrequencyPenalty float64  `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float64  `json:"presence_penalty,omitempty"`
+}
+
+type OllamaToolCallFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type OllamaToolCall struct {
+	Function OllamaToolCallFunction `json:"function"`
+}
+
+type OllamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type OllamaChatRequest struct {
+	Model    string            `json:"model"`
+	Messages []OllamaMessage   `json:"messages"`
+	Tools    []ToolCallRequest `json:"tools,omitempty"`
+	Format   json.RawMessage   `json:"format,omitempty"`
+	Options  *OllamaOptions    `json:"options,omitempty"`
+	Stream   *bool             `json:"stream,omitempty"`
+}
+
+type OllamaGenerateRequest struct {
+	Model   string          `json:"model"`
+	Prompt  string          `json:"prompt"`
+	Suffix  string          `json:"suffix,omitempty"`
+	System  string          `json:"system,omitempty"`
+	Images  []string        `json:"images,omitempty"`
+	Format  json.RawMessage `json:"format,omitempty"`
+	Options *OllamaOptions  `json:"options,omitempty"`
+	Stream  *bool           `json:"stream,omitempty"`
+	Raw     bool            `json:"raw,omitempty"`
+}
+
+// OllamaEmbeddingRequest 兼容 /api/embeddings (prompt) 与 /api/embed (input)
+type OllamaEmbeddingRequest struct {
+	Model   string         `json:"model"`
+	Prompt  string         `json:"prompt,omitempty"`
+	Input   any            `json:"input,omitempty"`
+	Options *OllamaOptions `json:"options,omitempty"`
+}
+
+// IsStream Ollama 的 stream 参数默认为 true
+func (r *OllamaChatRequest) IsStream() bool {
+	return r.Stream == nil || *r.Stream
+}
+
+func (r *OllamaGenerateRequest) IsStream() bool {
+	return r.Stream == nil || *r.Stream
+}
+
+type OllamaDoneInfo struct {
+	Done               bool   `json:"done"`
+	DoneReason         string `json:"done_reason,omitempty"`
+	TotalDuration      int64  `json:"total_duration,omitempty"`
+	LoadDuration       int64  `json:"load_duration,omitempty"`
+	PromptEvalCount    int    `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64  `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int    `json:"eval_count,omitempty"`
+	EvalDuration       int64  `json:"eval_duration,omitempty"`
+}
+
+type OllamaChatResponse struct {
+	Model     string        `json:"model"`
+	CreatedAt string        `json:"created_at"`
+	Message   OllamaMessage `json:"message"`
+	OllamaDoneInfo
+}
+
+type OllamaGenerateResponse struct {
+	Model     string `json:"model"`
+	CreatedAt string `json:"created_at"`
+	Response  string `json:"response"`
+	Thinking  string `json:"thinking,omitempty"`
+	OllamaDoneInfo
+}
+
+type OllamaEmbeddingResponse struct {
+	Model           string      `json:"model,omitempty"`
+	Embedding       []float64   `json:"embedding,omitempty"`
+	Embeddings      [][]float64 `json:"embeddings,omitempty"`
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"`
+}
+
+type OllamaModelDetails struct {
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
+
+type OllamaModel struct {
+	Name       string             `json:"name"`
+	Model      string             `json:"model"`
+	ModifiedAt string             `json:"modified_at"`
+	Size       int64              `json:"size"`
+	Digest     string             `json:"digest"`
+	Details    OllamaModelDetails `json:"details"`
+}
+
+type OllamaError struct {
+	Error string `json:"error"`
+}
@@ -34,6 +34,7 @@ type GeneralOpenAIRequest struct {
 	TopK                int               `json:"top_k,omitempty"`
 	Stop                any               `json:"stop,omitempty"`
 	N                   int               `json:"n,omitempty"`
+	BestOf              int               `json:"best_of,omitempty"`
 	Input               any               `json:"input,omitempty"`
 	Instruction         string            `json:"instruction,omitempty"`
 	Size                string            `json:"size,omitempty"`
@@ -70,6 +71,9 @@ type ToolCallRequest struct {
 	ID       string          `json:"id,omitempty"`
 	Type     string          `json:"type"`
 	Function FunctionRequest `json:"function"`
+	// type 为 mcp 时使用网关登记的 MCP 服务器
+	ServerLabel  string   `json:"server_label,omitempty"`
+	AllowedTools []string `json:"allowed_tools,omitempty"`
 }
 
 type FunctionRequest struct {
@@ -609,7 +613,7 @@ type OpenAIResponsesRequest struct {
 	PreviousResponseID string               `json:"previous_response_id,omitempty"`
 	Reasoning          *Reasoning           `json:"reasoning,omitempty"`
 	ServiceTier        string               `json:"service_tier,omitempty"`
-	Store              bool                 `json:"store,omitempty"`
+	Store              *bool                `json:"store,omitempty"`
 	Stream             bool                 `json:"stream,omitempty"`
 	Temperature        float64              `json:"temperature,omitempty"`
 	Text               json.RawMessage      `json:"text,omitempty"`
@@ -620,6 +624,130 @@ type OpenAIResponsesRequest struct {
 	User               string               `json:"user,omitempty"`
 }
 
+// ResponsesInputItem 为 Responses API input 数组中的单个条目
+type ResponsesInputItem struct {
+	Type    string          `json:"type,omitempty"`
+	ID      string          `json:"id,omitempty"`
+	Role    string          `json:"role,omitempty"`
+	Content json.RawMessage `json:"content,omitempty"`
+	Status  string          `json:"status,omitempty"`
+	// function_call / function_call_output
+	CallId    string          `json:"call_id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Arguments string          `json:"arguments,omitempty"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	// reasoning
+	Summary []ResponsesOutputContent `json:"summary,omitempty"`
+}
+
+type ResponsesInputContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageUrl string `json:"image_url,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	FileId   string `json:"file_id,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// IsStringContent 判断 content 是否为纯字符串
+func (i *ResponsesInputItem) IsStringContent() bool {
+	return len(i.Content) > 0 && i.Content[0] == '"'
+}
+
+func (i *ResponsesInputItem) GetStringContent() string {
+	var content string
+	if err := json.Unmarshal(i.Content, &content); err != nil {
+		return ""
+	}
+	return content
+}
+
+func (i *ResponsesInputItem) ParseContent() []ResponsesInputContent {
+	if len(i.Content) == 0 {
+		return nil
+	}
+	if i.IsStringContent() {
+		return []ResponsesInputContent{{
+			Type: ResponsesContentTypeInputText,
+			Text: i.GetStringContent(),
+		}}
+	}
+	var contents []ResponsesInputContent
+	_ = json.Unmarshal(i.Content, &contents)
+	return contents
+}
+
+// GetStringOutput 返回 function_call_output 的输出文本
+func (i *ResponsesInputItem) GetStringOutput() string {
+	if len(i.Output) == 0 {
+		return ""
+	}
+	var output string
+	if err := json.Unmarshal(i.Output, &output); err == nil {
+		return output
+	}
+	return string(i.Output)
+}
+
+// ParseInput 将 input 统一解析为条目数组，字符串 input 视为一条 user 消息
+func (r *OpenAIResponsesRequest) ParseInput() ([]ResponsesInputItem, error) {
+	if len(r.Input) == 0 {
+		return nil, nil
+	}
+	if r.Input[0] == '"' {
+		var text string
+		if err := json.Unmarshal(r.Input, &text); err != nil {
+			return nil, err
+		}
+		content, _ := json.Marshal(text)
+		return []ResponsesInputItem{{
+			Type:    ResponsesItemTypeMessage,
+			Role:    "user",
+			Content: content,
+		}}, nil
+	}
+	var items []ResponsesInputItem
+	if err := json.Unmarshal(r.Input, &items); err != nil {
+		return nil, err
+	}
+	for idx := range items {
+		if items[idx].Type == "" && items[idx].Role != "" {
+			items[idx].Type = ResponsesItemTypeMessage
+		}
+	}
+	return items, nil
+}
+
+// ShouldStore 未显式指定 store 时默认为 true，与 OpenAI 保持一致
+func (r *OpenAIResponsesRequest) ShouldStore() bool {
+	return r.Store == nil || *r.Store
+}
+
+// GetInstructions 返回字符串形式的 instructions
+func (r *OpenAIResponsesRequest) GetInstructions() string {
+	if len(r.Instructions) == 0 {
+		return ""
+	}
+	var instructions string
+	if err := json.Unmarshal(r.Instructions, &instructions); err != nil {
+		return ""
+	}
+	return instructions
+}
+
+type ResponsesTextFormat struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Schema      any    `json:"schema,omitempty"`
+	Strict      any    `json:"strict,omitempty"`
+}
+
+type ResponsesText struct {
+	Format *ResponsesTextFormat `json:"format,omitempty"`
+}
+
 type Reasoning struct {
 	Effort  string `json:"effort,omitempty"`
 	Summary string `json:"summary,omitempty"`
@@ -178,6 +178,8 @@ type Usage struct {
 	InputTokens            int                `json:"input_tokens"`
 	OutputTokens           int                `json:"output_tokens"`
 	InputTokensDetails     *InputTokenDetails `json:"input_tokens_details"`
+	// OutputTokensDetails 仅在 Responses API 格式中使用
+	OutputTokensDetails *OutputTokenDetails `json:"output_tokens_details,omitempty"`
 }
 
 type InputTokenDetails struct {
@@ -210,7 +212,7 @@ type OpenAIResponsesResponse struct {
 	Reasoning          *Reasoning           `json:"reasoning"`
 	Store              bool                 `json:"store"`
 	Temperature        float64              `json:"temperature"`
-	ToolChoice         string               `json:"tool_choice"`
+	ToolChoice         any                  `json:"tool_choice"`
 	Tools              []ResponsesToolsCall `json:"tools"`
 	TopP               float64              `json:"top_p"`
 	Truncation         string               `json:"truncation"`
@@ -220,15 +222,21 @@ type OpenAIResponsesResponse struct {
 }
 
 type IncompleteDetails struct {
-	Reasoning string `json:"reasoning"`
+	Reasoning string `json:"reason"`
 }
 
 type ResponsesOutput struct {
 	Type    string                   `json:"type"`
 	ID      string                   `json:"id"`
-	Status  string                   `json:"status"`
-	Role    string                   `json:"role"`
-	Content []ResponsesOutputContent `json:"content"`
+	Status  string                   `json:"status,omitempty"`
+	Role    string                   `json:"role,omitempty"`
+	Content []ResponsesOutputContent `json:"content,omitempty"`
+	// function_call
+	CallId    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	// reasoning
+	Summary []ResponsesOutputContent `json:"summary,omitempty"`
 }
 
 type ResponsesOutputContent struct {
@@ -246,15 +254,51 @@ const (
 	BuildInCallWebSearchCall = "web_search_call"
 )
 
+const (
+	ResponsesItemTypeMessage            = "message"
+	ResponsesItemTypeFunctionCall       = "function_call"
+	ResponsesItemTypeFunctionCallOutput = "function_call_output"
+	ResponsesItemTypeReasoning          = "reasoning"
+
+	ResponsesContentTypeInputText   = "input_text"
+	ResponsesContentTypeInputImage  = "input_image"
+	ResponsesContentTypeInputFile   = "input_file"
+	ResponsesContentTypeOutputText  = "output_text"
+	ResponsesContentTypeSummaryText = "summary_text"
+	ResponsesContentTypeRefusal     = "refusal"
+)
+
 const (
 	ResponsesOutputTypeItemAdded = "response.output_item.added"
 	ResponsesOutputTypeItemDone  = "response.output_item.done"
+
+	ResponsesEventCreated                   = "response.created"
+	ResponsesEventInProgress                = "response.in_progress"
+	ResponsesEventCompleted                 = "response.completed"
+	ResponsesEventIncomplete                = "response.incomplete"
+	ResponsesEventFailed                    = "response.failed"
+	ResponsesEventContentPartAdded          = "response.content_part.added"
+	ResponsesEventContentPartDone           = "response.content_part.done"
+	ResponsesEventOutputTextDelta           = "response.output_text.delta"
+	ResponsesEventOutputTextDone            = "response.output_text.done"
+	ResponsesEventFunctionCallArgsDelta     = "response.function_call_arguments.delta"
+	ResponsesEventFunctionCallArgsDone      = "response.function_call_arguments.done"
+	ResponsesEventReasoningSummaryTextDelta = "response.reasoning_summary_text.delta"
+	ResponsesEventReasoningSummaryTextDone  = "response.reasoning_summary_text.done"
 )
 
 // ResponsesStreamResponse 用于处理 /v1/responses 流式响应
 type ResponsesStreamResponse struct {
-	Type     string                   `json:"type"`
-	Response *OpenAIResponsesResponse `json:"response,omitempty"`
-	Delta    string                   `json:"delta,omitempty"`
-	Item     *ResponsesOutput         `json:"item,omitempty"`
+	Type           string                   `json:"type"`
+	SequenceNumber int                      `json:"sequence_number"`
+	Response       *OpenAIResponsesResponse `json:"response,omitempty"`
+	Delta          string                   `json:"delta,omitempty"`
+	Item           *ResponsesOutput         `json:"item,omitempty"`
+	ItemId         string                   `json:"item_id,omitempty"`
+	OutputIndex    *int                     `json:"output_index,omitempty"`
+	ContentIndex   *int                     `json:"content_index,omitempty"`
+	SummaryIndex   *int                     `json:"summary_index,omitempty"`
+	Part           *ResponsesOutputContent  `json:"part,omitempty"`
+	Text           string                   `json:"text,omitempty"`
+	Arguments      string                   `json:"arguments,omitempty"`
 }
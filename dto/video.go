@@ -0,0 +1,33 @@
+package dto
+
+// VideoRequest 统一的视频生成请求，由各平台的适配器转换为上游格式
+type VideoRequest struct {
+	Model          string         `json:"model"`
+	Prompt         string         `json:"prompt"`
+	NegativePrompt string         `json:"negative_prompt,omitempty"`
+	Image          string         `json:"image,omitempty"`        // 首帧图片，URL 或 base64，提供时为图生视频
+	Duration       int            `json:"duration,omitempty"`     // 视频时长（秒）
+	AspectRatio    string         `json:"aspect_ratio,omitempty"` // 如 16:9、9:16、1:1
+	Resolution     string         `json:"resolution,omitempty"`   // 如 720p、1080p
+	Seed           int            `json:"seed,omitempty"`
+	Metadata       map[string]any `json:"metadata,omitempty"` // 原样合并到上游请求中的平台参数
+}
+
+// VideoTaskResponse 视频生成任务的提交与查询结果
+type VideoTaskResponse struct {
+	TaskId     string          `json:"task_id"`
+	Object     string          `json:"object"`
+	Model      string          `json:"model,omitempty"`
+	Status     string          `json:"status"` // queued、in_progress、succeeded、failed
+	Progress   string          `json:"progress,omitempty"`
+	Url        string          `json:"url,omitempty"`
+	Duration   int             `json:"duration,omitempty"`
+	Error      *VideoTaskError `json:"error,omitempty"`
+	CreatedAt  int64           `json:"created_at"`
+	FinishedAt int64           `json:"finished_at,omitempty"`
+}
+
+type VideoTaskError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
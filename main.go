@@ -118,6 +118,16 @@ func main() {
 	// 数据看板
 	go model.UpdateQuotaData()
 
+	// 清理过期的 Responses API 响应
+	if common.IsMasterNode {
+		go service.CleanupExpiredResponses()
+	}
+
+	// 清理磁盘上过期的响应缓存
+	go service.CleanupExpiredResponseCache()
+	// 语义缓存的恢复、过期清理与持久化
+	go service.RunSemanticCache()
+
 	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
 		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
 		if err != nil {
@@ -140,6 +150,18 @@ func main() {
 			controller.UpdateTaskBulk()
 		})
 	}
+	// 异步任务完成回调的投递与重试、过期生成结果的清理，以及超时未支付订阅订单的处理
+	if common.IsMasterNode {
+		gopool.Go(func() {
+			service.RunTaskWebhookDelivery()
+		})
+		gopool.Go(func() {
+			service.CleanupExpiredMedia()
+		})
+		gopool.Go(func() {
+			service.ExpirePendingSubscriptionOrders()
+		})
+	}
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
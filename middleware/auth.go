@@ -249,6 +249,8 @@ func TokenAuth() func(c *gin.Context) {
 		}
 		c.Set("allow_ips", token.GetIpLimitsMap())
 		c.Set("token_group", token.Group)
+		c.Set("token_response_cache_enabled", token.ResponseCacheEnabled)
+		c.Set("token_callback_url", token.CallbackURL)
 		if len(parts) > 1 {
 			if model.IsAdmin(token.UserId) {
 				c.Set("specific_channel_id", parts[1])
@@ -258,6 +258,42 @@ func migrateDB() error {
 	if err != nil {
 		return err
 	}
+	err = DB.AutoMigrate(&SubscriptionPlanChange{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&SubscriptionQuota{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&SubscriptionQuotaReservation{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&SubscriptionCancellation{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&ResponseRecord{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&McpServer{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&TaskWebhookDelivery{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&MediaObject{})
+	if err != nil {
+		return err
+	}
+	err = migrateLegacySubscriptionQuotas()
+	if err != nil {
+		return err
+	}
 	common.SysLog("database migrated")
 	//err = createRootAccountIfNeed()
 	return err
@@ -0,0 +1,162 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"strings"
+)
+
+const (
+	McpTransportStdio = "stdio"
+	McpTransportHttp  = "http" // Streamable HTTP
+	McpTransportSSE   = "sse"  // HTTP + SSE
+)
+
+const (
+	McpServerStatusEnabled  = 1
+	McpServerStatusDisabled = 2
+)
+
+// McpServer 管理员登记的 MCP 工具服务器
+type McpServer struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	Description string `json:"description"`
+	Transport   string `json:"transport" gorm:"type:varchar(16)"`
+	// stdio：启动命令、参数（JSON 字符串数组）与环境变量（JSON 对象）
+	Command string `json:"command"`
+	Args    string `json:"args" gorm:"type:text"`
+	Env     string `json:"env" gorm:"type:text"`
+	// http / sse：服务地址与附加请求头（JSON 对象）
+	URL     string `json:"url"`
+	Headers string `json:"headers" gorm:"type:text"`
+	Status  int    `json:"status" gorm:"default:1"`
+	// 允许使用的分组，逗号分隔，为空时所有分组可用
+	AllowedGroups string `json:"allowed_groups"`
+	// 每次工具调用的价格（美元），按分组倍率计费
+	PricePerCall float64 `json:"price_per_call" gorm:"default:0"`
+	// 单次工具调用的超时时间（秒）
+	Timeout     int   `json:"timeout" gorm:"default:60"`
+	CreatedTime int64 `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64 `json:"updated_time" gorm:"bigint"`
+}
+
+func (server *McpServer) GetArgs() []string {
+	var args []string
+	if server.Args != "" {
+		_ = common.DecodeJsonStr(server.Args, &args)
+	}
+	return args
+}
+
+func (server *McpServer) GetEnv() map[string]string {
+	env := make(map[string]string)
+	if server.Env != "" {
+		_ = common.DecodeJsonStr(server.Env, &env)
+	}
+	return env
+}
+
+func (server *McpServer) GetHeaders() map[string]string {
+	headers := make(map[string]string)
+	if server.Headers != "" {
+		_ = common.DecodeJsonStr(server.Headers, &headers)
+	}
+	return headers
+}
+
+// IsGroupAllowed 判断分组是否有权使用该服务器
+func (server *McpServer) IsGroupAllowed(group string) bool {
+	if strings.TrimSpace(server.AllowedGroups) == "" {
+		return true
+	}
+	for _, g := range strings.Split(server.AllowedGroups, ",") {
+		if strings.TrimSpace(g) == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 校验服务器配置
+func (server *McpServer) Validate() error {
+	if server.Name == "" || len(server.Name) > 64 {
+		return errors.New("名称长度必须在1-64之间")
+	}
+	for _, r := range server.Name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return errors.New("名称只能包含字母、数字、下划线与连字符")
+		}
+	}
+	switch server.Transport {
+	case McpTransportStdio:
+		if server.Command == "" {
+			return errors.New("stdio 传输方式必须填写启动命令")
+		}
+	case McpTransportHttp, McpTransportSSE:
+		if server.URL == "" {
+			return errors.New("http / sse 传输方式必须填写服务地址")
+		}
+	default:
+		return errors.New("不支持的传输方式")
+	}
+	var args []string
+	if server.Args != "" && common.DecodeJsonStr(server.Args, &args) != nil {
+		return errors.New("启动参数必须为 JSON 字符串数组")
+	}
+	var obj map[string]string
+	if server.Env != "" && common.DecodeJsonStr(server.Env, &obj) != nil {
+		return errors.New("环境变量必须为 JSON 对象")
+	}
+	if server.Headers != "" && common.DecodeJsonStr(server.Headers, &obj) != nil {
+		return errors.New("请求头必须为 JSON 对象")
+	}
+	if server.PricePerCall < 0 {
+		return errors.New("调用价格不能为负数")
+	}
+	return nil
+}
+
+func GetAllMcpServers(startIdx int, num int) (servers []*McpServer, total int64, err error) {
+	err = DB.Model(&McpServer{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = DB.Order("id desc").Limit(num).Offset(startIdx).Find(&servers).Error
+	return servers, total, err
+}
+
+func GetMcpServerById(id int) (*McpServer, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	server := McpServer{}
+	err := DB.First(&server, "id = ?", id).Error
+	return &server, err
+}
+
+// GetEnabledMcpServerByName 获取已启用的服务器
+func GetEnabledMcpServerByName(name string) (*McpServer, error) {
+	server := McpServer{}
+	err := DB.First(&server, "name = ? AND status = ?", name, McpServerStatusEnabled).Error
+	return &server, err
+}
+
+func (server *McpServer) Insert() error {
+	server.CreatedTime = common.GetTimestamp()
+	server.UpdatedTime = server.CreatedTime
+	return DB.Create(server).Error
+}
+
+func (server *McpServer) Update() error {
+	server.UpdatedTime = common.GetTimestamp()
+	return DB.Model(server).Select("name", "description", "transport", "command", "args", "env", "url", "headers",
+		"status", "allowed_groups", "price_per_call", "timeout", "updated_time").Updates(server).Error
+}
+
+func DeleteMcpServerById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	return DB.Delete(&McpServer{}, "id = ?", id).Error
+}
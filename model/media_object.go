@@ -0,0 +1,95 @@
+package model
+
+import (
+	"one-api/common"
+)
+
+const (
+	MediaSourceImage      = "image"
+	MediaSourceMidjourney = "mj"
+	MediaSourceSuno       = "suno"
+	MediaSourceVideo      = "video"
+)
+
+// MediaObject 保存在网关存储中的生成结果
+type MediaObject struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Key         string `json:"key" gorm:"type:varchar(255);uniqueIndex"`
+	Backend     string `json:"backend" gorm:"type:varchar(16)"`
+	Source      string `json:"source" gorm:"type:varchar(16);index"`
+	TaskId      string `json:"task_id" gorm:"type:varchar(64);index"`
+	OriginURL   string `json:"origin_url" gorm:"type:text"`
+	ContentType string `json:"content_type" gorm:"type:varchar(128)"`
+	Size        int64  `json:"size" gorm:"bigint"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	ExpiresAt   int64  `json:"expires_at" gorm:"bigint;index"` // 0 表示永久保留
+}
+
+// MediaUsage 用户的存储用量
+type MediaUsage struct {
+	Count int64 `json:"count"`
+	Size  int64 `json:"size"`
+}
+
+func (object *MediaObject) Insert() error {
+	object.CreatedTime = common.GetTimestamp()
+	return DB.Create(object).Error
+}
+
+func (object *MediaObject) Delete() error {
+	return DB.Delete(object).Error
+}
+
+func GetMediaObjectById(id int) (*MediaObject, error) {
+	object := &MediaObject{}
+	err := DB.First(object, "id = ?", id).Error
+	return object, err
+}
+
+func GetMediaObjectByKey(key string) (*MediaObject, error) {
+	object := &MediaObject{}
+	err := DB.First(object, keyCol+" = ?", key).Error
+	return object, err
+}
+
+// GetTaskMediaObjects 获取任务已保存的结果
+func GetTaskMediaObjects(source string, taskId string) ([]*MediaObject, error) {
+	var objects []*MediaObject
+	err := DB.Where("source = ? AND task_id = ?", source, taskId).Find(&objects).Error
+	return objects, err
+}
+
+// GetMediaObjects 分页查询保存的结果，userId 为 0 时查询所有用户
+func GetMediaObjects(userId int, source string, taskId string, startIdx int, num int) (objects []*MediaObject, total int64, err error) {
+	query := DB.Model(&MediaObject{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if taskId != "" {
+		query = query.Where("task_id = ?", taskId)
+	}
+	err = query.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&objects).Error
+	return objects, total, err
+}
+
+// GetUserMediaUsage 统计用户占用的存储空间
+func GetUserMediaUsage(userId int) (usage MediaUsage, err error) {
+	err = DB.Model(&MediaObject{}).Where("user_id = ?", userId).
+		Select("count(*) as count, coalesce(sum(size), 0) as size").Scan(&usage).Error
+	return usage, err
+}
+
+// GetExpiredMediaObjects 获取已超过保留期的结果
+func GetExpiredMediaObjects(now int64, limit int) ([]*MediaObject, error) {
+	var objects []*MediaObject
+	err := DB.Where("expires_at > 0 AND expires_at <= ?", now).Order("id").Limit(limit).Find(&objects).Error
+	return objects, err
+}
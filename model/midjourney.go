@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type Midjourney struct {
 	Id          int    `json:"id"`
 	Code        int    `json:"code"`
@@ -21,6 +23,8 @@ type Midjourney struct {
 	Quota       int    `json:"quota"`
 	Buttons     string `json:"buttons"`
 	Properties  string `json:"properties"`
+	// 任务状态变化时推送的回调地址
+	CallbackURL string `json:"callback_url" gorm:"type:varchar(1024)"`
 }
 
 // TaskQueryParams 用于包含所有搜索条件的结构体，可以根据需求添加更多字段
@@ -99,6 +103,47 @@ func GetAllUnFinishTasks() []*Midjourney {
 	return tasks
 }
 
+// GetUnFinishTasksSubmittedBefore 获取在指定时间（毫秒）之前提交且仍未完成的任务
+func GetUnFinishTasksSubmittedBefore(before int64, limit int) []*Midjourney {
+	var tasks []*Midjourney
+	err := DB.Where("progress != ? AND submit_time < ?", "100%", before).Limit(limit).Order("id").Find(&tasks).Error
+	if err != nil {
+		return nil
+	}
+	return tasks
+}
+
+func GetMjByIds(ids []int) ([]*Midjourney, error) {
+	var tasks []*Midjourney
+	if len(ids) == 0 {
+		return tasks, nil
+	}
+	err := DB.Where("id in (?)", ids).Find(&tasks).Error
+	return tasks, err
+}
+
+// MjFailUnfinished 将仍未完成的任务标记为失败，返回是否由本次调用完成标记，用于避免重复退款
+func MjFailUnfinished(task *Midjourney, reason string) (bool, error) {
+	finishTime := time.Now().UnixMilli()
+	result := DB.Model(&Midjourney{}).Where("id = ? AND progress != ?", task.Id, "100%").Updates(map[string]any{
+		"status":      "FAILURE",
+		"progress":    "100%",
+		"fail_reason": reason,
+		"finish_time": finishTime,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	task.Status = "FAILURE"
+	task.Progress = "100%"
+	task.FailReason = reason
+	task.FinishTime = finishTime
+	return true, nil
+}
+
 func GetByOnlyMJId(mjId string) *Midjourney {
 	var mj *Midjourney
 	var err error
@@ -149,6 +194,16 @@ func (midjourney *Midjourney) Insert() error {
 	return err
 }
 
+// UpdateUnfinished 仅在任务仍未完成时保存轮询结果，返回是否写入成功；
+// 任务已被其他流程（如管理员退款）终结时不会覆盖，调用方也不应再退款或通知
+func (midjourney *Midjourney) UpdateUnfinished() (bool, error) {
+	result := DB.Model(&Midjourney{}).Where("id = ? AND progress != ?", midjourney.Id, "100%").Select("*").Updates(midjourney)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func (midjourney *Midjourney) Update() error {
 	var err error
 	err = DB.Save(midjourney).Error
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"one-api/common"
 	"strconv"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -21,6 +22,45 @@ type Redemption struct {
 	Count        int            `json:"count" gorm:"-:all"` // only for api request
 	UsedUserId   int            `json:"used_user_id"`
 	DeletedAt    gorm.DeletedAt `gorm:"index"`
+
+	SubscriptionPlanId   int    `json:"subscription_plan_id" gorm:"default:0;index"` // 兑换的订阅套餐ID，为0时兑换额度
+	SubscriptionDuration int    `json:"subscription_duration" gorm:"default:0"`      // 订阅有效期（天），为0时使用套餐有效期
+	ExpiredTime          int64  `json:"expired_time" gorm:"bigint;default:0"`        // 兑换码过期时间，为0时永不过期
+	IsGift               bool   `json:"is_gift" gorm:"default:false;index"`          // 是否为用户购买的订阅礼品码
+	PlanName             string `json:"plan_name,omitempty" gorm:"-:all"`            // 订阅套餐名称，仅用于展示
+}
+
+// SubscriptionPaymentRedemption 通过兑换码获得订阅时的支付方式
+const SubscriptionPaymentRedemption = "redemption"
+
+// IsExpired 兑换码是否已过期
+func (redemption *Redemption) IsExpired() bool {
+	return redemption.ExpiredTime != 0 && redemption.ExpiredTime < common.GetTimestamp()
+}
+
+// fillRedemptionPlanNames 为订阅兑换码填充套餐名称，已删除的套餐同样显示
+func fillRedemptionPlanNames(redemptions []*Redemption) error {
+	planIds := make([]int, 0)
+	for _, redemption := range redemptions {
+		if redemption.SubscriptionPlanId > 0 {
+			planIds = append(planIds, redemption.SubscriptionPlanId)
+		}
+	}
+	if len(planIds) == 0 {
+		return nil
+	}
+	var plans []*SubscriptionPlan
+	if err := DB.Unscoped().Select("id", "name").Where("id IN ?", planIds).Find(&plans).Error; err != nil {
+		return err
+	}
+	names := make(map[int]string, len(plans))
+	for _, plan := range plans {
+		names[plan.Id] = plan.Name
+	}
+	for _, redemption := range redemptions {
+		redemption.PlanName = names[redemption.SubscriptionPlanId]
+	}
+	return nil
 }
 
 func GetAllRedemptions(startIdx int, num int) (redemptions []*Redemption, total int64, err error) {
@@ -54,6 +94,9 @@ func GetAllRedemptions(startIdx int, num int) (redemptions []*Redemption, total
 		return nil, 0, err
 	}
 
+	if err = fillRedemptionPlanNames(redemptions); err != nil {
+		return nil, 0, err
+	}
 	return redemptions, total, nil
 }
 
@@ -96,6 +139,9 @@ func SearchRedemptions(keyword string, startIdx int, num int) (redemptions []*Re
 		return nil, 0, err
 	}
 
+	if err = fillRedemptionPlanNames(redemptions); err != nil {
+		return nil, 0, err
+	}
 	return redemptions, total, nil
 }
 
@@ -109,12 +155,13 @@ func GetRedemptionById(id int) (*Redemption, error) {
 	return &redemption, err
 }
 
-func Redeem(key string, userId int) (quota int, err error) {
+// Redeem 使用兑换码，额度兑换码增加用户额度，订阅兑换码在同一事务中为用户创建订阅
+func Redeem(key string, userId int) (quota int, subscription *UserSubscription, err error) {
 	if key == "" {
-		return 0, errors.New("未提供兑换码")
+		return 0, nil, errors.New("未提供兑换码")
 	}
 	if userId == 0 {
-		return 0, errors.New("无效的 user id")
+		return 0, nil, errors.New("无效的 user id")
 	}
 	redemption := &Redemption{}
 
@@ -131,9 +178,20 @@ func Redeem(key string, userId int) (quota int, err error) {
 		if redemption.Status != common.RedemptionCodeStatusEnabled {
 			return errors.New("该兑换码已被使用")
 		}
-		err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
-		if err != nil {
-			return err
+		if redemption.IsExpired() {
+			return errors.New("该兑换码已过期")
+		}
+		if redemption.SubscriptionPlanId > 0 {
+			subscription, err = createUserSubscription(tx, userId, redemption.SubscriptionPlanId, SubscriptionStatusActive,
+				SubscriptionPaymentRedemption, fmt.Sprintf("REDEEM%d", redemption.Id), redemption.SubscriptionDuration)
+			if err != nil {
+				return err
+			}
+		} else {
+			err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
+			if err != nil {
+				return err
+			}
 		}
 		redemption.RedeemedTime = common.GetTimestamp()
 		redemption.Status = common.RedemptionCodeStatusUsed
@@ -142,16 +200,91 @@ func Redeem(key string, userId int) (quota int, err error) {
 		return err
 	})
 	if err != nil {
-		return 0, errors.New("兑换失败，" + err.Error())
+		return 0, nil, errors.New("兑换失败，" + err.Error())
+	}
+	if subscription != nil {
+		content := fmt.Sprintf("通过兑换码兑换订阅套餐 %s，有效期至 %s，兑换码ID %d", subscription.SubscriptionPlan.Name,
+			time.Unix(subscription.EndTime, 0).Format("2006-01-02 15:04:05"), redemption.Id)
+		if redemption.IsGift {
+			content += fmt.Sprintf("，由用户 %d 赠送", redemption.UserId)
+		}
+		RecordLog(userId, LogTypeTopup, content)
+		return 0, subscription, nil
 	}
 	RecordLog(userId, LogTypeTopup, fmt.Sprintf("通过兑换码充值 %s，兑换码ID %d", common.LogQuota(redemption.Quota), redemption.Id))
-	return redemption.Quota, nil
+	return redemption.Quota, nil, nil
+}
+
+// CreateSubscriptionGift 使用账户余额购买订阅礼品码，扣除额度与生成兑换码在同一事务中完成
+func CreateSubscriptionGift(userId int, plan *SubscriptionPlan, quota int) (*Redemption, error) {
+	if quota < 0 {
+		return nil, errors.New("quota 不能为负数！")
+	}
+	if !plan.IsActive() {
+		return nil, errors.New("套餐未启用")
+	}
+	redemption := &Redemption{
+		UserId:             userId,
+		Name:               "订阅礼品",
+		Key:                common.GetUUID(),
+		Status:             common.RedemptionCodeStatusEnabled,
+		CreatedTime:        common.GetTimestamp(),
+		SubscriptionPlanId: plan.Id,
+		IsGift:             true,
+		PlanName:           plan.Name,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, quota).
+			Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInsufficientUserQuota
+		}
+		return redemption.insert(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cacheDecrUserQuota(userId, int64(quota)); err != nil {
+		common.SysError("failed to decrease user quota: " + err.Error())
+	}
+	return redemption, nil
+}
+
+// GetUserSubscriptionGifts 分页获取用户购买的订阅礼品码
+func GetUserSubscriptionGifts(userId int, startIdx int, num int) (redemptions []*Redemption, total int64, err error) {
+	query := DB.Model(&Redemption{}).Where("user_id = ? AND is_gift = ?", userId, true)
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&redemptions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if err = fillRedemptionPlanNames(redemptions); err != nil {
+		return nil, 0, err
+	}
+	return redemptions, total, nil
 }
 
 func (redemption *Redemption) Insert() error {
-	var err error
-	err = DB.Create(redemption).Error
-	return err
+	return redemption.insert(DB)
+}
+
+func (redemption *Redemption) insert(db *gorm.DB) error {
+	if redemption.SubscriptionPlanId == 0 {
+		return db.Create(redemption).Error
+	}
+	// 套餐兑换码不增加余额，零值额度会被列默认值覆盖，创建后显式写回 0
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(redemption).Error; err != nil {
+			return err
+		}
+		redemption.Quota = 0
+		return tx.Model(redemption).Update("quota", 0).Error
+	})
 }
 
 func (redemption *Redemption) SelectUpdate() error {
@@ -162,7 +295,7 @@ func (redemption *Redemption) SelectUpdate() error {
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (redemption *Redemption) Update() error {
 	var err error
-	err = DB.Model(redemption).Select("name", "status", "quota", "redeemed_time").Updates(redemption).Error
+	err = DB.Model(redemption).Select("name", "status", "quota", "redeemed_time", "subscription_plan_id", "subscription_duration", "expired_time").Updates(redemption).Error
 	return err
 }
 
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ResponseRecord 保存 Responses API 的响应对象及其输入条目，用于 previous_response_id 续接与查询
+type ResponseRecord struct {
+	Id                 int    `json:"id"`
+	ResponseId         string `json:"response_id" gorm:"type:varchar(128);uniqueIndex"`
+	PreviousResponseId string `json:"previous_response_id" gorm:"type:varchar(128);index"`
+	UserId             int    `json:"user_id" gorm:"index"`
+	TokenId            int    `json:"token_id"`
+	ChannelId          int    `json:"channel_id"`
+	Model              string `json:"model" gorm:"type:varchar(255)"`
+	// Input 本轮请求的输入条目 (JSON 数组)
+	Input string `json:"input"`
+	// Response 完整的响应对象 (JSON)
+	Response  string `json:"response"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func InsertResponseRecord(record *ResponseRecord) error {
+	return DB.Create(record).Error
+}
+
+// GetResponseRecord 获取用户的响应记录，不存在时返回 nil
+func GetResponseRecord(userId int, responseId string) (*ResponseRecord, error) {
+	if responseId == "" {
+		return nil, errors.New("response id 为空！")
+	}
+	var record ResponseRecord
+	err := DB.Where("user_id = ? and response_id = ?", userId, responseId).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// DeleteResponseRecord 删除用户的响应记录，返回是否删除了记录
+func DeleteResponseRecord(userId int, responseId string) (bool, error) {
+	result := DB.Where("user_id = ? and response_id = ?", userId, responseId).Delete(&ResponseRecord{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteResponseRecordsBefore 删除指定时间之前创建的响应记录
+func DeleteResponseRecordsBefore(timestamp int64) (int64, error) {
+	result := DB.Where("created_at < ?", timestamp).Delete(&ResponseRecord{})
+	return result.RowsAffected, result.Error
+}
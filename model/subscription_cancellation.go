@@ -0,0 +1,239 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SubscriptionCancellation 订阅取消与退款记录
+type SubscriptionCancellation struct {
+	Id                 int     `json:"id" gorm:"primaryKey"`
+	UserId             int     `json:"user_id" gorm:"index;not null"`               // 用户ID
+	UserSubscriptionId int     `json:"user_subscription_id" gorm:"index;not null"`  // 用户订阅ID
+	PlanId             int     `json:"plan_id"`                                     // 套餐ID
+	PlanName           string  `json:"plan_name" gorm:"type:varchar(100)"`          // 套餐名称
+	Mode               string  `json:"mode" gorm:"type:varchar(20)"`                // 取消方式：period_end 到期取消，immediate 立即取消，resume 撤销到期取消，purchase_limit 超出限购退款
+	OperatorId         int     `json:"operator_id"`                                 // 操作的管理员ID，用户自行操作时为0
+	Reason             string  `json:"reason" gorm:"type:varchar(255)"`             // 取消原因
+	RefundMethod       string  `json:"refund_method" gorm:"type:varchar(20)"`       // 退款方式
+	RefundMoney        float64 `json:"refund_money" gorm:"type:decimal(10,2)"`      // 退款金额（元）
+	RefundQuota        int     `json:"refund_quota"`                                // 退还到余额的额度
+	RefundStatus       string  `json:"refund_status" gorm:"type:varchar(20);index"` // 退款状态
+	RefundedTime       int64   `json:"refunded_time" gorm:"bigint"`                 // 退款完成时间
+	OldEndTime         int64   `json:"old_end_time" gorm:"bigint"`                  // 取消前到期时间
+	CreatedTime        int64   `json:"created_time" gorm:"bigint;index"`            // 操作时间
+}
+
+// 订阅取消方式
+const (
+	SubscriptionCancelAtPeriodEnd = "period_end"
+	SubscriptionCancelImmediate   = "immediate"
+	SubscriptionCancelResume      = "resume"
+	// 付款时已超出限购次数，订单未激活并转为退款，不计入购买次数
+	SubscriptionCancelPurchaseLimit = "purchase_limit"
+)
+
+// 订阅退款方式
+const (
+	SubscriptionRefundNone     = "none"     // 不退款
+	SubscriptionRefundBalance  = "balance"  // 退还到账户余额
+	SubscriptionRefundProvider = "provider" // 通过原支付渠道退款，需管理员在支付平台处理后确认
+	SubscriptionRefundManual   = "manual"   // 管理员已在线下手动退款
+)
+
+// 订阅退款状态
+const (
+	SubscriptionRefundStatusNone      = "none"
+	SubscriptionRefundStatusPending   = "pending"
+	SubscriptionRefundStatusCompleted = "completed"
+)
+
+// ScheduleUserSubscriptionCancel 设置订阅在到期后取消，同时关闭自动续费
+func ScheduleUserSubscriptionCancel(us *UserSubscription, record *SubscriptionCancellation) error {
+	now := time.Now().Unix()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&UserSubscription{}).
+			Where("id = ? AND status = ? AND cancel_at_period_end = ? AND end_time > ?", us.Id, SubscriptionStatusActive, false, now).
+			Updates(map[string]interface{}{
+				"cancel_at_period_end": true,
+				"canceled_time":        now,
+				"auto_renew":           false,
+				"updated_time":         now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSubscriptionChanged
+		}
+		record.Mode = SubscriptionCancelAtPeriodEnd
+		record.RefundMethod = SubscriptionRefundNone
+		record.RefundStatus = SubscriptionRefundStatusNone
+		record.OldEndTime = us.EndTime
+		record.CreatedTime = now
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return err
+	}
+	us.CancelAtPeriodEnd = true
+	us.CanceledTime = now
+	us.AutoRenew = false
+	us.UpdatedTime = now
+	return nil
+}
+
+// ResumeUserSubscriptionCancel 撤销订阅的到期取消
+func ResumeUserSubscriptionCancel(us *UserSubscription, record *SubscriptionCancellation) error {
+	now := time.Now().Unix()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&UserSubscription{}).
+			Where("id = ? AND status = ? AND cancel_at_period_end = ? AND end_time > ?", us.Id, SubscriptionStatusActive, true, now).
+			Updates(map[string]interface{}{
+				"cancel_at_period_end": false,
+				"canceled_time":        0,
+				"updated_time":         now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSubscriptionChanged
+		}
+		record.Mode = SubscriptionCancelResume
+		record.RefundMethod = SubscriptionRefundNone
+		record.RefundStatus = SubscriptionRefundStatusNone
+		record.OldEndTime = us.EndTime
+		record.CreatedTime = now
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return err
+	}
+	us.CancelAtPeriodEnd = false
+	us.CanceledTime = 0
+	us.UpdatedTime = now
+	return nil
+}
+
+// CancelUserSubscriptionNow 立即取消订阅，退款到余额时在同一事务中增加用户额度，并记录取消历史。
+// 订阅在此期间被续费、变更或取消时返回 ErrSubscriptionChanged
+func CancelUserSubscriptionNow(us *UserSubscription, record *SubscriptionCancellation) error {
+	now := time.Now().Unix()
+	record.Mode = SubscriptionCancelImmediate
+	record.OldEndTime = us.EndTime
+	record.CreatedTime = now
+	switch {
+	case record.RefundMethod == SubscriptionRefundNone || (record.RefundMoney <= 0 && record.RefundQuota <= 0):
+		record.RefundMethod = SubscriptionRefundNone
+		record.RefundMoney = 0
+		record.RefundQuota = 0
+		record.RefundStatus = SubscriptionRefundStatusNone
+	case record.RefundMethod == SubscriptionRefundProvider:
+		record.RefundStatus = SubscriptionRefundStatusPending
+	default:
+		record.RefundStatus = SubscriptionRefundStatusCompleted
+		record.RefundedTime = now
+	}
+	if record.RefundMethod != SubscriptionRefundBalance {
+		record.RefundQuota = 0
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&UserSubscription{}).
+			Where("id = ? AND status = ? AND end_time = ?", us.Id, SubscriptionStatusActive, us.EndTime).
+			Updates(map[string]interface{}{
+				"status":               SubscriptionStatusCanceled,
+				"end_time":             now,
+				"cancel_at_period_end": false,
+				"canceled_time":        now,
+				"auto_renew":           false,
+				"renewed_term_start":   0,
+				"renewed_term_price":   0,
+				"updated_time":         now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSubscriptionChanged
+		}
+		if record.RefundQuota > 0 {
+			err := tx.Model(&User{}).Where("id = ?", us.UserId).
+				Update("quota", gorm.Expr("quota + ?", record.RefundQuota)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return err
+	}
+	if record.RefundQuota > 0 {
+		if err := cacheIncrUserQuota(us.UserId, int64(record.RefundQuota)); err != nil {
+			common.SysError("failed to increase user quota: " + err.Error())
+		}
+	}
+
+	us.Status = SubscriptionStatusCanceled
+	us.EndTime = now
+	us.CancelAtPeriodEnd = false
+	us.CanceledTime = now
+	us.AutoRenew = false
+	us.RenewedTermStart = 0
+	us.RenewedTermPrice = 0
+	us.UpdatedTime = now
+	return nil
+}
+
+// CompleteSubscriptionRefund 管理员在支付平台完成退款后确认，仅处理待退款的记录
+func CompleteSubscriptionRefund(id int) (*SubscriptionCancellation, error) {
+	now := time.Now().Unix()
+	result := DB.Model(&SubscriptionCancellation{}).
+		Where("id = ? AND refund_status = ?", id, SubscriptionRefundStatusPending).
+		Updates(map[string]interface{}{
+			"refund_status": SubscriptionRefundStatusCompleted,
+			"refunded_time": now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("退款记录不存在或已处理")
+	}
+	var record SubscriptionCancellation
+	if err := DB.First(&record, id).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetSubscriptionCancellations 获取用户订阅的取消记录，按时间倒序
+func GetSubscriptionCancellations(userId int, subscriptionId int) ([]*SubscriptionCancellation, error) {
+	var records []*SubscriptionCancellation
+	err := DB.Where("user_id = ? AND user_subscription_id = ?", userId, subscriptionId).
+		Order("id DESC").
+		Find(&records).Error
+	return records, err
+}
+
+// GetSubscriptionCancellationsByPage 分页获取取消记录（管理员），refundStatus 为空时不筛选
+func GetSubscriptionCancellationsByPage(refundStatus string, page, pageSize int) ([]*SubscriptionCancellation, int64, error) {
+	var records []*SubscriptionCancellation
+	var total int64
+	query := DB.Model(&SubscriptionCancellation{})
+	if refundStatus != "" {
+		query = query.Where("refund_status = ?", refundStatus)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&records).Error
+	return records, total, err
+}
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"one-api/common"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -19,14 +19,235 @@ type SubscriptionPlan struct {
 	Duration    int            `json:"duration" gorm:"default:30"`                                     // 有效期（天）
 	Status      int            `json:"status" gorm:"default:1"`                                        // 状态：1启用，0禁用
 	ModelQuotas string         `json:"model_quotas" gorm:"type:text"`                                  // 模型配额JSON，格式：{"gpt-4": 100, "claude-3": 50}
+	QuotaUnits  string         `json:"quota_units" gorm:"type:text"`                                   // 模型配额计量单位JSON，格式：{"gpt-4": "tokens"}，未配置的模型按请求次数计
+	ResetPeriods string        `json:"reset_periods" gorm:"type:text"`                                 // 模型配额重置周期JSON，格式：{"gpt-4": "daily"}，配置后模型配额为每个周期的额度
+	ModelGroups string         `json:"model_groups" gorm:"type:text"`                                  // 模型组JSON，格式：{"gpt-4o系列": ["gpt-4o", "gpt-4o-mini-*"]}，组内模型共享模型配额中同名的配额
+	IsTrial       bool         `json:"is_trial" gorm:"default:false"`                                 // 试用套餐：每个用户限购一次，不支持自动续费、赠送和变更为该套餐
+	PurchaseLimit int          `json:"purchase_limit" gorm:"default:0"`                               // 每个用户最多购买次数，0表示不限
+	AllowedGroups string       `json:"allowed_groups" gorm:"type:text"`                               // 允许购买的用户分组JSON，格式：["vip"]，为空表示不限
+	NewUserDays   int          `json:"new_user_days" gorm:"default:0"`                                // 仅限注册不超过该天数的用户购买，0表示不限
+	RequireEmail  bool         `json:"require_email" gorm:"default:false"`                            // 需要已绑定邮箱
+	RequireOAuth  bool         `json:"require_oauth" gorm:"default:false"`                            // 需要已绑定第三方账号（GitHub、OIDC、微信、Telegram、LinuxDO）
 	CreatedTime int64          `json:"created_time" gorm:"bigint;autoCreateTime"`                      // 创建时间
 	UpdatedTime int64          `json:"updated_time" gorm:"bigint;autoUpdateTime"`                      // 更新时间
 	DeletedAt   gorm.DeletedAt `gorm:"index"`                                                          // 软删除
+
+	Eligibility *SubscriptionPlanEligibility `json:"eligibility,omitempty" gorm:"-"` // 当前用户的购买资格，仅在已登录时返回
+}
+
+// SubscriptionPlanEligibility 用户购买套餐的资格
+type SubscriptionPlanEligibility struct {
+	Eligible  bool   `json:"eligible"`         // 是否可以购买
+	Reason    string `json:"reason,omitempty"` // 不可购买的原因
+	Purchased int    `json:"purchased"`        // 已购买次数
+	Remaining int    `json:"remaining"`        // 剩余可购买次数，-1表示不限
 }
 
 // ModelQuotaMap 模型配额映射
 type ModelQuotaMap map[string]int
 
+// QuotaUnitMap 模型配额计量单位映射
+type QuotaUnitMap map[string]string
+
+// 模型配额计量单位
+const (
+	SubscriptionQuotaUnitRequest = "request" // 按请求次数
+	SubscriptionQuotaUnitTokens  = "tokens"  // 按输入与输出 token 总数
+	SubscriptionQuotaUnitQuota   = "quota"   // 按模型价格计算出的额度
+)
+
+// IsValidSubscriptionQuotaUnit 检查计量单位是否有效
+func IsValidSubscriptionQuotaUnit(unit string) bool {
+	switch unit {
+	case SubscriptionQuotaUnitRequest, SubscriptionQuotaUnitTokens, SubscriptionQuotaUnitQuota:
+		return true
+	}
+	return false
+}
+
+// parseQuotaUnits 解析计量单位JSON
+func parseQuotaUnits(data string) (QuotaUnitMap, error) {
+	if data == "" {
+		return make(QuotaUnitMap), nil
+	}
+	
+	var units QuotaUnitMap
+	err := json.Unmarshal([]byte(data), &units)
+	if err != nil {
+		return nil, fmt.Errorf("解析配额计量单位失败: %v", err)
+	}
+	return units, nil
+}
+
+// ResetPeriodMap 模型配额重置周期映射
+type ResetPeriodMap map[string]string
+
+// 模型配额重置周期
+const (
+	SubscriptionResetNone    = "none"    // 不重置，配额在整个有效期内使用
+	SubscriptionResetDaily   = "daily"   // 每天重置
+	SubscriptionResetWeekly  = "weekly"  // 每周重置
+	SubscriptionResetMonthly = "monthly" // 每月重置
+)
+
+// IsValidSubscriptionResetPeriod 检查重置周期是否有效
+func IsValidSubscriptionResetPeriod(period string) bool {
+	switch period {
+	case SubscriptionResetNone, SubscriptionResetDaily, SubscriptionResetWeekly, SubscriptionResetMonthly:
+		return true
+	}
+	return false
+}
+
+// parseResetPeriods 解析重置周期JSON
+func parseResetPeriods(data string) (ResetPeriodMap, error) {
+	if data == "" {
+		return make(ResetPeriodMap), nil
+	}
+	
+	var periods ResetPeriodMap
+	err := json.Unmarshal([]byte(data), &periods)
+	if err != nil {
+		return nil, fmt.Errorf("解析配额重置周期失败: %v", err)
+	}
+	return periods, nil
+}
+
+// GetPeriod 获取模型的重置周期，未配置时不重置
+func (periods ResetPeriodMap) GetPeriod(modelName string) string {
+	if period, ok := periods[modelName]; ok && period != "" {
+		return period
+	}
+	return SubscriptionResetNone
+}
+
+// GetQuotaPeriodWindow 返回从 anchor 开始按重置周期划分、包含 now 的时间窗口，不重置时 end 为 0
+func GetQuotaPeriodWindow(period string, anchor, now int64) (int64, int64) {
+	var length int64
+	switch period {
+	case SubscriptionResetDaily:
+		length = 24 * 3600
+	case SubscriptionResetWeekly:
+		length = 7 * 24 * 3600
+	case SubscriptionResetMonthly:
+		// 按自然月推算，以开始时间的日期为每个周期的起点
+		anchorTime := time.Unix(anchor, 0)
+		nowTime := time.Unix(now, 0)
+		months := (nowTime.Year()-anchorTime.Year())*12 + int(nowTime.Month()-anchorTime.Month())
+		if months < 0 {
+			months = 0
+		}
+		start := anchorTime.AddDate(0, months, 0)
+		// 月末日期会顺延到下月，需要回退到不晚于 now 的周期
+		for months > 0 && start.After(nowTime) {
+			months--
+			start = anchorTime.AddDate(0, months, 0)
+		}
+		return start.Unix(), anchorTime.AddDate(0, months+1, 0).Unix()
+	default:
+		return anchor, 0
+	}
+	if now < anchor {
+		return anchor, anchor + length
+	}
+	start := anchor + (now-anchor)/length*length
+	return start, start + length
+}
+
+// ModelGroupMap 模型组映射，组名对应组内的模型名称或通配符
+type ModelGroupMap map[string][]string
+
+// parseModelGroups 解析模型组JSON
+func parseModelGroups(data string) (ModelGroupMap, error) {
+	if data == "" {
+		return make(ModelGroupMap), nil
+	}
+	
+	var groups ModelGroupMap
+	err := json.Unmarshal([]byte(data), &groups)
+	if err != nil {
+		return nil, fmt.Errorf("解析模型组失败: %v", err)
+	}
+	return groups, nil
+}
+
+// 模型配额的匹配优先级，数值越小越优先
+const (
+	modelMatchExact    = 0 // 配额名称与模型名称相同
+	modelMatchGroup    = 1 // 模型组中列出了该模型
+	modelMatchWildcard = 2 // 通过通配符匹配，字面字符越多越优先
+)
+
+// MatchModelPattern 判断模型名称是否匹配，pattern 中的 * 匹配任意长度的字符
+func MatchModelPattern(pattern string, modelName string) bool {
+	if !strings.Contains(pattern, "*") {
+		return pattern == modelName
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(modelName, parts[0]) {
+		return false
+	}
+	rest := modelName[len(parts[0]):]
+	last := len(parts) - 1
+	for _, part := range parts[1:last] {
+		index := strings.Index(rest, part)
+		if index < 0 {
+			return false
+		}
+		rest = rest[index+len(part):]
+	}
+	return strings.HasSuffix(rest, parts[last])
+}
+
+// matchModelQuota 判断名为 key 的配额是否适用于模型，members 不为空时 key 为模型组名。
+// 返回匹配优先级及通配符的字面字符数，用于在多个配额同时匹配时排序
+func matchModelQuota(key string, members []string, modelName string) (int, int, bool) {
+	if len(members) == 0 {
+		if key == modelName {
+			return modelMatchExact, len(key), true
+		}
+		if strings.Contains(key, "*") && MatchModelPattern(key, modelName) {
+			return modelMatchWildcard, len(strings.ReplaceAll(key, "*", "")), true
+		}
+		return 0, 0, false
+	}
+	specificity := -1
+	for _, member := range members {
+		if member == modelName {
+			return modelMatchGroup, len(member), true
+		}
+		if MatchModelPattern(member, modelName) {
+			if literal := len(strings.ReplaceAll(member, "*", "")); literal > specificity {
+				specificity = literal
+			}
+		}
+	}
+	if specificity < 0 {
+		return 0, 0, false
+	}
+	return modelMatchWildcard, specificity, true
+}
+
+// lessModelMatch 比较两个匹配结果的优先级：精确匹配优先于模型组，模型组优先于通配符，
+// 同为通配符时字面字符多（更具体）的优先，仍相同时按配额名称排序以保证结果稳定
+func lessModelMatch(rankA, specificityA int, keyA string, rankB, specificityB int, keyB string) bool {
+	if rankA != rankB {
+		return rankA < rankB
+	}
+	if specificityA != specificityB {
+		return specificityA > specificityB
+	}
+	return keyA < keyB
+}
+
+// GetUnit 获取模型的计量单位，未配置时按请求次数计
+func (units QuotaUnitMap) GetUnit(modelName string) string {
+	if unit, ok := units[modelName]; ok && unit != "" {
+		return unit
+	}
+	return SubscriptionQuotaUnitRequest
+}
+
 // GetModelQuotasMap 获取模型配额映射
 func (sp *SubscriptionPlan) GetModelQuotasMap() (ModelQuotaMap, error) {
 	if sp.ModelQuotas == "" {
@@ -51,6 +272,98 @@ func (sp *SubscriptionPlan) SetModelQuotasMap(quotas ModelQuotaMap) error {
 	return nil
 }
 
+// GetQuotaUnitsMap 获取模型配额计量单位映射
+func (sp *SubscriptionPlan) GetQuotaUnitsMap() (QuotaUnitMap, error) {
+	return parseQuotaUnits(sp.QuotaUnits)
+}
+
+// SetQuotaUnitsMap 设置模型配额计量单位映射
+func (sp *SubscriptionPlan) SetQuotaUnitsMap(units QuotaUnitMap) error {
+	data, err := json.Marshal(units)
+	if err != nil {
+		return fmt.Errorf("序列化配额计量单位失败: %v", err)
+	}
+	sp.QuotaUnits = string(data)
+	return nil
+}
+
+// GetResetPeriodsMap 获取模型配额重置周期映射
+func (sp *SubscriptionPlan) GetResetPeriodsMap() (ResetPeriodMap, error) {
+	return parseResetPeriods(sp.ResetPeriods)
+}
+
+// GetModelGroupsMap 获取模型组映射
+func (sp *SubscriptionPlan) GetModelGroupsMap() (ModelGroupMap, error) {
+	return parseModelGroups(sp.ModelGroups)
+}
+
+// SetModelGroupsMap 设置模型组映射
+func (sp *SubscriptionPlan) SetModelGroupsMap(groups ModelGroupMap) error {
+	data, err := json.Marshal(groups)
+	if err != nil {
+		return fmt.Errorf("序列化模型组失败: %v", err)
+	}
+	sp.ModelGroups = string(data)
+	return nil
+}
+
+// GetAllowedGroups 获取允许购买的用户分组，为空表示不限
+func (sp *SubscriptionPlan) GetAllowedGroups() ([]string, error) {
+	if sp.AllowedGroups == "" {
+		return nil, nil
+	}
+	var groups []string
+	if err := json.Unmarshal([]byte(sp.AllowedGroups), &groups); err != nil {
+		return nil, fmt.Errorf("解析允许购买的分组失败: %v", err)
+	}
+	return groups, nil
+}
+
+// SetAllowedGroups 设置允许购买的用户分组
+func (sp *SubscriptionPlan) SetAllowedGroups(groups []string) error {
+	if len(groups) == 0 {
+		sp.AllowedGroups = ""
+		return nil
+	}
+	data, err := json.Marshal(groups)
+	if err != nil {
+		return fmt.Errorf("序列化允许购买的分组失败: %v", err)
+	}
+	sp.AllowedGroups = string(data)
+	return nil
+}
+
+// GetPurchaseLimit 获取每个用户的限购次数，试用套餐固定为1次，0表示不限
+func (sp *SubscriptionPlan) GetPurchaseLimit() int {
+	if sp.IsTrial {
+		return 1
+	}
+	return sp.PurchaseLimit
+}
+
+// GetPurchaseLimitReason 达到限购次数时提示用户的原因
+func (sp *SubscriptionPlan) GetPurchaseLimitReason() string {
+	if sp.IsTrial {
+		return "每个用户只能试用一次"
+	}
+	return fmt.Sprintf("该套餐每个用户限购 %d 次", sp.GetPurchaseLimit())
+}
+
+// HasPurchaseRestriction 套餐是否设置了购买限制，受限套餐不支持赠送
+func (sp *SubscriptionPlan) HasPurchaseRestriction() bool {
+	return sp.GetPurchaseLimit() > 0 || sp.AllowedGroups != "" || sp.NewUserDays > 0 || sp.RequireEmail || sp.RequireOAuth
+}
+
+// SetResetPeriodsMap 设置模型配额重置周期映射
+func (sp *SubscriptionPlan) SetResetPeriodsMap(periods ResetPeriodMap) error {
+	data, err := json.Marshal(periods)
+	if err != nil {
+		return fmt.Errorf("序列化配额重置周期失败: %v", err)
+	}
+	sp.ResetPeriods = string(data)
+	return nil
+}
+
 // Insert 创建订阅套餐
 func (sp *SubscriptionPlan) Insert() error {
 	if sp.Name == "" {
@@ -76,13 +389,22 @@ func (sp *SubscriptionPlan) Update() error {
 	
 	sp.UpdatedTime = time.Now().Unix()
 	return DB.Model(sp).Updates(map[string]interface{}{
-		"name":         sp.Name,
-		"description":  sp.Description,
-		"price":        sp.Price,
-		"duration":     sp.Duration,
-		"status":       sp.Status,
-		"model_quotas": sp.ModelQuotas,
-		"updated_time": sp.UpdatedTime,
+		"name":          sp.Name,
+		"description":   sp.Description,
+		"price":         sp.Price,
+		"duration":      sp.Duration,
+		"status":        sp.Status,
+		"model_quotas":  sp.ModelQuotas,
+		"quota_units":   sp.QuotaUnits,
+		"reset_periods": sp.ResetPeriods,
+		"model_groups":  sp.ModelGroups,
+		"is_trial":       sp.IsTrial,
+		"purchase_limit": sp.PurchaseLimit,
+		"allowed_groups": sp.AllowedGroups,
+		"new_user_days":  sp.NewUserDays,
+		"require_email":  sp.RequireEmail,
+		"require_oauth":  sp.RequireOAuth,
+		"updated_time":  sp.UpdatedTime,
 	}).Error
 }
 
@@ -182,30 +504,100 @@ func ValidateModelQuotas(modelQuotas string) error {
 	return nil
 }
 
-// GetModelQuotaByName 获取指定模型的配额
-func (sp *SubscriptionPlan) GetModelQuotaByName(modelName string) (int, error) {
+// ValidateQuotaUnits 验证配额计量单位，单位只能配置给套餐中包含的模型
+func ValidateQuotaUnits(units QuotaUnitMap, quotas ModelQuotaMap) error {
+	for model, unit := range units {
+		if _, ok := quotas[model]; !ok {
+			return fmt.Errorf("模型 %s 不在套餐配额中", model)
+		}
+		if !IsValidSubscriptionQuotaUnit(unit) {
+			return fmt.Errorf("模型 %s 的计量单位 %s 无效", model, unit)
+		}
+	}
+	return nil
+}
+
+// ValidateResetPeriods 验证配额重置周期，周期只能配置给套餐中包含的模型
+func ValidateResetPeriods(periods ResetPeriodMap, quotas ModelQuotaMap) error {
+	for model, period := range periods {
+		if _, ok := quotas[model]; !ok {
+			return fmt.Errorf("模型 %s 不在套餐配额中", model)
+		}
+		if !IsValidSubscriptionResetPeriod(period) {
+			return fmt.Errorf("模型 %s 的重置周期 %s 无效", model, period)
+		}
+	}
+	return nil
+}
+
+// ValidateModelGroups 验证模型组，组名必须是套餐配额中的名称，组内至少包含一个模型
+func ValidateModelGroups(groups ModelGroupMap, quotas ModelQuotaMap) error {
+	for name, members := range groups {
+		if _, ok := quotas[name]; !ok {
+			return fmt.Errorf("模型组 %s 不在套餐配额中", name)
+		}
+		if strings.Contains(name, "*") {
+			return fmt.Errorf("模型组名称 %s 不能包含通配符", name)
+		}
+		if len(members) == 0 {
+			return fmt.Errorf("模型组 %s 不能为空", name)
+		}
+		for _, member := range members {
+			if strings.TrimSpace(member) == "" || member == "*" {
+				return fmt.Errorf("模型组 %s 包含无效的模型名称", name)
+			}
+		}
+	}
+	return nil
+}
+
+// MatchModelQuotaName 获取适用于指定模型的配额名称，多个配额同时匹配时返回优先级最高的一个
+func (sp *SubscriptionPlan) MatchModelQuotaName(modelName string) (string, bool, error) {
 	quotas, err := sp.GetModelQuotasMap()
+	if err != nil {
+		return "", false, err
+	}
+	groups, err := sp.GetModelGroupsMap()
+	if err != nil {
+		return "", false, err
+	}
+	best := ""
+	bestRank, bestSpecificity := 0, 0
+	found := false
+	for key := range quotas {
+		rank, specificity, ok := matchModelQuota(key, groups[key], modelName)
+		if !ok {
+			continue
+		}
+		if !found || lessModelMatch(rank, specificity, key, bestRank, bestSpecificity, best) {
+			best, bestRank, bestSpecificity = key, rank, specificity
+			found = true
+		}
+	}
+	return best, found, nil
+}
+
+// GetModelQuotaByName 获取指定模型的配额，支持通配符与模型组，多个配额匹配时取优先级最高的一个
+func (sp *SubscriptionPlan) GetModelQuotaByName(modelName string) (int, error) {
+	key, found, err := sp.MatchModelQuotaName(modelName)
 	if err != nil {
 		return 0, err
 	}
-	
-	quota, exists := quotas[modelName]
-	if !exists {
+	if !found {
 		return 0, nil // 如果模型不在套餐中，返回0配额
 	}
 	
-	return quota, nil
-}
-
-// HasModel 检查套餐是否包含指定模型
-func (sp *SubscriptionPlan) HasModel(modelName string) bool {
 	quotas, err := sp.GetModelQuotasMap()
 	if err != nil {
-		return false
+		return 0, err
 	}
-	
-	_, exists := quotas[modelName]
-	return exists
+	return quotas[key], nil
+}
+
+// HasModel 检查套餐是否包含指定模型，支持通配符与模型组
+func (sp *SubscriptionPlan) HasModel(modelName string) bool {
+	_, found, err := sp.MatchModelQuotaName(modelName)
+	return err == nil && found
 }
 
 // GetTotalQuota 获取套餐总配额数
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SubscriptionPlanChange 订阅套餐变更（升级/降级）记录
+type SubscriptionPlanChange struct {
+	Id                 int    `json:"id" gorm:"primaryKey"`
+	UserId             int    `json:"user_id" gorm:"index;not null"`              // 用户ID
+	UserSubscriptionId int    `json:"user_subscription_id" gorm:"index;not null"` // 用户订阅ID
+	FromPlanId         int    `json:"from_plan_id"`                               // 变更前套餐ID
+	FromPlanName       string `json:"from_plan_name" gorm:"type:varchar(100)"`    // 变更前套餐名称
+	ToPlanId           int    `json:"to_plan_id"`                                 // 变更后套餐ID
+	ToPlanName         string `json:"to_plan_name" gorm:"type:varchar(100)"`      // 变更后套餐名称
+	Credit             int    `json:"credit"`                                     // 原套餐按剩余时间或配额折算的抵扣额度
+	Cost               int    `json:"cost"`                                       // 新套餐价格对应的额度
+	Amount             int    `json:"amount"`                                     // 实际从余额扣除的额度，负数表示退还
+	CreditMode         string `json:"credit_mode" gorm:"type:varchar(20)"`        // 折算方式
+	UsageMode          string `json:"usage_mode" gorm:"type:varchar(20)"`         // 已用配额处理方式
+	OldEndTime         int64  `json:"old_end_time" gorm:"bigint"`                 // 变更前到期时间
+	NewEndTime         int64  `json:"new_end_time" gorm:"bigint"`                 // 变更后到期时间
+	CreatedTime        int64  `json:"created_time" gorm:"bigint;index"`           // 变更时间
+}
+
+// ErrSubscriptionChanged 订阅在变更过程中已被其他操作修改
+var ErrSubscriptionChanged = errors.New("订阅状态已变化，请刷新后重试")
+
+// ChangeUserSubscriptionPlan 将订阅变更为新套餐并开始新的一期，按 change.Amount 扣除或退还余额并记录变更历史，
+// 以上操作在同一事务中完成。usedQuotas 为新套餐各模型沿用的已用配额
+func ChangeUserSubscriptionPlan(us *UserSubscription, plan *SubscriptionPlan, change *SubscriptionPlanChange, usedQuotas ModelQuotaMap) error {
+	now := time.Now().Unix()
+	updates := map[string]interface{}{
+		"subscription_plan_id": plan.Id,
+		"start_time":           now,
+		"term_start_time":      now,
+		"end_time":             change.NewEndTime,
+		"quota_units":          plan.QuotaUnits,
+		"reset_periods":        plan.ResetPeriods,
+		"purchase_price":       plan.Price,
+		"renewed_term_start":   0,
+		"renewed_term_price":   0,
+		"renew_fail_count":     0,
+		"updated_time":         now,
+	}
+	change.CreatedTime = now
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 变更后的套餐同样计入限购次数
+		if err := checkPlanPurchaseLimit(tx, us.UserId, plan, 0); err != nil {
+			return err
+		}
+		result := tx.Model(&UserSubscription{}).
+			Where("id = ? AND status = ? AND subscription_plan_id = ? AND end_time = ?",
+				us.Id, SubscriptionStatusActive, change.FromPlanId, change.OldEndTime).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSubscriptionChanged
+		}
+		if change.Amount > 0 {
+			result = tx.Model(&User{}).Where("id = ? AND quota >= ?", us.UserId, change.Amount).
+				Update("quota", gorm.Expr("quota - ?", change.Amount))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrInsufficientUserQuota
+			}
+		} else if change.Amount < 0 {
+			err := tx.Model(&User{}).Where("id = ?", us.UserId).
+				Update("quota", gorm.Expr("quota + ?", -change.Amount)).Error
+			if err != nil {
+				return err
+			}
+		}
+		if err := replaceSubscriptionQuotas(tx, us, plan, usedQuotas); err != nil {
+			return err
+		}
+		return tx.Create(change).Error
+	})
+	if err != nil {
+		return err
+	}
+	if change.Amount > 0 {
+		err = cacheDecrUserQuota(us.UserId, int64(change.Amount))
+	} else if change.Amount < 0 {
+		err = cacheIncrUserQuota(us.UserId, int64(-change.Amount))
+	}
+	if err != nil {
+		common.SysError("failed to update user quota cache: " + err.Error())
+	}
+
+	us.SubscriptionPlanId = plan.Id
+	us.SubscriptionPlan = plan
+	us.StartTime = now
+	us.TermStartTime = now
+	us.EndTime = change.NewEndTime
+	us.QuotaUnits = plan.QuotaUnits
+	us.ResetPeriods = plan.ResetPeriods
+	us.Quotas = nil
+	us.PurchasePrice = plan.Price
+	us.RenewedTermStart = 0
+	us.RenewedTermPrice = 0
+	us.RenewFailCount = 0
+	us.UpdatedTime = now
+	return nil
+}
+
+// GetSubscriptionPlanChanges 获取用户订阅的套餐变更历史，按时间倒序
+func GetSubscriptionPlanChanges(userId int, subscriptionId int) ([]*SubscriptionPlanChange, error) {
+	var changes []*SubscriptionPlanChange
+	err := DB.Where("user_id = ? AND user_subscription_id = ?", userId, subscriptionId).
+		Order("id DESC").
+		Find(&changes).Error
+	return changes, err
+}
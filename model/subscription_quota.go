@@ -0,0 +1,397 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SubscriptionQuota 订阅的模型配额计数，每个订阅的每个模型一行。
+// 所有扣减都通过带条件的原子更新完成，剩余配额 = Total - Used - Reserved
+type SubscriptionQuota struct {
+	Id                 int    `json:"id" gorm:"primaryKey"`
+	UserSubscriptionId int    `json:"user_subscription_id" gorm:"uniqueIndex:idx_subscription_quota_model;not null"`         // 用户订阅ID
+	UserId             int    `json:"user_id" gorm:"index;not null"`                                                         // 用户ID
+	ModelName          string `json:"model_name" gorm:"type:varchar(100);uniqueIndex:idx_subscription_quota_model;not null"` // 模型名称、通配符或模型组名
+	Models             string `json:"models,omitempty" gorm:"type:text"`                                                     // 模型组包含的模型或通配符JSON，为空时按 ModelName 匹配
+	Unit               string `json:"unit" gorm:"type:varchar(20)"`                                                          // 计量单位
+	ResetPeriod        string `json:"reset_period" gorm:"type:varchar(20)"`                                                  // 重置周期
+	Total              int    `json:"total"`                                                                                 // 本期总配额
+	Used               int    `json:"used" gorm:"default:0"`                                                                 // 本期已使用
+	Reserved           int    `json:"reserved" gorm:"default:0"`                                                             // 进行中请求预留的配额
+	PeriodStart        int64  `json:"period_start" gorm:"bigint;default:0"`                                                  // 当前重置周期的开始时间
+	UpdatedTime        int64  `json:"updated_time" gorm:"bigint"`                                                            // 更新时间
+}
+
+// SubscriptionQuotaReservation 请求进行中预留的订阅配额，结算或释放后删除，超时未结算的由调度器回收
+type SubscriptionQuotaReservation struct {
+	Id                  int   `json:"id" gorm:"primaryKey"`
+	SubscriptionQuotaId int   `json:"subscription_quota_id" gorm:"index;not null"`
+	UserSubscriptionId  int   `json:"user_subscription_id" gorm:"index"`
+	UserId              int   `json:"user_id" gorm:"index"`
+	Amount              int   `json:"amount"`
+	CreatedTime         int64 `json:"created_time" gorm:"bigint;index"`
+}
+
+// Remaining 获取可用的剩余配额
+func (q *SubscriptionQuota) Remaining() int {
+	remaining := q.Total - q.Used - q.Reserved
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// GetModels 获取模型组包含的模型或通配符，不是模型组时返回 nil
+func (q *SubscriptionQuota) GetModels() []string {
+	if q.Models == "" {
+		return nil
+	}
+	var models []string
+	if err := json.Unmarshal([]byte(q.Models), &models); err != nil {
+		common.SysError(fmt.Sprintf("解析订阅配额 %d 的模型组失败: %v", q.Id, err))
+		return nil
+	}
+	return models
+}
+
+// newSubscriptionQuotas 按套餐配置构造订阅的模型配额计数，used 为沿用的已用量
+func newSubscriptionQuotas(subscriptionId int, userId int, plan *SubscriptionPlan, used ModelQuotaMap) ([]*SubscriptionQuota, error) {
+	planQuotas, err := plan.GetModelQuotasMap()
+	if err != nil {
+		return nil, fmt.Errorf("获取套餐配额失败: %v", err)
+	}
+	units, err := plan.GetQuotaUnitsMap()
+	if err != nil {
+		return nil, err
+	}
+	periods, err := plan.GetResetPeriodsMap()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := plan.GetModelGroupsMap()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	quotas := make([]*SubscriptionQuota, 0, len(planQuotas))
+	for modelName, total := range planQuotas {
+		models := ""
+		if members := groups[modelName]; len(members) > 0 {
+			data, err := json.Marshal(members)
+			if err != nil {
+				return nil, fmt.Errorf("序列化模型组失败: %v", err)
+			}
+			models = string(data)
+		}
+		quotas = append(quotas, &SubscriptionQuota{
+			UserSubscriptionId: subscriptionId,
+			UserId:             userId,
+			ModelName:          modelName,
+			Models:             models,
+			Unit:               units.GetUnit(modelName),
+			ResetPeriod:        periods.GetPeriod(modelName),
+			Total:              total,
+			Used:               used[modelName],
+			UpdatedTime:        now,
+		})
+	}
+	return quotas, nil
+}
+
+// replaceSubscriptionQuotas 在事务中将订阅的模型配额替换为套餐配置，开始新的一期。
+// 已有模型原地更新以保留进行中请求的预留，套餐中已移除的模型删除
+func replaceSubscriptionQuotas(tx *gorm.DB, us *UserSubscription, plan *SubscriptionPlan, used ModelQuotaMap) error {
+	quotas, err := newSubscriptionQuotas(us.Id, us.UserId, plan, used)
+	if err != nil {
+		return err
+	}
+	modelNames := make([]string, 0, len(quotas))
+	for _, quota := range quotas {
+		modelNames = append(modelNames, quota.ModelName)
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_subscription_id"}, {Name: "model_name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"models", "unit", "reset_period", "total", "used", "period_start", "updated_time"}),
+		}).Create(quota).Error
+		if err != nil {
+			return err
+		}
+	}
+	query := tx.Where("user_subscription_id = ?", us.Id)
+	if len(modelNames) > 0 {
+		query = query.Where("model_name NOT IN ?", modelNames)
+	}
+	return query.Delete(&SubscriptionQuota{}).Error
+}
+
+// ReserveSubscriptionQuota 为进行中的请求预留订阅配额，剩余配额不足时返回 nil
+func ReserveSubscriptionQuota(quota *SubscriptionQuota, amount int) (*SubscriptionQuotaReservation, error) {
+	if amount <= 0 {
+		return nil, nil
+	}
+	reservation := &SubscriptionQuotaReservation{
+		SubscriptionQuotaId: quota.Id,
+		UserSubscriptionId:  quota.UserSubscriptionId,
+		UserId:              quota.UserId,
+		Amount:              amount,
+		CreatedTime:         time.Now().Unix(),
+	}
+	reserved := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&SubscriptionQuota{}).
+			Where("id = ? AND total - used - reserved >= ?", quota.Id, amount).
+			Updates(map[string]interface{}{
+				"reserved":     gorm.Expr("reserved + ?", amount),
+				"updated_time": reservation.CreatedTime,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		reserved = true
+		return tx.Create(reservation).Error
+	})
+	if err != nil || !reserved {
+		return nil, err
+	}
+	quota.Reserved += amount
+	return reservation, nil
+}
+
+// SettleSubscriptionQuota 按实际用量结算订阅配额：释放 reservation（可为 nil）并原子扣除 amount。
+// 释放预留后剩余配额仍不足 amount 时不扣除，仅释放预留并返回 false
+func SettleSubscriptionQuota(quotaId int, reservation *SubscriptionQuotaReservation, amount int) (bool, error) {
+	settled := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		held, err := deleteSubscriptionQuotaReservation(tx, reservation)
+		if err != nil {
+			return err
+		}
+		now := time.Now().Unix()
+		if amount > 0 {
+			result := tx.Model(&SubscriptionQuota{}).
+				Where("id = ? AND total - used - reserved + ? >= ?", quotaId, held, amount).
+				Updates(map[string]interface{}{
+					"used":         gorm.Expr("used + ?", amount),
+					"reserved":     gorm.Expr("reserved - ?", held),
+					"updated_time": now,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				settled = true
+				return nil
+			}
+		}
+		return releaseSubscriptionQuota(tx, quotaId, held, now)
+	})
+	return settled, err
+}
+
+// ReleaseSubscriptionQuotaReservations 释放请求失败或未使用的预留配额
+func ReleaseSubscriptionQuotaReservations(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var reservations []*SubscriptionQuotaReservation
+	if err := DB.Where("id IN ?", ids).Find(&reservations).Error; err != nil {
+		return err
+	}
+	for _, reservation := range reservations {
+		if err := releaseSubscriptionQuotaReservation(reservation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSubscriptionQuotaReservations 根据ID获取预留记录
+func GetSubscriptionQuotaReservations(ids []int) ([]*SubscriptionQuotaReservation, error) {
+	var reservations []*SubscriptionQuotaReservation
+	if len(ids) == 0 {
+		return reservations, nil
+	}
+	err := DB.Where("id IN ?", ids).Find(&reservations).Error
+	return reservations, err
+}
+
+// ReapExpiredSubscriptionReservations 回收创建时间早于 before 仍未结算的预留，返回回收的数量
+func ReapExpiredSubscriptionReservations(before int64) (int, error) {
+	var reservations []*SubscriptionQuotaReservation
+	if err := DB.Where("created_time < ?", before).Find(&reservations).Error; err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, reservation := range reservations {
+		if err := releaseSubscriptionQuotaReservation(reservation); err != nil {
+			common.SysError(fmt.Sprintf("回收订阅配额预留 %d 失败: %v", reservation.Id, err))
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func releaseSubscriptionQuotaReservation(reservation *SubscriptionQuotaReservation) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		held, err := deleteSubscriptionQuotaReservation(tx, reservation)
+		if err != nil {
+			return err
+		}
+		return releaseSubscriptionQuota(tx, reservation.SubscriptionQuotaId, held, time.Now().Unix())
+	})
+}
+
+// deleteSubscriptionQuotaReservation 删除预留记录并返回需要释放的数量，已被结算或回收的预留返回 0
+func deleteSubscriptionQuotaReservation(tx *gorm.DB, reservation *SubscriptionQuotaReservation) (int, error) {
+	if reservation == nil {
+		return 0, nil
+	}
+	result := tx.Delete(&SubscriptionQuotaReservation{}, reservation.Id)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, nil
+	}
+	return reservation.Amount, nil
+}
+
+func releaseSubscriptionQuota(tx *gorm.DB, quotaId int, amount int, now int64) error {
+	if amount <= 0 {
+		return nil
+	}
+	return tx.Model(&SubscriptionQuota{}).Where("id = ?", quotaId).
+		Updates(map[string]interface{}{
+			"reserved":     gorm.Expr("CASE WHEN reserved >= ? THEN reserved - ? ELSE 0 END", amount, amount),
+			"updated_time": now,
+		}).Error
+}
+
+// refreshPeriod 进入新的重置周期时恢复配额，首次记录周期时沿用当前用量。
+// 以原周期开始时间为条件更新，多个节点并发刷新时只会重置一次
+func (q *SubscriptionQuota) refreshPeriod(anchor int64, now int64) (bool, error) {
+	if q.ResetPeriod == "" || q.ResetPeriod == SubscriptionResetNone {
+		return false, nil
+	}
+	windowStart, _ := GetQuotaPeriodWindow(q.ResetPeriod, anchor, now)
+	if q.PeriodStart == windowStart {
+		return false, nil
+	}
+	updates := map[string]interface{}{
+		"period_start": windowStart,
+		"updated_time": now,
+	}
+	if q.PeriodStart != 0 {
+		updates["used"] = 0
+	}
+	result := DB.Model(&SubscriptionQuota{}).
+		Where("id = ? AND period_start = ?", q.Id, q.PeriodStart).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 其他节点已刷新，重新读取最新计数
+		return false, DB.First(q, q.Id).Error
+	}
+	if q.PeriodStart != 0 {
+		q.Used = 0
+	}
+	q.PeriodStart = windowStart
+	return true, nil
+}
+
+// migrateLegacySubscriptionQuotas 将旧版本保存在订阅 JSON 字段中的配额迁移到计数表
+func migrateLegacySubscriptionQuotas() error {
+	var subscriptions []*UserSubscription
+	migrated := DB.Model(&SubscriptionQuota{}).Select("1").Where("user_subscription_id = user_subscriptions.id")
+	err := DB.Preload("SubscriptionPlan").
+		Where("model_quotas <> '' AND model_quotas IS NOT NULL").
+		Where("NOT EXISTS (?)", migrated).
+		Find(&subscriptions).Error
+	if err != nil {
+		return err
+	}
+	for _, subscription := range subscriptions {
+		quotas, err := subscription.legacySubscriptionQuotas()
+		if err != nil {
+			common.SysError(fmt.Sprintf("迁移订阅 %d 配额失败: %v", subscription.Id, err))
+			continue
+		}
+		if len(quotas) == 0 {
+			continue
+		}
+		err = DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&quotas).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(subscriptions) > 0 {
+		common.SysLog(fmt.Sprintf("migrated quotas of %d subscriptions", len(subscriptions)))
+	}
+	return nil
+}
+
+// legacySubscriptionQuotas 根据旧版 JSON 字段构造配额计数，套餐已删除时以剩余与已用之和作为总配额
+func (us *UserSubscription) legacySubscriptionQuotas() ([]*SubscriptionQuota, error) {
+	remaining := make(ModelQuotaMap)
+	if err := json.Unmarshal([]byte(us.ModelQuotas), &remaining); err != nil {
+		return nil, fmt.Errorf("解析剩余配额失败: %v", err)
+	}
+	used := make(ModelQuotaMap)
+	if us.UsedQuotas != "" {
+		if err := json.Unmarshal([]byte(us.UsedQuotas), &used); err != nil {
+			return nil, fmt.Errorf("解析已使用配额失败: %v", err)
+		}
+	}
+	starts := make(map[string]int64)
+	if us.PeriodStarts != "" {
+		if err := json.Unmarshal([]byte(us.PeriodStarts), &starts); err != nil {
+			return nil, fmt.Errorf("解析重置周期失败: %v", err)
+		}
+	}
+	totals := make(ModelQuotaMap)
+	if us.SubscriptionPlan != nil {
+		planQuotas, err := us.SubscriptionPlan.GetModelQuotasMap()
+		if err != nil {
+			return nil, err
+		}
+		totals = planQuotas
+	} else {
+		for modelName, left := range remaining {
+			totals[modelName] = left + used[modelName]
+		}
+	}
+	units, err := us.GetQuotaUnitsMap()
+	if err != nil {
+		return nil, err
+	}
+	periods, err := us.GetResetPeriodsMap()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	quotas := make([]*SubscriptionQuota, 0, len(totals))
+	for modelName, total := range totals {
+		quotas = append(quotas, &SubscriptionQuota{
+			UserSubscriptionId: us.Id,
+			UserId:             us.UserId,
+			ModelName:          modelName,
+			Unit:               units.GetUnit(modelName),
+			ResetPeriod:        periods.GetPeriod(modelName),
+			Total:              total,
+			Used:               used[modelName],
+			PeriodStart:        starts[modelName],
+			UpdatedTime:        now,
+		})
+	}
+	return quotas, nil
+}
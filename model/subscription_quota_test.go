@@ -0,0 +1,72 @@
+package model
+
+import "testing"
+
+func TestReserveAndSettleSubscriptionQuota(t *testing.T) {
+	tests := []struct {
+		name         string
+		used         int // 结算前已使用的配额
+		reserve      int
+		settle       int
+		wantReserved bool
+		wantSettled  bool
+		wantUsed     int
+	}{
+		{name: "settle more than reserved", reserve: 30, settle: 50, wantReserved: true, wantSettled: true, wantUsed: 50},
+		{name: "settle less than reserved", reserve: 30, settle: 10, wantReserved: true, wantSettled: true, wantUsed: 10},
+		{name: "settle uses the rest of the quota", used: 60, reserve: 30, settle: 40, wantReserved: true, wantSettled: true, wantUsed: 100},
+		{name: "settle beyond the quota only releases", reserve: 30, settle: 120, wantReserved: true, wantUsed: 0},
+		{name: "reserve beyond the quota", used: 90, reserve: 20, settle: 5, wantSettled: true, wantUsed: 95},
+		{name: "release without usage", reserve: 30, wantReserved: true, wantUsed: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			us := createTestSubscription(t, 0, `{"gpt-4":100}`)
+			quota, err := us.GetQuota("gpt-4")
+			if err != nil || quota == nil {
+				t.Fatalf("get quota: %+v %v", quota, err)
+			}
+			if tt.used > 0 {
+				if err := DB.Model(quota).Update("used", tt.used).Error; err != nil {
+					t.Fatalf("set used: %v", err)
+				}
+				quota.Used = tt.used
+			}
+
+			reservation, err := ReserveSubscriptionQuota(quota, tt.reserve)
+			if err != nil {
+				t.Fatalf("reserve: %v", err)
+			}
+			if (reservation != nil) != tt.wantReserved {
+				t.Fatalf("reserved = %v, want %v", reservation != nil, tt.wantReserved)
+			}
+			settled, err := SettleSubscriptionQuota(quota.Id, reservation, tt.settle)
+			if err != nil {
+				t.Fatalf("settle: %v", err)
+			}
+			if settled != tt.wantSettled {
+				t.Fatalf("settled = %v, want %v", settled, tt.wantSettled)
+			}
+			// 预留只能结算一次，重复结算不会再次释放
+			if reservation != nil {
+				if _, err := SettleSubscriptionQuota(quota.Id, reservation, 0); err != nil {
+					t.Fatalf("settle again: %v", err)
+				}
+			}
+
+			var stored SubscriptionQuota
+			if err := DB.First(&stored, quota.Id).Error; err != nil {
+				t.Fatalf("get quota: %v", err)
+			}
+			if stored.Used != tt.wantUsed || stored.Reserved != 0 {
+				t.Fatalf("used = %d reserved = %d, want used %d and nothing reserved", stored.Used, stored.Reserved, tt.wantUsed)
+			}
+			var count int64
+			DB.Model(&SubscriptionQuotaReservation{}).Count(&count)
+			if count != 0 {
+				t.Fatalf("%d reservations left", count)
+			}
+		})
+	}
+}
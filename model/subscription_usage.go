@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"time"
 
-	"gorm.io/gorm"
 )
 
 // SubscriptionUsage 订阅使用记录表
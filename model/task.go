@@ -37,6 +37,9 @@ type Task struct {
 	FinishTime int64                 `json:"finish_time" gorm:"index"`
 	Progress   string                `json:"progress" gorm:"type:varchar(20);index"`
 	Properties Properties            `json:"properties" gorm:"type:json"`
+	ResultURL  string                `json:"result_url" gorm:"type:text"` // 生成结果的地址（视频等）
+	// 任务状态变化时推送的回调地址
+	CallbackURL string `json:"callback_url" gorm:"type:varchar(1024)"`
 
 	Data json.RawMessage `json:"data" gorm:"type:json"`
 }
@@ -52,7 +55,9 @@ func (t *Task) GetData(v any) error {
 }
 
 type Properties struct {
-	Input string `json:"input"`
+	Input    string `json:"input"`
+	Model    string `json:"model,omitempty"`    // 请求的模型名称
+	Duration int    `json:"duration,omitempty"` // 视频时长（秒）
 }
 
 func (m *Properties) Scan(val interface{}) error {
@@ -181,6 +186,57 @@ func GetAllUnFinishSyncTasks(limit int) []*Task {
 	return tasks
 }
 
+// GetUnFinishSyncTasksCreatedBefore 获取在指定时间之前创建且仍未完成的任务
+func GetUnFinishSyncTasksCreatedBefore(before int64, limit int) []*Task {
+	var tasks []*Task
+	err := DB.Where("progress != ? AND created_at < ?", "100%", before).Limit(limit).Order("id").Find(&tasks).Error
+	if err != nil {
+		return nil
+	}
+	return tasks
+}
+
+func GetTasksByIDs(ids []int64) ([]*Task, error) {
+	var tasks []*Task
+	if len(ids) == 0 {
+		return tasks, nil
+	}
+	err := DB.Where("id in (?)", ids).Find(&tasks).Error
+	return tasks, err
+}
+
+// TaskFailUnfinished 将仍未完成的任务标记为失败，返回是否由本次调用完成标记，用于避免重复退款
+func TaskFailUnfinished(task *Task, reason string) (bool, error) {
+	finishTime := time.Now().Unix()
+	result := DB.Model(&Task{}).Where("id = ? AND progress != ?", task.ID, "100%").Updates(map[string]any{
+		"status":      TaskStatusFailure,
+		"progress":    "100%",
+		"fail_reason": reason,
+		"finish_time": finishTime,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	task.Status = TaskStatusFailure
+	task.Progress = "100%"
+	task.FailReason = reason
+	task.FinishTime = finishTime
+	return true, nil
+}
+
+// UpdateUnfinished 仅在任务仍未完成时保存轮询结果，返回是否写入成功；
+// 任务已被其他流程（如管理员退款）终结时不会覆盖，调用方也不应再退款或通知
+func (task *Task) UpdateUnfinished() (bool, error) {
+	result := DB.Model(&Task{}).Where("id = ? AND progress != ?", task.ID, "100%").Select("*").Updates(task)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func GetByOnlyTaskId(taskId string) (*Task, bool, error) {
 	if taskId == "" {
 		return nil, false, nil
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"one-api/common"
+)
+
+const (
+	TaskWebhookStatusPending = "pending"
+	TaskWebhookStatusSuccess = "success"
+	TaskWebhookStatusFailed  = "failed"
+)
+
+// TaskWebhookDelivery 异步任务状态变化的回调投递记录，失败后按退避时间重试
+type TaskWebhookDelivery struct {
+	Id            int    `json:"id"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	EventId       string `json:"event_id" gorm:"type:varchar(64);index"`
+	Platform      string `json:"platform" gorm:"type:varchar(30)"`
+	TaskId        string `json:"task_id" gorm:"type:varchar(50);index"`
+	TaskStatus    string `json:"task_status" gorm:"type:varchar(20)"`
+	CallbackURL   string `json:"callback_url" gorm:"type:varchar(1024)"`
+	Payload       string `json:"payload" gorm:"type:text"`
+	Status        string `json:"status" gorm:"type:varchar(16);index"`
+	Attempts      int    `json:"attempts" gorm:"default:0"`
+	NextAttemptAt int64  `json:"next_attempt_at" gorm:"bigint;index"`
+	LastError     string `json:"last_error" gorm:"type:text"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime   int64  `json:"updated_time" gorm:"bigint"`
+}
+
+func (delivery *TaskWebhookDelivery) Insert() error {
+	delivery.CreatedTime = common.GetTimestamp()
+	delivery.UpdatedTime = delivery.CreatedTime
+	return DB.Create(delivery).Error
+}
+
+func (delivery *TaskWebhookDelivery) Update() error {
+	delivery.UpdatedTime = common.GetTimestamp()
+	return DB.Model(delivery).Select("status", "attempts", "next_attempt_at", "last_error", "updated_time").
+		Updates(delivery).Error
+}
+
+// GetDueTaskWebhookDeliveries 获取到达投递时间的待投递记录
+func GetDueTaskWebhookDeliveries(now int64, limit int) ([]*TaskWebhookDelivery, error) {
+	var deliveries []*TaskWebhookDelivery
+	err := DB.Where("status = ? AND next_attempt_at <= ?", TaskWebhookStatusPending, now).
+		Order("next_attempt_at").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// GetTaskWebhookDeliveries 分页查询投递记录，userId 为 0 时查询所有用户
+func GetTaskWebhookDeliveries(userId int, taskId string, status string, startIdx int, num int) (deliveries []*TaskWebhookDelivery, total int64, err error) {
+	query := DB.Model(&TaskWebhookDelivery{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if taskId != "" {
+		query = query.Where("task_id = ?", taskId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err = query.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+func GetTaskWebhookDeliveryById(id int) (*TaskWebhookDelivery, error) {
+	delivery := &TaskWebhookDelivery{}
+	err := DB.First(delivery, "id = ?", id).Error
+	return delivery, err
+}
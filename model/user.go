@@ -41,6 +41,7 @@ type User struct {
 	DeletedAt        gorm.DeletedAt `gorm:"index"`
 	LinuxDOId        string         `json:"linux_do_id" gorm:"column:linux_do_id;index"`
 	Setting          string         `json:"setting" gorm:"type:text;column:setting"`
+	CreatedTime      int64          `json:"created_time" gorm:"bigint;default:0"` // 注册时间，早于该字段加入的用户为0
 }
 
 func (user *User) ToBaseUser() *UserBase {
@@ -312,6 +313,7 @@ func (user *User) Insert(inviterId int) error {
 	user.Quota = common.QuotaForNewUser
 	//user.SetAccessToken(common.GetUUID())
 	user.AffCode = common.GetRandomString(4)
+	user.CreatedTime = common.GetTimestamp()
 	result := DB.Create(user)
 	if result.Error != nil {
 		return result.Error
@@ -1,13 +1,14 @@
 package model
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"one-api/common"
+	"sort"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UserSubscription 用户订阅表
@@ -15,87 +16,305 @@ type UserSubscription struct {
 	Id                 int            `json:"id" gorm:"primaryKey"`
 	UserId             int            `json:"user_id" gorm:"index;not null"`                              // 用户ID
 	SubscriptionPlanId int            `json:"subscription_plan_id" gorm:"index;not null"`                // 订阅套餐ID
-	Status             int            `json:"status" gorm:"default:1;index"`                             // 状态：1激活，2过期，3取消
+	Status             int            `json:"status" gorm:"default:1;index"`                             // 状态：1激活，2过期，3取消，4待支付，5支付超时
 	StartTime          int64          `json:"start_time" gorm:"bigint;not null"`                         // 开始时间
 	EndTime            int64          `json:"end_time" gorm:"bigint;not null;index"`                     // 结束时间
-	ModelQuotas        string         `json:"model_quotas" gorm:"type:text"`                             // 剩余模型配额JSON
-	UsedQuotas         string         `json:"used_quotas" gorm:"type:text"`                              // 已使用配额JSON
+	TermStartTime      int64          `json:"term_start_time" gorm:"bigint;default:0"`                   // 当前付费周期开始时间，续费与变更套餐时更新
+	ModelQuotas        string         `json:"-" gorm:"type:text"`                                        // 旧版剩余模型配额JSON，已迁移到 SubscriptionQuota
+	UsedQuotas         string         `json:"-" gorm:"type:text"`                                        // 旧版已使用配额JSON，已迁移到 SubscriptionQuota
+	QuotaUnits         string         `json:"quota_units" gorm:"type:text"`                              // 购买时套餐的配额计量单位JSON
+	ResetPeriods       string         `json:"reset_periods" gorm:"type:text"`                            // 购买时套餐的配额重置周期JSON
+	PeriodStarts       string         `json:"-" gorm:"type:text"`                                        // 旧版各模型重置周期开始时间JSON，已迁移到 SubscriptionQuota
 	PurchasePrice      float64        `json:"purchase_price" gorm:"type:decimal(10,2)"`                  // 购买价格
+	RenewedTermStart   int64          `json:"renewed_term_start" gorm:"bigint;default:0"`                // 已提前续费的下一期开始时间，到达后才发放新一期配额，0表示没有
+	RenewedTermPrice   float64        `json:"renewed_term_price" gorm:"type:decimal(10,2);default:0"`    // 已提前续费的下一期价格
 	PaymentMethod      string         `json:"payment_method" gorm:"type:varchar(50)"`                    // 支付方式
 	PaymentId          string         `json:"payment_id" gorm:"type:varchar(100);index"`                 // 支付订单ID
+	AutoRenew          bool           `json:"auto_renew" gorm:"default:false;index"`                     // 到期前是否使用余额自动续费
+	RenewFailCount     int            `json:"renew_fail_count" gorm:"default:0"`                         // 本期连续自动续费失败次数
+	CancelAtPeriodEnd  bool           `json:"cancel_at_period_end" gorm:"default:false"`                 // 是否在到期后取消，不再续费
+	CanceledTime       int64          `json:"canceled_time" gorm:"bigint"`                               // 申请取消的时间
 	CreatedTime        int64          `json:"created_time" gorm:"bigint;autoCreateTime"`                 // 创建时间
 	UpdatedTime        int64          `json:"updated_time" gorm:"bigint;autoUpdateTime"`                 // 更新时间
 	DeletedAt          gorm.DeletedAt `gorm:"index"`                                                     // 软删除
 	
 	// 关联字段
-	User             *User             `json:"user,omitempty" gorm:"foreignKey:UserId"`
-	SubscriptionPlan *SubscriptionPlan `json:"subscription_plan,omitempty" gorm:"foreignKey:SubscriptionPlanId"`
+	User             *User                `json:"user,omitempty" gorm:"foreignKey:UserId"`
+	SubscriptionPlan *SubscriptionPlan    `json:"subscription_plan,omitempty" gorm:"foreignKey:SubscriptionPlanId"`
+	Quotas           []*SubscriptionQuota `json:"quotas,omitempty" gorm:"-"` // 各模型配额计数，通过 LoadQuotas 加载
 }
 
 // 订阅状态常量
 const (
-	SubscriptionStatusActive   = 1 // 激活
-	SubscriptionStatusExpired  = 2 // 过期
-	SubscriptionStatusCanceled = 3 // 取消
+	SubscriptionStatusActive         = 1 // 激活
+	SubscriptionStatusExpired        = 2 // 过期
+	SubscriptionStatusCanceled       = 3 // 取消
+	SubscriptionStatusPending        = 4 // 待支付
+	SubscriptionStatusPaymentExpired = 5 // 支付超时
 )
 
+// SubscriptionPaymentBalance 使用账户余额购买订阅时的支付方式
+const SubscriptionPaymentBalance = "balance"
+
+var ErrInsufficientUserQuota = errors.New("用户余额不足")
+
+// ErrSubscriptionPurchaseLimit 用户购买该套餐的次数已达上限
+var ErrSubscriptionPurchaseLimit = errors.New("已达到该套餐的购买次数上限")
+
+// ErrSubscriptionRenewSkipped 订阅已被续费、取消自动续费或状态已变化
+var ErrSubscriptionRenewSkipped = errors.New("订阅状态已变化，跳过续费")
+
 // ModelQuotaInfo 模型配额信息
 type ModelQuotaInfo struct {
-	Total     int `json:"total"`     // 总配额
-	Used      int `json:"used"`      // 已使用
-	Remaining int `json:"remaining"` // 剩余
+	Total     int    `json:"total"`     // 总配额
+	Used      int    `json:"used"`      // 已使用
+	Remaining int    `json:"remaining"` // 剩余
+	Reserved  int    `json:"reserved"`  // 进行中请求预留
+	Unit      string `json:"unit"`      // 计量单位
+	// 配置了重置周期时，以上数量均为当前周期内的配额
+	ResetPeriod string `json:"reset_period"`           // 重置周期
+	PeriodStart int64  `json:"period_start,omitempty"` // 当前周期开始时间
+	PeriodEnd   int64  `json:"period_end,omitempty"`   // 当前周期结束时间，即下次重置时间
 }
 
-// GetModelQuotasMap 获取剩余模型配额映射
-func (us *UserSubscription) GetModelQuotasMap() (ModelQuotaMap, error) {
-	if us.ModelQuotas == "" {
-		return make(ModelQuotaMap), nil
+// LoadQuotas 加载订阅的模型配额计数
+func (us *UserSubscription) LoadQuotas() error {
+	if us.Quotas != nil {
+		return nil
 	}
-	
-	var quotas ModelQuotaMap
-	err := json.Unmarshal([]byte(us.ModelQuotas), &quotas)
+	var quotas []*SubscriptionQuota
+	err := DB.Where("user_subscription_id = ?", us.Id).Find(&quotas).Error
 	if err != nil {
-		return nil, fmt.Errorf("解析剩余配额失败: %v", err)
+		return fmt.Errorf("获取订阅配额失败: %v", err)
 	}
-	return quotas, nil
+	us.Quotas = quotas
+	return nil
 }
 
-// SetModelQuotasMap 设置剩余模型配额映射
-func (us *UserSubscription) SetModelQuotasMap(quotas ModelQuotaMap) error {
-	data, err := json.Marshal(quotas)
+// loadSubscriptionQuotas 批量加载多个订阅的模型配额计数
+func loadSubscriptionQuotas(subscriptions []*UserSubscription) error {
+	if len(subscriptions) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(subscriptions))
+	byId := make(map[int]*UserSubscription, len(subscriptions))
+	for _, subscription := range subscriptions {
+		subscription.Quotas = make([]*SubscriptionQuota, 0)
+		ids = append(ids, subscription.Id)
+		byId[subscription.Id] = subscription
+	}
+	var quotas []*SubscriptionQuota
+	err := DB.Where("user_subscription_id IN ?", ids).Find(&quotas).Error
 	if err != nil {
-		return fmt.Errorf("序列化剩余配额失败: %v", err)
+		return fmt.Errorf("获取订阅配额失败: %v", err)
+	}
+	for _, quota := range quotas {
+		if subscription, ok := byId[quota.UserSubscriptionId]; ok {
+			subscription.Quotas = append(subscription.Quotas, quota)
+		}
 	}
-	us.ModelQuotas = string(data)
 	return nil
 }
 
-// GetUsedQuotasMap 获取已使用配额映射
-func (us *UserSubscription) GetUsedQuotasMap() (ModelQuotaMap, error) {
-	if us.UsedQuotas == "" {
-		return make(ModelQuotaMap), nil
+// GetQuota 获取指定模型的配额计数，套餐不包含该模型时返回 nil
+func (us *UserSubscription) GetQuota(modelName string) (*SubscriptionQuota, error) {
+	if err := us.LoadQuotas(); err != nil {
+		return nil, err
 	}
-	
-	var quotas ModelQuotaMap
-	err := json.Unmarshal([]byte(us.UsedQuotas), &quotas)
-	if err != nil {
-		return nil, fmt.Errorf("解析已使用配额失败: %v", err)
+	for _, quota := range us.Quotas {
+		if quota.ModelName == modelName {
+			return quota, nil
+		}
+	}
+	return nil, nil
+}
+
+// MatchQuotas 获取适用于模型的所有配额，按扣减顺序排列：精确匹配的模型配额优先，其次是列出该模型的模型组，
+// 最后是通配符（越具体越优先）。按顺序尝试 modelNames（例如请求的模型与映射后的模型），使用第一个有匹配配额的名称
+func (us *UserSubscription) MatchQuotas(modelNames ...string) ([]*SubscriptionQuota, error) {
+	if err := us.LoadQuotas(); err != nil {
+		return nil, err
+	}
+	type quotaMatch struct {
+		quota       *SubscriptionQuota
+		rank        int
+		specificity int
+	}
+	for _, modelName := range modelNames {
+		if modelName == "" {
+			continue
+		}
+		var matches []quotaMatch
+		for _, quota := range us.Quotas {
+			rank, specificity, ok := matchModelQuota(quota.ModelName, quota.GetModels(), modelName)
+			if ok {
+				matches = append(matches, quotaMatch{quota: quota, rank: rank, specificity: specificity})
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			return lessModelMatch(matches[i].rank, matches[i].specificity, matches[i].quota.ModelName,
+				matches[j].rank, matches[j].specificity, matches[j].quota.ModelName)
+		})
+		quotas := make([]*SubscriptionQuota, 0, len(matches))
+		for _, match := range matches {
+			quotas = append(quotas, match.quota)
+		}
+		return quotas, nil
+	}
+	return nil, nil
+}
+
+// GetModelQuotasMap 获取剩余模型配额映射
+func (us *UserSubscription) GetModelQuotasMap() (ModelQuotaMap, error) {
+	if err := us.LoadQuotas(); err != nil {
+		return nil, err
+	}
+	quotas := make(ModelQuotaMap, len(us.Quotas))
+	for _, quota := range us.Quotas {
+		quotas[quota.ModelName] = quota.Remaining()
 	}
 	return quotas, nil
 }
 
-// SetUsedQuotasMap 设置已使用配额映射
-func (us *UserSubscription) SetUsedQuotasMap(quotas ModelQuotaMap) error {
-	data, err := json.Marshal(quotas)
+// GetQuotaUnitsMap 获取配额计量单位映射，购买时未记录的使用套餐当前配置
+func (us *UserSubscription) GetQuotaUnitsMap() (QuotaUnitMap, error) {
+	if us.QuotaUnits == "" && us.SubscriptionPlan != nil {
+		return us.SubscriptionPlan.GetQuotaUnitsMap()
+	}
+	return parseQuotaUnits(us.QuotaUnits)
+}
+
+// GetResetPeriodsMap 获取配额重置周期映射，购买时未记录的使用套餐当前配置
+func (us *UserSubscription) GetResetPeriodsMap() (ResetPeriodMap, error) {
+	if us.ResetPeriods == "" && us.SubscriptionPlan != nil {
+		return us.SubscriptionPlan.GetResetPeriodsMap()
+	}
+	return parseResetPeriods(us.ResetPeriods)
+}
+
+// RefreshQuotaPeriods 进入新的重置周期时恢复对应模型的配额，返回是否有改动
+func (us *UserSubscription) RefreshQuotaPeriods(now int64) (bool, error) {
+	if us.Status != SubscriptionStatusActive || us.StartTime == 0 {
+		return false, nil
+	}
+	changed, err := us.startRenewedTerm(now)
 	if err != nil {
-		return fmt.Errorf("序列化已使用配额失败: %v", err)
+		return false, err
 	}
-	us.UsedQuotas = string(data)
-	return nil
+	if err := us.LoadQuotas(); err != nil {
+		return changed, err
+	}
+	for _, quota := range us.Quotas {
+		refreshed, err := quota.refreshPeriod(us.StartTime, now)
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || refreshed
+	}
+	return changed, nil
+}
+
+// startRenewedTerm 提前续费的下一期到达开始时间后，开始新的付费周期并按套餐发放新一期配额，返回是否有改动
+func (us *UserSubscription) startRenewedTerm(now int64) (bool, error) {
+	if us.RenewedTermStart == 0 || us.RenewedTermStart > now {
+		return false, nil
+	}
+	// 续费已经付款，套餐之后被停用或删除也照常发放
+	var plan SubscriptionPlan
+	if err := DB.Unscoped().First(&plan, us.SubscriptionPlanId).Error; err != nil {
+		return false, fmt.Errorf("获取套餐信息失败: %v", err)
+	}
+	termStart, price := us.RenewedTermStart, us.RenewedTermPrice
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&UserSubscription{}).
+			Where("id = ? AND status = ? AND renewed_term_start = ?", us.Id, SubscriptionStatusActive, termStart).
+			Updates(map[string]interface{}{
+				"term_start_time":    termStart,
+				"purchase_price":     price,
+				"renewed_term_start": 0,
+				"renewed_term_price": 0,
+				"quota_units":        plan.QuotaUnits,
+				"reset_periods":      plan.ResetPeriods,
+				"updated_time":       now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSubscriptionChanged
+		}
+		return replaceSubscriptionQuotas(tx, us, &plan, nil)
+	})
+	if errors.Is(err, ErrSubscriptionChanged) {
+		// 已由其他节点开始新的一期，重新读取订阅
+		us.Quotas = nil
+		return false, DB.First(us, us.Id).Error
+	}
+	if err != nil {
+		return false, err
+	}
+	us.TermStartTime = termStart
+	us.PurchasePrice = price
+	us.RenewedTermStart = 0
+	us.RenewedTermPrice = 0
+	us.QuotaUnits = plan.QuotaUnits
+	us.ResetPeriods = plan.ResetPeriods
+	us.Quotas = nil
+	us.UpdatedTime = now
+	return true, nil
+}
+
+// ResetSubscriptionQuotaPeriods 为所有配置了重置周期或到达提前续费周期的激活订阅重置配额，返回处理的订阅数
+func ResetSubscriptionQuotaPeriods() (int, error) {
+	var subscriptions []*UserSubscription
+	now := time.Now().Unix()
+	err := DB.Where("status = ? AND start_time <= ? AND end_time > ?", SubscriptionStatusActive, now, now).
+		Where("(reset_periods <> '' AND reset_periods <> 'null' AND reset_periods <> '{}') OR (renewed_term_start > 0 AND renewed_term_start <= ?)", now).
+		Find(&subscriptions).Error
+	if err != nil {
+		return 0, err
+	}
+	if err := loadSubscriptionQuotas(subscriptions); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, subscription := range subscriptions {
+		changed, err := subscription.RefreshQuotaPeriods(now)
+		if err != nil {
+			common.SysError(fmt.Sprintf("重置订阅 %d 周期配额失败: %v", subscription.Id, err))
+			continue
+		}
+		if changed {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetUsedQuotasMap 获取已使用配额映射
+func (us *UserSubscription) GetUsedQuotasMap() (ModelQuotaMap, error) {
+	if err := us.LoadQuotas(); err != nil {
+		return nil, err
+	}
+	quotas := make(ModelQuotaMap, len(us.Quotas))
+	for _, quota := range us.Quotas {
+		if quota.Used > 0 {
+			quotas[quota.ModelName] = quota.Used
+		}
+	}
+	return quotas, nil
 }
 
 // Insert 创建用户订阅
 func (us *UserSubscription) Insert() error {
+	return us.insert(DB)
+}
+
+// insert 在 db 中创建订阅，db 为外部事务时订阅与配额计数随外部事务一起提交
+func (us *UserSubscription) insert(db *gorm.DB) error {
 	if us.UserId == 0 {
 		return errors.New("用户ID不能为空")
 	}
@@ -105,7 +324,23 @@ func (us *UserSubscription) Insert() error {
 	
 	us.CreatedTime = time.Now().Unix()
 	us.UpdatedTime = us.CreatedTime
-	return DB.Create(us).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		return us.create(tx)
+	})
+}
+
+// create 在事务中写入订阅及其模型配额计数
+func (us *UserSubscription) create(tx *gorm.DB) error {
+	if err := tx.Create(us).Error; err != nil {
+		return err
+	}
+	if len(us.Quotas) == 0 {
+		return nil
+	}
+	for _, quota := range us.Quotas {
+		quota.UserSubscriptionId = us.Id
+	}
+	return tx.Create(&us.Quotas).Error
 }
 
 // Update 更新用户订阅
@@ -117,8 +352,6 @@ func (us *UserSubscription) Update() error {
 	us.UpdatedTime = time.Now().Unix()
 	return DB.Model(us).Updates(map[string]interface{}{
 		"status":         us.Status,
-		"model_quotas":   us.ModelQuotas,
-		"used_quotas":    us.UsedQuotas,
 		"updated_time":   us.UpdatedTime,
 	}).Error
 }
@@ -151,8 +384,21 @@ func GetActiveUserSubscriptions(userId int) ([]*UserSubscription, error) {
 		Preload("SubscriptionPlan").
 		Order("end_time ASC").
 		Find(&subscriptions).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := loadSubscriptionQuotas(subscriptions); err != nil {
+		return nil, err
+	}
 	
-	return subscriptions, err
+	// 读取时按需重置已进入新周期的配额
+	for _, subscription := range subscriptions {
+		if _, err := subscription.RefreshQuotaPeriods(now); err != nil {
+			common.SysError(fmt.Sprintf("重置订阅 %d 周期配额失败: %v", subscription.Id, err))
+		}
+	}
+	
+	return subscriptions, nil
 }
 
 // GetUserSubscriptionsByPage 分页获取用户订阅
@@ -175,11 +421,15 @@ func GetUserSubscriptionsByPage(userId int, page, pageSize int) ([]*UserSubscrip
 		Order("created_time DESC").
 		Offset(offset).Limit(pageSize).
 		Find(&subscriptions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = loadSubscriptionQuotas(subscriptions)
 	
 	return subscriptions, total, err
 }
 
-// ConsumeModelQuota 消费模型配额
+// ConsumeModelQuota 原子扣除模型配额，按 MatchQuotas 的顺序从第一个剩余充足的配额中扣除，均不足时返回错误
 func (us *UserSubscription) ConsumeModelQuota(modelName string, count int) error {
 	if count <= 0 {
 		return errors.New("消费数量必须大于0")
@@ -190,79 +440,55 @@ func (us *UserSubscription) ConsumeModelQuota(modelName string, count int) error
 		return errors.New("订阅未激活或已过期")
 	}
 	
-	// 获取剩余配额
-	remainingQuotas, err := us.GetModelQuotasMap()
-	if err != nil {
-		return err
-	}
-	
-	// 检查配额是否足够
-	remaining, exists := remainingQuotas[modelName]
-	if !exists || remaining < count {
-		return fmt.Errorf("模型 %s 配额不足，剩余: %d，需要: %d", modelName, remaining, count)
-	}
-	
-	// 更新剩余配额
-	remainingQuotas[modelName] = remaining - count
-	err = us.SetModelQuotasMap(remainingQuotas)
-	if err != nil {
-		return err
-	}
-	
-	// 更新已使用配额
-	usedQuotas, err := us.GetUsedQuotasMap()
+	quotas, err := us.MatchQuotas(modelName)
 	if err != nil {
 		return err
 	}
-	
-	if usedQuotas == nil {
-		usedQuotas = make(ModelQuotaMap)
+	if len(quotas) == 0 {
+		return fmt.Errorf("模型 %s 不在订阅套餐中", modelName)
 	}
-	
-	usedQuotas[modelName] += count
-	err = us.SetUsedQuotasMap(usedQuotas)
-	if err != nil {
-		return err
+	for _, quota := range quotas {
+		ok, err := SettleSubscriptionQuota(quota.Id, nil, count)
+		if err != nil {
+			return err
+		}
+		if ok {
+			quota.Used += count
+			return nil
+		}
 	}
-	
-	// 保存到数据库
-	return us.Update()
+	return fmt.Errorf("模型 %s 配额不足，需要: %d", modelName, count)
 }
 
-// GetModelQuotaInfo 获取指定模型的配额信息
+// GetModelQuotaInfo 获取指定配额名称（模型名称、通配符或模型组名）的配额信息
 func (us *UserSubscription) GetModelQuotaInfo(modelName string) (*ModelQuotaInfo, error) {
-	// 获取套餐信息
-	if us.SubscriptionPlan == nil {
-		plan, err := GetSubscriptionPlanById(us.SubscriptionPlanId)
-		if err != nil {
-			return nil, err
-		}
-		us.SubscriptionPlan = plan
-	}
-	
-	// 获取总配额
-	total, err := us.SubscriptionPlan.GetModelQuotaByName(modelName)
+	quota, err := us.GetQuota(modelName)
 	if err != nil {
 		return nil, err
 	}
-	
-	// 获取已使用配额
-	usedQuotas, err := us.GetUsedQuotasMap()
-	if err != nil {
-		return nil, err
+	if quota == nil {
+		// 模型不在套餐中，返回0配额
+		return &ModelQuotaInfo{Unit: SubscriptionQuotaUnitRequest, ResetPeriod: SubscriptionResetNone}, nil
 	}
 	
-	used := usedQuotas[modelName]
-	remaining := total - used
-	if remaining < 0 {
-		remaining = 0
+	quotaInfo := &ModelQuotaInfo{
+		Total:       quota.Total,
+		Used:        quota.Used,
+		Remaining:   quota.Remaining(),
+		Reserved:    quota.Reserved,
+		Unit:        quota.Unit,
+		ResetPeriod: quota.ResetPeriod,
 	}
-	
-	return &ModelQuotaInfo{
-		Total:     total,
-		Used:      used,
-		Remaining: remaining,
-	}, nil
+	if quotaInfo.ResetPeriod == "" {
+		quotaInfo.ResetPeriod = SubscriptionResetNone
+	}
+	if quotaInfo.ResetPeriod != SubscriptionResetNone && us.StartTime > 0 {
+		quotaInfo.PeriodStart, quotaInfo.PeriodEnd = GetQuotaPeriodWindow(quotaInfo.ResetPeriod, us.StartTime, time.Now().Unix())
+		if us.EndTime > 0 && quotaInfo.PeriodEnd > us.EndTime {
+			quotaInfo.PeriodEnd = us.EndTime
+		}
+	}
+	return quotaInfo, nil
 }
 
 // IsActive 检查订阅是否激活
@@ -273,6 +499,22 @@ func (us *UserSubscription) IsActive() bool {
 		   us.EndTime > now
 }
 
+// GetTermStartTime 获取当前付费周期的开始时间，旧数据未记录时使用订阅开始时间
+func (us *UserSubscription) GetTermStartTime() int64 {
+	if us.TermStartTime > 0 {
+		return us.TermStartTime
+	}
+	return us.StartTime
+}
+
+// GetTermEndTime 获取当前付费周期的结束时间，已提前续费时为下一期的开始时间
+func (us *UserSubscription) GetTermEndTime() int64 {
+	if us.RenewedTermStart > 0 {
+		return us.RenewedTermStart
+	}
+	return us.EndTime
+}
+
 // IsExpired 检查订阅是否过期
 func (us *UserSubscription) IsExpired() bool {
 	now := time.Now().Unix()
@@ -310,59 +552,415 @@ func (us *UserSubscription) GetStatusText() string {
 		return "已过期"
 	case SubscriptionStatusCanceled:
 		return "已取消"
+	case SubscriptionStatusPending:
+		return "待支付"
+	case SubscriptionStatusPaymentExpired:
+		return "支付超时"
 	default:
 		return "未知"
 	}
 }
 
-// CreateUserSubscription 创建用户订阅
-func CreateUserSubscription(userId, planId int, paymentMethod, paymentId string) (*UserSubscription, error) {
-	// 获取套餐信息
-	plan, err := GetSubscriptionPlanById(planId)
-	if err != nil {
-		return nil, fmt.Errorf("获取套餐信息失败: %v", err)
-	}
-	
-	if !plan.IsActive() {
+// newUserSubscription 根据套餐构造订阅记录，剩余配额等于套餐配额
+func newUserSubscription(userId int, plan *SubscriptionPlan, status int, paymentMethod, paymentId string) (*UserSubscription, error) {
+	// 已售出的兑换码在套餐停用后仍然可以兑换
+	if !plan.IsActive() && paymentMethod != SubscriptionPaymentRedemption {
 		return nil, errors.New("套餐未启用")
 	}
 	
-	// 获取套餐配额
-	planQuotas, err := plan.GetModelQuotasMap()
+	// 初始配额等于套餐配额，随订阅一起创建
+	quotas, err := newSubscriptionQuotas(0, userId, plan, nil)
 	if err != nil {
-		return nil, fmt.Errorf("获取套餐配额失败: %v", err)
+		return nil, err
 	}
 	
-	now := time.Now().Unix()
-	endTime := now + int64(plan.Duration*24*3600) // 转换为秒
-	
 	subscription := &UserSubscription{
 		UserId:             userId,
-		SubscriptionPlanId: planId,
-		Status:             SubscriptionStatusActive,
-		StartTime:          now,
-		EndTime:            endTime,
+		SubscriptionPlanId: plan.Id,
+		Status:             status,
 		PurchasePrice:      plan.Price,
 		PaymentMethod:      paymentMethod,
 		PaymentId:          paymentId,
+		QuotaUnits:         plan.QuotaUnits,
+		ResetPeriods:       plan.ResetPeriods,
+		Quotas:             quotas,
 	}
-	
-	// 设置初始配额（剩余配额等于套餐配额）
-	err = subscription.SetModelQuotasMap(planQuotas)
+	if paymentMethod == SubscriptionPaymentRedemption {
+		// 兑换获得的订阅没有实际支付，取消与变更套餐时不折算退款
+		subscription.PurchasePrice = 0
+	}
+	if status == SubscriptionStatusActive {
+		subscription.StartTime = time.Now().Unix()
+		subscription.TermStartTime = subscription.StartTime
+		subscription.EndTime = subscription.StartTime + int64(plan.Duration*24*3600) // 转换为秒
+	}
+	return subscription, nil
+}
+
+// CreateUserSubscription 创建用户订阅
+func CreateUserSubscription(userId, planId int, paymentMethod, paymentId string) (*UserSubscription, error) {
+	return createUserSubscription(DB, userId, planId, SubscriptionStatusActive, paymentMethod, paymentId, 0)
+}
+
+// CreatePendingUserSubscription 创建待支付的订阅，支付成功后通过 Activate 激活
+func CreatePendingUserSubscription(userId, planId int, paymentMethod, paymentId string) (*UserSubscription, error) {
+	return createUserSubscription(DB, userId, planId, SubscriptionStatusPending, paymentMethod, paymentId, 0)
+}
+
+// createUserSubscription 在 db（可以是外部事务）中创建订阅，durationDays 大于0时覆盖套餐的有效期
+func createUserSubscription(db *gorm.DB, userId, planId int, status int, paymentMethod, paymentId string, durationDays int) (*UserSubscription, error) {
+	// 获取套餐信息
+	var plan SubscriptionPlan
+	err := db.First(&plan, planId).Error
 	if err != nil {
-		return nil, fmt.Errorf("设置配额失败: %v", err)
+		return nil, fmt.Errorf("获取套餐信息失败: %v", err)
 	}
 	
-	// 初始化已使用配额为空
-	err = subscription.SetUsedQuotasMap(make(ModelQuotaMap))
+	subscription, err := newUserSubscription(userId, &plan, status, paymentMethod, paymentId)
 	if err != nil {
-		return nil, fmt.Errorf("初始化已使用配额失败: %v", err)
+		return nil, err
+	}
+	if durationDays > 0 && status == SubscriptionStatusActive {
+		subscription.EndTime = subscription.StartTime + int64(durationDays*24*3600)
 	}
 	
-	err = subscription.Insert()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 兑换码由管理员发放，不计入限购；待支付的订单占用限购次数直到支付超时
+		if paymentMethod != SubscriptionPaymentRedemption {
+			if err := checkPlanPurchaseLimit(tx, userId, &plan, 0); err != nil {
+				return err
+			}
+		}
+		return subscription.insert(tx)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("创建订阅失败: %v", err)
+		return nil, fmt.Errorf("创建订阅失败: %w", err)
 	}
+	subscription.SubscriptionPlan = &plan
 	
 	return subscription, nil
 }
+
+// CreateUserSubscriptionWithQuota 使用账户余额购买订阅，扣除额度与创建订阅在同一事务中完成
+func CreateUserSubscriptionWithQuota(userId, planId int, quota int, paymentId string) (*UserSubscription, error) {
+	if quota < 0 {
+		return nil, errors.New("quota 不能为负数！")
+	}
+	plan, err := GetSubscriptionPlanById(planId)
+	if err != nil {
+		return nil, fmt.Errorf("获取套餐信息失败: %v", err)
+	}
+	subscription, err := newUserSubscription(userId, plan, SubscriptionStatusActive, SubscriptionPaymentBalance, paymentId)
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkPlanPurchaseLimit(tx, userId, plan, 0); err != nil {
+			return err
+		}
+		result := tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, quota).
+			Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInsufficientUserQuota
+		}
+		subscription.CreatedTime = time.Now().Unix()
+		subscription.UpdatedTime = subscription.CreatedTime
+		return subscription.create(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cacheDecrUserQuota(userId, int64(quota)); err != nil {
+		common.SysError("failed to decrease user quota: " + err.Error())
+	}
+	return subscription, nil
+}
+
+// GetUserSubscriptionByPaymentId 根据支付订单号获取订阅
+func GetUserSubscriptionByPaymentId(paymentId string) (*UserSubscription, error) {
+	if paymentId == "" {
+		return nil, errors.New("无效的支付订单号")
+	}
+	
+	var subscription UserSubscription
+	err := DB.Preload("SubscriptionPlan").Where("payment_id = ?", paymentId).First(&subscription).Error
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// Activate 支付成功后激活订阅，有效期从激活时开始计算。
+// 已因超时标记为支付超时的订单在收到支付成功回调后仍会被激活，但用户可能在超时后重新下单并同时付款，
+// 因此在激活事务中重新检查限购：超出时订单不激活，转为已取消并生成待原路退款的记录，返回 ErrSubscriptionPurchaseLimit。
+// 订阅已被处理过时返回 false
+func (us *UserSubscription) Activate() (bool, error) {
+	if us.SubscriptionPlan == nil {
+		plan, err := GetSubscriptionPlanById(us.SubscriptionPlanId)
+		if err != nil {
+			return false, err
+		}
+		us.SubscriptionPlan = plan
+	}
+	now := time.Now().Unix()
+	endTime := now + int64(us.SubscriptionPlan.Duration*24*3600)
+	activated, overLimit := false, false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		limitErr := checkPlanPurchaseLimit(tx, us.UserId, us.SubscriptionPlan, us.Id)
+		if limitErr != nil && !errors.Is(limitErr, ErrSubscriptionPurchaseLimit) {
+			return limitErr
+		}
+		updates := map[string]interface{}{
+			"status":          SubscriptionStatusActive,
+			"start_time":      now,
+			"term_start_time": now,
+			"end_time":        endTime,
+			"updated_time":    now,
+		}
+		if limitErr != nil {
+			updates = map[string]interface{}{
+				"status":        SubscriptionStatusCanceled,
+				"canceled_time": now,
+				"updated_time":  now,
+			}
+		}
+		result := tx.Model(&UserSubscription{}).
+			Where("id = ? AND status IN ?", us.Id, []int{SubscriptionStatusPending, SubscriptionStatusPaymentExpired}).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if limitErr == nil {
+			activated = true
+			return nil
+		}
+		overLimit = true
+		return tx.Create(&SubscriptionCancellation{
+			UserId:             us.UserId,
+			UserSubscriptionId: us.Id,
+			PlanId:             us.SubscriptionPlanId,
+			PlanName:           us.SubscriptionPlan.Name,
+			Mode:               SubscriptionCancelPurchaseLimit,
+			Reason:             "付款时已超出套餐限购次数，订单未激活",
+			RefundMethod:       SubscriptionRefundProvider,
+			RefundMoney:        us.PurchasePrice,
+			RefundStatus:       SubscriptionRefundStatusPending,
+			CreatedTime:        now,
+		}).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	if overLimit {
+		us.Status = SubscriptionStatusCanceled
+		us.CanceledTime = now
+		us.UpdatedTime = now
+		return false, ErrSubscriptionPurchaseLimit
+	}
+	if !activated {
+		return false, nil
+	}
+	us.Status = SubscriptionStatusActive
+	us.StartTime = now
+	us.TermStartTime = now
+	us.EndTime = endTime
+	us.UpdatedTime = now
+	return true, nil
+}
+
+// ExpirePendingSubscriptions 将创建时间早于 before 仍未支付的订阅标记为支付超时
+func ExpirePendingSubscriptions(before int64) (int64, error) {
+	result := DB.Model(&UserSubscription{}).
+		Where("status = ? AND created_time < ?", SubscriptionStatusPending, before).
+		Updates(map[string]interface{}{
+			"status":       SubscriptionStatusPaymentExpired,
+			"updated_time": time.Now().Unix(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// SetUserSubscriptionAutoRenew 开启或关闭用户自己的激活订阅的自动续费，同时清零续费失败次数。
+// 已设置到期取消的订阅需要先撤销取消才能开启自动续费
+func SetUserSubscriptionAutoRenew(id int, userId int, autoRenew bool) error {
+	query := DB.Model(&UserSubscription{}).
+		Where("id = ? AND user_id = ? AND status = ?", id, userId, SubscriptionStatusActive)
+	if autoRenew {
+		trialPlans := DB.Model(&SubscriptionPlan{}).Select("id").Where("is_trial = ?", true)
+		query = query.Where("cancel_at_period_end = ?", false).
+			Where("subscription_plan_id NOT IN (?)", trialPlans)
+	}
+	result := query.Updates(map[string]interface{}{
+		"auto_renew":       autoRenew,
+		"renew_fail_count": 0,
+		"updated_time":     time.Now().Unix(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if autoRenew {
+			return errors.New("订阅不存在、未激活、已设置到期取消或为试用套餐")
+		}
+		return errors.New("订阅不存在或未激活")
+	}
+	return nil
+}
+
+// CountUserPlanPurchases 统计用户购买过指定套餐的订阅数量，包括待支付、已取消、已删除以及之后变更为其他套餐的订阅，
+// 支付超时的订单以及因超出限购未激活而退款的订单不计入
+func CountUserPlanPurchases(userId, planId int) (int, error) {
+	return countUserPlanPurchases(DB, userId, planId, 0)
+}
+
+// countUserPlanPurchases 统计购买次数，excludeId 为正在激活的订单，不计入统计
+func countUserPlanPurchases(db *gorm.DB, userId, planId int, excludeId int) (int, error) {
+	var subscriptionIds []int
+	overLimitIds := db.Model(&SubscriptionCancellation{}).Select("user_subscription_id").
+		Where("user_id = ? AND mode = ?", userId, SubscriptionCancelPurchaseLimit)
+	err := db.Unscoped().Model(&UserSubscription{}).
+		Where("user_id = ? AND subscription_plan_id = ? AND status <> ? AND id <> ?", userId, planId, SubscriptionStatusPaymentExpired, excludeId).
+		Where("id NOT IN (?)", overLimitIds).
+		Pluck("id", &subscriptionIds).Error
+	if err != nil {
+		return 0, err
+	}
+	var changedIds []int
+	err = db.Model(&SubscriptionPlanChange{}).
+		Where("user_id = ? AND (from_plan_id = ? OR to_plan_id = ?)", userId, planId, planId).
+		Pluck("user_subscription_id", &changedIds).Error
+	if err != nil {
+		return 0, err
+	}
+	purchased := make(map[int]struct{}, len(subscriptionIds)+len(changedIds))
+	for _, id := range subscriptionIds {
+		purchased[id] = struct{}{}
+	}
+	for _, id := range changedIds {
+		purchased[id] = struct{}{}
+	}
+	return len(purchased), nil
+}
+
+// checkPlanPurchaseLimit 在下单事务中锁定用户行后重新统计购买次数，同一用户的并发下单（包括跨节点）
+// 会在此排队，达到限购次数时返回 ErrSubscriptionPurchaseLimit。SQLite 不支持行锁，由其写事务串行执行保证。
+// excludeId 为正在激活的订单，新下单时传 0
+func checkPlanPurchaseLimit(tx *gorm.DB, userId int, plan *SubscriptionPlan, excludeId int) error {
+	limit := plan.GetPurchaseLimit()
+	if limit <= 0 {
+		return nil
+	}
+	var user User
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userId).Error
+	if err != nil {
+		return err
+	}
+	purchased, err := countUserPlanPurchases(tx, userId, plan.Id, excludeId)
+	if err != nil {
+		return err
+	}
+	if purchased >= limit {
+		return ErrSubscriptionPurchaseLimit
+	}
+	return nil
+}
+
+// GetSubscriptionsDueForRenewal 获取开启了自动续费且将在 before 之前到期的激活订阅
+func GetSubscriptionsDueForRenewal(before int64) ([]*UserSubscription, error) {
+	var subscriptions []*UserSubscription
+	err := DB.Preload("SubscriptionPlan").
+		Where("status = ? AND auto_renew = ? AND end_time > ? AND end_time <= ?",
+			SubscriptionStatusActive, true, time.Now().Unix(), before).
+		Order("end_time ASC").
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// RenewWithQuota 使用账户余额续费订阅：扣除额度并将到期时间顺延一个套餐周期。
+// 续费通常在到期前提前进行，新一期的配额与付费周期在原到期时间到达后才开始（见 startRenewedTerm），
+// 本期剩余配额在此之前仍可使用。扣费与续期在同一事务中完成，订阅已被其他节点续费或关闭自动续费时返回 ErrSubscriptionRenewSkipped
+func (us *UserSubscription) RenewWithQuota(quota int) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	if us.SubscriptionPlan == nil {
+		plan, err := GetSubscriptionPlanById(us.SubscriptionPlanId)
+		if err != nil {
+			return fmt.Errorf("获取套餐信息失败: %v", err)
+		}
+		us.SubscriptionPlan = plan
+	}
+	plan := us.SubscriptionPlan
+	if !plan.IsActive() {
+		return errors.New("套餐未启用")
+	}
+	now := time.Now().Unix()
+	// 上一次提前续费的周期尚未开始时不能再次续费
+	if _, err := us.startRenewedTerm(now); err != nil {
+		return err
+	}
+	if us.RenewedTermStart > 0 {
+		return ErrSubscriptionRenewSkipped
+	}
+	endTime := us.EndTime + int64(plan.Duration*24*3600)
+	updates := map[string]interface{}{
+		"end_time":           endTime,
+		"renewed_term_start": us.EndTime,
+		"renewed_term_price": plan.Price,
+		"renew_fail_count":   0,
+		"updated_time":       now,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&UserSubscription{}).
+			Where("id = ? AND status = ? AND auto_renew = ? AND end_time = ? AND renewed_term_start = ?",
+				us.Id, SubscriptionStatusActive, true, us.EndTime, 0).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSubscriptionRenewSkipped
+		}
+		result = tx.Model(&User{}).Where("id = ? AND quota >= ?", us.UserId, quota).
+			Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInsufficientUserQuota
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := cacheDecrUserQuota(us.UserId, int64(quota)); err != nil {
+		common.SysError("failed to decrease user quota: " + err.Error())
+	}
+	us.RenewedTermStart = us.EndTime
+	us.RenewedTermPrice = plan.Price
+	us.EndTime = endTime
+	us.RenewFailCount = 0
+	us.UpdatedTime = now
+	// 已经到达原到期时间时立即开始新的一期
+	if _, err := us.startRenewedTerm(now); err != nil {
+		common.SysError(fmt.Sprintf("订阅 %d 开始续费周期失败: %v", us.Id, err))
+	}
+	return nil
+}
+
+// IncreaseRenewFailCount 记录一次自动续费失败，返回本期累计失败次数
+func (us *UserSubscription) IncreaseRenewFailCount() (int, error) {
+	err := DB.Model(&UserSubscription{}).Where("id = ?", us.Id).
+		Update("renew_fail_count", gorm.Expr("renew_fail_count + 1")).Error
+	if err != nil {
+		return us.RenewFailCount, err
+	}
+	us.RenewFailCount++
+	return us.RenewFailCount, nil
+}
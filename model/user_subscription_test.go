@@ -0,0 +1,183 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTestDB 使用内存 SQLite 作为数据库，并关闭 Redis 缓存
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	// 内存数据库每个连接相互独立，只保留一个连接
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	err = db.AutoMigrate(&User{}, &Log{}, &SubscriptionPlan{}, &UserSubscription{}, &SubscriptionQuota{},
+		&SubscriptionQuotaReservation{}, &SubscriptionPlanChange{}, &SubscriptionCancellation{})
+	if err != nil {
+		t.Fatalf("migrate test db: %v", err)
+	}
+	redisEnabled := common.RedisEnabled
+	common.RedisEnabled = false
+	DB, LOG_DB = db, db
+	t.Cleanup(func() { common.RedisEnabled = redisEnabled })
+}
+
+// createTestUser 创建一个余额为 quota 的用户
+func createTestUser(t *testing.T, quota int) *User {
+	t.Helper()
+	user := &User{Username: "tester", Quota: quota, Status: common.UserStatusEnabled}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// createTestPlan 创建一个价格 10 元、有效期 30 天的套餐，modelQuotas 为套餐配额JSON
+func createTestPlan(t *testing.T, modelQuotas string, purchaseLimit int) *SubscriptionPlan {
+	t.Helper()
+	plan := &SubscriptionPlan{Name: "plan", Price: 10, Duration: 30, Status: 1, ModelQuotas: modelQuotas, PurchaseLimit: purchaseLimit}
+	if err := DB.Create(plan).Error; err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	return plan
+}
+
+// createTestSubscription 创建一个用户及其生效中的订阅
+func createTestSubscription(t *testing.T, quota int, modelQuotas string) *UserSubscription {
+	t.Helper()
+	user := createTestUser(t, quota)
+	plan := createTestPlan(t, modelQuotas, 0)
+	us, err := CreateUserSubscription(user.Id, plan.Id, SubscriptionPaymentBalance, "")
+	if err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	if err := us.LoadQuotas(); err != nil {
+		t.Fatalf("load quotas: %v", err)
+	}
+	return us
+}
+
+func TestRenewWithQuotaStartsTermAtBoundary(t *testing.T) {
+	setupTestDB(t)
+	us := createTestSubscription(t, 1000, `{"gpt-4":100}`)
+	if err := us.ConsumeModelQuota("gpt-4", 40); err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	if err := DB.Model(&UserSubscription{}).Where("id = ?", us.Id).Update("auto_renew", true).Error; err != nil {
+		t.Fatalf("enable auto renew: %v", err)
+	}
+	us.AutoRenew = true
+	termStart, oldEnd := us.GetTermStartTime(), us.EndTime
+
+	if err := us.RenewWithQuota(300); err != nil {
+		t.Fatalf("renew: %v", err)
+	}
+	user, err := GetUserById(us.UserId, false)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if user.Quota != 700 {
+		t.Fatalf("renewal payment should be taken immediately, quota = %d", user.Quota)
+	}
+	if us.EndTime != oldEnd+30*24*3600 || us.RenewedTermStart != oldEnd || us.RenewedTermPrice != 10 {
+		t.Fatalf("unexpected renewal state: end=%d renewed=%d/%v", us.EndTime, us.RenewedTermStart, us.RenewedTermPrice)
+	}
+	if us.GetTermStartTime() != termStart || us.GetTermEndTime() != oldEnd {
+		t.Fatalf("current term should not change before the boundary")
+	}
+	if err := us.RenewWithQuota(300); err != ErrSubscriptionRenewSkipped {
+		t.Fatalf("second renewal before the boundary should be skipped, got %v", err)
+	}
+
+	// 到期前刷新不应发放新一期配额
+	if _, err := us.RefreshQuotaPeriods(oldEnd - 1); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	quota, err := us.GetQuota("gpt-4")
+	if err != nil || quota == nil || quota.Used != 40 {
+		t.Fatalf("current term usage should be kept before the boundary, got %+v %v", quota, err)
+	}
+
+	// 到达原到期时间后开始新的一期
+	changed, err := us.RefreshQuotaPeriods(oldEnd)
+	if err != nil || !changed {
+		t.Fatalf("refresh at boundary: changed=%v err=%v", changed, err)
+	}
+	quota, err = us.GetQuota("gpt-4")
+	if err != nil || quota == nil || quota.Used != 0 || quota.Total != 100 {
+		t.Fatalf("new term quota should be granted at the boundary, got %+v %v", quota, err)
+	}
+	stored, err := GetUserSubscriptionById(us.Id)
+	if err != nil {
+		t.Fatalf("get subscription: %v", err)
+	}
+	if stored.TermStartTime != oldEnd || stored.RenewedTermStart != 0 || stored.RenewedTermPrice != 0 || stored.PurchasePrice != 10 {
+		t.Fatalf("unexpected stored term: %+v", stored)
+	}
+	if changed, err := us.RefreshQuotaPeriods(oldEnd + 1); err != nil || changed {
+		t.Fatalf("renewed term should start only once: changed=%v err=%v", changed, err)
+	}
+}
+
+func TestActivateRechecksPurchaseLimit(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, 0)
+	plan := createTestPlan(t, `{"gpt-4":100}`, 1)
+
+	// 第一笔订单支付超时后重新下单，两笔订单随后都付款
+	expired, err := CreatePendingUserSubscription(user.Id, plan.Id, "alipay", "SUB-1")
+	if err != nil {
+		t.Fatalf("create first order: %v", err)
+	}
+	if _, err := ExpirePendingSubscriptions(time.Now().Unix() + 1); err != nil {
+		t.Fatalf("expire order: %v", err)
+	}
+	pending, err := CreatePendingUserSubscription(user.Id, plan.Id, "alipay", "SUB-2")
+	if err != nil {
+		t.Fatalf("create second order: %v", err)
+	}
+	if ok, err := pending.Activate(); err != nil || !ok {
+		t.Fatalf("activate second order: ok=%v err=%v", ok, err)
+	}
+
+	if ok, err := expired.Activate(); ok || !errors.Is(err, ErrSubscriptionPurchaseLimit) {
+		t.Fatalf("activating over the limit should fail, ok=%v err=%v", ok, err)
+	}
+	stored, err := GetUserSubscriptionById(expired.Id)
+	if err != nil {
+		t.Fatalf("get subscription: %v", err)
+	}
+	if stored.Status != SubscriptionStatusCanceled {
+		t.Fatalf("over-limit order should be canceled, status = %d", stored.Status)
+	}
+	var records []*SubscriptionCancellation
+	if err := DB.Where("user_subscription_id = ?", expired.Id).Find(&records).Error; err != nil {
+		t.Fatalf("get cancellations: %v", err)
+	}
+	if len(records) != 1 || records[0].Mode != SubscriptionCancelPurchaseLimit ||
+		records[0].RefundStatus != SubscriptionRefundStatusPending || records[0].RefundMoney != 10 {
+		t.Fatalf("expected one pending provider refund, got %+v", records)
+	}
+	purchased, err := CountUserPlanPurchases(user.Id, plan.Id)
+	if err != nil || purchased != 1 {
+		t.Fatalf("refunded order should not count as a purchase: purchased=%d err=%v", purchased, err)
+	}
+
+	// 重复的支付回调不会再次生成退款记录
+	if ok, err := expired.Activate(); ok || err != nil {
+		t.Fatalf("duplicate callback should be ignored, ok=%v err=%v", ok, err)
+	}
+}
@@ -45,3 +45,11 @@ type TaskAdaptor interface {
 	// FetchTask
 	FetchTask(baseUrl, key string, body map[string]any) (*http.Response, error)
 }
+
+// VideoTaskAdaptor 视频生成任务的适配器，任务逐个查询，FetchTask 的 body 包含 task_id 与 action
+type VideoTaskAdaptor interface {
+	TaskAdaptor
+
+	// ParseTaskResult 把 FetchTask 返回的响应解析为统一的任务结果
+	ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error)
+}
@@ -26,6 +26,28 @@ func oaiImage2Ali(request dto.ImageRequest) *AliImageRequest {
 	return &imageRequest
 }
 
+func oaiImageEdit2Ali(request dto.ImageRequest) (*AliImageEditRequest, error) {
+	if len(request.Images) != 1 {
+		return nil, errors.New("ali image edit requires exactly one image")
+	}
+	var imageRequest AliImageEditRequest
+	imageRequest.Model = request.Model
+	imageRequest.Input.Prompt = request.Prompt
+	imageRequest.Input.BaseImageUrl = request.Images[0].DataUrl()
+	imageRequest.Parameters.N = request.N
+	if request.Mask != nil {
+		// 局部重绘：蒙版中需要修改的区域
+		imageRequest.Input.Function = "description_edit_with_mask"
+		imageRequest.Input.MaskImageUrl = request.Mask.DataUrl()
+	} else {
+		imageRequest.Input.Function = "description_edit"
+	}
+	if imageRequest.Input.Prompt == "" {
+		imageRequest.Input.Prompt = "Generate a variation of this image"
+	}
+	return &imageRequest, nil
+}
+
 func updateTask(info *relaycommon.RelayInfo, taskID string) (*AliResponse, error, []byte) {
 	url := fmt.Sprintf("%s/api/v1/tasks/%s", info.BaseUrl, taskID)
 
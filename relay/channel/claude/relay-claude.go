@@ -62,6 +62,27 @@ func RequestOpenAI2ClaudeComplete(textRequest dto.GeneralOpenAIRequest) *dto.Cla
 	return &claudeRequest
 }
 
+// toolChoiceOpenAI2Claude 将 OpenAI 的 tool_choice 转换为 Claude 格式
+func toolChoiceOpenAI2Claude(toolChoice any) any {
+	switch choice := toolChoice.(type) {
+	case string:
+		switch choice {
+		case "none":
+			return map[string]any{"type": "none"}
+		case "required":
+			return map[string]any{"type": "any"}
+		}
+		return map[string]any{"type": "auto"}
+	case map[string]any:
+		if function, ok := choice["function"].(map[string]any); ok {
+			if name, ok := function["name"].(string); ok && name != "" {
+				return map[string]any{"type": "tool", "name": name}
+			}
+		}
+	}
+	return map[string]any{"type": "auto"}
+}
+
 func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*dto.ClaudeRequest, error) {
 	claudeTools := make([]dto.Tool, 0, len(textRequest.Tools))
 
@@ -122,6 +143,16 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*dto.Cla
 		claudeRequest.Model = strings.TrimSuffix(textRequest.Model, "-thinking")
 	}
 
+	if textRequest.ToolChoice != nil && len(claudeTools) > 0 {
+		claudeRequest.ToolChoice = toolChoiceOpenAI2Claude(textRequest.ToolChoice)
+		// 启用 thinking 时 Claude 只允许 auto / none
+		if claudeRequest.Thinking != nil {
+			if toolChoice, ok := claudeRequest.ToolChoice.(map[string]any); ok && toolChoice["type"] != "none" {
+				claudeRequest.ToolChoice = map[string]any{"type": "auto"}
+			}
+		}
+	}
+
 	if textRequest.Stop != nil {
 		// stop maybe string/array string, convert to array string
 		switch textRequest.Stop.(type) {
@@ -34,7 +34,11 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
 	if !strings.HasPrefix(info.UpstreamModelName, "imagen") {
-		return nil, errors.New("not supported model for image generation")
+		// gemini 图像输出模型通过 generateContent 生成或编辑图片
+		return convertImageRequest2GeminiChat(request, info)
+	}
+	if info.RelayMode != constant.RelayModeImagesGenerations {
+		return nil, errors.New("imagen models only support image generation")
 	}
 
 	// convert size to aspect ratio
@@ -178,6 +182,10 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		return GeminiImageHandler(c, resp, info)
 	}
 
+	if isImageRelayMode(info.RelayMode) {
+		return GeminiChatImageHandler(c, resp, info)
+	}
+
 	// check if the model is an embedding model
 	if strings.HasPrefix(info.UpstreamModelName, "text-embedding") ||
 		strings.HasPrefix(info.UpstreamModelName, "embedding") ||
@@ -266,3 +274,111 @@ func (a *Adaptor) GetModelList() []string {
 func (a *Adaptor) GetChannelName() string {
 	return ChannelName
 }
+
+func isImageRelayMode(relayMode int) bool {
+	return relayMode == constant.RelayModeImagesGenerations ||
+		relayMode == constant.RelayModeImagesEdits ||
+		relayMode == constant.RelayModeImagesVariations
+}
+
+// convertImageRequest2GeminiChat 将图像生成 / 编辑 / 变体请求转换为 gemini 图像输出模型的 generateContent 请求
+func convertImageRequest2GeminiChat(request dto.ImageRequest, info *relaycommon.RelayInfo) (*GeminiChatRequest, error) {
+	prompt := request.Prompt
+	if info.RelayMode == constant.RelayModeImagesVariations && prompt == "" {
+		prompt = "Generate a variation of this image, keeping its subject and style."
+	}
+	parts := []GeminiPart{
+		{
+			Text: prompt,
+		},
+	}
+	for _, image := range request.Images {
+		parts = append(parts, GeminiPart{
+			InlineData: &GeminiInlineData{
+				MimeType: image.MimeType,
+				Data:     image.Base64(),
+			},
+		})
+	}
+	// gemini 不支持蒙版参数，作为附加图片传入并说明其含义
+	if request.Mask != nil {
+		parts = append(parts, GeminiPart{
+			Text: "The next image is a mask: only edit the areas that are transparent in the mask, keep everything else unchanged.",
+		}, GeminiPart{
+			InlineData: &GeminiInlineData{
+				MimeType: request.Mask.MimeType,
+				Data:     request.Mask.Base64(),
+			},
+		})
+	}
+	geminiRequest := &GeminiChatRequest{
+		Contents: []GeminiChatContent{
+			{
+				Role:  "user",
+				Parts: parts,
+			},
+		},
+		GenerationConfig: GeminiChatGenerationConfig{
+			ResponseModalities: []string{"TEXT", "IMAGE"},
+		},
+	}
+	if request.N > 1 {
+		geminiRequest.GenerationConfig.CandidateCount = request.N
+	}
+	return geminiRequest, nil
+}
+
+// GeminiChatImageHandler 从 generateContent 响应中提取图片，转换为 OpenAI 图像响应格式
+func GeminiChatImageHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *dto.OpenAIErrorWithStatusCode) {
+	responseBody, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return nil, service.OpenAIErrorWrapper(readErr, "read_response_body_failed", http.StatusInternalServerError)
+	}
+	_ = resp.Body.Close()
+
+	var geminiResponse GeminiChatResponse
+	if jsonErr := json.Unmarshal(responseBody, &geminiResponse); jsonErr != nil {
+		return nil, service.OpenAIErrorWrapper(jsonErr, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+
+	openAIResponse := dto.ImageResponse{
+		Created: common.GetTimestamp(),
+		Data:    make([]dto.ImageData, 0),
+	}
+	for _, candidate := range geminiResponse.Candidates {
+		var revisedPrompt string
+		for _, part := range candidate.Content.Parts {
+			if part.Text != "" && !part.Thought {
+				revisedPrompt += part.Text
+			}
+		}
+		for _, part := range candidate.Content.Parts {
+			if part.InlineData == nil || !strings.HasPrefix(part.InlineData.MimeType, "image/") {
+				continue
+			}
+			openAIResponse.Data = append(openAIResponse.Data, dto.ImageData{
+				B64Json:       part.InlineData.Data,
+				RevisedPrompt: revisedPrompt,
+			})
+		}
+	}
+	if len(openAIResponse.Data) == 0 {
+		return nil, service.OpenAIErrorWrapper(errors.New("no images generated"), "no_images", http.StatusBadRequest)
+	}
+
+	jsonResponse, jsonErr := json.Marshal(openAIResponse)
+	if jsonErr != nil {
+		return nil, service.OpenAIErrorWrapper(jsonErr, "marshal_response_failed", http.StatusInternalServerError)
+	}
+
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, _ = c.Writer.Write(jsonResponse)
+
+	usage = &dto.Usage{
+		PromptTokens:     geminiResponse.UsageMetadata.PromptTokenCount,
+		CompletionTokens: geminiResponse.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      geminiResponse.UsageMetadata.TotalTokenCount,
+	}
+	return usage, nil
+}
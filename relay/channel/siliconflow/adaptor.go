@@ -29,8 +29,21 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	sfRequest := &SFImageRequest{
+		Model:     request.Model,
+		Prompt:    request.Prompt,
+		ImageSize: request.Size,
+		BatchSize: request.N,
+	}
+	if request.Mask != nil {
+		return nil, errors.New("mask is not supported by siliconflow")
+	}
+	// 图生图模型只接受一张参考图
+	if len(request.Images) > 0 {
+		sfRequest.Image = request.Images[0].DataUrl()
+	}
+	c.Set("response_format", request.ResponseFormat)
+	return sfRequest, nil
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
@@ -45,6 +58,10 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 		return fmt.Sprintf("%s/v1/chat/completions", info.BaseUrl), nil
 	} else if info.RelayMode == constant.RelayModeCompletions {
 		return fmt.Sprintf("%s/v1/completions", info.BaseUrl), nil
+	} else if info.RelayMode == constant.RelayModeImagesGenerations ||
+		info.RelayMode == constant.RelayModeImagesEdits ||
+		info.RelayMode == constant.RelayModeImagesVariations {
+		return fmt.Sprintf("%s/v1/images/generations", info.BaseUrl), nil
 	}
 	return "", errors.New("invalid relay mode")
 }
@@ -90,6 +107,8 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		}
 	case constant.RelayModeEmbeddings:
 		err, usage = openai.OpenaiHandler(c, resp, info)
+	case constant.RelayModeImagesGenerations, constant.RelayModeImagesEdits, constant.RelayModeImagesVariations:
+		err, usage = siliconflowImageHandler(c, resp, info)
 	}
 	return
 }
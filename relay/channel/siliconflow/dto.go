@@ -15,3 +15,22 @@ type SFRerankResponse struct {
 	Results []dto.RerankResponseResult `json:"results"`
 	Meta    SFMeta                     `json:"meta"`
 }
+
+type SFImageRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	ImageSize      string `json:"image_size,omitempty"`
+	BatchSize      int    `json:"batch_size,omitempty"`
+	Seed           int64  `json:"seed,omitempty"`
+	Image          string `json:"image,omitempty"`
+}
+
+type SFImage struct {
+	Url string `json:"url"`
+}
+
+type SFImageResponse struct {
+	Images []SFImage `json:"images"`
+	Seed   int64     `json:"seed"`
+}
@@ -5,7 +5,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"io"
 	"net/http"
+	"one-api/common"
 	"one-api/dto"
+	relaycommon "one-api/relay/common"
 	"one-api/service"
 )
 
@@ -42,3 +44,48 @@ func siliconflowRerankHandler(c *gin.Context, resp *http.Response) (*dto.OpenAIE
 	_, err = c.Writer.Write(jsonResponse)
 	return nil, usage
 }
+
+func siliconflowImageHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	var siliconflowResp SFImageResponse
+	err = json.Unmarshal(responseBody, &siliconflowResp)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	responseFormat := c.GetString("response_format")
+	imageResponse := dto.ImageResponse{
+		Created: info.StartTime.Unix(),
+		Data:    make([]dto.ImageData, 0, len(siliconflowResp.Images)),
+	}
+	for _, image := range siliconflowResp.Images {
+		imageData := dto.ImageData{
+			Url: image.Url,
+		}
+		// siliconflow 只返回临时链接，b64_json 需要下载转换
+		if responseFormat == "b64_json" {
+			_, b64, err := service.GetImageFromUrl(image.Url)
+			if err != nil {
+				common.LogError(c, "get_image_data_failed: "+err.Error())
+				continue
+			}
+			imageData.B64Json = b64
+		}
+		imageResponse.Data = append(imageResponse.Data, imageData)
+	}
+
+	jsonResponse, err := json.Marshal(imageResponse)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, _ = c.Writer.Write(jsonResponse)
+	return nil, &dto.Usage{}
+}
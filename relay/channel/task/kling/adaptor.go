@@ -0,0 +1,176 @@
+package kling
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+type TaskAdaptor struct {
+	ChannelType int
+}
+
+func (a *TaskAdaptor) Init(info *relaycommon.TaskRelayInfo) {
+	a.ChannelType = info.ChannelType
+}
+
+func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.TaskRelayInfo) *dto.TaskError {
+	taskErr := channel.ValidateVideoRequest(c, info, 5)
+	if taskErr != nil {
+		return taskErr
+	}
+	videoRequest, _ := channel.GetVideoRequest(c)
+	// 只支持 5 秒与 10 秒
+	if videoRequest.Duration > 5 {
+		videoRequest.Duration = 10
+	} else {
+		videoRequest.Duration = 5
+	}
+	return nil
+}
+
+func actionPath(action string) string {
+	if action == constant.TaskActionGenerate {
+		return "image2video"
+	}
+	return "text2video"
+}
+
+func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.TaskRelayInfo) (string, error) {
+	return fmt.Sprintf("%s/v1/videos/%s", info.BaseUrl, actionPath(info.Action)), nil
+}
+
+func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info *relaycommon.TaskRelayInfo) error {
+	token, err := createToken(info.ApiKey)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.TaskRelayInfo) (io.Reader, error) {
+	videoRequest, err := channel.GetVideoRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	payload := requestPayload{
+		ModelName:      info.UpstreamModelName,
+		Prompt:         videoRequest.Prompt,
+		NegativePrompt: videoRequest.NegativePrompt,
+		Image:          videoRequest.Image,
+		Mode:           "std",
+		AspectRatio:    videoRequest.AspectRatio,
+		Duration:       fmt.Sprintf("%d", videoRequest.Duration),
+	}
+	if payload.AspectRatio == "" && payload.Image == "" {
+		payload.AspectRatio = "16:9"
+	}
+	return channel.BuildVideoRequestBody(payload, videoRequest.Metadata)
+}
+
+func (a *TaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.TaskRelayInfo, requestBody io.Reader) (*http.Response, error) {
+	return channel.DoTaskApiRequest(a, c, info, requestBody)
+}
+
+func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.TaskRelayInfo) (taskID string, taskData []byte, taskErr *dto.TaskError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	var klingResponse responsePayload
+	err = json.Unmarshal(responseBody, &klingResponse)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	if klingResponse.Code != 0 || klingResponse.Data.TaskId == "" {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("%s", klingResponse.Message), fmt.Sprintf("%d", klingResponse.Code), http.StatusInternalServerError)
+		return
+	}
+	channel.WriteVideoSubmitResponse(c, info, klingResponse.Data.TaskId)
+	return klingResponse.Data.TaskId, responseBody, nil
+}
+
+func (a *TaskAdaptor) GetModelList() []string {
+	return ModelList
+}
+
+func (a *TaskAdaptor) GetChannelName() string {
+	return ChannelName
+}
+
+func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any) (*http.Response, error) {
+	taskId, _ := body["task_id"].(string)
+	action, _ := body["action"].(string)
+	token, err := createToken(key)
+	if err != nil {
+		return nil, err
+	}
+	requestUrl := fmt.Sprintf("%s/v1/videos/%s/%s", baseUrl, actionPath(action), taskId)
+	return channel.DoVideoFetchRequest(http.MethodGet, requestUrl, nil, map[string]string{
+		"Accept":        "application/json",
+		"Authorization": "Bearer " + token,
+	})
+}
+
+func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
+	var klingResponse responsePayload
+	if err := json.Unmarshal(respBody, &klingResponse); err != nil {
+		return nil, err
+	}
+	if klingResponse.Code != 0 {
+		return nil, fmt.Errorf("kling error %d: %s", klingResponse.Code, klingResponse.Message)
+	}
+	taskInfo := &relaycommon.TaskInfo{
+		TaskID: klingResponse.Data.TaskId,
+		Reason: klingResponse.Data.TaskStatusMsg,
+	}
+	switch klingResponse.Data.TaskStatus {
+	case "submitted":
+		taskInfo.Status = model.TaskStatusSubmitted
+	case "processing":
+		taskInfo.Status = model.TaskStatusInProgress
+	case "succeed":
+		taskInfo.Status = model.TaskStatusSuccess
+		if len(klingResponse.Data.TaskResult.Videos) > 0 {
+			taskInfo.Url = klingResponse.Data.TaskResult.Videos[0].Url
+		}
+	case "failed":
+		taskInfo.Status = model.TaskStatusFailure
+	default:
+		taskInfo.Status = model.TaskStatusUnknown
+	}
+	return taskInfo, nil
+}
+
+// createToken 渠道密钥格式为 AccessKey|SecretKey 时按可灵的要求签发 JWT，否则直接作为令牌使用
+func createToken(key string) (string, error) {
+	parts := strings.Split(key, "|")
+	if len(parts) != 2 {
+		return key, nil
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": parts[0],
+		"exp": now.Add(30 * time.Minute).Unix(),
+		"nbf": now.Add(-5 * time.Second).Unix(),
+	})
+	token.Header["typ"] = "JWT"
+	return token.SignedString([]byte(parts[1]))
+}
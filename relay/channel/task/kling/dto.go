@@ -0,0 +1,32 @@
+package kling
+
+type requestPayload struct {
+	ModelName      string  `json:"model_name,omitempty"`
+	Prompt         string  `json:"prompt,omitempty"`
+	NegativePrompt string  `json:"negative_prompt,omitempty"`
+	Image          string  `json:"image,omitempty"`
+	Mode           string  `json:"mode,omitempty"`
+	AspectRatio    string  `json:"aspect_ratio,omitempty"`
+	Duration       string  `json:"duration,omitempty"`
+	CfgScale       float64 `json:"cfg_scale,omitempty"`
+}
+
+type responsePayload struct {
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	RequestId string `json:"request_id"`
+	Data      struct {
+		TaskId        string `json:"task_id"`
+		TaskStatus    string `json:"task_status"`
+		TaskStatusMsg string `json:"task_status_msg"`
+		TaskResult    struct {
+			Videos []struct {
+				Id       string `json:"id"`
+				Url      string `json:"url"`
+				Duration string `json:"duration"`
+			} `json:"videos"`
+		} `json:"task_result"`
+		CreatedAt int64 `json:"created_at"`
+		UpdatedAt int64 `json:"updated_at"`
+	} `json:"data"`
+}
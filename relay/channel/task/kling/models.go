@@ -0,0 +1,11 @@
+package kling
+
+var ModelList = []string{
+	"kling-v1",
+	"kling-v1-6",
+	"kling-v2-master",
+	"kling-v2-1",
+	"kling-v2-1-master",
+}
+
+var ChannelName = "kling"
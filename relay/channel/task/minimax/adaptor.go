@@ -0,0 +1,168 @@
+package minimax
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskAdaptor MiniMax（海螺）视频生成，与对话模型共用 MiniMax 渠道
+type TaskAdaptor struct {
+	ChannelType int
+}
+
+func (a *TaskAdaptor) Init(info *relaycommon.TaskRelayInfo) {
+	a.ChannelType = info.ChannelType
+}
+
+func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.TaskRelayInfo) *dto.TaskError {
+	taskErr := channel.ValidateVideoRequest(c, info, 6)
+	if taskErr != nil {
+		return taskErr
+	}
+	videoRequest, _ := channel.GetVideoRequest(c)
+	// 只支持 6 秒与 10 秒
+	if videoRequest.Duration > 6 {
+		videoRequest.Duration = 10
+	} else {
+		videoRequest.Duration = 6
+	}
+	return nil
+}
+
+func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.TaskRelayInfo) (string, error) {
+	return fmt.Sprintf("%s/v1/video_generation", info.BaseUrl), nil
+}
+
+func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info *relaycommon.TaskRelayInfo) error {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+info.ApiKey)
+	return nil
+}
+
+func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.TaskRelayInfo) (io.Reader, error) {
+	videoRequest, err := channel.GetVideoRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	payload := requestPayload{
+		Model:           info.UpstreamModelName,
+		Prompt:          videoRequest.Prompt,
+		FirstFrameImage: videoRequest.Image,
+		Duration:        videoRequest.Duration,
+		Resolution:      strings.ToUpper(videoRequest.Resolution),
+	}
+	return channel.BuildVideoRequestBody(payload, videoRequest.Metadata)
+}
+
+func (a *TaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.TaskRelayInfo, requestBody io.Reader) (*http.Response, error) {
+	return channel.DoTaskApiRequest(a, c, info, requestBody)
+}
+
+func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.TaskRelayInfo) (taskID string, taskData []byte, taskErr *dto.TaskError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	var minimaxResponse submitResponse
+	err = json.Unmarshal(responseBody, &minimaxResponse)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	if minimaxResponse.BaseResp.StatusCode != 0 || minimaxResponse.TaskId == "" {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("%s", minimaxResponse.BaseResp.StatusMsg), fmt.Sprintf("%d", minimaxResponse.BaseResp.StatusCode), http.StatusInternalServerError)
+		return
+	}
+	channel.WriteVideoSubmitResponse(c, info, minimaxResponse.TaskId)
+	return minimaxResponse.TaskId, responseBody, nil
+}
+
+func (a *TaskAdaptor) GetModelList() []string {
+	return ModelList
+}
+
+func (a *TaskAdaptor) GetChannelName() string {
+	return ChannelName
+}
+
+// FetchTask 查询任务状态，任务成功时再查询生成的文件以获得下载地址，两者合并后返回
+func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any) (*http.Response, error) {
+	taskId, _ := body["task_id"].(string)
+	headers := map[string]string{
+		"Accept":        "application/json",
+		"Authorization": "Bearer " + key,
+	}
+	resp, err := channel.DoVideoFetchRequest(http.MethodGet, fmt.Sprintf("%s/v1/query/video_generation?task_id=%s", baseUrl, taskId), nil, headers)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	var result taskResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result.Task); err != nil {
+		return nil, err
+	}
+	if result.Task.Status == "Success" && result.Task.FileId != "" {
+		fileResp, err := channel.DoVideoFetchRequest(http.MethodGet, fmt.Sprintf("%s/v1/files/retrieve?file_id=%s", baseUrl, result.Task.FileId), nil, headers)
+		if err != nil {
+			return nil, err
+		}
+		if fileResp.StatusCode != http.StatusOK {
+			return fileResp, nil
+		}
+		result.File = &fileResponse{}
+		if err := json.NewDecoder(fileResp.Body).Decode(result.File); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
+	var result taskResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, err
+	}
+	if result.Task.BaseResp.StatusCode != 0 {
+		return nil, fmt.Errorf("minimax error %d: %s", result.Task.BaseResp.StatusCode, result.Task.BaseResp.StatusMsg)
+	}
+	taskInfo := &relaycommon.TaskInfo{
+		TaskID: result.Task.TaskId,
+	}
+	switch result.Task.Status {
+	case "Preparing", "Queueing":
+		taskInfo.Status = model.TaskStatusQueued
+	case "Processing":
+		taskInfo.Status = model.TaskStatusInProgress
+	case "Success":
+		taskInfo.Status = model.TaskStatusSuccess
+		if result.File == nil || result.File.File.DownloadUrl == "" {
+			// 文件信息尚未获取到，下次轮询时重试
+			taskInfo.Status = model.TaskStatusInProgress
+		} else {
+			taskInfo.Url = result.File.File.DownloadUrl
+		}
+	case "Fail":
+		taskInfo.Status = model.TaskStatusFailure
+		taskInfo.Reason = result.Task.BaseResp.StatusMsg
+	default:
+		taskInfo.Status = model.TaskStatusUnknown
+	}
+	return taskInfo, nil
+}
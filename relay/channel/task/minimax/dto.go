@@ -0,0 +1,41 @@
+package minimax
+
+type requestPayload struct {
+	Model           string `json:"model"`
+	Prompt          string `json:"prompt,omitempty"`
+	FirstFrameImage string `json:"first_frame_image,omitempty"`
+	Duration        int    `json:"duration,omitempty"`
+	Resolution      string `json:"resolution,omitempty"`
+}
+
+type baseResp struct {
+	StatusCode int    `json:"status_code"`
+	StatusMsg  string `json:"status_msg"`
+}
+
+type submitResponse struct {
+	TaskId   string   `json:"task_id"`
+	BaseResp baseResp `json:"base_resp"`
+}
+
+type queryResponse struct {
+	TaskId   string   `json:"task_id"`
+	Status   string   `json:"status"`
+	FileId   string   `json:"file_id"`
+	BaseResp baseResp `json:"base_resp"`
+}
+
+type fileResponse struct {
+	File struct {
+		FileId      any    `json:"file_id"`
+		Filename    string `json:"filename"`
+		DownloadUrl string `json:"download_url"`
+	} `json:"file"`
+	BaseResp baseResp `json:"base_resp"`
+}
+
+// taskResponse FetchTask 返回的内容：任务状态与成功后的文件信息
+type taskResponse struct {
+	Task queryResponse `json:"task"`
+	File *fileResponse `json:"file,omitempty"`
+}
@@ -0,0 +1,11 @@
+package minimax
+
+var ModelList = []string{
+	"MiniMax-Hailuo-02",
+	"T2V-01-Director",
+	"I2V-01-Director",
+	"T2V-01",
+	"I2V-01",
+}
+
+var ChannelName = "minimax"
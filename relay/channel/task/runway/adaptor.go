@@ -0,0 +1,171 @@
+package runway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const apiVersion = "2024-11-06"
+
+// 宽高比与 Runway 分辨率的对应关系
+var ratioMap = map[string]string{
+	"16:9": "1280:720",
+	"9:16": "720:1280",
+	"1:1":  "960:960",
+	"4:3":  "1104:832",
+	"3:4":  "832:1104",
+	"21:9": "1584:672",
+}
+
+type TaskAdaptor struct {
+	ChannelType int
+}
+
+func (a *TaskAdaptor) Init(info *relaycommon.TaskRelayInfo) {
+	a.ChannelType = info.ChannelType
+}
+
+func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.TaskRelayInfo) *dto.TaskError {
+	taskErr := channel.ValidateVideoRequest(c, info, 5)
+	if taskErr != nil {
+		return taskErr
+	}
+	videoRequest, _ := channel.GetVideoRequest(c)
+	if info.Action == constant.TaskActionTextGenerate && info.UpstreamModelName != "veo3" {
+		return service.TaskErrorWrapperLocal(errors.New("image is required for this model"), "invalid_request", http.StatusBadRequest)
+	}
+	// 只支持 5 秒与 10 秒，veo3 固定 8 秒
+	if info.UpstreamModelName == "veo3" {
+		videoRequest.Duration = 8
+	} else if videoRequest.Duration > 5 {
+		videoRequest.Duration = 10
+	} else {
+		videoRequest.Duration = 5
+	}
+	return nil
+}
+
+func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.TaskRelayInfo) (string, error) {
+	if info.Action == constant.TaskActionGenerate {
+		return fmt.Sprintf("%s/v1/image_to_video", info.BaseUrl), nil
+	}
+	return fmt.Sprintf("%s/v1/text_to_video", info.BaseUrl), nil
+}
+
+func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info *relaycommon.TaskRelayInfo) error {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+info.ApiKey)
+	req.Header.Set("X-Runway-Version", apiVersion)
+	return nil
+}
+
+func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.TaskRelayInfo) (io.Reader, error) {
+	videoRequest, err := channel.GetVideoRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	payload := requestPayload{
+		Model:       info.UpstreamModelName,
+		PromptText:  videoRequest.Prompt,
+		PromptImage: videoRequest.Image,
+		Ratio:       ratioMap["16:9"],
+		Duration:    videoRequest.Duration,
+		Seed:        videoRequest.Seed,
+	}
+	if ratio, ok := ratioMap[videoRequest.AspectRatio]; ok {
+		payload.Ratio = ratio
+	} else if videoRequest.AspectRatio != "" {
+		payload.Ratio = videoRequest.AspectRatio
+	}
+	return channel.BuildVideoRequestBody(payload, videoRequest.Metadata)
+}
+
+func (a *TaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.TaskRelayInfo, requestBody io.Reader) (*http.Response, error) {
+	return channel.DoTaskApiRequest(a, c, info, requestBody)
+}
+
+func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.TaskRelayInfo) (taskID string, taskData []byte, taskErr *dto.TaskError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	var runwayResponse submitResponse
+	err = json.Unmarshal(responseBody, &runwayResponse)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	if runwayResponse.Id == "" {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("%s", runwayResponse.Error), "submit_task_failed", http.StatusInternalServerError)
+		return
+	}
+	channel.WriteVideoSubmitResponse(c, info, runwayResponse.Id)
+	return runwayResponse.Id, responseBody, nil
+}
+
+func (a *TaskAdaptor) GetModelList() []string {
+	return ModelList
+}
+
+func (a *TaskAdaptor) GetChannelName() string {
+	return ChannelName
+}
+
+func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any) (*http.Response, error) {
+	taskId, _ := body["task_id"].(string)
+	requestUrl := fmt.Sprintf("%s/v1/tasks/%s", baseUrl, taskId)
+	return channel.DoVideoFetchRequest(http.MethodGet, requestUrl, nil, map[string]string{
+		"Accept":           "application/json",
+		"Authorization":    "Bearer " + key,
+		"X-Runway-Version": apiVersion,
+	})
+}
+
+func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
+	var runwayResponse taskResponse
+	if err := json.Unmarshal(respBody, &runwayResponse); err != nil {
+		return nil, err
+	}
+	if runwayResponse.Id == "" {
+		return nil, fmt.Errorf("runway error: %s", runwayResponse.Error)
+	}
+	taskInfo := &relaycommon.TaskInfo{
+		TaskID: runwayResponse.Id,
+		Reason: runwayResponse.Failure,
+	}
+	switch runwayResponse.Status {
+	case "PENDING", "THROTTLED":
+		taskInfo.Status = model.TaskStatusQueued
+	case "RUNNING":
+		taskInfo.Status = model.TaskStatusInProgress
+		if runwayResponse.Progress > 0 {
+			taskInfo.Progress = fmt.Sprintf("%d%%", int(runwayResponse.Progress*100))
+		}
+	case "SUCCEEDED":
+		taskInfo.Status = model.TaskStatusSuccess
+		if len(runwayResponse.Output) > 0 {
+			taskInfo.Url = runwayResponse.Output[0]
+		}
+	case "FAILED", "CANCELLED":
+		taskInfo.Status = model.TaskStatusFailure
+		if taskInfo.Reason == "" {
+			taskInfo.Reason = runwayResponse.Status
+		}
+	default:
+		taskInfo.Status = model.TaskStatusUnknown
+	}
+	return taskInfo, nil
+}
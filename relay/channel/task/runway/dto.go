@@ -0,0 +1,26 @@
+package runway
+
+type requestPayload struct {
+	Model       string `json:"model"`
+	PromptText  string `json:"promptText,omitempty"`
+	PromptImage string `json:"promptImage,omitempty"`
+	Ratio       string `json:"ratio,omitempty"`
+	Duration    int    `json:"duration,omitempty"`
+	Seed        int    `json:"seed,omitempty"`
+}
+
+type submitResponse struct {
+	Id    string `json:"id"`
+	Error string `json:"error"`
+}
+
+type taskResponse struct {
+	Id          string   `json:"id"`
+	Status      string   `json:"status"`
+	Progress    float64  `json:"progress"`
+	Output      []string `json:"output"`
+	Failure     string   `json:"failure"`
+	FailureCode string   `json:"failureCode"`
+	CreatedAt   string   `json:"createdAt"`
+	Error       string   `json:"error"`
+}
@@ -0,0 +1,9 @@
+package runway
+
+var ModelList = []string{
+	"gen4_turbo",
+	"gen3a_turbo",
+	"veo3",
+}
+
+var ChannelName = "runway"
@@ -0,0 +1,154 @@
+package volcengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskAdaptor 火山方舟视频生成（Seedance），与对话模型共用火山引擎渠道
+type TaskAdaptor struct {
+	ChannelType int
+}
+
+func (a *TaskAdaptor) Init(info *relaycommon.TaskRelayInfo) {
+	a.ChannelType = info.ChannelType
+}
+
+func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.TaskRelayInfo) *dto.TaskError {
+	return channel.ValidateVideoRequest(c, info, 5)
+}
+
+func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.TaskRelayInfo) (string, error) {
+	return fmt.Sprintf("%s/api/v3/contents/generations/tasks", info.BaseUrl), nil
+}
+
+func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info *relaycommon.TaskRelayInfo) error {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+info.ApiKey)
+	return nil
+}
+
+func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.TaskRelayInfo) (io.Reader, error) {
+	videoRequest, err := channel.GetVideoRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	// 生成参数以 --key value 的形式追加在提示词之后
+	var text strings.Builder
+	text.WriteString(videoRequest.Prompt)
+	if videoRequest.AspectRatio != "" {
+		text.WriteString(" --ratio " + videoRequest.AspectRatio)
+	}
+	if videoRequest.Resolution != "" {
+		text.WriteString(" --resolution " + strings.ToLower(videoRequest.Resolution))
+	}
+	text.WriteString(fmt.Sprintf(" --duration %d", videoRequest.Duration))
+	if videoRequest.Seed != 0 {
+		text.WriteString(fmt.Sprintf(" --seed %d", videoRequest.Seed))
+	}
+	payload := requestPayload{
+		Model: info.UpstreamModelName,
+		Content: []content{
+			{Type: "text", Text: strings.TrimSpace(text.String())},
+		},
+	}
+	if videoRequest.Image != "" {
+		payload.Content = append(payload.Content, content{
+			Type:     "image_url",
+			ImageUrl: &imageUrl{Url: videoRequest.Image},
+		})
+	}
+	return channel.BuildVideoRequestBody(payload, videoRequest.Metadata)
+}
+
+func (a *TaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.TaskRelayInfo, requestBody io.Reader) (*http.Response, error) {
+	return channel.DoTaskApiRequest(a, c, info, requestBody)
+}
+
+func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.TaskRelayInfo) (taskID string, taskData []byte, taskErr *dto.TaskError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	var volcResponse submitResponse
+	err = json.Unmarshal(responseBody, &volcResponse)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	if volcResponse.Error != nil {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("%s", volcResponse.Error.Message), volcResponse.Error.Code, http.StatusInternalServerError)
+		return
+	}
+	if volcResponse.Id == "" {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("empty task id"), "submit_task_failed", http.StatusInternalServerError)
+		return
+	}
+	channel.WriteVideoSubmitResponse(c, info, volcResponse.Id)
+	return volcResponse.Id, responseBody, nil
+}
+
+func (a *TaskAdaptor) GetModelList() []string {
+	return ModelList
+}
+
+func (a *TaskAdaptor) GetChannelName() string {
+	return ChannelName
+}
+
+func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any) (*http.Response, error) {
+	taskId, _ := body["task_id"].(string)
+	requestUrl := fmt.Sprintf("%s/api/v3/contents/generations/tasks/%s", baseUrl, taskId)
+	return channel.DoVideoFetchRequest(http.MethodGet, requestUrl, nil, map[string]string{
+		"Accept":        "application/json",
+		"Authorization": "Bearer " + key,
+	})
+}
+
+func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
+	var volcResponse taskResponse
+	if err := json.Unmarshal(respBody, &volcResponse); err != nil {
+		return nil, err
+	}
+	if volcResponse.Id == "" {
+		if volcResponse.Error != nil {
+			return nil, fmt.Errorf("volcengine error %s: %s", volcResponse.Error.Code, volcResponse.Error.Message)
+		}
+		return nil, fmt.Errorf("empty task id")
+	}
+	taskInfo := &relaycommon.TaskInfo{
+		TaskID: volcResponse.Id,
+	}
+	if volcResponse.Error != nil {
+		taskInfo.Reason = volcResponse.Error.Message
+	}
+	switch volcResponse.Status {
+	case "queued":
+		taskInfo.Status = model.TaskStatusQueued
+	case "running":
+		taskInfo.Status = model.TaskStatusInProgress
+	case "succeeded":
+		taskInfo.Status = model.TaskStatusSuccess
+		taskInfo.Url = volcResponse.Content.VideoUrl
+	case "failed", "cancelled":
+		taskInfo.Status = model.TaskStatusFailure
+		if taskInfo.Reason == "" {
+			taskInfo.Reason = volcResponse.Status
+		}
+	default:
+		taskInfo.Status = model.TaskStatusUnknown
+	}
+	return taskInfo, nil
+}
@@ -0,0 +1,38 @@
+package volcengine
+
+type content struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageUrl *imageUrl `json:"image_url,omitempty"`
+}
+
+type imageUrl struct {
+	Url string `json:"url"`
+}
+
+type requestPayload struct {
+	Model   string    `json:"model"`
+	Content []content `json:"content"`
+}
+
+type responseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type submitResponse struct {
+	Id    string         `json:"id"`
+	Error *responseError `json:"error,omitempty"`
+}
+
+type taskResponse struct {
+	Id      string `json:"id"`
+	Model   string `json:"model"`
+	Status  string `json:"status"`
+	Content struct {
+		VideoUrl string `json:"video_url"`
+	} `json:"content"`
+	Error     *responseError `json:"error,omitempty"`
+	CreatedAt int64          `json:"created_at"`
+	UpdatedAt int64          `json:"updated_at"`
+}
@@ -0,0 +1,9 @@
+package volcengine
+
+var ModelList = []string{
+	"doubao-seedance-1-0-pro-250528",
+	"doubao-seedance-1-0-lite-t2v-250428",
+	"doubao-seedance-1-0-lite-i2v-250428",
+}
+
+var ChannelName = "volcengine"
@@ -0,0 +1,127 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting/model_setting"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ValidateVideoRequest 解析并校验统一的视频生成请求，未指定时长时使用平台的默认时长
+func ValidateVideoRequest(c *gin.Context, info *relaycommon.TaskRelayInfo, defaultDuration int) *dto.TaskError {
+	var videoRequest dto.VideoRequest
+	if err := common.UnmarshalBodyReusable(c, &videoRequest); err != nil {
+		return service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+	if videoRequest.Prompt == "" && videoRequest.Image == "" {
+		return service.TaskErrorWrapperLocal(errors.New("prompt or image is required"), "invalid_request", http.StatusBadRequest)
+	}
+	if videoRequest.Duration < 0 {
+		return service.TaskErrorWrapperLocal(errors.New("duration must be positive"), "invalid_request", http.StatusBadRequest)
+	}
+	if videoRequest.Duration == 0 {
+		videoRequest.Duration = defaultDuration
+	}
+	maxDuration := model_setting.GetVideoSettings().MaxDuration
+	if maxDuration > 0 && videoRequest.Duration > maxDuration {
+		return service.TaskErrorWrapperLocal(fmt.Errorf("duration must not exceed %d seconds", maxDuration), "invalid_request", http.StatusBadRequest)
+	}
+	if videoRequest.Image != "" {
+		info.Action = constant.TaskActionGenerate
+	} else {
+		info.Action = constant.TaskActionTextGenerate
+	}
+	c.Set("task_request", &videoRequest)
+	return nil
+}
+
+// GetVideoRequest 获取 ValidateVideoRequest 解析的请求
+func GetVideoRequest(c *gin.Context) (*dto.VideoRequest, error) {
+	videoRequest, ok := c.Get("task_request")
+	if !ok {
+		return nil, errors.New("video request not found")
+	}
+	request, ok := videoRequest.(*dto.VideoRequest)
+	if !ok {
+		return nil, errors.New("invalid video request")
+	}
+	return request, nil
+}
+
+// BuildVideoRequestBody 序列化上游请求，并把请求中的 metadata 合并到请求体顶层
+func BuildVideoRequestBody(payload any, metadata map[string]any) (io.Reader, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata) > 0 {
+		body := make(map[string]any)
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, err
+		}
+		for k, v := range metadata {
+			body[k] = v
+		}
+		data, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bytes.NewReader(data), nil
+}
+
+// WriteVideoSubmitResponse 向客户端返回统一格式的任务提交结果
+func WriteVideoSubmitResponse(c *gin.Context, info *relaycommon.TaskRelayInfo, taskId string) {
+	response := dto.VideoTaskResponse{
+		TaskId:    taskId,
+		Object:    "video.generation",
+		Model:     info.OriginModelName,
+		Status:    "queued",
+		CreatedAt: time.Now().Unix(),
+	}
+	if videoRequest, err := GetVideoRequest(c); err == nil {
+		response.Duration = videoRequest.Duration
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DoVideoFetchRequest 请求上游的任务查询接口
+func DoVideoFetchRequest(method string, url string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		common.SysError(fmt.Sprintf("Get Task error: %v", err))
+		return nil, err
+	}
+	// 设置超时时间
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	req = req.WithContext(ctx)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := service.GetHttpClient().Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// 读取完响应后再取消超时
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
@@ -30,8 +30,23 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	if request.Mask != nil {
+		return nil, errors.New("mask is not supported by volcengine")
+	}
+	volcRequest := &VolcImageRequest{
+		Model:          request.Model,
+		Prompt:         request.Prompt,
+		Size:           request.Size,
+		ResponseFormat: request.ResponseFormat,
+	}
+	// 图生图 (seededit / seedream) 通过 image 字段传入参考图
+	if len(request.Images) > 0 {
+		volcRequest.Image = request.Images[0].DataUrl()
+	}
+	if info.RelayMode == constant.RelayModeImagesVariations && volcRequest.Prompt == "" {
+		volcRequest.Prompt = "Generate a variation of this image"
+	}
+	return volcRequest, nil
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
@@ -46,6 +61,8 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 		return fmt.Sprintf("%s/api/v3/chat/completions", info.BaseUrl), nil
 	case constant.RelayModeEmbeddings:
 		return fmt.Sprintf("%s/api/v3/embeddings", info.BaseUrl), nil
+	case constant.RelayModeImagesGenerations, constant.RelayModeImagesEdits, constant.RelayModeImagesVariations:
+		return fmt.Sprintf("%s/api/v3/images/generations", info.BaseUrl), nil
 	default:
 	}
 	return "", fmt.Errorf("unsupported relay mode: %d", info.RelayMode)
@@ -91,6 +108,8 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		}
 	case constant.RelayModeEmbeddings:
 		err, usage = openai.OpenaiHandler(c, resp, info)
+	case constant.RelayModeImagesGenerations, constant.RelayModeImagesEdits, constant.RelayModeImagesVariations:
+		err, usage = openai.OpenaiHandlerWithUsage(c, resp, info)
 	}
 	return
 }
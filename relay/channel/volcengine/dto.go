@@ -0,0 +1,12 @@
+package volcengine
+
+type VolcImageRequest struct {
+	Model          string   `json:"model"`
+	Prompt         string   `json:"prompt"`
+	Image          string   `json:"image,omitempty"`
+	Size           string   `json:"size,omitempty"`
+	ResponseFormat string   `json:"response_format,omitempty"`
+	Seed           *int64   `json:"seed,omitempty"`
+	GuidanceScale  *float64 `json:"guidance_scale,omitempty"`
+	Watermark      *bool    `json:"watermark,omitempty"`
+}
@@ -39,6 +39,25 @@ const (
 	RelayFormatGemini = "gemini"
 )
 
+// ResponsesConvertInfo 保存 Responses API 与 Chat Completions 互转时的流式状态
+type ResponsesConvertInfo struct {
+	ResponseId   string
+	Model        string
+	CreatedAt    int64
+	Sequence     int
+	Started      bool
+	FinishReason string
+	Usage        *dto.Usage
+	// chat -> responses，Output 的下标即 output_index
+	Output         []*dto.ResponsesOutput
+	MessageIndex   int
+	ReasoningIndex int
+	ToolCallItems  map[int]int
+	// responses -> chat
+	SentRole      bool
+	ToolCallIndex map[string]int
+}
+
 type RerankerInfo struct {
 	Documents       []any
 	ReturnDocuments bool
@@ -50,6 +69,14 @@ type BuildInToolInfo struct {
 	SearchContextSize string
 }
 
+// McpToolCallInfo 一次由网关执行的 MCP 工具调用
+type McpToolCallInfo struct {
+	Server       string
+	Tool         string
+	PricePerCall float64
+	Success      bool
+}
+
 type ResponsesUsageInfo struct {
 	BuiltInTools map[string]*BuildInToolInfo
 }
@@ -75,37 +102,51 @@ type RelayInfo struct {
 	UpstreamModelName string
 	OriginModelName   string
 	//RecodeModelName      string
-	RequestURLPath       string
-	ApiVersion           string
-	PromptTokens         int
-	ApiKey               string
-	Organization         string
-	BaseUrl              string
-	SupportStreamOptions bool
-	ShouldIncludeUsage   bool
-	IsModelMapped        bool
-	ClientWs             *websocket.Conn
-	TargetWs             *websocket.Conn
-	InputAudioFormat     string
-	OutputAudioFormat    string
-	RealtimeTools        []dto.RealTimeTool
-	IsFirstRequest       bool
-	AudioUsage           bool
-	ReasoningEffort      string
-	ChannelSetting       map[string]interface{}
-	ParamOverride        map[string]interface{}
-	UserSetting          map[string]interface{}
-	UserEmail            string
-	UserQuota            int
-	RelayFormat          string
-	SendResponseCount    int
-	ChannelCreateTime    int64
-	UsedSubscriptionQuota bool  // 是否使用了订阅配额
-	SubscriptionId       int   // 使用的订阅ID
+	RequestURLPath        string
+	ApiVersion            string
+	PromptTokens          int
+	PreConsumedTokens     int // 预扣费时预估的输入与输出 token 总数，用于预留按 token 计量的订阅配额
+	ApiKey                string
+	Organization          string
+	BaseUrl               string
+	SupportStreamOptions  bool
+	ShouldIncludeUsage    bool
+	IsModelMapped         bool
+	ClientWs              *websocket.Conn
+	TargetWs              *websocket.Conn
+	InputAudioFormat      string
+	OutputAudioFormat     string
+	RealtimeTools         []dto.RealTimeTool
+	IsFirstRequest        bool
+	AudioUsage            bool
+	ReasoningEffort       string
+	ChannelSetting        map[string]interface{}
+	ParamOverride         map[string]interface{}
+	UserSetting           map[string]interface{}
+	UserEmail             string
+	UserQuota             int
+	RelayFormat           string
+	SendResponseCount     int
+	ChannelCreateTime     int64
+	RequestId             string
+	UsedSubscriptionQuota bool // 是否使用了订阅配额
+	SubscriptionId        int  // 使用的订阅ID
+	// 预扣阶段预留的订阅配额记录ID，请求结束时结算，失败时释放
+	SubscriptionReservationIds []int
+	// Structured Outputs 模拟的尝试次数，0 表示未模拟
+	StructuredOutputAttempts int
+	StructuredOutputFailed   bool
+	// 是否命中响应缓存
+	ResponseCacheHit bool
+	// 命中语义缓存时的相似度，未命中时为 0
+	SemanticCacheSimilarity float64
+	// 由网关执行的 MCP 工具调用，按次计费
+	McpToolCalls []McpToolCallInfo
 	ThinkingContentInfo
 	*ClaudeConvertInfo
 	*RerankerInfo
 	*ResponsesUsageInfo
+	ResponsesConvertInfo *ResponsesConvertInfo
 }
 
 // 定义支持流式选项的通道类型
@@ -221,6 +262,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 		Organization:      c.GetString("channel_organization"),
 		ChannelSetting:    channelSetting,
 		ChannelCreateTime: c.GetInt64("channel_create_time"),
+		RequestId:         c.GetString(common.RequestIdKey),
 		ParamOverride:     paramOverride,
 		RelayFormat:       RelayFormatOpenAI,
 		ThinkingContentInfo: ThinkingContentInfo{
@@ -252,6 +294,26 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 	return info
 }
 
+// SwitchRelayMode 在 Responses API 与 Chat Completions 之间桥接时切换上游请求的模式和路径
+func (info *RelayInfo) SwitchRelayMode(relayMode int, requestURLPath string) {
+	info.RelayMode = relayMode
+	info.RequestURLPath = requestURLPath
+	info.SupportStreamOptions = streamSupportedChannels[info.ChannelType] && relayMode != relayconstant.RelayModeResponses
+	if info.ResponsesConvertInfo == nil {
+		info.ResponsesConvertInfo = &ResponsesConvertInfo{
+			MessageIndex:   -1,
+			ReasoningIndex: -1,
+			ToolCallItems:  make(map[int]int),
+			ToolCallIndex:  make(map[string]int),
+		}
+	}
+	if info.ResponsesUsageInfo == nil {
+		info.ResponsesUsageInfo = &ResponsesUsageInfo{
+			BuiltInTools: make(map[string]*BuildInToolInfo),
+		}
+	}
+}
+
 func (info *RelayInfo) SetPromptTokens(promptTokens int) {
 	info.PromptTokens = promptTokens
 }
@@ -279,6 +341,15 @@ type TaskRelayInfo struct {
 	ConsumeQuota bool
 }
 
+// TaskInfo 上游任务查询结果的统一表示，Status 取值与 model.TaskStatus 一致
+type TaskInfo struct {
+	TaskID   string
+	Status   string
+	Progress string
+	Url      string
+	Reason   string
+}
+
 func GenTaskRelayInfo(c *gin.Context) *TaskRelayInfo {
 	info := &TaskRelayInfo{
 		RelayInfo: GenRelayInfo(c),
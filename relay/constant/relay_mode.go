@@ -45,6 +45,11 @@ const (
 	RelayModeRealtime
 
 	RelayModeGemini
+
+	RelayModeImagesVariations
+
+	RelayModeVideoSubmit
+	RelayModeVideoFetchByID
 )
 
 func Path2RelayMode(path string) int {
@@ -63,6 +68,8 @@ func Path2RelayMode(path string) int {
 		relayMode = RelayModeImagesGenerations
 	} else if strings.HasPrefix(path, "/v1/images/edits") {
 		relayMode = RelayModeImagesEdits
+	} else if strings.HasPrefix(path, "/v1/images/variations") {
+		relayMode = RelayModeImagesVariations
 	} else if strings.HasPrefix(path, "/v1/edits") {
 		relayMode = RelayModeEdits
 	} else if strings.HasPrefix(path, "/v1/responses") {
@@ -122,6 +129,16 @@ func Path2RelayModeMidjourney(path string) int {
 	return relayMode
 }
 
+func Path2RelayVideo(method, path string) int {
+	relayMode := RelayModeUnknown
+	if method == http.MethodPost && strings.HasSuffix(path, "/video/generations") {
+		relayMode = RelayModeVideoSubmit
+	} else if method == http.MethodGet && strings.Contains(path, "/video/generations/") {
+		relayMode = RelayModeVideoFetchByID
+	}
+	return relayMode
+}
+
 func Path2RelaySuno(method, path string) int {
 	relayMode := RelayModeUnknown
 	if method == http.MethodPost && strings.HasSuffix(path, "/fetch") {
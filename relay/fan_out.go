@@ -0,0 +1,285 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/model_setting"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shouldFanOut 判断 n>1 的请求是否需要由网关并发请求上游后合并
+func shouldFanOut(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) bool {
+	if info.RelayMode != relayconstant.RelayModeChatCompletions || request.N <= 1 {
+		return false
+	}
+	if fanOut, ok := info.ChannelSetting[constant.ChannelSettingNFanOut].(bool); ok && fanOut {
+		return true
+	}
+	settings := model_setting.GetFanOutSettings()
+	return settings.Enabled && !settings.IsNativeChannelType(info.ChannelType)
+}
+
+// validateFanOutRequest 校验需要拆分的请求。best_of 要求上游在多个候选中按对数概率挑选最优结果，
+// 拆分后的请求之间无法比较，因此 best_of 大于1时一律拒绝，而不是静默忽略
+func validateFanOutRequest(request *dto.GeneralOpenAIRequest) *dto.OpenAIErrorWithStatusCode {
+	if maxN := model_setting.GetFanOutSettings().MaxN; maxN > 0 && request.N > maxN {
+		return service.OpenAIErrorWrapperLocal(fmt.Errorf("n must be less than or equal to %d", maxN), "invalid_request", http.StatusBadRequest)
+	}
+	if request.BestOf > 1 {
+		return service.OpenAIErrorWrapperLocal(errors.New("best_of is not supported by this channel"), "invalid_request", http.StatusBadRequest)
+	}
+	return nil
+}
+
+// fanOutCall 单个上游请求的上下文，每个请求使用独立的 gin.Context 与 RelayInfo 副本以便并发执行
+type fanOutCall struct {
+	c                *gin.Context
+	info             *relaycommon.RelayInfo
+	resp             *http.Response
+	usage            *dto.Usage
+	err              *dto.OpenAIErrorWithStatusCode
+	emulateToolCalls bool
+}
+
+func newFanOutCall(c *gin.Context, info *relaycommon.RelayInfo, emulateToolCalls bool) *fanOutCall {
+	infoCopy := *info
+	if info.ClaudeConvertInfo != nil {
+		claudeConvertInfo := *info.ClaudeConvertInfo
+		infoCopy.ClaudeConvertInfo = &claudeConvertInfo
+	}
+	return &fanOutCall{
+		c:                c.Copy(),
+		info:             &infoCopy,
+		emulateToolCalls: emulateToolCalls,
+	}
+}
+
+// doResponse 处理上游响应，模拟工具调用时把模型输出改写为标准 tool_calls
+func (call *fanOutCall) doResponse(adaptor channel.Adaptor) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+	var usage any
+	var openaiErr *dto.OpenAIErrorWithStatusCode
+	if call.emulateToolCalls {
+		usage, openaiErr = doToolCallEmulation(call.c, adaptor, call.resp, call.info)
+	} else {
+		usage, openaiErr = adaptor.DoResponse(call.c, call.resp, call.info)
+	}
+	if openaiErr != nil {
+		return nil, openaiErr
+	}
+	u, _ := usage.(*dto.Usage)
+	return u, nil
+}
+
+// doFanOut 把 n>1 的请求拆分为 n 个 n=1 的上游请求并发执行，合并为一个多 choice 的响应，用量按所有请求累加
+func doFanOut(c *gin.Context, adaptor channel.Adaptor, info *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+	if openaiErr := validateFanOutRequest(textRequest); openaiErr != nil {
+		return nil, openaiErr
+	}
+	n := textRequest.N
+
+	request := *textRequest
+	request.N = 0
+	request.BestOf = 0
+	// 上游不支持函数调用时每个请求同样在提示词中模拟工具调用
+	emulateToolCalls := shouldEmulateToolCalls(info, &request)
+	if emulateToolCalls {
+		request.Messages = append([]dto.Message{}, textRequest.Messages...)
+		service.ApplyToolCallPrompt(&request)
+	}
+	convertedRequest, err := adaptor.ConvertOpenAIRequest(c, info, &request)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
+	}
+	jsonData, openaiErr := getRequestBodyJson(info, convertedRequest)
+	if openaiErr != nil {
+		return nil, openaiErr
+	}
+
+	// 第一阶段：并发发送请求，任意一个失败则整体失败
+	calls := make([]*fanOutCall, n)
+	var wg sync.WaitGroup
+	for i := range calls {
+		call := newFanOutCall(c, info, emulateToolCalls)
+		calls[i] = call
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := adaptor.DoRequest(call.c, call.info, bytes.NewReader(jsonData))
+			if err != nil {
+				call.err = service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+				return
+			}
+			httpResp, _ := resp.(*http.Response)
+			if httpResp == nil {
+				call.err = service.OpenAIErrorWrapper(errors.New("empty upstream response"), "do_request_failed", http.StatusInternalServerError)
+				return
+			}
+			call.resp = httpResp
+			if httpResp.StatusCode != http.StatusOK {
+				call.err = service.RelayErrorHandler(httpResp, false)
+				service.ResetStatusCode(call.err, c.GetString("status_code_mapping"))
+			}
+		}()
+	}
+	wg.Wait()
+	for _, call := range calls {
+		if call.err != nil {
+			for _, other := range calls {
+				if other.resp != nil && other.resp.Body != nil {
+					_ = other.resp.Body.Close()
+				}
+			}
+			return nil, call.err
+		}
+	}
+	info.IsStream = info.IsStream || strings.HasPrefix(calls[0].resp.Header.Get("Content-Type"), "text/event-stream")
+	for _, call := range calls {
+		call.info.IsStream = info.IsStream
+	}
+
+	// 第二阶段：并发读取响应并合并
+	if info.IsStream {
+		return doFanOutStream(c, adaptor, info, calls)
+	}
+	responses := make([]*dto.OpenAITextResponse, n)
+	for i, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			writer := helper.NewBufferedConvertWriter(c.Writer)
+			call.c.Writer = writer
+			usage, openaiErr := call.doResponse(adaptor)
+			if openaiErr != nil {
+				call.err = openaiErr
+				return
+			}
+			call.usage = usage
+			var openAIResponse dto.OpenAITextResponse
+			if err := common.DecodeJson(writer.Body(), &openAIResponse); err != nil {
+				call.err = service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+				return
+			}
+			responses[i] = &openAIResponse
+		}()
+	}
+	wg.Wait()
+
+	totalUsage := &dto.Usage{}
+	for _, call := range calls {
+		accumulateUsage(totalUsage, call.usage)
+	}
+	for _, call := range calls {
+		if call.err != nil {
+			// 其余请求已经产生用量，照常计费
+			if totalUsage.TotalTokens > 0 || totalUsage.PromptTokens > 0 {
+				c.JSON(call.err.StatusCode, gin.H{
+					"error": call.err.Error,
+				})
+				return totalUsage, nil
+			}
+			return nil, call.err
+		}
+	}
+	merged := mergeFanOutResponses(responses)
+	merged.Usage = *totalUsage
+	c.JSON(http.StatusOK, merged)
+	return totalUsage, nil
+}
+
+// mergeFanOutResponses 以第一个响应为基础，按请求顺序合并所有 choice 并重新编号
+func mergeFanOutResponses(responses []*dto.OpenAITextResponse) *dto.OpenAITextResponse {
+	var choices []dto.OpenAITextResponseChoice
+	for _, response := range responses {
+		for _, choice := range response.Choices {
+			choice.Index = len(choices)
+			choices = append(choices, choice)
+		}
+	}
+	merged := responses[0]
+	merged.Choices = choices
+	return merged
+}
+
+// rewriteFanOutChunk 将第 index 个请求的流式响应块改写为合并后的响应，没有 choice 的块（例如用量）返回 false
+func rewriteFanOutChunk(chunk *dto.ChatCompletionsStreamResponse, index int, responseId string) bool {
+	if len(chunk.Choices) == 0 {
+		return false
+	}
+	chunk.Id = responseId
+	chunk.Usage = nil
+	for j := range chunk.Choices {
+		chunk.Choices[j].Index = index
+	}
+	return true
+}
+
+// doFanOutStream 并发读取 n 个流式响应，按请求序号改写 choice index 后交错输出，最后合并输出用量
+func doFanOutStream(c *gin.Context, adaptor channel.Adaptor, info *relaycommon.RelayInfo, calls []*fanOutCall) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+	responseId := helper.GetResponseID(c)
+	var created int64
+	var model string
+	var mu sync.Mutex
+	helper.SetEventStreamHeaders(c)
+
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			call.c.Writer = helper.NewDetachedStreamConvertWriter(c.Writer, func(data string) {
+				if data == "[DONE]" {
+					return
+				}
+				var chunk dto.ChatCompletionsStreamResponse
+				if err := common.DecodeJsonStr(data, &chunk); err != nil {
+					common.SysError("error unmarshalling fan-out stream response: " + err.Error())
+					return
+				}
+				// 各请求的用量在结束后统一输出
+				if !rewriteFanOutChunk(&chunk, i, responseId) {
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if created == 0 {
+					created = chunk.Created
+					model = chunk.Model
+					info.SetFirstResponseTime()
+				}
+				chunk.Created = created
+				_ = helper.ObjectData(c, chunk)
+			})
+			usage, openaiErr := call.doResponse(adaptor)
+			if openaiErr != nil {
+				call.err = openaiErr
+				common.LogError(c, fmt.Sprintf("fan-out stream %d failed: %s", i, openaiErr.Error.Message))
+				return
+			}
+			call.usage = usage
+		}()
+	}
+	wg.Wait()
+
+	totalUsage := &dto.Usage{}
+	for _, call := range calls {
+		accumulateUsage(totalUsage, call.usage)
+	}
+	if info.ShouldIncludeUsage {
+		_ = helper.ObjectData(c, helper.GenerateFinalUsageResponse(responseId, created, model, *totalUsage))
+	}
+	helper.Done(c)
+	return totalUsage, nil
+}
@@ -0,0 +1,141 @@
+package relay
+
+import (
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/model_setting"
+	"testing"
+)
+
+func TestShouldFanOut(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		channelType int
+		setting     map[string]interface{}
+		n           int
+		want        bool
+	}{
+		{name: "disabled by default", channelType: common.ChannelTypeAnthropic, n: 2},
+		{name: "single completion", enabled: true, channelType: common.ChannelTypeAnthropic, n: 1},
+		{name: "enabled globally", enabled: true, channelType: common.ChannelTypeAnthropic, n: 2, want: true},
+		{name: "native channel forwards n", enabled: true, channelType: common.ChannelTypeOpenAI, n: 2},
+		{name: "enabled by channel setting", channelType: common.ChannelTypeOpenAI, n: 2,
+			setting: map[string]interface{}{constant.ChannelSettingNFanOut: true}, want: true},
+	}
+	settings := model_setting.GetFanOutSettings()
+	enabled := settings.Enabled
+	defer func() { settings.Enabled = enabled }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings.Enabled = tt.enabled
+			info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeChatCompletions, ChannelType: tt.channelType, ChannelSetting: tt.setting}
+			if got := shouldFanOut(info, &dto.GeneralOpenAIRequest{N: tt.n}); got != tt.want {
+				t.Fatalf("shouldFanOut = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeFanOutResponses(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses [][]string // 每个响应中各 choice 的内容
+		want      []string
+	}{
+		{name: "one choice per response", responses: [][]string{{"a"}, {"b"}, {"c"}}, want: []string{"a", "b", "c"}},
+		{name: "responses with several choices", responses: [][]string{{"a", "b"}, {}, {"c"}}, want: []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := make([]*dto.OpenAITextResponse, len(tt.responses))
+			for i, contents := range tt.responses {
+				response := &dto.OpenAITextResponse{Id: "resp", Model: "gpt-4"}
+				for _, content := range contents {
+					// 上游各自从 0 开始编号
+					choice := dto.OpenAITextResponseChoice{Index: 0, FinishReason: "stop"}
+					choice.Message.SetStringContent(content)
+					response.Choices = append(response.Choices, choice)
+				}
+				responses[i] = response
+			}
+			merged := mergeFanOutResponses(responses)
+			if merged.Id != "resp" || merged.Model != "gpt-4" {
+				t.Fatalf("merged response should keep the first response's metadata, got %+v", merged)
+			}
+			if len(merged.Choices) != len(tt.want) {
+				t.Fatalf("got %d choices, want %d", len(merged.Choices), len(tt.want))
+			}
+			for i, choice := range merged.Choices {
+				if choice.Index != i || choice.Message.StringContent() != tt.want[i] {
+					t.Fatalf("choice %d = (%d, %q), want (%d, %q)", i, choice.Index, choice.Message.StringContent(), i, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteFanOutChunk(t *testing.T) {
+	content := "hi"
+	tests := []struct {
+		name  string
+		chunk dto.ChatCompletionsStreamResponse
+		index int
+		want  bool
+	}{
+		{
+			name: "content chunk takes the request index",
+			chunk: dto.ChatCompletionsStreamResponse{Id: "upstream", Usage: &dto.Usage{TotalTokens: 3},
+				Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: 0, Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}}}},
+			index: 2,
+			want:  true,
+		},
+		{
+			name:  "usage only chunk is dropped",
+			chunk: dto.ChatCompletionsStreamResponse{Id: "upstream", Usage: &dto.Usage{TotalTokens: 3}},
+			index: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk := tt.chunk
+			if got := rewriteFanOutChunk(&chunk, tt.index, "merged"); got != tt.want {
+				t.Fatalf("rewriteFanOutChunk = %v, want %v", got, tt.want)
+			}
+			if !tt.want {
+				return
+			}
+			if chunk.Id != "merged" || chunk.Usage != nil {
+				t.Fatalf("chunk should use the merged id without usage, got id %q usage %v", chunk.Id, chunk.Usage)
+			}
+			for _, choice := range chunk.Choices {
+				if choice.Index != tt.index {
+					t.Fatalf("choice index = %d, want %d", choice.Index, tt.index)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateFanOutRequest(t *testing.T) {
+	maxN := model_setting.GetFanOutSettings().MaxN
+	tests := []struct {
+		name    string
+		request dto.GeneralOpenAIRequest
+		wantErr bool
+	}{
+		{name: "within max n", request: dto.GeneralOpenAIRequest{N: maxN}},
+		{name: "beyond max n", request: dto.GeneralOpenAIRequest{N: maxN + 1}, wantErr: true},
+		{name: "best_of is rejected", request: dto.GeneralOpenAIRequest{N: 2, BestOf: 3}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateFanOutRequest(&tt.request); (err != nil) != tt.wantErr {
+				t.Fatalf("validateFanOutRequest error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package helper
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConvertWriter 替换 gin 的 ResponseWriter，拦截渠道处理器写给客户端的数据，
+// 以便在 API 格式之间桥接（例如把 Chat Completions 的响应改写为 Responses API 格式）。
+// 非流式模式下数据被完整缓存；流式模式下按 SSE 事件解析后交给 onData 回调。
+// tee 模式下数据照常写给客户端，同时保留一份副本供解析（例如保存响应对象）。
+// contentType 不为空时（例如 NDJSON），响应头由 ConvertWriter 自行管理，首次输出时才写给客户端。
+// detached 模式下不会向客户端输出任何数据，用于并发请求多个上游后再汇总输出。
+type ConvertWriter struct {
+	gin.ResponseWriter
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	stream      bool
+	tee         bool
+	detached    bool
+	contentType string
+	started     bool
+	pending     []byte
+	onData      func(data string)
+}
+
+// NewBufferedConvertWriter 创建缓存完整响应体的 ConvertWriter
+func NewBufferedConvertWriter(w gin.ResponseWriter) *ConvertWriter {
+	return &ConvertWriter{
+		ResponseWriter: w,
+		header:         make(http.Header),
+		status:         http.StatusOK,
+	}
+}
+
+// NewStreamConvertWriter 创建逐个解析 SSE data 的 ConvertWriter，注释行（如 PING）直接透传
+func NewStreamConvertWriter(w gin.ResponseWriter, onData func(data string)) *ConvertWriter {
+	return &ConvertWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+		stream:         true,
+		onData:         onData,
+	}
+}
+
+// NewLineConvertWriter 创建逐个解析 SSE data 并以换行分隔输出（如 NDJSON）的 ConvertWriter，
+// 上游的 SSE 响应头与注释行不会写给客户端
+func NewLineConvertWriter(w gin.ResponseWriter, contentType string, onData func(data string)) *ConvertWriter {
+	return &ConvertWriter{
+		ResponseWriter: w,
+		header:         make(http.Header),
+		status:         http.StatusOK,
+		stream:         true,
+		contentType:    contentType,
+		onData:         onData,
+	}
+}
+
+// NewTeeConvertWriter 创建透传数据的 ConvertWriter，流式时同时把 SSE data 交给 onData，非流式时缓存响应体副本
+func NewTeeConvertWriter(w gin.ResponseWriter, stream bool, onData func(data string)) *ConvertWriter {
+	return &ConvertWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+		stream:         stream,
+		tee:            true,
+		onData:         onData,
+	}
+}
+
+// NewDetachedStreamConvertWriter 创建只把 SSE data 交给 onData、不向客户端输出任何数据的 ConvertWriter
+func NewDetachedStreamConvertWriter(w gin.ResponseWriter, onData func(data string)) *ConvertWriter {
+	return &ConvertWriter{
+		ResponseWriter: w,
+		header:         make(http.Header),
+		status:         http.StatusOK,
+		stream:         true,
+		detached:       true,
+		onData:         onData,
+	}
+}
+
+// passThrough 是否把响应头与状态直接交给被包装的 ResponseWriter
+func (w *ConvertWriter) passThrough() bool {
+	if w.detached {
+		return false
+	}
+	return (w.stream && w.contentType == "") || w.tee
+}
+
+func (w *ConvertWriter) Header() http.Header {
+	if w.passThrough() {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *ConvertWriter) WriteHeader(code int) {
+	w.status = code
+	if w.passThrough() {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *ConvertWriter) WriteHeaderNow() {
+	if w.passThrough() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *ConvertWriter) Status() int {
+	if w.tee {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *ConvertWriter) Size() int {
+	if w.passThrough() || w.started {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+func (w *ConvertWriter) Written() bool {
+	if w.passThrough() || w.started {
+		return w.ResponseWriter.Written()
+	}
+	return w.body.Len() > 0 || len(w.pending) > 0
+}
+
+func (w *ConvertWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *ConvertWriter) Write(data []byte) (int, error) {
+	if w.tee {
+		n, err := w.ResponseWriter.Write(data)
+		if err != nil {
+			return n, err
+		}
+		if !w.stream {
+			return w.body.Write(data)
+		}
+	} else if !w.stream {
+		return w.body.Write(data)
+	}
+	w.pending = append(w.pending, data...)
+	for {
+		idx := bytes.Index(w.pending, []byte("\n\n"))
+		if idx < 0 {
+			break
+		}
+		w.handleEvent(string(w.pending[:idx]))
+		w.pending = w.pending[idx+2:]
+	}
+	return len(data), nil
+}
+
+// Flush 响应仍在缓存中时不向客户端刷新
+func (w *ConvertWriter) Flush() {
+	if !w.passThrough() && !w.started {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Body 返回非流式模式下被拦截（或 tee 模式下复制）的原始响应体
+func (w *ConvertWriter) Body() []byte {
+	return w.body.Bytes()
+}
+
+// Pending 返回流式模式下尚未构成完整 SSE 事件的数据，例如处理器出错时直接写出的 JSON
+func (w *ConvertWriter) Pending() []byte {
+	return w.pending
+}
+
+// Started 是否已经通过 WriteLine 向客户端输出过数据
+func (w *ConvertWriter) Started() bool {
+	return w.started
+}
+
+func (w *ConvertWriter) handleEvent(event string) {
+	var dataLines []string
+	for _, line := range strings.Split(event, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.HasPrefix(line, ":") && w.passThrough() && !w.tee:
+			_, _ = fmt.Fprintf(w.ResponseWriter, "%s\n\n", line)
+			w.ResponseWriter.Flush()
+		case strings.HasPrefix(line, "data:"):
+			dataLines = append(dataLines, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if len(dataLines) > 0 && w.onData != nil {
+		w.onData(strings.Join(dataLines, "\n"))
+	}
+}
+
+// WriteEvent 直接向被包装的 ResponseWriter 写入一条 SSE 事件
+func (w *ConvertWriter) WriteEvent(event string, data []byte) {
+	if event != "" {
+		_, _ = fmt.Fprintf(w.ResponseWriter, "event: %s\n", event)
+	}
+	_, _ = fmt.Fprintf(w.ResponseWriter, "data: %s\n\n", data)
+	w.ResponseWriter.Flush()
+}
+
+// WriteLine 直接向被包装的 ResponseWriter 写入一行数据，首次写入时输出 contentType 响应头
+func (w *ConvertWriter) WriteLine(data []byte) {
+	if !w.started {
+		w.started = true
+		if w.contentType != "" {
+			w.ResponseWriter.Header().Set("Content-Type", w.contentType)
+			w.ResponseWriter.WriteHeader(w.status)
+		}
+	}
+	_, _ = w.ResponseWriter.Write(data)
+	_, _ = w.ResponseWriter.Write([]byte("\n"))
+	w.ResponseWriter.Flush()
+}
@@ -46,6 +46,7 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		if maxTokens != 0 {
 			preConsumedTokens = promptTokens + maxTokens
 		}
+		info.PreConsumedTokens = preConsumedTokens
 		var success bool
 		modelRatio, success = operation_setting.GetModelRatio(info.OriginModelName)
 		if !success {
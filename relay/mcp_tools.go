@@ -0,0 +1,138 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting/model_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mcpToolFunctionName 提供给模型的函数名：服务器名__工具名，只保留函数名允许的字符并限制长度
+func mcpToolFunctionName(serverName string, toolName string) string {
+	name := []rune(serverName + "__" + toolName)
+	for i, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return string(name)
+}
+
+// addMcpServerTools 把请求中 type 为 mcp 的工具展开为对应服务器提供的工具，并检查分组权限
+func addMcpServerTools(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest, set *serverToolSet) *dto.OpenAIErrorWithStatusCode {
+	settings := model_setting.GetMcpSettings()
+	hasMcp := false
+	for _, tool := range request.Tools {
+		if tool.Type != "mcp" {
+			continue
+		}
+		hasMcp = true
+		if tool.ServerLabel == "" {
+			return service.OpenAIErrorWrapperLocal(errors.New("server_label is required for mcp tools"), "invalid_request", http.StatusBadRequest)
+		}
+		server, err := model.GetEnabledMcpServerByName(tool.ServerLabel)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(fmt.Errorf("mcp server %s not found", tool.ServerLabel), "mcp_server_not_found", http.StatusBadRequest)
+		}
+		if err := service.CheckMcpServerPermission(c, server, info.Group); err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "mcp_server_forbidden", http.StatusForbidden)
+		}
+		client, err := service.GetMcpClient(c.Request.Context(), server)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "mcp_server_unavailable", http.StatusBadGateway)
+		}
+		mcpTools, err := client.ListTools(c.Request.Context(), false)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "mcp_server_unavailable", http.StatusBadGateway)
+		}
+		allowed := make(map[string]bool, len(tool.AllowedTools))
+		for _, name := range tool.AllowedTools {
+			allowed[name] = true
+		}
+		for _, mcpTool := range mcpTools {
+			if len(allowed) > 0 && !allowed[mcpTool.Name] {
+				continue
+			}
+			set.add(newMcpServerTool(server, mcpTool))
+		}
+	}
+	if !hasMcp {
+		return nil
+	}
+	if settings.MaxTools > 0 && len(set.tools) > settings.MaxTools {
+		return service.OpenAIErrorWrapperLocal(fmt.Errorf("too many server tools: %d, maximum is %d", len(set.tools), settings.MaxTools), "invalid_request", http.StatusBadRequest)
+	}
+	if settings.MaxRounds > set.maxRounds {
+		set.maxRounds = settings.MaxRounds
+	}
+	return nil
+}
+
+func newMcpServerTool(server *model.McpServer, mcpTool service.McpTool) *serverTool {
+	parameters := mcpTool.InputSchema
+	if parameters == nil {
+		parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	return &serverTool{
+		definition: dto.ToolCallRequest{
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:        mcpToolFunctionName(server.Name, mcpTool.Name),
+				Description: mcpTool.Description,
+				Parameters:  parameters,
+			},
+		},
+		execute: func(c *gin.Context, info *relaycommon.RelayInfo, toolCall dto.ToolCallRequest) string {
+			return executeMcpTool(c, info, server, mcpTool.Name, toolCall.Function.Arguments)
+		},
+	}
+}
+
+// executeMcpTool 调用 MCP 工具并记录调用，调用失败时把错误告知模型，只有成功的调用计费
+func executeMcpTool(c *gin.Context, info *relaycommon.RelayInfo, server *model.McpServer, toolName string, arguments string) string {
+	call := relaycommon.McpToolCallInfo{
+		Server:       server.Name,
+		Tool:         toolName,
+		PricePerCall: server.PricePerCall,
+	}
+	defer func() {
+		info.McpToolCalls = append(info.McpToolCalls, call)
+		common.LogInfo(c, fmt.Sprintf("mcp tool call %s/%s, success: %t", server.Name, toolName, call.Success))
+	}()
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	if !json.Valid([]byte(arguments)) {
+		return "Invalid tool arguments: arguments must be a JSON object"
+	}
+	client, err := service.GetMcpClient(c.Request.Context(), server)
+	if err != nil {
+		return "Tool call failed: " + err.Error()
+	}
+	raw, err := client.CallTool(c.Request.Context(), toolName, json.RawMessage(arguments))
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("mcp tool call %s/%s failed: %s", server.Name, toolName, err.Error()))
+		return "Tool call failed: " + err.Error()
+	}
+	call.Success = true
+	var result service.McpToolResult
+	if err := common.DecodeJson(raw, &result); err != nil {
+		return string(raw)
+	}
+	if result.IsError {
+		return "Tool returned an error: " + result.Text()
+	}
+	return result.Text()
+}
@@ -2,10 +2,12 @@ package relay
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"one-api/common"
 	"one-api/dto"
@@ -24,7 +26,7 @@ func getAndValidImageRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.
 	imageRequest := &dto.ImageRequest{}
 
 	switch info.RelayMode {
-	case relayconstant.RelayModeImagesEdits:
+	case relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
 		_, err := c.MultipartForm()
 		if err != nil {
 			return nil, err
@@ -35,7 +37,28 @@ func getAndValidImageRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.
 		imageRequest.N = common.String2Int(formData.Get("n"))
 		imageRequest.Quality = formData.Get("quality")
 		imageRequest.Size = formData.Get("size")
+		imageRequest.ResponseFormat = formData.Get("response_format")
+		imageRequest.User = formData.Get("user")
+		imageRequest.Background = formData.Get("background")
+		imageRequest.OutputFormat = formData.Get("output_format")
+		imageRequest.Images, imageRequest.Mask, err = getImageFiles(c)
+		if err != nil {
+			return nil, err
+		}
 
+		if info.RelayMode == relayconstant.RelayModeImagesVariations {
+			if imageRequest.Model == "" {
+				imageRequest.Model = "dall-e-2"
+			}
+			if len(imageRequest.Images) != 1 {
+				return nil, errors.New("exactly one image is required for image variations")
+			}
+			if imageRequest.Mask != nil {
+				return nil, errors.New("mask is not supported for image variations")
+			}
+		} else if imageRequest.Prompt == "" {
+			return nil, errors.New("prompt is required")
+		}
 		if imageRequest.Model == "gpt-image-1" {
 			if imageRequest.Quality == "" {
 				imageRequest.Quality = "standard"
@@ -101,6 +124,110 @@ func getAndValidImageRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.
 	return imageRequest, nil
 }
 
+// getImageFiles 读取编辑 / 变体请求上传的图片与蒙版，兼容 image、image[] 与 image[n] 字段
+func getImageFiles(c *gin.Context) ([]*dto.ImageFile, *dto.ImageFile, error) {
+	form := c.Request.MultipartForm
+	if form == nil || form.File == nil {
+		return nil, nil, errors.New("no multipart form data found")
+	}
+	imageHeaders := form.File["image"]
+	if len(imageHeaders) == 0 {
+		imageHeaders = form.File["image[]"]
+	}
+	if len(imageHeaders) == 0 {
+		for fieldName, files := range form.File {
+			if strings.HasPrefix(fieldName, "image[") {
+				imageHeaders = append(imageHeaders, files...)
+			}
+		}
+	}
+	if len(imageHeaders) == 0 {
+		return nil, nil, errors.New("image is required")
+	}
+	images := make([]*dto.ImageFile, 0, len(imageHeaders))
+	for i, fileHeader := range imageHeaders {
+		image, err := readImageFile(fileHeader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read image file %d: %w", i, err)
+		}
+		images = append(images, image)
+	}
+	var mask *dto.ImageFile
+	if maskHeaders := form.File["mask"]; len(maskHeaders) > 0 {
+		var err error
+		mask, err = readImageFile(maskHeaders[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read mask file: %w", err)
+		}
+		// 蒙版的透明区域表示需要编辑的部分，必须为带 alpha 通道的 PNG
+		if mask.MimeType != "image/png" {
+			return nil, nil, errors.New("mask must be a png image")
+		}
+	}
+	return images, mask, nil
+}
+
+func readImageFile(fileHeader *multipart.FileHeader) (*dto.ImageFile, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	mimeType := fileHeader.Header.Get("Content-Type")
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = http.DetectContentType(data)
+	}
+	return &dto.ImageFile{
+		Filename: fileHeader.Filename,
+		MimeType: mimeType,
+		Data:     data,
+	}, nil
+}
+
+// getImageSizeRatio 按尺寸计算单张图片的价格倍率，未知尺寸按相对 1024x1024 的面积计算
+func getImageSizeRatio(size string) float64 {
+	switch size {
+	case "", "1024x1024":
+		return 1
+	case "256x256":
+		return 0.4
+	case "512x512":
+		return 0.45
+	case "1024x1792", "1792x1024":
+		return 2
+	case "1024x1536", "1536x1024":
+		return 1.5
+	}
+	var width, height int
+	if _, err := fmt.Sscanf(strings.Replace(size, "*", "x", 1), "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		return 1
+	}
+	return float64(width*height) / (1024 * 1024)
+}
+
+// getImageQualityRatio 按品质计算单张图片的价格倍率
+func getImageQualityRatio(model string, quality string, size string) float64 {
+	switch {
+	case model == "dall-e-3" && quality == "hd":
+		if size == "1024x1792" || size == "1792x1024" {
+			return 1.5
+		}
+		return 2
+	case strings.HasPrefix(model, "gpt-image"):
+		switch quality {
+		case "low":
+			return 0.25
+		case "high", "hd":
+			return 4
+		}
+	}
+	return 1
+}
+
 func ImageHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
 	relayInfo := relaycommon.GenRelayInfo(c)
 
@@ -140,25 +267,8 @@ func ImageHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
 		}()
 
 	} else {
-		sizeRatio := 1.0
-		// Size
-		if imageRequest.Size == "256x256" {
-			sizeRatio = 0.4
-		} else if imageRequest.Size == "512x512" {
-			sizeRatio = 0.45
-		} else if imageRequest.Size == "1024x1024" {
-			sizeRatio = 1
-		} else if imageRequest.Size == "1024x1792" || imageRequest.Size == "1792x1024" {
-			sizeRatio = 2
-		}
-
-		qualityRatio := 1.0
-		if imageRequest.Model == "dall-e-3" && imageRequest.Quality == "hd" {
-			qualityRatio = 2.0
-			if imageRequest.Size == "1024x1792" || imageRequest.Size == "1792x1024" {
-				qualityRatio = 1.5
-			}
-		}
+		sizeRatio := getImageSizeRatio(imageRequest.Size)
+		qualityRatio := getImageQualityRatio(imageRequest.Model, imageRequest.Quality, imageRequest.Size)
 
 		// reset model price
 		priceData.ModelPrice *= sizeRatio * qualityRatio * float64(imageRequest.N)
@@ -184,8 +294,8 @@ func ImageHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
 	if err != nil {
 		return service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
 	}
-	if relayInfo.RelayMode == relayconstant.RelayModeImagesEdits {
-		requestBody = convertedRequest.(io.Reader)
+	if reader, ok := convertedRequest.(io.Reader); ok {
+		requestBody = reader
 	} else {
 		jsonData, err := json.Marshal(convertedRequest)
 		if err != nil {
@@ -216,7 +326,20 @@ func ImageHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
 		}
 	}
 
+	// 需要保存结果时先缓存响应，替换为网关签名地址后再输出
+	var imageWriter *helper.ConvertWriter
+	originWriter := c.Writer
+	if service.MediaImageStorageEnabled() && !relayInfo.IsStream {
+		imageWriter = helper.NewBufferedConvertWriter(originWriter)
+		c.Writer = imageWriter
+	}
 	usage, openaiErr := adaptor.DoResponse(c, httpResp, relayInfo)
+	if imageWriter != nil {
+		c.Writer = originWriter
+		if openaiErr == nil {
+			writeStoredImageResponse(c, relayInfo, imageWriter)
+		}
+	}
 	if openaiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
@@ -229,12 +352,67 @@ func ImageHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
 	if usage.(*dto.Usage).PromptTokens == 0 {
 		usage.(*dto.Usage).PromptTokens = imageRequest.N
 	}
-	quality := "standard"
-	if imageRequest.Quality == "hd" {
-		quality = "hd"
-	}
+	quality := common.GetStringIfEmpty(imageRequest.Quality, "standard")
 
-	logContent := fmt.Sprintf("大小 %s, 品质 %s", imageRequest.Size, quality)
+	logContent := fmt.Sprintf("大小 %s, 品质 %s, 数量 %d", imageRequest.Size, quality, imageRequest.N)
 	postConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, logContent)
 	return nil
 }
+
+// writeStoredImageResponse 将图片结果（url 或 b64_json）保存到网关存储，并在 url 字段返回签名地址
+func writeStoredImageResponse(c *gin.Context, relayInfo *relaycommon.RelayInfo, writer *helper.ConvertWriter) {
+	body := writer.Body()
+	contentType := writer.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	var response map[string]any
+	if err := common.DecodeJson(body, &response); err == nil {
+		if items, ok := response["data"].([]any); ok && len(items) > 0 {
+			if storeImageItems(c, relayInfo, items) {
+				if newBody, err := json.Marshal(response); err == nil {
+					body = newBody
+				}
+			}
+		}
+	}
+	c.Data(writer.Status(), contentType, body)
+}
+
+func storeImageItems(c *gin.Context, relayInfo *relaycommon.RelayInfo, items []any) bool {
+	requestId := c.GetString(common.RequestIdKey)
+	changed := false
+	for _, rawItem := range items {
+		item, ok := rawItem.(map[string]any)
+		if !ok {
+			continue
+		}
+		var object *model.MediaObject
+		var err error
+		if b64, _ := item["b64_json"].(string); b64 != "" {
+			var data []byte
+			data, err = base64.StdEncoding.DecodeString(b64)
+			if err == nil {
+				object, err = service.StoreMedia(c.Request.Context(), relayInfo.UserId, model.MediaSourceImage, requestId, "", "", data)
+			}
+		} else if imageURL, _ := item["url"].(string); strings.HasPrefix(imageURL, "http") {
+			var client *http.Client
+			client, err = service.GetChannelHttpClient(relayInfo.ChannelId)
+			if err == nil {
+				object, err = service.StoreMediaFromURL(c.Request.Context(), client, relayInfo.UserId, model.MediaSourceImage, requestId, imageURL)
+			}
+		} else {
+			continue
+		}
+		if err != nil {
+			common.LogError(c, "store image failed: "+err.Error())
+			if errors.Is(err, service.ErrMediaStorageLimitExceeded) {
+				break
+			}
+			continue
+		}
+		item["url"] = service.SignMediaURL(object)
+		changed = true
+	}
+	return changed
+}
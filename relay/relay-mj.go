@@ -32,6 +32,25 @@ func RelayMidjourneyImage(c *gin.Context) {
 		})
 		return
 	}
+	// 图片已保存到网关存储时直接读取，不再请求可能已过期的上游地址
+	if objects, err := model.GetTaskMediaObjects(model.MediaSourceMidjourney, midjourneyTask.MjId); err == nil {
+		for _, object := range objects {
+			if object.OriginURL != midjourneyTask.ImageUrl {
+				continue
+			}
+			reader, err := service.OpenMedia(c.Request.Context(), object)
+			if err != nil {
+				common.LogError(c, "open stored midjourney image failed: "+err.Error())
+				break
+			}
+			defer reader.Close()
+			c.Writer.Header().Set("Content-Type", object.ContentType)
+			if _, err := io.Copy(c.Writer, reader); err != nil {
+				log.Println("Failed to stream image:", err)
+			}
+			return
+		}
+	}
 	var httpClient *http.Client
 	if channel, err := model.CacheGetChannel(midjourneyTask.ChannelId); err == nil {
 		if proxy, ok := channel.GetSetting()["proxy"]; ok {
@@ -99,6 +118,7 @@ func RelayMidjourneyNotify(c *gin.Context) *dto.MidjourneyResponse {
 			Result:      "",
 		}
 	}
+	oldStatus := midjourneyTask.Status
 	midjourneyTask.Progress = midjRequest.Progress
 	midjourneyTask.PromptEn = midjRequest.PromptEn
 	midjourneyTask.State = midjRequest.State
@@ -115,10 +135,37 @@ func RelayMidjourneyNotify(c *gin.Context) *dto.MidjourneyResponse {
 			Description: "update_midjourney_task_failed",
 		}
 	}
+	if midjourneyTask.Status != oldStatus {
+		ArchiveMidjourneyMedia(midjourneyTask)
+		NotifyMidjourneyTaskWebhook(midjourneyTask)
+	}
 
 	return nil
 }
 
+// midjourneyStoredImageURL 返回已保存到网关存储的图片签名地址
+func midjourneyStoredImageURL(task *model.Midjourney) (string, bool) {
+	if task.Status != "SUCCESS" || task.ImageUrl == "" || !service.MediaTaskStorageEnabled() {
+		return "", false
+	}
+	signedURL, ok := service.GetTaskMediaURLs(model.MediaSourceMidjourney, task.MjId)[task.ImageUrl]
+	return signedURL, ok
+}
+
+// ArchiveMidjourneyMedia 任务成功后将图片保存到网关存储
+func ArchiveMidjourneyMedia(task *model.Midjourney) {
+	if task.Status != "SUCCESS" || task.ImageUrl == "" {
+		return
+	}
+	service.ArchiveTaskMedia(task.UserId, task.ChannelId, model.MediaSourceMidjourney, task.MjId, []string{task.ImageUrl})
+}
+
+// NotifyMidjourneyTaskWebhook 向任务的回调地址推送状态变化
+func NotifyMidjourneyTaskWebhook(task *model.Midjourney) {
+	service.EnqueueTaskWebhook(task.UserId, task.CallbackURL, constant.TaskPlatformMidjourney, task.MjId, task.Status,
+		coverMidjourneyTaskDto(nil, task))
+}
+
 func coverMidjourneyTaskDto(c *gin.Context, originTask *model.Midjourney) (midjourneyTask dto.MidjourneyDto) {
 	midjourneyTask.MjId = originTask.MjId
 	midjourneyTask.Progress = originTask.Progress
@@ -136,6 +183,9 @@ func coverMidjourneyTaskDto(c *gin.Context, originTask *model.Midjourney) (midjo
 	} else {
 		midjourneyTask.ImageUrl = originTask.ImageUrl
 	}
+	if signedURL, ok := midjourneyStoredImageURL(originTask); ok {
+		midjourneyTask.ImageUrl = signedURL
+	}
 	midjourneyTask.Status = originTask.Status
 	midjourneyTask.FailReason = originTask.FailReason
 	midjourneyTask.Action = originTask.Action
@@ -173,6 +223,10 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 	if swapFaceRequest.SourceBase64 == "" || swapFaceRequest.TargetBase64 == "" {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "sour_base64_and_target_base64_is_required")
 	}
+	callbackURL, err := service.GetTaskCallbackURL(c)
+	if err != nil {
+		return service.MidjourneyErrorWrapper(constant.MjRequestError, "invalid_callback_url")
+	}
 	modelName := service.CoverActionToModelName(constant.MjActionSwapFace)
 	modelPrice, success := operation_setting.GetModelPrice(modelName, true)
 	// 如果没有配置价格，则使用默认价格
@@ -251,6 +305,7 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 		FailReason:  "",
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       quota,
+		CallbackURL: callbackURL,
 	}
 	err = midjourneyTask.Insert()
 	if err != nil {
@@ -383,6 +438,10 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 	if err != nil {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "bind_request_body_failed")
 	}
+	callbackURL, err := service.GetTaskCallbackURL(c)
+	if err != nil {
+		return service.MidjourneyErrorWrapper(constant.MjRequestError, "invalid_callback_url")
+	}
 
 	if relayMode == relayconstant.RelayModeMidjourneyAction { // midjourney plus，需要从customId中获取任务信息
 		mjErr := service.CoverPlusActionToNormalAction(&midjRequest)
@@ -560,6 +619,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 		FailReason:  "",
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       quota,
+		CallbackURL: callbackURL,
 	}
 	if midjResponse.Code == 3 {
 		//无实例账号自动禁用渠道（No available account instance）
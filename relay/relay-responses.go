@@ -10,6 +10,7 @@ import (
 	"one-api/common"
 	"one-api/dto"
 	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting"
@@ -68,6 +69,17 @@ func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 		return service.OpenAIErrorWrapperLocal(err, "model_mapped_error", http.StatusBadRequest)
 	}
 	req.Model = relayInfo.UpstreamModelName
+
+	// 渠道不支持 Responses API 时转换为 Chat Completions
+	bridgeToChat := shouldBridgeResponsesToChat(relayInfo)
+	recorder, err := newResponsesRecorder(c, relayInfo, req)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "invalid_responses_request", http.StatusBadRequest)
+	}
+	historyInlined, err := inlineResponsesHistory(relayInfo, req, bridgeToChat)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "invalid_previous_response_id", http.StatusBadRequest)
+	}
 	if value, exists := c.Get("prompt_tokens"); exists {
 		promptTokens := value.(int)
 		relayInfo.SetPromptTokens(promptTokens)
@@ -97,16 +109,24 @@ func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 	if adaptor == nil {
 		return service.OpenAIErrorWrapperLocal(fmt.Errorf("invalid api type: %d", relayInfo.ApiType), "invalid_api_type", http.StatusBadRequest)
 	}
+	if bridgeToChat {
+		relayInfo.SwitchRelayMode(relayconstant.RelayModeChatCompletions, "/v1/chat/completions")
+	}
 	adaptor.Init(relayInfo)
 	var requestBody io.Reader
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled {
+	if model_setting.GetGlobalSettings().PassThroughRequestEnabled && !bridgeToChat && !historyInlined {
 		body, err := common.GetRequestBody(c)
 		if err != nil {
 			return service.OpenAIErrorWrapperLocal(err, "get_request_body_error", http.StatusInternalServerError)
 		}
 		requestBody = bytes.NewBuffer(body)
 	} else {
-		convertedRequest, err := adaptor.ConvertOpenAIResponsesRequest(c, relayInfo, *req)
+		var convertedRequest any
+		if bridgeToChat {
+			convertedRequest, err = convertResponsesToChatRequest(c, adaptor, relayInfo, req)
+		} else {
+			convertedRequest, err = adaptor.ConvertOpenAIResponsesRequest(c, relayInfo, *req)
+		}
 		if err != nil {
 			return service.OpenAIErrorWrapperLocal(err, "convert_request_error", http.StatusBadRequest)
 		}
@@ -155,12 +175,25 @@ func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 		}
 	}
 
-	usage, openaiErr := adaptor.DoResponse(c, httpResp, relayInfo)
+	originWriter := c.Writer
+	if recorder != nil {
+		c.Writer = recorder.writer
+	}
+	var usage any
+	if bridgeToChat {
+		usage, openaiErr = doResponsesViaChat(c, adaptor, httpResp, relayInfo)
+	} else {
+		usage, openaiErr = adaptor.DoResponse(c, httpResp, relayInfo)
+	}
+	c.Writer = originWriter
 	if openaiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
 		return openaiErr
 	}
+	if recorder != nil {
+		recorder.save(c, relayInfo)
+	}
 
 	if strings.HasPrefix(relayInfo.OriginModelName, "gpt-4o-audio") {
 		service.PostAudioConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
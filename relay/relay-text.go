@@ -132,6 +132,11 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 	if err != nil {
 		return service.OpenAIErrorWrapperLocal(err, "model_price_error", http.StatusInternalServerError)
 	}
+	// 网关拆分 n>1 的请求时每个上游请求单独计费，按 n 个请求预扣
+	if shouldFanOut(relayInfo, textRequest) && !shouldBridgeChatToResponses(relayInfo) {
+		priceData.ShouldPreConsumedQuota *= textRequest.N
+		relayInfo.PreConsumedTokens *= textRequest.N
+	}
 
 	// pre-consume quota 预消耗配额
 	preConsumedQuota, userQuota, openaiErr := preConsumeQuota(c, priceData.ShouldPreConsumedQuota, relayInfo)
@@ -165,47 +170,108 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		relayInfo.ShouldIncludeUsage = true
 	}
 
+	// 精确匹配的响应缓存，命中时直接回放并按缓存倍率计费
+	cache := newResponseCache(c, relayInfo, textRequest)
+	if cachedUsage := cache.get(c); cachedUsage != nil {
+		relayInfo.ResponseCacheHit = true
+		postConsumeQuota(c, relayInfo, cachedUsage, preConsumedQuota, userQuota, priceData, "")
+		return nil
+	}
+	cache.record(c)
+	defer cache.restore(c)
+
+	// 语义缓存，按最后一条用户消息的向量相似度复用回答
+	semantic := newSemanticCache(c, relayInfo, textRequest)
+	if cachedUsage := semantic.get(c, relayInfo); cachedUsage != nil {
+		postConsumeQuota(c, relayInfo, cachedUsage, preConsumedQuota, userQuota, priceData, "")
+		return nil
+	}
+	semantic.record(c)
+	defer semantic.restore(c)
+
 	adaptor := GetAdaptor(relayInfo.ApiType)
 	if adaptor == nil {
 		return service.OpenAIErrorWrapperLocal(fmt.Errorf("invalid api type: %d", relayInfo.ApiType), "invalid_api_type", http.StatusBadRequest)
 	}
+	// 仅支持 Responses API 的模型转换为 /v1/responses
+	bridgeToResponses := shouldBridgeChatToResponses(relayInfo)
+	if bridgeToResponses {
+		relayInfo.SwitchRelayMode(relayconstant.RelayModeResponses, "/v1/responses")
+	}
 	adaptor.Init(relayInfo)
+
+	// 由网关执行的工具（联网搜索、MCP）
+	if !bridgeToResponses {
+		serverTools, toolsErr := getServerTools(c, relayInfo, textRequest)
+		if toolsErr != nil {
+			return toolsErr
+		}
+		if serverTools != nil {
+			var usage *dto.Usage
+			usage, openaiErr = doServerTools(c, adaptor, relayInfo, textRequest, serverTools)
+			if openaiErr != nil {
+				return openaiErr
+			}
+			cache.save(c, usage)
+			semantic.save(usage)
+			postConsumeQuota(c, relayInfo, usage, preConsumedQuota, userQuota, priceData, "")
+			return nil
+		}
+	}
+
+	// 上游不支持 json_schema 时由网关模拟 Structured Outputs，n>1 时在其中逐个生成
+	if !bridgeToResponses && shouldEmulateStructuredOutput(relayInfo, textRequest) {
+		var usage *dto.Usage
+		usage, openaiErr = doStructuredOutput(c, adaptor, relayInfo, textRequest)
+		if openaiErr != nil {
+			return openaiErr
+		}
+		cache.save(c, usage)
+		semantic.save(usage)
+		postConsumeQuota(c, relayInfo, usage, preConsumedQuota, userQuota, priceData, "")
+		return nil
+	}
+
+	// 上游不支持 n 参数时并发请求后合并，需要模拟工具调用时在每个请求中完成
+	if !bridgeToResponses && shouldFanOut(relayInfo, textRequest) {
+		var usage *dto.Usage
+		usage, openaiErr = doFanOut(c, adaptor, relayInfo, textRequest)
+		if openaiErr != nil {
+			return openaiErr
+		}
+		cache.save(c, usage)
+		semantic.save(usage)
+		postConsumeQuota(c, relayInfo, usage, preConsumedQuota, userQuota, priceData, "")
+		return nil
+	}
+
+	// 上游不支持函数调用时在提示词中模拟工具调用
+	emulateToolCalls := !bridgeToResponses && shouldEmulateToolCalls(relayInfo, textRequest)
+	if emulateToolCalls {
+		service.ApplyToolCallPrompt(textRequest)
+	}
+
 	var requestBody io.Reader
 
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled {
+	if model_setting.GetGlobalSettings().PassThroughRequestEnabled && !bridgeToResponses && !emulateToolCalls {
 		body, err := common.GetRequestBody(c)
 		if err != nil {
 			return service.OpenAIErrorWrapperLocal(err, "get_request_body_failed", http.StatusInternalServerError)
 		}
 		requestBody = bytes.NewBuffer(body)
 	} else {
-		convertedRequest, err := adaptor.ConvertOpenAIRequest(c, relayInfo, textRequest)
-		if err != nil {
-			return service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
+		var convertedRequest any
+		if bridgeToResponses {
+			convertedRequest, err = convertChatToResponsesRequest(c, adaptor, relayInfo, textRequest)
+		} else {
+			convertedRequest, err = adaptor.ConvertOpenAIRequest(c, relayInfo, textRequest)
 		}
-		jsonData, err := json.Marshal(convertedRequest)
 		if err != nil {
-			return service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
-		}
-
-		// apply param override
-		if len(relayInfo.ParamOverride) > 0 {
-			reqMap := make(map[string]interface{})
-			err = json.Unmarshal(jsonData, &reqMap)
-			if err != nil {
-				return service.OpenAIErrorWrapperLocal(err, "param_override_unmarshal_failed", http.StatusInternalServerError)
-			}
-			for key, value := range relayInfo.ParamOverride {
-				reqMap[key] = value
-			}
-			jsonData, err = json.Marshal(reqMap)
-			if err != nil {
-				return service.OpenAIErrorWrapperLocal(err, "param_override_marshal_failed", http.StatusInternalServerError)
-			}
+			return service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
 		}
-
-		if common.DebugEnabled {
-			println("requestBody: ", string(jsonData))
+		jsonData, openaiErr := getRequestBodyJson(relayInfo, convertedRequest)
+		if openaiErr != nil {
+			return openaiErr
 		}
 		requestBody = bytes.NewBuffer(jsonData)
 	}
@@ -230,13 +296,22 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		}
 	}
 
-	usage, openaiErr := adaptor.DoResponse(c, httpResp, relayInfo)
+	var usage any
+	if bridgeToResponses {
+		usage, openaiErr = doChatViaResponses(c, adaptor, httpResp, relayInfo)
+	} else if emulateToolCalls {
+		usage, openaiErr = doToolCallEmulation(c, adaptor, httpResp, relayInfo)
+	} else {
+		usage, openaiErr = adaptor.DoResponse(c, httpResp, relayInfo)
+	}
 	if openaiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
 		return openaiErr
 	}
 
+	cache.save(c, usage.(*dto.Usage))
+	semantic.save(usage.(*dto.Usage))
 	if strings.HasPrefix(relayInfo.OriginModelName, "gpt-4o-audio") {
 		service.PostAudioConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
 	} else {
@@ -245,6 +320,35 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 	return nil
 }
 
+// getRequestBodyJson 序列化转换后的请求并应用渠道参数覆盖
+func getRequestBodyJson(relayInfo *relaycommon.RelayInfo, convertedRequest any) ([]byte, *dto.OpenAIErrorWithStatusCode) {
+	jsonData, err := json.Marshal(convertedRequest)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+	}
+
+	// apply param override
+	if len(relayInfo.ParamOverride) > 0 {
+		reqMap := make(map[string]interface{})
+		err = json.Unmarshal(jsonData, &reqMap)
+		if err != nil {
+			return nil, service.OpenAIErrorWrapperLocal(err, "param_override_unmarshal_failed", http.StatusInternalServerError)
+		}
+		for key, value := range relayInfo.ParamOverride {
+			reqMap[key] = value
+		}
+		jsonData, err = json.Marshal(reqMap)
+		if err != nil {
+			return nil, service.OpenAIErrorWrapperLocal(err, "param_override_marshal_failed", http.StatusInternalServerError)
+		}
+	}
+
+	if common.DebugEnabled {
+		println("requestBody: ", string(jsonData))
+	}
+	return jsonData, nil
+}
+
 func getPromptTokens(textRequest *dto.GeneralOpenAIRequest, info *relaycommon.RelayInfo) (int, error) {
 	var promptTokens int
 	var err error
@@ -283,28 +387,47 @@ func checkRequestSensitive(textRequest *dto.GeneralOpenAIRequest, info *relaycom
 
 // 预扣费并返回用户剩余配额
 func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommon.RelayInfo) (int, int, *dto.OpenAIErrorWithStatusCode) {
-	// 首先检查订阅配额是否可用
+	// 首先按预估用量（输入加最大输出）原子预留订阅配额
+	estimatedTokens := relayInfo.PromptTokens
+	if relayInfo.PreConsumedTokens > estimatedTokens {
+		estimatedTokens = relayInfo.PreConsumedTokens
+	}
 	subscriptionService := service.NewSubscriptionService()
-	hasSubscription, quotaInfo, err := subscriptionService.CheckModelQuotaAvailable(relayInfo.UserId, relayInfo.OriginModelName, 1)
+	hasSubscription, uncoveredQuota, err := subscriptionService.ReserveUsage(relayInfo, estimatedTokens, preConsumedQuota)
 	if err != nil {
 		common.LogError(c, fmt.Sprintf("检查订阅配额失败: %v", err))
 		// 不阻断请求，继续使用原有计费方式
 	}
 
-	if hasSubscription && quotaInfo != nil && quotaInfo.Remaining > 0 {
-		// 有可用订阅配额，标记使用订阅配额
+	if hasSubscription {
+		// 有可用订阅配额，标记使用订阅配额，实际用量在请求完成后结算
 		relayInfo.UsedSubscriptionQuota = true
-		common.LogInfo(c, fmt.Sprintf("用户 %d 将使用订阅配额，模型: %s，剩余: %d",
-			relayInfo.UserId, relayInfo.OriginModelName, quotaInfo.Remaining))
-		return 0, 0, nil
+		if uncoveredQuota == 0 {
+			common.LogInfo(c, fmt.Sprintf("用户 %d 将使用订阅配额，模型: %s", relayInfo.UserId, relayInfo.OriginModelName))
+		} else {
+			common.LogInfo(c, fmt.Sprintf("用户 %d 订阅配额不足以覆盖预估用量，超出部分按余额计费，模型: %s", relayInfo.UserId, relayInfo.OriginModelName))
+		}
+		// 余额只需覆盖订阅未能覆盖的预估用量
+		preConsumedQuota = uncoveredQuota
+	}
+
+	// 使用原有计费方式
+	consumedQuota, userQuota, openaiErr := preConsumeUserQuota(c, preConsumedQuota, relayInfo)
+	if openaiErr != nil {
+		// 余额预扣失败，释放已预留的订阅配额
+		subscriptionService.ReleaseReservations(relayInfo)
 	}
+	return consumedQuota, userQuota, openaiErr
+}
 
-	// 没有可用订阅配额，使用原有计费方式
+// preConsumeUserQuota 从用户余额预扣额度
+func preConsumeUserQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommon.RelayInfo) (int, int, *dto.OpenAIErrorWithStatusCode) {
 	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
 	if err != nil {
 		return 0, 0, service.OpenAIErrorWrapperLocal(err, "get_user_quota_failed", http.StatusInternalServerError)
 	}
-	if userQuota <= 0 {
+	// 订阅已覆盖全部预估用量时允许余额为 0
+	if userQuota < 0 || (userQuota == 0 && (preConsumedQuota > 0 || !relayInfo.UsedSubscriptionQuota)) {
 		return 0, 0, service.OpenAIErrorWrapperLocal(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
 	}
 	if userQuota-preConsumedQuota < 0 {
@@ -343,6 +466,8 @@ func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 }
 
 func returnPreConsumedQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo, userQuota int, preConsumedQuota int) {
+	// 请求失败，释放预留的订阅配额
+	service.NewSubscriptionService().ReleaseReservations(relayInfo)
 	if preConsumedQuota != 0 {
 		gopool.Go(func() {
 			relayInfoCopy := *relayInfo
@@ -358,36 +483,6 @@ func returnPreConsumedQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo, us
 func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	usage *dto.Usage, preConsumedQuota int, userQuota int, priceData helper.PriceData, extraContent string) {
 
-	// 如果使用了订阅配额，实际消费订阅配额
-	if relayInfo.UsedSubscriptionQuota {
-		subscriptionService := service.NewSubscriptionService()
-		hasSubscription, _, err := subscriptionService.CheckAndConsumeSubscriptionQuota(ctx, relayInfo, relayInfo.OriginModelName, 1)
-		if err != nil {
-			common.SysError(fmt.Sprintf("消费订阅配额失败: %v", err))
-			// 如果订阅配额消费失败，回退到普通计费方式
-			relayInfo.UsedSubscriptionQuota = false
-		} else if hasSubscription {
-			common.LogInfo(ctx, fmt.Sprintf("用户 %d 使用订阅配额完成请求，订阅ID: %d，模型: %s",
-				relayInfo.UserId, relayInfo.SubscriptionId, relayInfo.OriginModelName))
-
-			// 记录使用记录（包含token信息）
-			if usage != nil {
-				totalTokens := usage.PromptTokens + usage.CompletionTokens
-				err := model.RecordSubscriptionUsage(relayInfo.UserId, relayInfo.SubscriptionId,
-					relayInfo.OriginModelName, 1, totalTokens, relayInfo.RequestId)
-				if err != nil {
-					common.SysError(fmt.Sprintf("更新订阅使用记录失败: %v", err))
-				}
-			}
-			return
-		} else {
-			// 订阅配额不足，回退到普通计费方式
-			relayInfo.UsedSubscriptionQuota = false
-			common.LogInfo(ctx, fmt.Sprintf("用户 %d 订阅配额不足，回退到普通计费，模型: %s",
-				relayInfo.UserId, relayInfo.OriginModelName))
-		}
-	}
-
 	if usage == nil {
 		usage = &dto.Usage{
 			PromptTokens:     relayInfo.PromptTokens,
@@ -467,6 +562,19 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 				fileSearchTool.CallCount, dFileSearchQuota.String())
 		}
 	}
+	// MCP 工具调用计费，按各服务器的单次价格累加
+	var dMcpQuota decimal.Decimal
+	var mcpCallCount int
+	for _, call := range relayInfo.McpToolCalls {
+		if call.Success {
+			mcpCallCount++
+			dMcpQuota = dMcpQuota.Add(decimal.NewFromFloat(call.PricePerCall))
+		}
+	}
+	if mcpCallCount > 0 {
+		dMcpQuota = dMcpQuota.Mul(dGroupRatio).Mul(dQuotaPerUnit)
+		extraContent += fmt.Sprintf("MCP 工具调用 %d 次，调用花费 %s", mcpCallCount, dMcpQuota.String())
+	}
 
 	var quotaCalculateDecimal decimal.Decimal
 
@@ -513,8 +621,14 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	// 添加 responses tools call 调用的配额
 	quotaCalculateDecimal = quotaCalculateDecimal.Add(dWebSearchQuota)
 	quotaCalculateDecimal = quotaCalculateDecimal.Add(dFileSearchQuota)
+	quotaCalculateDecimal = quotaCalculateDecimal.Add(dMcpQuota)
 	// 添加 audio input 独立计费
 	quotaCalculateDecimal = quotaCalculateDecimal.Add(audioInputQuota)
+	// 命中响应缓存时按缓存倍率计费
+	responseCacheRatio, responseCacheHit := getResponseCacheRatio(relayInfo)
+	if responseCacheHit {
+		quotaCalculateDecimal = quotaCalculateDecimal.Mul(decimal.NewFromFloat(responseCacheRatio))
+	}
 
 	quota := int(quotaCalculateDecimal.Round(0).IntPart())
 	totalTokens := promptTokens + completionTokens
@@ -534,9 +648,23 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		logContent += fmt.Sprintf("（可能是上游超时）")
 		common.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, modelName, preConsumedQuota))
-	} else {
+	}
+
+	// 使用订阅配额抵扣本次用量，不足部分按余额计费
+	if relayInfo.UsedSubscriptionQuota {
+		var subscriptionContent string
+		quota, subscriptionContent = service.NewSubscriptionService().ConsumeUsage(ctx, relayInfo, totalTokens, quota)
+		if subscriptionContent != "" {
+			logContent += "，" + subscriptionContent
+		}
+	}
+	if totalTokens != 0 {
+		// 只统计订阅抵扣后按余额计费的额度
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
-		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		// 命中缓存时没有请求渠道
+		if !responseCacheHit {
+			model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		}
 	}
 
 	quotaDelta := quota - preConsumedQuota
@@ -556,10 +684,24 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		logModel = "gpt-4o-gizmo-*"
 		logContent += fmt.Sprintf("，模型 %s", modelName)
 	}
+	if relayInfo.ResponseCacheHit {
+		logContent += fmt.Sprintf("，响应缓存命中，缓存倍率 %.2f", responseCacheRatio)
+	} else if relayInfo.SemanticCacheSimilarity > 0 {
+		logContent += fmt.Sprintf("，语义缓存命中（相似度 %.4f），缓存倍率 %.2f", relayInfo.SemanticCacheSimilarity, responseCacheRatio)
+	}
 	if extraContent != "" {
 		logContent += ", " + extraContent
 	}
 	other := service.GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, cacheTokens, cacheRatio, modelPrice, userGroupRatio)
+	if relayInfo.ResponseCacheHit {
+		other["response_cache_hit"] = true
+	} else if relayInfo.SemanticCacheSimilarity > 0 {
+		other["semantic_cache_hit"] = true
+		other["semantic_cache_similarity"] = relayInfo.SemanticCacheSimilarity
+	}
+	if responseCacheHit {
+		other["response_cache_ratio"] = responseCacheRatio
+	}
 	if imageTokens != 0 {
 		other["image"] = true
 		other["image_ratio"] = imageRatio
@@ -585,6 +727,15 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 			other["file_search_price"] = fileSearchPrice
 		}
 	}
+	if len(relayInfo.McpToolCalls) > 0 {
+		calls := make([]string, 0, len(relayInfo.McpToolCalls))
+		for _, call := range relayInfo.McpToolCalls {
+			calls = append(calls, call.Server+"/"+call.Tool)
+		}
+		other["mcp_tool_calls"] = calls
+		other["mcp_call_count"] = mcpCallCount
+		other["mcp_quota"] = dMcpQuota.IntPart()
+	}
 	if !audioInputQuota.IsZero() {
 		other["audio_input_seperate_price"] = true
 		other["audio_input_token_count"] = audioTokens
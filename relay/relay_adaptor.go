@@ -1,6 +1,7 @@
 package relay
 
 import (
+	"one-api/common"
 	commonconstant "one-api/constant"
 	"one-api/relay/channel"
 	"one-api/relay/channel/ali"
@@ -22,7 +23,11 @@ import (
 	"one-api/relay/channel/palm"
 	"one-api/relay/channel/perplexity"
 	"one-api/relay/channel/siliconflow"
+	"one-api/relay/channel/task/kling"
+	taskminimax "one-api/relay/channel/task/minimax"
+	"one-api/relay/channel/task/runway"
 	"one-api/relay/channel/task/suno"
+	taskvolcengine "one-api/relay/channel/task/volcengine"
 	"one-api/relay/channel/tencent"
 	"one-api/relay/channel/vertex"
 	"one-api/relay/channel/volcengine"
@@ -101,6 +106,29 @@ func GetTaskAdaptor(platform commonconstant.TaskPlatform) channel.TaskAdaptor {
 	//	return &aiproxy.Adaptor{}
 	case commonconstant.TaskPlatformSuno:
 		return &suno.TaskAdaptor{}
+	case commonconstant.TaskPlatformKling:
+		return &kling.TaskAdaptor{}
+	case commonconstant.TaskPlatformRunway:
+		return &runway.TaskAdaptor{}
+	case commonconstant.TaskPlatformVolcEngine:
+		return &taskvolcengine.TaskAdaptor{}
+	case commonconstant.TaskPlatformMiniMax:
+		return &taskminimax.TaskAdaptor{}
 	}
 	return nil
 }
+
+// GetVideoTaskPlatform 根据渠道类型获取视频生成任务的平台，不支持视频生成的渠道返回空
+func GetVideoTaskPlatform(channelType int) commonconstant.TaskPlatform {
+	switch channelType {
+	case common.ChannelTypeKling:
+		return commonconstant.TaskPlatformKling
+	case common.ChannelTypeRunway:
+		return commonconstant.TaskPlatformRunway
+	case common.ChannelTypeVolcEngine:
+		return commonconstant.TaskPlatformVolcEngine
+	case common.ChannelTypeMiniMax:
+		return commonconstant.TaskPlatformMiniMax
+	}
+	return ""
+}
@@ -12,10 +12,13 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/relay/channel"
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/model_setting"
 	"one-api/setting/operation_setting"
 )
 
@@ -25,6 +28,17 @@ Task 任务通过平台、Action 区分任务
 func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 	platform := constant.TaskPlatform(c.GetString("platform"))
 	relayInfo := relaycommon.GenTaskRelayInfo(c)
+	isVideo := relayMode == relayconstant.RelayModeVideoSubmit
+	if isVideo {
+		// 视频生成的平台由选中的渠道决定，模型按渠道的模型重定向映射
+		platform = GetVideoTaskPlatform(relayInfo.ChannelType)
+		if platform == "" {
+			return service.TaskErrorWrapperLocal(fmt.Errorf("channel type %d does not support video generation", relayInfo.ChannelType), "invalid_api_platform", http.StatusBadRequest)
+		}
+		if err := helper.ModelMappedHelper(c, relayInfo.RelayInfo); err != nil {
+			return service.TaskErrorWrapperLocal(err, "model_mapped_error", http.StatusInternalServerError)
+		}
+	}
 
 	adaptor := GetTaskAdaptor(platform)
 	if adaptor == nil {
@@ -36,8 +50,15 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 	if taskErr != nil {
 		return
 	}
+	callbackURL, err := service.GetTaskCallbackURL(c)
+	if err != nil {
+		return service.TaskErrorWrapperLocal(err, "invalid_callback_url", http.StatusBadRequest)
+	}
 
 	modelName := service.CoverTaskActionToModelName(platform, relayInfo.Action)
+	if isVideo {
+		modelName = relayInfo.OriginModelName
+	}
 	modelPrice, success := operation_setting.GetModelPrice(modelName, true)
 	if !success {
 		defaultPrice, ok := operation_setting.GetDefaultModelRatioMap()[modelName]
@@ -56,6 +77,16 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 		taskErr = service.TaskErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 		return
 	}
+	// 视频按秒计费时价格乘以视频时长，否则按条计费
+	duration := 0
+	if isVideo {
+		if videoRequest, err := channel.GetVideoRequest(c); err == nil {
+			duration = videoRequest.Duration
+		}
+		if model_setting.GetVideoSettings().IsPerSecondModel(modelName) {
+			ratio *= float64(duration)
+		}
+	}
 	quota := int(ratio * common.QuotaPerUnit)
 	if userQuota-quota < 0 {
 		taskErr = service.TaskErrorWrapperLocal(errors.New("user quota is not enough"), "quota_not_enough", http.StatusForbidden)
@@ -123,6 +154,14 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 				other := make(map[string]interface{})
 				other["model_price"] = modelPrice
 				other["group_ratio"] = groupRatio
+				if isVideo {
+					perSecond := model_setting.GetVideoSettings().IsPerSecondModel(modelName)
+					if perSecond {
+						logContent = fmt.Sprintf("模型价格 %.4f / 秒，视频时长 %d 秒，分组倍率 %.2f，操作 %s", modelPrice, duration, groupRatio, relayInfo.Action)
+					}
+					other["video_duration"] = duration
+					other["video_per_second"] = perSecond
+				}
 				model.RecordConsumeLog(c, relayInfo.UserId, relayInfo.ChannelId, 0, 0,
 					modelName, tokenName, quota, logContent, relayInfo.TokenId, userQuota, 0, false, relayInfo.Group, other)
 				model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
@@ -137,10 +176,17 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 	}
 	relayInfo.ConsumeQuota = true
 	// insert task
-	task := model.InitTask(constant.TaskPlatformSuno, relayInfo)
+	task := model.InitTask(platform, relayInfo)
 	task.TaskID = taskID
+	task.Action = relayInfo.Action
+	task.CallbackURL = callbackURL
 	task.Quota = quota
 	task.Data = taskData
+	if isVideo {
+		task.Status = model.TaskStatusSubmitted
+		task.Properties.Model = modelName
+		task.Properties.Duration = duration
+	}
 	err = task.Insert()
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "insert_task_failed", http.StatusInternalServerError)
@@ -150,8 +196,9 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 }
 
 var fetchRespBuilders = map[int]func(c *gin.Context) (respBody []byte, taskResp *dto.TaskError){
-	relayconstant.RelayModeSunoFetchByID: sunoFetchByIDRespBodyBuilder,
-	relayconstant.RelayModeSunoFetch:     sunoFetchRespBodyBuilder,
+	relayconstant.RelayModeSunoFetchByID:  sunoFetchByIDRespBodyBuilder,
+	relayconstant.RelayModeSunoFetch:      sunoFetchRespBodyBuilder,
+	relayconstant.RelayModeVideoFetchByID: videoFetchByIDRespBodyBuilder,
 }
 
 func RelayTaskFetch(c *gin.Context, relayMode int) (taskResp *dto.TaskError) {
@@ -236,6 +283,107 @@ func TaskModel2Dto(task *model.Task) *dto.TaskDto {
 		StartTime:  task.StartTime,
 		FinishTime: task.FinishTime,
 		Progress:   task.Progress,
-		Data:       task.Data,
+		Data:       taskResultData(task),
+	}
+}
+
+// taskResultData 返回任务数据，结果已保存到网关存储时替换为签名地址
+func taskResultData(task *model.Task) json.RawMessage {
+	if task.Status != model.TaskStatusSuccess || !service.MediaTaskStorageEnabled() {
+		return task.Data
+	}
+	return service.RewriteMediaURLs(task.Data, service.GetTaskMediaURLs(model.MediaSourceSuno, task.TaskID))
+}
+
+func videoFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *dto.TaskError) {
+	taskId := c.Param("task_id")
+	userId := c.GetInt("id")
+
+	originTask, exist, err := model.GetByTaskId(userId, taskId)
+	if err != nil {
+		taskResp = service.TaskErrorWrapper(err, "get_task_failed", http.StatusInternalServerError)
+		return
+	}
+	if !exist {
+		taskResp = service.TaskErrorWrapperLocal(errors.New("task_not_exist"), "task_not_exist", http.StatusNotFound)
+		return
+	}
+
+	respBody, err = json.Marshal(TaskModel2VideoDto(originTask))
+	return
+}
+
+// videoTaskStatus 把任务状态转换为视频接口的状态
+func videoTaskStatus(status model.TaskStatus) string {
+	switch status {
+	case model.TaskStatusNotStart, model.TaskStatusSubmitted, model.TaskStatusQueued:
+		return "queued"
+	case model.TaskStatusInProgress:
+		return "in_progress"
+	case model.TaskStatusSuccess:
+		return "succeeded"
+	case model.TaskStatusFailure:
+		return "failed"
+	}
+	return "unknown"
+}
+
+func TaskModel2VideoDto(task *model.Task) *dto.VideoTaskResponse {
+	response := &dto.VideoTaskResponse{
+		TaskId:     task.TaskID,
+		Object:     "video.generation",
+		Model:      task.Properties.Model,
+		Status:     videoTaskStatus(task.Status),
+		Progress:   task.Progress,
+		Url:        task.ResultURL,
+		Duration:   task.Properties.Duration,
+		CreatedAt:  task.SubmitTime,
+		FinishedAt: task.FinishTime,
+	}
+	if task.Status == model.TaskStatusSuccess && task.ResultURL != "" && service.MediaTaskStorageEnabled() {
+		if signedURL, ok := service.GetTaskMediaURLs(model.MediaSourceVideo, task.TaskID)[task.ResultURL]; ok {
+			response.Url = signedURL
+		}
+	}
+	if task.Status == model.TaskStatusFailure {
+		response.Error = &dto.VideoTaskError{
+			Code:    "generation_failed",
+			Message: task.FailReason,
+		}
+	}
+	return response
+}
+
+// NotifyTaskWebhook 向任务的回调地址推送状态变化，推送内容与查询接口一致
+func NotifyTaskWebhook(task *model.Task) {
+	var data any
+	if task.Platform == constant.TaskPlatformSuno {
+		data = TaskModel2Dto(task)
+	} else {
+		data = TaskModel2VideoDto(task)
+	}
+	service.EnqueueTaskWebhook(task.UserId, task.CallbackURL, task.Platform, task.TaskID, string(task.Status), data)
+}
+
+// ArchiveTaskMedia 任务成功后将生成结果（Suno 音频、封面，视频）保存到网关存储
+func ArchiveTaskMedia(task *model.Task) {
+	if task.Status != model.TaskStatusSuccess {
+		return
+	}
+	if task.Platform == constant.TaskPlatformSuno {
+		var songs []dto.SunoSong
+		// 歌词任务的数据不是歌曲列表，无需保存
+		if err := json.Unmarshal(task.Data, &songs); err != nil {
+			return
+		}
+		urls := make([]string, 0, len(songs)*4)
+		for _, song := range songs {
+			urls = append(urls, song.AudioURL, song.VideoURL, song.ImageURL, song.ImageLargeURL)
+		}
+		service.ArchiveTaskMedia(task.UserId, task.ChannelId, model.MediaSourceSuno, task.TaskID, urls)
+		return
+	}
+	if task.ResultURL != "" {
+		service.ArchiveTaskMedia(task.UserId, task.ChannelId, model.MediaSourceVideo, task.TaskID, []string{task.ResultURL})
 	}
 }
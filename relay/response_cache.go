@@ -0,0 +1,190 @@
+package relay
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// responseCache 单个请求的响应缓存状态，为 nil 时表示该请求不使用缓存
+type responseCache struct {
+	key          string
+	stream       bool
+	lookup       bool // Cache-Control: no-cache 时跳过读取
+	store        bool // Cache-Control: no-store 时跳过写入
+	maxAge       int64
+	ttl          time.Duration
+	writer       *helper.ConvertWriter
+	originWriter gin.ResponseWriter
+	chunks       []string
+}
+
+// cacheControl 请求头 Cache-Control 中与网关缓存相关的指令
+type cacheControl struct {
+	noCache   bool // 跳过读取缓存
+	noStore   bool // 跳过写入缓存
+	hasMaxAge bool
+	maxAge    int64 // 只接受不超过该时长的缓存
+}
+
+func parseCacheControl(c *gin.Context) cacheControl {
+	var control cacheControl
+	for _, directive := range strings.Split(strings.ToLower(c.GetHeader("Cache-Control")), ",") {
+		directive = strings.TrimSpace(directive)
+		switch {
+		case directive == "no-cache":
+			control.noCache = true
+		case directive == "no-store":
+			control.noStore = true
+		case strings.HasPrefix(directive, "max-age="):
+			if maxAge, err := strconv.ParseInt(strings.TrimPrefix(directive, "max-age="), 10, 64); err == nil && maxAge >= 0 {
+				control.hasMaxAge = true
+				control.maxAge = maxAge
+			}
+		}
+	}
+	return control
+}
+
+// newResponseCache 根据配置、令牌设置与 Cache-Control 请求头判断请求是否使用响应缓存
+func newResponseCache(c *gin.Context, info *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) *responseCache {
+	setting := operation_setting.GetResponseCacheSetting()
+	if !setting.Enabled {
+		return nil
+	}
+	if info.RelayMode != relayconstant.RelayModeChatCompletions && info.RelayMode != relayconstant.RelayModeCompletions {
+		return nil
+	}
+	// 音频模型单独计费，不缓存
+	if strings.HasPrefix(info.OriginModelName, "gpt-4o-audio") {
+		return nil
+	}
+	cache := &responseCache{
+		stream: textRequest.Stream,
+		ttl:    time.Duration(setting.TTLSeconds) * time.Second,
+	}
+	control := parseCacheControl(c)
+	cache.lookup = !control.noCache
+	cache.store = !control.noStore
+	cache.maxAge = control.maxAge
+	// 携带 max-age 视为主动使用缓存
+	enabled := setting.DefaultEnabled || c.GetBool("token_response_cache_enabled") || control.hasMaxAge
+	if !enabled || (!cache.lookup && !cache.store) {
+		return nil
+	}
+	if setting.DeterministicOnly && (textRequest.Temperature == nil || *textRequest.Temperature != 0) {
+		return nil
+	}
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return nil
+	}
+	key, err := service.ResponseCacheKey(c.Request.URL.Path, info.Group, info.OriginModelName, body)
+	if err != nil {
+		return nil
+	}
+	cache.key = key
+	return cache
+}
+
+// get 查询缓存，未命中时返回 nil
+func (r *responseCache) get(c *gin.Context) *dto.Usage {
+	if r == nil || !r.lookup {
+		return nil
+	}
+	entry, err := service.GetResponseCache(r.key)
+	if err != nil {
+		common.LogError(c, "get response cache failed: "+err.Error())
+		return nil
+	}
+	if entry == nil || entry.Stream != r.stream {
+		return nil
+	}
+	if r.maxAge > 0 && time.Now().Unix()-entry.CreatedAt > r.maxAge {
+		return nil
+	}
+	c.Header("X-Response-Cache", "HIT")
+	if entry.Stream {
+		helper.SetEventStreamHeaders(c)
+		for _, chunk := range entry.Chunks {
+			_ = helper.StringData(c, chunk)
+		}
+	} else {
+		c.Data(http.StatusOK, common.GetStringIfEmpty(entry.ContentType, "application/json"), []byte(entry.Body))
+	}
+	return &entry.Usage
+}
+
+// record 替换 c.Writer，在输出给客户端的同时记录响应
+func (r *responseCache) record(c *gin.Context) {
+	if r == nil || !r.store {
+		return
+	}
+	r.originWriter = c.Writer
+	r.writer = helper.NewTeeConvertWriter(c.Writer, r.stream, func(data string) {
+		r.chunks = append(r.chunks, data)
+	})
+	c.Writer = r.writer
+}
+
+// restore 恢复原始的 c.Writer
+func (r *responseCache) restore(c *gin.Context) {
+	if r == nil || r.writer == nil {
+		return
+	}
+	c.Writer = r.originWriter
+}
+
+// getResponseCacheRatio 返回命中精确或语义缓存时的计费倍率
+func getResponseCacheRatio(info *relaycommon.RelayInfo) (float64, bool) {
+	if info.ResponseCacheHit {
+		return operation_setting.GetResponseCacheSetting().CacheRatio, true
+	}
+	if info.SemanticCacheSimilarity > 0 {
+		return operation_setting.GetSemanticCacheSetting().CacheRatio, true
+	}
+	return 0, false
+}
+
+// save 请求成功后异步写入缓存
+func (r *responseCache) save(c *gin.Context, usage *dto.Usage) {
+	if r == nil || r.writer == nil || usage == nil {
+		return
+	}
+	if r.writer.Status() != http.StatusOK {
+		return
+	}
+	entry := &service.ResponseCacheEntry{
+		Stream: r.stream,
+		Usage:  *usage,
+	}
+	if r.stream {
+		if len(r.chunks) == 0 {
+			return
+		}
+		entry.Chunks = r.chunks
+	} else {
+		if len(r.writer.Body()) == 0 {
+			return
+		}
+		entry.ContentType = r.originWriter.Header().Get("Content-Type")
+		entry.Body = string(r.writer.Body())
+	}
+	key, ttl := r.key, r.ttl
+	gopool.Go(func() {
+		if err := service.SetResponseCache(key, entry, ttl); err != nil {
+			common.SysError("set response cache failed: " + err.Error())
+		}
+	})
+}
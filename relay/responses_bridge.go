@@ -0,0 +1,181 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/model_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// channelSupportsResponses 判断渠道是否原生支持 /v1/responses
+func channelSupportsResponses(info *relaycommon.RelayInfo) bool {
+	if responsesToChat, ok := info.ChannelSetting[constant.ChannelSettingResponsesToChat].(bool); ok && responsesToChat {
+		return false
+	}
+	switch info.ChannelType {
+	case common.ChannelTypeOpenAI, common.ChannelTypeAzure:
+		return true
+	}
+	return false
+}
+
+// shouldBridgeResponsesToChat 判断 /v1/responses 请求是否需要转换为 Chat Completions 发往上游
+func shouldBridgeResponsesToChat(info *relaycommon.RelayInfo) bool {
+	return model_setting.GetResponsesSettings().ChatBridgeEnabled && !channelSupportsResponses(info)
+}
+
+// shouldBridgeChatToResponses 判断 /v1/chat/completions 请求是否需要转换为 Responses API 发往上游
+func shouldBridgeChatToResponses(info *relaycommon.RelayInfo) bool {
+	if info.RelayMode != relayconstant.RelayModeChatCompletions {
+		return false
+	}
+	return channelSupportsResponses(info) &&
+		model_setting.GetResponsesSettings().IsResponsesOnlyModel(info.UpstreamModelName)
+}
+
+func convertResponsesToChatRequest(c *gin.Context, adaptor channel.Adaptor, info *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest) (any, error) {
+	openAIRequest, err := service.ResponsesToOpenAIRequest(*request, info)
+	if err != nil {
+		return nil, err
+	}
+	return adaptor.ConvertOpenAIRequest(c, info, openAIRequest)
+}
+
+func convertChatToResponsesRequest(c *gin.Context, adaptor channel.Adaptor, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (any, error) {
+	responsesRequest, err := service.OpenAIToResponsesRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	for _, tool := range responsesRequest.Tools {
+		info.ResponsesUsageInfo.BuiltInTools[tool.Type] = &relaycommon.BuildInToolInfo{
+			ToolName:          tool.Type,
+			SearchContextSize: common.GetStringIfEmpty(tool.SearchContextSize, "medium"),
+		}
+	}
+	return adaptor.ConvertOpenAIResponsesRequest(c, info, *responsesRequest)
+}
+
+func writeBridgeEvent(writer *helper.ConvertWriter, event string, object any) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		common.SysError("error marshalling bridged stream response: " + err.Error())
+		return
+	}
+	writer.WriteEvent(event, data)
+}
+
+// doResponsesViaChat 处理以 Chat Completions 格式返回的上游响应，并改写为 Responses API 格式
+func doResponsesViaChat(c *gin.Context, adaptor channel.Adaptor, resp *http.Response, info *relaycommon.RelayInfo) (any, *dto.OpenAIErrorWithStatusCode) {
+	originWriter := c.Writer
+	defer func() {
+		c.Writer = originWriter
+	}()
+
+	if info.IsStream {
+		var writer *helper.ConvertWriter
+		writer = helper.NewStreamConvertWriter(originWriter, func(data string) {
+			if data == "[DONE]" {
+				return
+			}
+			var streamResponse dto.ChatCompletionsStreamResponse
+			if err := common.DecodeJsonStr(data, &streamResponse); err != nil {
+				common.SysError("error unmarshalling bridged stream response: " + err.Error())
+				return
+			}
+			for _, event := range service.StreamResponseOpenAI2Responses(&streamResponse, info) {
+				writeBridgeEvent(writer, event.Type, event)
+			}
+		})
+		c.Writer = writer
+		usage, openaiErr := adaptor.DoResponse(c, resp, info)
+		if openaiErr != nil {
+			return nil, openaiErr
+		}
+		chatUsage, _ := usage.(*dto.Usage)
+		for _, event := range service.FinishStreamResponseOpenAI2Responses(info, chatUsage) {
+			writeBridgeEvent(writer, event.Type, event)
+		}
+		return usage, nil
+	}
+
+	writer := helper.NewBufferedConvertWriter(originWriter)
+	c.Writer = writer
+	usage, openaiErr := adaptor.DoResponse(c, resp, info)
+	c.Writer = originWriter
+	if openaiErr != nil {
+		return nil, openaiErr
+	}
+	var openAIResponse dto.OpenAITextResponse
+	if err := common.DecodeJson(writer.Body(), &openAIResponse); err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+	responsesResponse := service.ResponseOpenAI2Responses(&openAIResponse, info)
+	if chatUsage, ok := usage.(*dto.Usage); ok && chatUsage != nil {
+		responsesResponse.Usage = service.UsageOpenAI2Responses(chatUsage)
+	}
+	c.JSON(http.StatusOK, responsesResponse)
+	return usage, nil
+}
+
+// doChatViaResponses 处理以 Responses API 格式返回的上游响应，并改写为 Chat Completions 格式
+func doChatViaResponses(c *gin.Context, adaptor channel.Adaptor, resp *http.Response, info *relaycommon.RelayInfo) (any, *dto.OpenAIErrorWithStatusCode) {
+	originWriter := c.Writer
+	defer func() {
+		c.Writer = originWriter
+	}()
+
+	if info.IsStream {
+		var writer *helper.ConvertWriter
+		writer = helper.NewStreamConvertWriter(originWriter, func(data string) {
+			var streamResponse dto.ResponsesStreamResponse
+			if err := common.DecodeJsonStr(data, &streamResponse); err != nil {
+				common.SysError("error unmarshalling bridged stream response: " + err.Error())
+				return
+			}
+			for _, chunk := range service.StreamResponseResponses2OpenAI(&streamResponse, info) {
+				writeBridgeEvent(writer, "", chunk)
+			}
+		})
+		c.Writer = writer
+		usage, openaiErr := adaptor.DoResponse(c, resp, info)
+		if openaiErr != nil {
+			return nil, openaiErr
+		}
+		if chatUsage, ok := usage.(*dto.Usage); ok && chatUsage != nil && info.ShouldIncludeUsage {
+			convertInfo := info.ResponsesConvertInfo
+			responseId := "chatcmpl-" + strings.TrimPrefix(convertInfo.ResponseId, "resp_")
+			chunk := helper.GenerateFinalUsageResponse(responseId, convertInfo.CreatedAt, convertInfo.Model, *chatUsage)
+			writeBridgeEvent(writer, "", chunk)
+		}
+		writer.WriteEvent("", []byte("[DONE]"))
+		return usage, nil
+	}
+
+	writer := helper.NewBufferedConvertWriter(originWriter)
+	c.Writer = writer
+	usage, openaiErr := adaptor.DoResponse(c, resp, info)
+	c.Writer = originWriter
+	if openaiErr != nil {
+		return nil, openaiErr
+	}
+	var responsesResponse dto.OpenAIResponsesResponse
+	if err := common.DecodeJson(writer.Body(), &responsesResponse); err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+	openAIResponse := service.ResponseResponses2OpenAI(&responsesResponse, info)
+	if chatUsage, ok := usage.(*dto.Usage); ok && chatUsage != nil {
+		openAIResponse.Usage = *chatUsage
+	}
+	c.JSON(http.StatusOK, openAIResponse)
+	return usage, nil
+}
@@ -0,0 +1,101 @@
+package relay
+
+import (
+	"encoding/json"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/model_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responsesRecorder 在响应写给客户端的同时捕获最终的响应对象，用于保存
+type responsesRecorder struct {
+	writer             *helper.ConvertWriter
+	previousResponseId string
+	input              []dto.ResponsesInputItem
+	response           json.RawMessage
+}
+
+// inlineResponsesHistory 当上游无法识别 previous_response_id 时（桥接到 Chat Completions，
+// 或上一轮由其他渠道处理），根据网关保存的响应重建上下文并写入 input
+func inlineResponsesHistory(info *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest, bridgeToChat bool) (bool, error) {
+	if request.PreviousResponseID == "" || !model_setting.GetResponsesSettings().StoreEnabled {
+		return false, nil
+	}
+	record, err := model.GetResponseRecord(info.UserId, request.PreviousResponseID)
+	if err != nil {
+		return false, err
+	}
+	if !bridgeToChat && (record == nil || record.ChannelId == info.ChannelId) {
+		// 同一渠道或网关未保存时交给上游处理
+		return false, nil
+	}
+	history, err := service.BuildResponsesHistory(info.UserId, request.PreviousResponseID)
+	if err != nil {
+		return false, err
+	}
+	items, err := request.ParseInput()
+	if err != nil {
+		return false, err
+	}
+	input, err := json.Marshal(append(history, items...))
+	if err != nil {
+		return false, err
+	}
+	request.Input = input
+	request.PreviousResponseID = ""
+	return true, nil
+}
+
+// newResponsesRecorder 需要保存响应时替换 c.Writer，调用方需在处理结束后恢复
+func newResponsesRecorder(c *gin.Context, info *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest) (*responsesRecorder, error) {
+	if !model_setting.GetResponsesSettings().StoreEnabled || !request.ShouldStore() {
+		return nil, nil
+	}
+	input, err := request.ParseInput()
+	if err != nil {
+		return nil, err
+	}
+	service.AssignResponsesInputItemIds(input)
+	recorder := &responsesRecorder{
+		previousResponseId: request.PreviousResponseID,
+		input:              input,
+	}
+	recorder.writer = helper.NewTeeConvertWriter(c.Writer, info.IsStream, func(data string) {
+		if !strings.Contains(data, dto.ResponsesEventCompleted) && !strings.Contains(data, dto.ResponsesEventIncomplete) {
+			return
+		}
+		var event struct {
+			Type     string          `json:"type"`
+			Response json.RawMessage `json:"response"`
+		}
+		if err := common.DecodeJsonStr(data, &event); err != nil {
+			return
+		}
+		if event.Type == dto.ResponsesEventCompleted || event.Type == dto.ResponsesEventIncomplete {
+			recorder.response = event.Response
+		}
+	})
+	return recorder, nil
+}
+
+// save 保存捕获到的响应对象，失败只记录日志
+func (r *responsesRecorder) save(c *gin.Context, info *relaycommon.RelayInfo) {
+	response := r.response
+	if !info.IsStream {
+		response = r.writer.Body()
+	}
+	if len(response) == 0 {
+		common.LogWarn(c, "no response object captured, skip storing response")
+		return
+	}
+	if err := service.SaveResponsesRecord(info, r.previousResponseId, r.input, response); err != nil {
+		common.LogError(c, "failed to store response: "+err.Error())
+	}
+}
@@ -0,0 +1,246 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// semanticCache 单个请求的语义缓存状态，为 nil 时表示该请求不使用语义缓存
+type semanticCache struct {
+	partition    string
+	vector       []float64
+	stream       bool
+	lookup       bool
+	store        bool
+	maxAge       int64
+	writer       *helper.ConvertWriter
+	originWriter gin.ResponseWriter
+	content      strings.Builder
+	finishReason string
+}
+
+// newSemanticCache 判断请求是否使用语义缓存，并通过嵌入模型计算最后一条用户消息的向量
+func newSemanticCache(c *gin.Context, info *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) *semanticCache {
+	setting := operation_setting.GetSemanticCacheSetting()
+	if !setting.Enabled || info.RelayMode != relayconstant.RelayModeChatCompletions {
+		return nil
+	}
+	// 工具调用、结构化输出与多个候选的回答依赖请求细节，不做语义复用
+	if textRequest.N > 1 || len(textRequest.Tools) > 0 || textRequest.ResponseFormat != nil && textRequest.ResponseFormat.Type != "text" {
+		return nil
+	}
+	if strings.HasPrefix(info.OriginModelName, "gpt-4o-audio") || !setting.IsSemanticCacheModel(info.OriginModelName) {
+		return nil
+	}
+	control := parseCacheControl(c)
+	if control.noCache && control.noStore {
+		return nil
+	}
+
+	messages := textRequest.Messages
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		return nil
+	}
+	last := messages[len(messages)-1]
+	for _, content := range last.ParseContent() {
+		if content.Type != dto.ContentTypeText {
+			return nil
+		}
+	}
+	question := strings.TrimSpace(last.StringContent())
+	if question == "" {
+		return nil
+	}
+
+	var scope string
+	switch setting.Scope {
+	case operation_setting.SemanticCacheScopeUser:
+		scope = "user:" + strconv.Itoa(info.UserId)
+	case operation_setting.SemanticCacheScopeGlobal:
+		scope = "group:" + info.Group
+	default:
+		scope = "token:" + strconv.Itoa(info.TokenId)
+	}
+	// 系统提示词与历史消息不同的请求不共享缓存
+	context, err := json.Marshal(messages[:len(messages)-1])
+	if err != nil {
+		return nil
+	}
+
+	vector, err := getSemanticCacheEmbedding(c, setting.EmbeddingModel, question)
+	if err != nil {
+		common.LogError(c, "semantic cache embedding failed: "+err.Error())
+		return nil
+	}
+	return &semanticCache{
+		partition: service.SemanticCachePartition(scope, info.OriginModelName, context),
+		vector:    vector,
+		stream:    textRequest.Stream,
+		lookup:    !control.noCache,
+		store:     !control.noStore,
+		maxAge:    control.maxAge,
+	}
+}
+
+// getSemanticCacheEmbedding 以内部请求的方式通过 EmbeddingHelper 计算向量，嵌入请求按正常流程选择渠道并计费
+func getSemanticCacheEmbedding(c *gin.Context, embeddingModel string, input string) ([]float64, error) {
+	group := c.GetString("group")
+	channel, err := model.CacheGetRandomSatisfiedChannel(group, embeddingModel, 0)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, fmt.Errorf("no available channel for model %s in group %s", embeddingModel, group)
+	}
+	body, err := json.Marshal(dto.EmbeddingRequest{
+		Model: embeddingModel,
+		Input: input,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := c.Copy()
+	ctx.Request, err = http.NewRequestWithContext(c.Request.Context(), http.MethodPost, "/v1/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	// 清除对话渠道遗留的渠道相关参数
+	for _, key := range []string{"api_version", "region", "plugin", "bot_id", "channel_organization"} {
+		delete(ctx.Keys, key)
+	}
+	ctx.Set(common.KeyRequestBody, body)
+	ctx.Set(constant.ContextKeyRequestStartTime, time.Now())
+	middleware.SetupContextForSelectedChannel(ctx, channel, embeddingModel)
+
+	writer := helper.NewBufferedConvertWriter(c.Writer)
+	ctx.Writer = writer
+	if openaiErr := EmbeddingHelper(ctx); openaiErr != nil {
+		return nil, errors.New(openaiErr.Error.Message)
+	}
+	var embeddingResponse dto.OpenAIEmbeddingResponse
+	if err := common.DecodeJson(writer.Body(), &embeddingResponse); err != nil {
+		return nil, err
+	}
+	if len(embeddingResponse.Data) == 0 || len(embeddingResponse.Data[0].Embedding) == 0 {
+		return nil, errors.New("empty embedding response")
+	}
+	return embeddingResponse.Data[0].Embedding, nil
+}
+
+// get 查找语义相近的缓存回答并输出，未命中时返回 nil
+func (s *semanticCache) get(c *gin.Context, info *relaycommon.RelayInfo) *dto.Usage {
+	if s == nil || !s.lookup {
+		return nil
+	}
+	entry, similarity := service.SearchSemanticCache(s.partition, s.vector)
+	if entry == nil {
+		return nil
+	}
+	if s.maxAge > 0 && time.Now().Unix()-entry.CreatedAt > s.maxAge {
+		return nil
+	}
+	info.SemanticCacheSimilarity = similarity
+
+	message := dto.Message{Role: "assistant"}
+	message.SetStringContent(entry.Content)
+	response := &dto.OpenAITextResponse{
+		Id:      helper.GetResponseID(c),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   info.OriginModelName,
+		Choices: []dto.OpenAITextResponseChoice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: entry.FinishReason,
+			},
+		},
+		Usage: entry.Usage,
+	}
+	c.Header("X-Semantic-Cache", "HIT")
+	c.Header("X-Semantic-Cache-Similarity", strconv.FormatFloat(similarity, 'f', 4, 64))
+	writeChatCompletionResponse(c, info, s.stream, response)
+	usage := entry.Usage
+	return &usage
+}
+
+// record 替换 c.Writer，在输出给客户端的同时记录回答内容
+func (s *semanticCache) record(c *gin.Context) {
+	if s == nil || !s.store {
+		return
+	}
+	s.originWriter = c.Writer
+	s.writer = helper.NewTeeConvertWriter(c.Writer, s.stream, func(data string) {
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.DecodeJsonStr(data, &chunk); err != nil {
+			return
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			if choice.Delta.Content != nil {
+				s.content.WriteString(*choice.Delta.Content)
+			}
+			if choice.FinishReason != nil {
+				s.finishReason = *choice.FinishReason
+			}
+		}
+	})
+	c.Writer = s.writer
+}
+
+// restore 恢复原始的 c.Writer
+func (s *semanticCache) restore(c *gin.Context) {
+	if s == nil || s.writer == nil {
+		return
+	}
+	c.Writer = s.originWriter
+}
+
+// save 回答完整结束时写入语义缓存
+func (s *semanticCache) save(usage *dto.Usage) {
+	if s == nil || s.writer == nil || usage == nil || s.writer.Status() != http.StatusOK {
+		return
+	}
+	content, finishReason := s.content.String(), s.finishReason
+	if !s.stream {
+		var response dto.OpenAITextResponse
+		if err := common.DecodeJson(s.writer.Body(), &response); err != nil || len(response.Choices) == 0 {
+			return
+		}
+		content = response.Choices[0].Message.StringContent()
+		finishReason = response.Choices[0].FinishReason
+	}
+	// 只缓存正常结束的文本回答
+	if content == "" || finishReason != constant.FinishReasonStop {
+		return
+	}
+	service.AddSemanticCache(&service.SemanticCacheEntry{
+		Partition:    s.partition,
+		Vector:       s.vector,
+		Content:      content,
+		FinishReason: finishReason,
+		Usage:        *usage,
+	})
+}
@@ -0,0 +1,200 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serverTool 由网关执行的工具：以函数工具的形式提供给模型，模型调用时由网关执行并把结果交还模型
+type serverTool struct {
+	definition dto.ToolCallRequest
+	execute    func(c *gin.Context, info *relaycommon.RelayInfo, toolCall dto.ToolCallRequest) string
+}
+
+// serverToolSet 一次请求中由网关执行的工具集合
+type serverToolSet struct {
+	clientTools []dto.ToolCallRequest  // 客户端自己的函数工具，照常透传并由客户端执行
+	tools       map[string]*serverTool // 按函数名索引
+	names       []string               // 保持工具的声明顺序
+	maxRounds   int
+}
+
+func (s *serverToolSet) add(tool *serverTool) {
+	if s.tools == nil {
+		s.tools = make(map[string]*serverTool)
+	}
+	name := tool.definition.Function.Name
+	if _, ok := s.tools[name]; !ok {
+		s.names = append(s.names, name)
+	}
+	s.tools[name] = tool
+}
+
+// getServerTools 收集请求中需要由网关执行的工具（联网搜索、MCP），没有时返回 nil
+func getServerTools(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (*serverToolSet, *dto.OpenAIErrorWithStatusCode) {
+	if info.RelayMode != relayconstant.RelayModeChatCompletions || request.N > 1 {
+		return nil, nil
+	}
+	set := &serverToolSet{}
+	webSearch := shouldDoWebSearch(info, request)
+	for _, tool := range request.Tools {
+		switch {
+		case tool.Type == "mcp":
+		case webSearch && service.IsWebSearchTool(tool):
+		default:
+			set.clientTools = append(set.clientTools, tool)
+		}
+	}
+	if webSearch {
+		set.add(newWebSearchServerTool(request))
+		set.maxRounds = getWebSearchMaxRounds()
+	}
+	if openaiErr := addMcpServerTools(c, info, request, set); openaiErr != nil {
+		return nil, openaiErr
+	}
+	if len(set.tools) == 0 {
+		return nil, nil
+	}
+	if set.maxRounds <= 0 {
+		set.maxRounds = 1
+	}
+	return set, nil
+}
+
+// doServerTools 执行工具调用循环：模型调用网关工具时执行工具并把结果作为工具消息加入对话后继续请求，
+// 直到模型给出回答、调用了客户端的工具或达到最大轮数。所有请求的用量均计入账单
+func doServerTools(c *gin.Context, adaptor channel.Adaptor, info *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest, set *serverToolSet) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+	// 需要在请求之间执行工具，上游统一使用非流式请求
+	clientStream := info.IsStream
+	textRequest.Stream = false
+	textRequest.StreamOptions = nil
+	info.IsStream = false
+	defer func() {
+		info.IsStream = clientStream
+	}()
+
+	totalUsage := &dto.Usage{}
+	// 已经产生上游用量时直接返回错误并照常计费，否则交给调用方处理（退还预扣费、重试其他渠道）
+	fail := func(openaiErr *dto.OpenAIErrorWithStatusCode) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+		if totalUsage.TotalTokens == 0 && totalUsage.PromptTokens == 0 {
+			return nil, openaiErr
+		}
+		c.JSON(openaiErr.StatusCode, gin.H{
+			"error": openaiErr.Error,
+		})
+		return totalUsage, nil
+	}
+
+	originWriter := c.Writer
+	defer func() {
+		c.Writer = originWriter
+	}()
+
+	messages := textRequest.Messages
+	for round := 0; round <= set.maxRounds; round++ {
+		request := *textRequest
+		request.WebSearchOptions = nil
+		request.Messages = append([]dto.Message{}, messages...)
+		request.Tools = append([]dto.ToolCallRequest{}, set.clientTools...)
+		// 达到最大轮数后不再提供网关工具，要求模型直接回答
+		if round < set.maxRounds {
+			for _, name := range set.names {
+				request.Tools = append(request.Tools, set.tools[name].definition)
+			}
+		}
+		if len(request.Tools) == 0 {
+			request.ToolChoice = nil
+		}
+		emulateToolCalls := shouldEmulateToolCalls(info, &request)
+		if emulateToolCalls {
+			service.ApplyToolCallPrompt(&request)
+		}
+
+		convertedRequest, err := adaptor.ConvertOpenAIRequest(c, info, &request)
+		if err != nil {
+			return fail(service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError))
+		}
+		jsonData, openaiErr := getRequestBodyJson(info, convertedRequest)
+		if openaiErr != nil {
+			return fail(openaiErr)
+		}
+		resp, err := adaptor.DoRequest(c, info, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fail(service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError))
+		}
+		httpResp, _ := resp.(*http.Response)
+		if httpResp == nil {
+			return fail(service.OpenAIErrorWrapper(errors.New("empty upstream response"), "do_request_failed", http.StatusInternalServerError))
+		}
+		if httpResp.StatusCode != http.StatusOK {
+			openaiErr = service.RelayErrorHandler(httpResp, false)
+			service.ResetStatusCode(openaiErr, c.GetString("status_code_mapping"))
+			return fail(openaiErr)
+		}
+
+		writer := helper.NewBufferedConvertWriter(originWriter)
+		c.Writer = writer
+		usage, openaiErr := adaptor.DoResponse(c, httpResp, info)
+		c.Writer = originWriter
+		if openaiErr != nil {
+			return fail(openaiErr)
+		}
+		roundUsage, _ := usage.(*dto.Usage)
+		accumulateUsage(totalUsage, roundUsage)
+
+		var openAIResponse dto.OpenAITextResponse
+		if err := common.DecodeJson(writer.Body(), &openAIResponse); err != nil {
+			return fail(service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError))
+		}
+		if emulateToolCalls {
+			service.ResponseToolCallEmulation(&openAIResponse)
+		}
+		if len(openAIResponse.Choices) == 0 {
+			return fail(service.OpenAIErrorWrapper(errors.New("upstream returned no choices"), "server_tool_failed", http.StatusInternalServerError))
+		}
+
+		message := &openAIResponse.Choices[0].Message
+		var serverCalls, clientCalls []dto.ToolCallRequest
+		for _, toolCall := range message.ParseToolCalls() {
+			if _, ok := set.tools[toolCall.Function.Name]; ok {
+				serverCalls = append(serverCalls, toolCall)
+			} else {
+				clientCalls = append(clientCalls, toolCall)
+			}
+		}
+		// 模型给出回答或调用了客户端的工具时结束，响应中不保留网关工具的调用
+		if len(serverCalls) == 0 || len(clientCalls) > 0 || round == set.maxRounds {
+			if len(clientCalls) > 0 {
+				message.SetToolCalls(clientCalls)
+			} else if len(serverCalls) > 0 {
+				message.ToolCalls = nil
+				openAIResponse.Choices[0].FinishReason = constant.FinishReasonStop
+			}
+			openAIResponse.Usage = *totalUsage
+			writeChatCompletionResponse(c, info, clientStream, &openAIResponse)
+			return totalUsage, nil
+		}
+
+		messages = append(messages, *message)
+		for _, toolCall := range serverCalls {
+			toolMessage := dto.Message{
+				Role:       "tool",
+				ToolCallId: toolCall.ID,
+			}
+			toolMessage.SetStringContent(set.tools[toolCall.Function.Name].execute(c, info, toolCall))
+			messages = append(messages, toolMessage)
+		}
+	}
+	return totalUsage, nil
+}
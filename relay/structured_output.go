@@ -0,0 +1,235 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/model_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	structuredOutputModePrompt = iota // 注入 schema 说明到系统提示
+	structuredOutputModeTool          // 以强制工具调用获取结构化参数 (Claude)
+	structuredOutputModeNative        // 上游原生 schema 约束 (Gemini responseSchema)
+)
+
+// shouldEmulateStructuredOutput 判断是否由网关模拟 Structured Outputs
+func shouldEmulateStructuredOutput(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) bool {
+	if info.RelayMode != relayconstant.RelayModeChatCompletions || !service.IsStructuredOutputRequest(request) {
+		return false
+	}
+	if emulation, ok := info.ChannelSetting[constant.ChannelSettingStructuredOutput].(bool); ok && emulation {
+		return true
+	}
+	return model_setting.GetStructuredOutputSettings().IsEmulationModel(info.UpstreamModelName)
+}
+
+func getStructuredOutputMode(info *relaycommon.RelayInfo, format *dto.ResponseFormat) int {
+	switch info.ApiType {
+	case relayconstant.APITypeAnthropic:
+		if format.JsonSchema != nil && format.JsonSchema.Schema != nil {
+			return structuredOutputModeTool
+		}
+	case relayconstant.APITypeGemini:
+		return structuredOutputModeNative
+	}
+	return structuredOutputModePrompt
+}
+
+// accumulateUsage 把一次上游请求的用量累加到 total
+func accumulateUsage(total *dto.Usage, usage *dto.Usage) {
+	if usage == nil {
+		return
+	}
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+	total.PromptTokensDetails.CachedTokens += usage.PromptTokensDetails.CachedTokens
+	total.PromptTokensDetails.ImageTokens += usage.PromptTokensDetails.ImageTokens
+	total.PromptTokensDetails.AudioTokens += usage.PromptTokensDetails.AudioTokens
+	total.PromptTokensDetails.TextTokens += usage.PromptTokensDetails.TextTokens
+	total.CompletionTokenDetails.ReasoningTokens += usage.CompletionTokenDetails.ReasoningTokens
+}
+
+// doStructuredOutput 以非流式请求上游，校验输出是否符合 schema，不符合时携带错误信息重试，
+// 全部通过后再按客户端要求的格式（流式或非流式）输出。上游不支持 n 参数时逐个生成并校验 n 个结果后合并。
+// 所有尝试的用量均计入账单
+func doStructuredOutput(c *gin.Context, adaptor channel.Adaptor, info *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+	format := textRequest.ResponseFormat
+	mode := getStructuredOutputMode(info, format)
+	toolName := service.StructuredOutputToolName(format)
+	maxRetries := model_setting.GetStructuredOutputSettings().MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	completions := 1
+	if shouldFanOut(info, textRequest) {
+		if openaiErr := validateFanOutRequest(textRequest); openaiErr != nil {
+			return nil, openaiErr
+		}
+		completions = textRequest.N
+	}
+
+	// 需要完整的输出才能校验，上游统一使用非流式请求
+	clientStream := info.IsStream
+	textRequest.Stream = false
+	textRequest.StreamOptions = nil
+	info.IsStream = false
+	defer func() {
+		info.IsStream = clientStream
+	}()
+
+	totalUsage := &dto.Usage{}
+	// 已经产生上游用量时直接返回错误并照常计费，否则交给调用方处理（退还预扣费、重试其他渠道）
+	fail := func(openaiErr *dto.OpenAIErrorWithStatusCode) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+		if totalUsage.TotalTokens == 0 && totalUsage.PromptTokens == 0 {
+			return nil, openaiErr
+		}
+		info.StructuredOutputFailed = true
+		c.JSON(openaiErr.StatusCode, gin.H{
+			"error": openaiErr.Error,
+		})
+		return totalUsage, nil
+	}
+
+	originWriter := c.Writer
+	defer func() {
+		c.Writer = originWriter
+	}()
+
+	// generate 生成一个通过校验的响应，失败时返回的错误交给 fail 处理
+	generate := func() (*dto.OpenAITextResponse, *dto.OpenAIErrorWithStatusCode) {
+		messages := textRequest.Messages
+		var validateErr error
+		for attempt := 0; attempt <= maxRetries; attempt++ {
+			info.StructuredOutputAttempts++
+			request := *textRequest
+			if completions > 1 {
+				request.N = 0
+				request.BestOf = 0
+			}
+			request.Messages = append([]dto.Message{}, messages...)
+			request.Tools = append([]dto.ToolCallRequest{}, textRequest.Tools...)
+			switch mode {
+			case structuredOutputModeTool:
+				service.ApplyStructuredOutputTool(&request, format)
+			case structuredOutputModePrompt:
+				service.ApplyStructuredOutputPrompt(&request, format)
+			}
+
+			convertedRequest, err := adaptor.ConvertOpenAIRequest(c, info, &request)
+			if err != nil {
+				return nil, service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
+			}
+			jsonData, openaiErr := getRequestBodyJson(info, convertedRequest)
+			if openaiErr != nil {
+				return nil, openaiErr
+			}
+			resp, err := adaptor.DoRequest(c, info, bytes.NewBuffer(jsonData))
+			if err != nil {
+				return nil, service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+			}
+			httpResp, _ := resp.(*http.Response)
+			if httpResp == nil {
+				return nil, service.OpenAIErrorWrapper(errors.New("empty upstream response"), "do_request_failed", http.StatusInternalServerError)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				openaiErr = service.RelayErrorHandler(httpResp, false)
+				service.ResetStatusCode(openaiErr, c.GetString("status_code_mapping"))
+				return nil, openaiErr
+			}
+
+			writer := helper.NewBufferedConvertWriter(originWriter)
+			c.Writer = writer
+			usage, openaiErr := adaptor.DoResponse(c, httpResp, info)
+			c.Writer = originWriter
+			if openaiErr != nil {
+				return nil, openaiErr
+			}
+			attemptUsage, _ := usage.(*dto.Usage)
+			accumulateUsage(totalUsage, attemptUsage)
+
+			var openAIResponse dto.OpenAITextResponse
+			if err := common.DecodeJson(writer.Body(), &openAIResponse); err != nil {
+				return nil, service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+			}
+			if mode == structuredOutputModeTool {
+				service.StructuredOutputToolToContent(&openAIResponse, toolName)
+			}
+			if len(openAIResponse.Choices) == 0 {
+				return nil, service.OpenAIErrorWrapper(errors.New("upstream returned no choices"), "structured_output_failed", http.StatusInternalServerError)
+			}
+
+			validateErr = nil
+			var invalidContent string
+			for i := range openAIResponse.Choices {
+				message := &openAIResponse.Choices[i].Message
+				content := message.StringContent()
+				text, err := service.ValidateStructuredOutput(content, format)
+				if err != nil {
+					validateErr = err
+					invalidContent = content
+					break
+				}
+				message.SetStringContent(text)
+			}
+			if validateErr == nil {
+				return &openAIResponse, nil
+			}
+			if errors.Is(validateErr, service.ErrInvalidJsonSchema) {
+				return nil, service.OpenAIErrorWrapperLocal(validateErr, "invalid_json_schema", http.StatusBadRequest)
+			}
+
+			common.LogWarn(c, fmt.Sprintf("structured output attempt %d failed validation: %s", attempt+1, validateErr.Error()))
+			messages = append(messages, service.StructuredOutputRetryMessages(invalidContent, validateErr)...)
+		}
+		return nil, &dto.OpenAIErrorWithStatusCode{
+			Error: dto.OpenAIError{
+				Message: fmt.Sprintf("model output failed structured output validation after %d attempts: %s", maxRetries+1, validateErr.Error()),
+				Type:    "invalid_response_error",
+				Code:    "structured_output_validation_failed",
+			},
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+
+	responses := make([]*dto.OpenAITextResponse, 0, completions)
+	for i := 0; i < completions; i++ {
+		response, openaiErr := generate()
+		if openaiErr != nil {
+			return fail(openaiErr)
+		}
+		responses = append(responses, response)
+	}
+	merged := mergeFanOutResponses(responses)
+	merged.Usage = *totalUsage
+	writeChatCompletionResponse(c, info, clientStream, merged)
+	return totalUsage, nil
+}
+
+// writeChatCompletionResponse 按客户端要求的格式输出完整的对话响应，流式时拆分为 chunk 输出
+func writeChatCompletionResponse(c *gin.Context, info *relaycommon.RelayInfo, stream bool, response *dto.OpenAITextResponse) {
+	if !stream {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	helper.SetEventStreamHeaders(c)
+	for _, chunk := range service.ResponseOpenAI2StreamResponses(response) {
+		_ = helper.ObjectData(c, chunk)
+	}
+	if info.ShouldIncludeUsage {
+		_ = helper.ObjectData(c, helper.GenerateFinalUsageResponse(response.Id, response.Created, response.Model, response.Usage))
+	}
+	helper.Done(c)
+}
@@ -0,0 +1,169 @@
+package relay
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/model_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shouldEmulateToolCalls 判断是否由网关在提示词中模拟工具调用
+func shouldEmulateToolCalls(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) bool {
+	if info.RelayMode != relayconstant.RelayModeChatCompletions || len(request.Tools) == 0 {
+		return false
+	}
+	if emulation, ok := info.ChannelSetting[constant.ChannelSettingToolCallEmulation].(bool); ok && emulation {
+		return true
+	}
+	return model_setting.GetToolCallSettings().IsEmulationModel(info.UpstreamModelName)
+}
+
+func isEmptyStreamChoice(choice *dto.ChatCompletionsStreamResponseChoice) bool {
+	delta := choice.Delta
+	return delta.Content == nil && delta.ReasoningContent == nil && delta.Reasoning == nil &&
+		delta.Role == "" && len(delta.ToolCalls) == 0 && choice.FinishReason == nil
+}
+
+// doToolCallEmulation 处理模拟工具调用的上游响应，把模型输出中的工具调用改写为标准 tool_calls
+func doToolCallEmulation(c *gin.Context, adaptor channel.Adaptor, resp *http.Response, info *relaycommon.RelayInfo) (any, *dto.OpenAIErrorWithStatusCode) {
+	originWriter := c.Writer
+	defer func() {
+		c.Writer = originWriter
+	}()
+
+	if !info.IsStream {
+		writer := helper.NewBufferedConvertWriter(originWriter)
+		c.Writer = writer
+		usage, openaiErr := adaptor.DoResponse(c, resp, info)
+		c.Writer = originWriter
+		if openaiErr != nil {
+			return nil, openaiErr
+		}
+		var openAIResponse dto.OpenAITextResponse
+		if err := common.DecodeJson(writer.Body(), &openAIResponse); err != nil {
+			return nil, service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+		}
+		service.ResponseToolCallEmulation(&openAIResponse)
+		c.JSON(http.StatusOK, openAIResponse)
+		return usage, nil
+	}
+
+	parsers := make(map[int]*service.ToolCallStreamParser)
+	finished := make(map[int]bool)
+	getParser := func(index int) *service.ToolCallStreamParser {
+		parser, ok := parsers[index]
+		if !ok {
+			parser = &service.ToolCallStreamParser{}
+			parsers[index] = parser
+		}
+		return parser
+	}
+	var lastChunk dto.ChatCompletionsStreamResponse
+	newChunk := func(choice dto.ChatCompletionsStreamResponseChoice) *dto.ChatCompletionsStreamResponse {
+		return &dto.ChatCompletionsStreamResponse{
+			Id:      lastChunk.Id,
+			Object:  "chat.completion.chunk",
+			Created: lastChunk.Created,
+			Model:   lastChunk.Model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{choice},
+		}
+	}
+
+	var writer *helper.ConvertWriter
+	// finishChoice 输出暂存的文本或解析出的工具调用，finishReason 为空时仅在解析出工具调用时输出结束标记
+	finishChoice := func(index int, finishReason string) {
+		finished[index] = true
+		text, toolCalls := getParser(index).Finish()
+		if text != "" || len(toolCalls) > 0 {
+			delta := dto.ChatCompletionsStreamResponseChoiceDelta{}
+			if text != "" {
+				delta.SetContentString(text)
+			}
+			for i := range toolCalls {
+				toolCalls[i].SetIndex(i)
+			}
+			delta.ToolCalls = toolCalls
+			writeBridgeEvent(writer, "", newChunk(dto.ChatCompletionsStreamResponseChoice{
+				Index: index,
+				Delta: delta,
+			}))
+		}
+		if len(toolCalls) > 0 {
+			finishReason = "tool_calls"
+		}
+		if finishReason != "" {
+			writeBridgeEvent(writer, "", newChunk(dto.ChatCompletionsStreamResponseChoice{
+				Index:        index,
+				FinishReason: &finishReason,
+			}))
+		}
+	}
+
+	writer = helper.NewStreamConvertWriter(originWriter, func(data string) {
+		if data == "[DONE]" {
+			// 上游没有返回 finish_reason 时在结束前补充输出
+			for index := range parsers {
+				if !finished[index] {
+					finishChoice(index, "")
+				}
+			}
+			writer.WriteEvent("", []byte(data))
+			return
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.DecodeJsonStr(data, &chunk); err != nil {
+			writer.WriteEvent("", []byte(data))
+			return
+		}
+		lastChunk.Id = chunk.Id
+		lastChunk.Created = chunk.Created
+		lastChunk.Model = chunk.Model
+
+		var finishes []dto.ChatCompletionsStreamResponseChoice
+		choices := make([]dto.ChatCompletionsStreamResponseChoice, 0, len(chunk.Choices))
+		for _, choice := range chunk.Choices {
+			parser := getParser(choice.Index)
+			if choice.Delta.Content != nil {
+				if text := parser.Feed(*choice.Delta.Content); text != "" {
+					choice.Delta.SetContentString(text)
+				} else {
+					choice.Delta.Content = nil
+				}
+			}
+			if choice.FinishReason != nil {
+				finishes = append(finishes, choice)
+				choice.FinishReason = nil
+			}
+			if !isEmptyStreamChoice(&choice) {
+				choices = append(choices, choice)
+			}
+		}
+		// 用量放在结束标记之后单独输出
+		usage := chunk.Usage
+		chunk.Usage = nil
+		chunk.Choices = choices
+		if len(chunk.Choices) > 0 {
+			writeBridgeEvent(writer, "", chunk)
+		}
+		for _, choice := range finishes {
+			finishChoice(choice.Index, *choice.FinishReason)
+		}
+		if usage != nil {
+			writeBridgeEvent(writer, "", helper.GenerateFinalUsageResponse(chunk.Id, chunk.Created, chunk.Model, *usage))
+		}
+	})
+	c.Writer = writer
+	usage, openaiErr := adaptor.DoResponse(c, resp, info)
+	if openaiErr != nil {
+		return nil, openaiErr
+	}
+	return usage, nil
+}
@@ -0,0 +1,77 @@
+package relay
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting/model_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shouldDoWebSearch 判断是否由网关执行请求中的联网搜索
+func shouldDoWebSearch(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) bool {
+	requested := request.WebSearchOptions != nil
+	for _, tool := range request.Tools {
+		if service.IsWebSearchTool(tool) {
+			requested = true
+			break
+		}
+	}
+	if !requested {
+		return false
+	}
+	if webSearch, ok := info.ChannelSetting[constant.ChannelSettingWebSearch].(bool); ok && webSearch {
+		return true
+	}
+	return model_setting.GetWebSearchSettings().IsWebSearchModel(info.UpstreamModelName)
+}
+
+func getWebSearchMaxRounds() int {
+	return model_setting.GetWebSearchSettings().MaxRounds
+}
+
+// newWebSearchServerTool 创建由网关查询搜索后端的 web_search 工具，搜索次数按内置工具计费
+func newWebSearchServerTool(request *dto.GeneralOpenAIRequest) *serverTool {
+	searchContextSize := "medium"
+	if request.WebSearchOptions != nil && request.WebSearchOptions.SearchContextSize != "" {
+		searchContextSize = request.WebSearchOptions.SearchContextSize
+	}
+	return &serverTool{
+		definition: service.WebSearchFunctionTool(),
+		execute: func(c *gin.Context, info *relaycommon.RelayInfo, toolCall dto.ToolCallRequest) string {
+			return executeWebSearch(c, info, toolCall, searchContextSize)
+		},
+	}
+}
+
+// executeWebSearch 执行一次搜索调用并返回工具消息内容，搜索失败时把错误告知模型，只有成功的搜索计费
+func executeWebSearch(c *gin.Context, info *relaycommon.RelayInfo, toolCall dto.ToolCallRequest, searchContextSize string) string {
+	query, err := service.ParseWebSearchQuery(toolCall.Function.Arguments)
+	if err != nil {
+		return "Invalid search arguments: " + err.Error()
+	}
+	results, err := service.WebSearch(c.Request.Context(), query)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("web search %q failed: %s", query, err.Error()))
+		return "Search failed: " + err.Error()
+	}
+	if info.ResponsesUsageInfo == nil {
+		info.ResponsesUsageInfo = &relaycommon.ResponsesUsageInfo{
+			BuiltInTools: make(map[string]*relaycommon.BuildInToolInfo),
+		}
+	}
+	toolInfo, ok := info.ResponsesUsageInfo.BuiltInTools[dto.BuildInToolWebSearchPreview]
+	if !ok {
+		toolInfo = &relaycommon.BuildInToolInfo{
+			ToolName:          dto.BuildInToolWebSearchPreview,
+			SearchContextSize: searchContextSize,
+		}
+		info.ResponsesUsageInfo.BuiltInTools[dto.BuildInToolWebSearchPreview] = toolInfo
+	}
+	toolInfo.CallCount++
+	return service.RenderWebSearchResults(results)
+}
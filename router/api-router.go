@@ -81,6 +81,8 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.GET("/", controller.GetOptions)
 			optionRoute.PUT("/", controller.UpdateOption)
 			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
+			optionRoute.GET("/semantic_cache/stats", controller.GetSemanticCacheStats)
+			optionRoute.DELETE("/semantic_cache", controller.ClearSemanticCache)
 		}
 		channelRoute := apiRouter.Group("/channel")
 		channelRoute.Use(middleware.AdminAuth())
@@ -128,6 +130,17 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.PUT("/", controller.UpdateRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
+		// MCP 服务器可以配置在网关主机上执行的 stdio 命令，仅超级管理员可以管理
+		mcpServerRoute := apiRouter.Group("/mcp_server")
+		mcpServerRoute.Use(middleware.RootAuth())
+		{
+			mcpServerRoute.GET("/", controller.GetAllMcpServers)
+			mcpServerRoute.GET("/:id", controller.GetMcpServer)
+			mcpServerRoute.GET("/:id/tools", controller.GetMcpServerTools)
+			mcpServerRoute.POST("/", controller.AddMcpServer)
+			mcpServerRoute.PUT("/", controller.UpdateMcpServer)
+			mcpServerRoute.DELETE("/:id", controller.DeleteMcpServer)
+		}
 		logRoute := apiRouter.Group("/log")
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
@@ -154,21 +167,34 @@ func SetApiRouter(router *gin.Engine) {
 		mjRoute := apiRouter.Group("/mj")
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
 		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
+		mjRoute.POST("/refund", middleware.AdminAuth(), controller.RefundMidjourneyTasks)
+
+		mediaRoute := apiRouter.Group("/media")
+		{
+			mediaRoute.GET("/self", middleware.UserAuth(), controller.GetUserMedia)
+			mediaRoute.DELETE("/self/:id", middleware.UserAuth(), controller.DeleteUserMedia)
+			mediaRoute.GET("/", middleware.AdminAuth(), controller.GetAllMedia)
+			mediaRoute.DELETE("/:id", middleware.AdminAuth(), controller.DeleteMedia)
+		}
 
 		taskRoute := apiRouter.Group("/task")
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
 			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+			taskRoute.POST("/refund", middleware.AdminAuth(), controller.RefundTasks)
+			taskRoute.GET("/webhook/self", middleware.UserAuth(), controller.GetUserTaskWebhookDeliveries)
+			taskRoute.GET("/webhook", middleware.AdminAuth(), controller.GetAllTaskWebhookDeliveries)
+			taskRoute.POST("/webhook/:id/retry", middleware.AdminAuth(), controller.RetryTaskWebhookDelivery)
 		}
 
 		// 订阅相关路由
 		subscriptionRoute := apiRouter.Group("/subscription")
 		{
 			// 公开接口 - 获取订阅套餐
-			subscriptionRoute.GET("/plans", controller.GetAllSubscriptionPlans)
+			subscriptionRoute.GET("/plans", middleware.TryUserAuth(), controller.GetAllSubscriptionPlans)
 			subscriptionRoute.GET("/plans/search", controller.SearchSubscriptionPlans)
 			subscriptionRoute.GET("/plans/page", controller.GetSubscriptionPlansByPage)
-			subscriptionRoute.GET("/plans/:id", controller.GetSubscriptionPlan)
+			subscriptionRoute.GET("/plans/:id", middleware.TryUserAuth(), controller.GetSubscriptionPlan)
 
 			// 用户接口 - 需要登录
 			userSubscriptionRoute := subscriptionRoute.Group("/")
@@ -176,6 +202,16 @@ func SetApiRouter(router *gin.Engine) {
 			{
 				userSubscriptionRoute.POST("/purchase", controller.PurchaseSubscription)
 				userSubscriptionRoute.GET("/my", controller.GetUserSubscriptions)
+				userSubscriptionRoute.PUT("/my/:id/auto-renew", controller.UpdateSubscriptionAutoRenew)
+				userSubscriptionRoute.GET("/my/:id/change", controller.GetSubscriptionPlanChangeQuote)
+				userSubscriptionRoute.POST("/my/:id/change", controller.ChangeSubscriptionPlan)
+				userSubscriptionRoute.GET("/my/:id/changes", controller.GetSubscriptionPlanChanges)
+				userSubscriptionRoute.GET("/my/:id/cancel", controller.GetSubscriptionCancelQuote)
+				userSubscriptionRoute.POST("/my/:id/cancel", controller.CancelSubscription)
+				userSubscriptionRoute.DELETE("/my/:id/cancel", controller.ResumeSubscription)
+				userSubscriptionRoute.GET("/my/:id/cancellations", controller.GetSubscriptionCancellations)
+				userSubscriptionRoute.POST("/gift", controller.PurchaseSubscriptionGift)
+				userSubscriptionRoute.GET("/gift", controller.GetSubscriptionGifts)
 				userSubscriptionRoute.GET("/active", controller.GetActiveUserSubscriptions)
 				userSubscriptionRoute.GET("/quotas", controller.GetSubscriptionQuotas)
 				userSubscriptionRoute.GET("/usage", controller.GetSubscriptionUsage)
@@ -191,6 +227,11 @@ func SetApiRouter(router *gin.Engine) {
 				adminSubscriptionRoute.PUT("/plans/:id", controller.UpdateSubscriptionPlan)
 				adminSubscriptionRoute.DELETE("/plans/:id", controller.DeleteSubscriptionPlan)
 				adminSubscriptionRoute.GET("/users", controller.GetAllUserSubscriptions)
+				adminSubscriptionRoute.GET("/users/:id/cancel", controller.AdminGetSubscriptionCancelQuote)
+				adminSubscriptionRoute.POST("/users/:id/cancel", controller.AdminCancelSubscription)
+				adminSubscriptionRoute.DELETE("/users/:id/cancel", controller.AdminResumeSubscription)
+				adminSubscriptionRoute.GET("/cancellations", controller.GetAllSubscriptionCancellations)
+				adminSubscriptionRoute.POST("/cancellations/:id/refunded", controller.CompleteSubscriptionRefund)
 				adminSubscriptionRoute.GET("/report", controller.GetSubscriptionReport)
 				adminSubscriptionRoute.GET("/system-stats", controller.GetSystemSubscriptionStats)
 				adminSubscriptionRoute.POST("/monitor", controller.TriggerSubscriptionMonitor)
@@ -33,6 +33,19 @@ func SetRelayRouter(router *gin.Engine) {
 		wsRouter.Use(middleware.Distribute())
 		wsRouter.GET("/realtime", controller.WssRelay)
 	}
+	{
+		// 网关保存的 Responses API 响应
+		relayV1Router.GET("/responses/:id", controller.GetResponse)
+		relayV1Router.DELETE("/responses/:id", controller.DeleteResponse)
+		relayV1Router.GET("/responses/:id/input_items", controller.ListResponseInputItems)
+	}
+	{
+		// 视频生成异步任务
+		videoRouter := relayV1Router.Group("/video")
+		videoRouter.Use(middleware.Distribute())
+		videoRouter.POST("/generations", controller.RelayTask)
+		videoRouter.GET("/generations/:task_id", controller.RelayTask)
+	}
 	{
 		//http router
 		httpRouter := relayV1Router.Group("")
@@ -43,7 +56,7 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/edits", controller.Relay)
 		httpRouter.POST("/images/generations", controller.Relay)
 		httpRouter.POST("/images/edits", controller.Relay)
-		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
+		httpRouter.POST("/images/variations", controller.Relay)
 		httpRouter.POST("/embeddings", controller.Relay)
 		httpRouter.POST("/engines/:model/embeddings", controller.Relay)
 		httpRouter.POST("/audio/transcriptions", controller.Relay)
@@ -65,6 +78,31 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/rerank", controller.Relay)
 	}
 
+	// Ollama 兼容接口
+	relayOllamaRouter := router.Group("/api")
+	relayOllamaRouter.Use(middleware.TokenAuth())
+	{
+		relayOllamaRouter.GET("/tags", controller.OllamaListModels)
+		ollamaHttpRouter := relayOllamaRouter.Group("")
+		ollamaHttpRouter.Use(middleware.ModelRequestRateLimit(), middleware.Distribute())
+		ollamaHttpRouter.POST("/chat", controller.RelayOllama)
+		ollamaHttpRouter.POST("/generate", controller.RelayOllama)
+		ollamaHttpRouter.POST("/embeddings", controller.RelayOllama)
+		ollamaHttpRouter.POST("/embed", controller.RelayOllama)
+	}
+
+	// MCP 工具服务器代理（Streamable HTTP）
+	relayMcpRouter := router.Group("/mcp")
+	relayMcpRouter.Use(middleware.TokenAuth())
+	{
+		relayMcpRouter.POST("/:name", controller.RelayMcp)
+		relayMcpRouter.GET("/:name", controller.RelayMcp)
+		relayMcpRouter.DELETE("/:name", controller.RelayMcp)
+	}
+
+	// 网关存储的生成结果，通过签名地址访问
+	router.GET("/media/*key", controller.ServeMedia)
+
 	relayMjRouter := router.Group("/mj")
 	registerMjRouterGroup(relayMjRouter)
 
@@ -0,0 +1,359 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// jsonSchemaValidator 实现 Structured Outputs 常用的 JSON Schema 子集校验：
+// type、enum、const、properties、required、additionalProperties、items、anyOf/oneOf/allOf、
+// 长度与数值范围、pattern 以及指向 $defs / definitions 的 $ref
+type jsonSchemaValidator struct {
+	root map[string]any
+	// 正在校验的 $ref 与值位置的组合，同一位置再次进入同一个 $ref 说明引用成环
+	activeRefs map[string]bool
+	depth      int
+}
+
+// jsonSchemaMaxDepth schema 嵌套校验的最大深度，防止客户端构造的 schema 耗尽栈空间
+const jsonSchemaMaxDepth = 256
+
+// ErrInvalidJsonSchema schema 本身无效（无法解析的 $ref、循环引用、嵌套过深等），与模型输出无关
+var ErrInvalidJsonSchema = errors.New("invalid json schema")
+
+// ValidateJsonSchema 校验 value（json.Unmarshal 得到的值）是否符合 schema，返回第一个不符合的位置
+func ValidateJsonSchema(value any, schema any) error {
+	root, err := normalizeJsonSchema(schema)
+	if err != nil {
+		return err
+	}
+	v := &jsonSchemaValidator{root: root, activeRefs: make(map[string]bool)}
+	return v.validate(value, root, "$")
+}
+
+func normalizeJsonSchema(schema any) (map[string]any, error) {
+	switch s := schema.(type) {
+	case nil:
+		return map[string]any{}, nil
+	case map[string]any:
+		return s, nil
+	}
+	// 其他类型（如 json.RawMessage、结构体）统一转换为 map
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidJsonSchema, err)
+	}
+	return root, nil
+}
+
+func (v *jsonSchemaValidator) resolveRef(ref string) (map[string]any, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("%w: unsupported $ref: %s", ErrInvalidJsonSchema, ref)
+	}
+	var current any = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		node, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: unresolvable $ref: %s", ErrInvalidJsonSchema, ref)
+		}
+		current, ok = node[part]
+		if !ok {
+			return nil, fmt.Errorf("%w: unresolvable $ref: %s", ErrInvalidJsonSchema, ref)
+		}
+	}
+	schema, ok := current.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: unresolvable $ref: %s", ErrInvalidJsonSchema, ref)
+	}
+	return schema, nil
+}
+
+func (v *jsonSchemaValidator) validate(value any, schema map[string]any, path string) error {
+	v.depth++
+	defer func() {
+		v.depth--
+	}()
+	if v.depth > jsonSchemaMaxDepth {
+		return fmt.Errorf("%w: %s: schema nesting exceeds %d levels", ErrInvalidJsonSchema, path, jsonSchemaMaxDepth)
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		key := ref + "\x00" + path
+		if v.activeRefs[key] {
+			return fmt.Errorf("%w: %s: circular $ref %s", ErrInvalidJsonSchema, path, ref)
+		}
+		resolved, err := v.resolveRef(ref)
+		if err != nil {
+			return err
+		}
+		v.activeRefs[key] = true
+		defer delete(v.activeRefs, key)
+		return v.validate(value, resolved, path)
+	}
+
+	if types, ok := jsonSchemaTypes(schema["type"]); ok {
+		matched := false
+		for _, t := range types {
+			if jsonValueMatchesType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonValueType(value))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		matched := false
+		for _, candidate := range enum {
+			if jsonValueEqual(value, candidate) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value is not one of the allowed enum values", path)
+		}
+	}
+	if constValue, ok := schema["const"]; ok && !jsonValueEqual(value, constValue) {
+		return fmt.Errorf("%s: value does not match const", path)
+	}
+
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			if subSchema, ok := sub.(map[string]any); ok {
+				if err := v.validate(value, subSchema, path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		count, err := v.countMatches(value, anyOf, path)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%s: value does not match any schema in anyOf", path)
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		count, err := v.countMatches(value, oneOf, path)
+		if err != nil {
+			return err
+		}
+		if count != 1 {
+			return fmt.Errorf("%s: value must match exactly one schema in oneOf, matched %d", path, count)
+		}
+	}
+
+	switch val := value.(type) {
+	case map[string]any:
+		return v.validateObject(val, schema, path)
+	case []any:
+		return v.validateArray(val, schema, path)
+	case string:
+		return validateJsonString(val, schema, path)
+	case float64:
+		return validateJsonNumber(val, schema, path)
+	}
+	return nil
+}
+
+// countMatches 统计 value 符合的子 schema 个数，子 schema 本身无效时直接返回错误
+func (v *jsonSchemaValidator) countMatches(value any, schemas []any, path string) (int, error) {
+	count := 0
+	for _, sub := range schemas {
+		subSchema, ok := sub.(map[string]any)
+		if !ok {
+			continue
+		}
+		err := v.validate(value, subSchema, path)
+		if err == nil {
+			count++
+		} else if errors.Is(err, ErrInvalidJsonSchema) {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+func (v *jsonSchemaValidator) validateObject(value map[string]any, schema map[string]any, path string) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, exists := value[key]; !exists {
+					return fmt.Errorf("%s: missing required property %q", path, key)
+				}
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	for key, propertyValue := range value {
+		propertyPath := path + "." + key
+		if propertySchema, ok := properties[key].(map[string]any); ok {
+			if err := v.validate(propertyValue, propertySchema, propertyPath); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: additional property %q is not allowed", path, key)
+			}
+		case map[string]any:
+			if err := v.validate(propertyValue, additional, propertyPath); err != nil {
+				return err
+			}
+		}
+	}
+	if minProperties, ok := jsonSchemaNumber(schema["minProperties"]); ok && float64(len(value)) < minProperties {
+		return fmt.Errorf("%s: expected at least %v properties", path, minProperties)
+	}
+	if maxProperties, ok := jsonSchemaNumber(schema["maxProperties"]); ok && float64(len(value)) > maxProperties {
+		return fmt.Errorf("%s: expected at most %v properties", path, maxProperties)
+	}
+	return nil
+}
+
+func (v *jsonSchemaValidator) validateArray(value []any, schema map[string]any, path string) error {
+	if minItems, ok := jsonSchemaNumber(schema["minItems"]); ok && float64(len(value)) < minItems {
+		return fmt.Errorf("%s: expected at least %v items", path, minItems)
+	}
+	if maxItems, ok := jsonSchemaNumber(schema["maxItems"]); ok && float64(len(value)) > maxItems {
+		return fmt.Errorf("%s: expected at most %v items", path, maxItems)
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range value {
+			if err := v.validate(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	if uniqueItems, ok := schema["uniqueItems"].(bool); ok && uniqueItems {
+		for i := 0; i < len(value); i++ {
+			for j := i + 1; j < len(value); j++ {
+				if jsonValueEqual(value[i], value[j]) {
+					return fmt.Errorf("%s: items must be unique", path)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateJsonString(value string, schema map[string]any, path string) error {
+	length := float64(len([]rune(value)))
+	if minLength, ok := jsonSchemaNumber(schema["minLength"]); ok && length < minLength {
+		return fmt.Errorf("%s: string is shorter than %v", path, minLength)
+	}
+	if maxLength, ok := jsonSchemaNumber(schema["maxLength"]); ok && length > maxLength {
+		return fmt.Errorf("%s: string is longer than %v", path, maxLength)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		// 无法编译的正则（如 lookahead）不做校验
+		if err == nil && !re.MatchString(value) {
+			return fmt.Errorf("%s: string does not match pattern %q", path, pattern)
+		}
+	}
+	return nil
+}
+
+func validateJsonNumber(value float64, schema map[string]any, path string) error {
+	if minimum, ok := jsonSchemaNumber(schema["minimum"]); ok && value < minimum {
+		return fmt.Errorf("%s: %v is less than minimum %v", path, value, minimum)
+	}
+	if maximum, ok := jsonSchemaNumber(schema["maximum"]); ok && value > maximum {
+		return fmt.Errorf("%s: %v is greater than maximum %v", path, value, maximum)
+	}
+	if minimum, ok := jsonSchemaNumber(schema["exclusiveMinimum"]); ok && value <= minimum {
+		return fmt.Errorf("%s: %v must be greater than %v", path, value, minimum)
+	}
+	if maximum, ok := jsonSchemaNumber(schema["exclusiveMaximum"]); ok && value >= maximum {
+		return fmt.Errorf("%s: %v must be less than %v", path, value, maximum)
+	}
+	if multipleOf, ok := jsonSchemaNumber(schema["multipleOf"]); ok && multipleOf > 0 {
+		quotient := value / multipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			return fmt.Errorf("%s: %v is not a multiple of %v", path, value, multipleOf)
+		}
+	}
+	return nil
+}
+
+func jsonSchemaTypes(t any) ([]string, bool) {
+	switch tt := t.(type) {
+	case string:
+		return []string{tt}, true
+	case []any:
+		types := make([]string, 0, len(tt))
+		for _, item := range tt {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types, len(types) > 0
+	}
+	return nil, false
+}
+
+func jsonSchemaNumber(n any) (float64, bool) {
+	f, ok := n.(float64)
+	return f, ok
+}
+
+func jsonValueType(value any) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func jsonValueMatchesType(value any, t string) bool {
+	actual := jsonValueType(value)
+	if t == "number" && actual == "integer" {
+		return true
+	}
+	return actual == t
+}
+
+func jsonValueEqual(a, b any) bool {
+	aData, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bData, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(aData) == string(bData)
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"one-api/dto"
+	"strings"
+	"testing"
+)
+
+func TestValidateJsonSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		value   string
+		wantErr string // 为空表示应通过校验
+	}{
+		{
+			name:   "object with required properties",
+			schema: `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer","minimum":0}},"required":["name","age"],"additionalProperties":false}`,
+			value:  `{"name":"a","age":3}`,
+		},
+		{
+			name:    "missing required property",
+			schema:  `{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`,
+			value:   `{}`,
+			wantErr: `missing required property "name"`,
+		},
+		{
+			name:    "additional property not allowed",
+			schema:  `{"type":"object","properties":{"name":{"type":"string"}},"additionalProperties":false}`,
+			value:   `{"name":"a","extra":1}`,
+			wantErr: `additional property "extra" is not allowed`,
+		},
+		{
+			name:    "wrong type reports path",
+			schema:  `{"type":"object","properties":{"age":{"type":"integer"}}}`,
+			value:   `{"age":"3"}`,
+			wantErr: "$.age: expected integer, got string",
+		},
+		{
+			name:   "integer is a number",
+			schema: `{"type":"number"}`,
+			value:  `3`,
+		},
+		{
+			name:    "number is not an integer",
+			schema:  `{"type":"integer"}`,
+			value:   `3.5`,
+			wantErr: "expected integer",
+		},
+		{
+			name:   "nullable type list",
+			schema: `{"type":["string","null"]}`,
+			value:  `null`,
+		},
+		{
+			name:    "enum",
+			schema:  `{"enum":["red","green"]}`,
+			value:   `"blue"`,
+			wantErr: "enum",
+		},
+		{
+			name:    "array items and length",
+			schema:  `{"type":"array","items":{"type":"string"},"maxItems":2}`,
+			value:   `["a",1]`,
+			wantErr: "$[1]: expected string",
+		},
+		{
+			name:    "unique items",
+			schema:  `{"type":"array","uniqueItems":true}`,
+			value:   `[1,2,1]`,
+			wantErr: "items must be unique",
+		},
+		{
+			name:    "string pattern",
+			schema:  `{"type":"string","pattern":"^[a-z]+$"}`,
+			value:   `"ABC"`,
+			wantErr: "does not match pattern",
+		},
+		{
+			name:   "anyOf",
+			schema: `{"anyOf":[{"type":"string"},{"type":"integer"}]}`,
+			value:  `1`,
+		},
+		{
+			name:    "oneOf matches two",
+			schema:  `{"oneOf":[{"type":"number"},{"type":"integer"}]}`,
+			value:   `1`,
+			wantErr: "matched 2",
+		},
+		{
+			name:   "ref to defs",
+			schema: `{"$defs":{"id":{"type":"integer"}},"type":"object","properties":{"id":{"$ref":"#/$defs/id"}}}`,
+			value:  `{"id":1}`,
+		},
+		{
+			name:   "recursive schema consumes the value",
+			schema: `{"type":"object","properties":{"value":{"type":"integer"},"children":{"type":"array","items":{"$ref":"#"}}}}`,
+			value:  `{"value":1,"children":[{"value":2,"children":[{"value":3}]}]}`,
+		},
+		{
+			name:    "recursive schema rejects nested mismatch",
+			schema:  `{"type":"object","properties":{"value":{"type":"integer"},"children":{"type":"array","items":{"$ref":"#"}}}}`,
+			value:   `{"value":1,"children":[{"value":"x"}]}`,
+			wantErr: "$.children[0].value: expected integer",
+		},
+		{
+			name:    "unresolvable ref",
+			schema:  `{"$ref":"#/$defs/missing"}`,
+			value:   `1`,
+			wantErr: "unresolvable $ref",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateJsonSchema(decodeTestJson(t, tt.value), decodeTestJson(t, tt.schema))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateJsonSchemaCircularRef(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+	}{
+		{name: "self reference", schema: `{"$ref":"#"}`, value: `{}`},
+		{name: "ref cycle", schema: `{"$defs":{"a":{"$ref":"#/$defs/b"},"b":{"$ref":"#/$defs/a"}},"$ref":"#/$defs/a"}`, value: `1`},
+		{name: "allOf self reference", schema: `{"allOf":[{"$ref":"#"}]}`, value: `"x"`},
+		{name: "anyOf self reference", schema: `{"anyOf":[{"type":"string"},{"$ref":"#"}]}`, value: `1`},
+		{name: "cycle below a property", schema: `{"properties":{"a":{"$ref":"#/properties/a"}}}`, value: `{"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateJsonSchema(decodeTestJson(t, tt.value), decodeTestJson(t, tt.schema))
+			if !errors.Is(err, ErrInvalidJsonSchema) || !strings.Contains(err.Error(), "circular $ref") {
+				t.Fatalf("expected circular $ref schema error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateJsonSchemaMaxDepth(t *testing.T) {
+	schema := `{"type":"array","items":{"$ref":"#"}}`
+	value := strings.Repeat("[", jsonSchemaMaxDepth+1) + strings.Repeat("]", jsonSchemaMaxDepth+1)
+	err := ValidateJsonSchema(decodeTestJson(t, value), decodeTestJson(t, schema))
+	if !errors.Is(err, ErrInvalidJsonSchema) {
+		t.Fatalf("expected schema depth error, got %v", err)
+	}
+}
+
+func TestValidateStructuredOutputInvalidSchema(t *testing.T) {
+	format := &dto.ResponseFormat{
+		Type:       "json_schema",
+		JsonSchema: &dto.FormatJsonSchema{Name: "loop", Schema: json.RawMessage(`{"$ref":"#"}`)},
+	}
+	_, err := ValidateStructuredOutput(`{"a":1}`, format)
+	if !errors.Is(err, ErrInvalidJsonSchema) {
+		t.Fatalf("expected schema error, got %v", err)
+	}
+}
+
+func decodeTestJson(t *testing.T, data string) any {
+	t.Helper()
+	var value any
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		t.Fatalf("invalid test json %s: %v", data, err)
+	}
+	return value
+}
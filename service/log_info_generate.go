@@ -25,6 +25,13 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		other["is_model_mapped"] = true
 		other["upstream_model_name"] = relayInfo.UpstreamModelName
 	}
+	if relayInfo.StructuredOutputAttempts > 0 {
+		other["structured_output_emulation"] = true
+		other["structured_output_attempts"] = relayInfo.StructuredOutputAttempts
+		if relayInfo.StructuredOutputFailed {
+			other["structured_output_failed"] = true
+		}
+	}
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
 	other["admin_info"] = adminInfo
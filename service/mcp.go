@@ -0,0 +1,68 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// McpModelName 在令牌模型限制与日志中代表 MCP 服务器的名称
+func McpModelName(serverName string) string {
+	return "mcp/" + serverName
+}
+
+// CheckMcpServerPermission 检查分组与令牌是否有权使用 MCP 服务器，
+// 启用了模型限制的令牌需要在可用模型中包含 mcp/<服务器名>
+func CheckMcpServerPermission(c *gin.Context, server *model.McpServer, group string) error {
+	if !server.IsGroupAllowed(group) {
+		return fmt.Errorf("group %s is not allowed to use mcp server %s", group, server.Name)
+	}
+	if c.GetBool("token_model_limit_enabled") {
+		tokenModelLimit, _ := c.Get("token_model_limit")
+		limits, _ := tokenModelLimit.(map[string]bool)
+		if _, ok := limits[McpModelName(server.Name)]; !ok {
+			return fmt.Errorf("token is not allowed to use mcp server %s", server.Name)
+		}
+	}
+	return nil
+}
+
+// GetMcpCallQuota 计算单次工具调用的额度
+func GetMcpCallQuota(server *model.McpServer, group string) (int, float64) {
+	groupRatio := setting.GetGroupRatio(group)
+	return int(server.PricePerCall * groupRatio * common.QuotaPerUnit), groupRatio
+}
+
+// ConsumeMcpToolCall 直接代理 MCP 会话时，对一次工具调用扣费并记录日志
+func ConsumeMcpToolCall(c *gin.Context, server *model.McpServer, toolName string, group string, startTime time.Time, success bool) {
+	relayInfo := relaycommon.GenRelayInfo(c)
+	quota, groupRatio := GetMcpCallQuota(server, group)
+	if !success {
+		quota = 0
+	}
+	if quota > 0 {
+		if err := PostConsumeQuota(relayInfo, quota, 0, true); err != nil {
+			common.SysError("error consuming mcp tool call quota: " + err.Error())
+		}
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+	}
+	other := map[string]interface{}{
+		"mcp_server":     server.Name,
+		"mcp_tool":       toolName,
+		"mcp_success":    success,
+		"price_per_call": server.PricePerCall,
+		"group_ratio":    groupRatio,
+	}
+	logContent := fmt.Sprintf("MCP 工具调用 %s/%s，单次价格 %.4f，分组倍率 %.2f", server.Name, toolName, server.PricePerCall, groupRatio)
+	if !success {
+		logContent += "，调用失败不计费"
+	}
+	useTimeSeconds := int(time.Since(startTime).Seconds())
+	model.RecordConsumeLog(c, relayInfo.UserId, 0, 0, 0, McpModelName(server.Name), c.GetString("token_name"), quota, logContent,
+		relayInfo.TokenId, relayInfo.UserQuota, useTimeSeconds, false, group, other)
+}
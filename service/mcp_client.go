@@ -0,0 +1,694 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const mcpProtocolVersion = "2025-03-26"
+
+// McpMessage JSON-RPC 2.0 消息
+type McpMessage struct {
+	JsonRPC string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *McpError       `json:"error,omitempty"`
+}
+
+type McpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *McpError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// McpTool MCP 服务器提供的工具
+type McpTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"inputSchema,omitempty"`
+}
+
+// McpToolResult tools/call 的返回结果
+type McpToolResult struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text,omitempty"`
+	} `json:"content"`
+	IsError bool `json:"isError,omitempty"`
+}
+
+// Text 合并结果中的文本内容，非文本内容以类型占位
+func (r *McpToolResult) Text() string {
+	parts := make([]string, 0, len(r.Content))
+	for _, content := range r.Content {
+		if content.Type == "text" {
+			parts = append(parts, content.Text)
+		} else {
+			parts = append(parts, fmt.Sprintf("[%s content omitted]", content.Type))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// mcpTransport MCP 传输层
+type mcpTransport interface {
+	// request 发送请求并等待对应 id 的响应
+	request(ctx context.Context, message *McpMessage) (*McpMessage, error)
+	// notify 发送不需要响应的通知
+	notify(ctx context.Context, message *McpMessage) error
+	close()
+}
+
+// mcpPending 按请求 id 分发异步收到的响应（stdio 与 sse 传输）
+type mcpPending struct {
+	mu      sync.Mutex
+	waiters map[string]chan *McpMessage
+	err     error
+}
+
+func (p *mcpPending) add(id string) (chan *McpMessage, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.waiters == nil {
+		p.waiters = make(map[string]chan *McpMessage)
+	}
+	ch := make(chan *McpMessage, 1)
+	p.waiters[id] = ch
+	return ch, nil
+}
+
+func (p *mcpPending) remove(id string) {
+	p.mu.Lock()
+	delete(p.waiters, id)
+	p.mu.Unlock()
+}
+
+func (p *mcpPending) dispatch(message *McpMessage) {
+	// 忽略服务器发出的通知与请求
+	if len(message.Id) == 0 || message.Method != "" {
+		return
+	}
+	p.mu.Lock()
+	ch, ok := p.waiters[string(message.Id)]
+	delete(p.waiters, string(message.Id))
+	p.mu.Unlock()
+	if ok {
+		ch <- message
+	}
+}
+
+// fail 连接断开时唤醒所有等待中的请求
+func (p *mcpPending) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+	for id, ch := range p.waiters {
+		close(ch)
+		delete(p.waiters, id)
+	}
+}
+
+func (p *mcpPending) wait(ctx context.Context, id string, ch chan *McpMessage) (*McpMessage, error) {
+	select {
+	case message, ok := <-ch:
+		if !ok {
+			p.mu.Lock()
+			err := p.err
+			p.mu.Unlock()
+			return nil, err
+		}
+		return message, nil
+	case <-ctx.Done():
+		p.remove(id)
+		return nil, ctx.Err()
+	}
+}
+
+// mcpStdioTransport 启动子进程，通过标准输入输出按行收发 JSON-RPC 消息
+type mcpStdioTransport struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+	pending mcpPending
+}
+
+// mcpInheritedEnv 子进程从网关继承的环境变量，其余变量（数据库、Redis 等密钥）不会传给 MCP 服务器
+var mcpInheritedEnv = []string{"PATH", "HOME", "USER", "LANG", "LC_ALL", "TZ", "TMPDIR", "SYSTEMROOT"}
+
+func newMcpStdioTransport(server *model.McpServer) (*mcpStdioTransport, error) {
+	cmd := exec.Command(server.Command, server.GetArgs()...)
+	cmd.Env = make([]string, 0, len(mcpInheritedEnv))
+	for _, key := range mcpInheritedEnv {
+		if value, ok := os.LookupEnv(key); ok {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	for key, value := range server.GetEnv() {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	t := &mcpStdioTransport{cmd: cmd, stdin: stdin}
+	go func() {
+		reader := bufio.NewReaderSize(stdout, 64*1024)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				var message McpMessage
+				if common.DecodeJson(line, &message) == nil {
+					t.pending.dispatch(&message)
+				}
+			}
+			if err != nil {
+				t.pending.fail(fmt.Errorf("mcp server %s exited: %v", server.Name, err))
+				_ = cmd.Wait()
+				return
+			}
+		}
+	}()
+	return t, nil
+}
+
+func (t *mcpStdioTransport) write(message *McpMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *mcpStdioTransport) request(ctx context.Context, message *McpMessage) (*McpMessage, error) {
+	id := string(message.Id)
+	ch, err := t.pending.add(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.write(message); err != nil {
+		t.pending.remove(id)
+		return nil, err
+	}
+	return t.pending.wait(ctx, id, ch)
+}
+
+func (t *mcpStdioTransport) notify(ctx context.Context, message *McpMessage) error {
+	return t.write(message)
+}
+
+func (t *mcpStdioTransport) close() {
+	_ = t.stdin.Close()
+	if t.cmd.Process != nil {
+		_ = t.cmd.Process.Kill()
+	}
+}
+
+func setMcpHeaders(req *http.Request, headers map[string]string) {
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+}
+
+// readMcpSSE 逐个读取 SSE 事件，onEvent 返回 false 时停止
+func readMcpSSE(body io.Reader, onEvent func(event string, data string) bool) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 && !onEvent(common.GetStringIfEmpty(event, "message"), strings.Join(data, "\n")) {
+				return nil
+			}
+			event, data = "", nil
+			continue
+		}
+		if strings.HasPrefix(line, "event:") {
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		} else if strings.HasPrefix(line, "data:") {
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// mcpHttpTransport Streamable HTTP 传输：每个请求单独 POST，响应为 JSON 或 SSE 流
+type mcpHttpTransport struct {
+	url       string
+	headers   map[string]string
+	sessionMu sync.Mutex
+	sessionId string
+}
+
+func (t *mcpHttpTransport) post(ctx context.Context, message *McpMessage) (*http.Response, error) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	setMcpHeaders(req, t.headers)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.sessionMu.Lock()
+	if t.sessionId != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionId)
+	}
+	t.sessionMu.Unlock()
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if sessionId := resp.Header.Get("Mcp-Session-Id"); sessionId != "" {
+		t.sessionMu.Lock()
+		t.sessionId = sessionId
+		t.sessionMu.Unlock()
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("mcp server returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
+
+func (t *mcpHttpTransport) request(ctx context.Context, message *McpMessage) (*McpMessage, error) {
+	resp, err := t.post(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var response McpMessage
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if err := common.DecodeJson(body, &response); err != nil {
+			return nil, err
+		}
+		return &response, nil
+	}
+	var response *McpMessage
+	err = readMcpSSE(resp.Body, func(event string, data string) bool {
+		var m McpMessage
+		if event != "message" || common.DecodeJsonStr(data, &m) != nil {
+			return true
+		}
+		if m.Method == "" && string(m.Id) == string(message.Id) {
+			response = &m
+			return false
+		}
+		return true
+	})
+	if response == nil {
+		if err == nil || errors.Is(err, io.EOF) {
+			err = errors.New("mcp server closed the stream without a response")
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+func (t *mcpHttpTransport) notify(ctx context.Context, message *McpMessage) error {
+	resp, err := t.post(ctx, message)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (t *mcpHttpTransport) close() {
+	t.sessionMu.Lock()
+	sessionId := t.sessionId
+	t.sessionMu.Unlock()
+	if sessionId == "" {
+		return
+	}
+	// 通知服务器结束会话
+	req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return
+	}
+	setMcpHeaders(req, t.headers)
+	req.Header.Set("Mcp-Session-Id", sessionId)
+	if resp, err := GetImpatientHttpClient().Do(req); err == nil {
+		_ = resp.Body.Close()
+	}
+}
+
+// mcpSSETransport HTTP + SSE 传输：通过长连接的 SSE 接收消息，向 endpoint 事件给出的地址 POST 发送消息
+type mcpSSETransport struct {
+	endpoint string
+	headers  map[string]string
+	cancel   context.CancelFunc
+	pending  mcpPending
+}
+
+func newMcpSSETransport(server *model.McpServer, timeout time.Duration) (*mcpSSETransport, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// 建立连接并收到 endpoint 事件之前受 timeout 限制，之后的长连接不再超时
+	timer := time.AfterFunc(timeout, cancel)
+	defer timer.Stop()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	headers := server.GetHeaders()
+	setMcpHeaders(req, headers)
+	req.Header.Set("Accept", "text/event-stream")
+	// SSE 长连接不能使用带超时的客户端
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("mcp server returned status %d", resp.StatusCode)
+	}
+
+	t := &mcpSSETransport{headers: headers, cancel: cancel}
+	endpointCh := make(chan string, 1)
+	go func() {
+		defer resp.Body.Close()
+		err := readMcpSSE(resp.Body, func(event string, data string) bool {
+			switch event {
+			case "endpoint":
+				select {
+				case endpointCh <- data:
+				default:
+				}
+			case "message":
+				var message McpMessage
+				if common.DecodeJsonStr(data, &message) == nil {
+					t.pending.dispatch(&message)
+				}
+			}
+			return true
+		})
+		close(endpointCh)
+		t.pending.fail(fmt.Errorf("mcp server %s closed the event stream: %v", server.Name, err))
+	}()
+
+	select {
+	case endpoint, ok := <-endpointCh:
+		if !ok {
+			cancel()
+			return nil, errors.New("mcp server closed the event stream before sending the endpoint")
+		}
+		base, err := url.Parse(server.URL)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		endpointURL, err := base.Parse(endpoint)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		t.endpoint = endpointURL.String()
+	case <-ctx.Done():
+		return nil, errors.New("timeout waiting for mcp endpoint event")
+	}
+	if !timer.Stop() {
+		cancel()
+		return nil, errors.New("timeout waiting for mcp endpoint event")
+	}
+	return t, nil
+}
+
+func (t *mcpSSETransport) post(ctx context.Context, message *McpMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	setMcpHeaders(req, t.headers)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("mcp server returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (t *mcpSSETransport) request(ctx context.Context, message *McpMessage) (*McpMessage, error) {
+	id := string(message.Id)
+	ch, err := t.pending.add(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.post(ctx, message); err != nil {
+		t.pending.remove(id)
+		return nil, err
+	}
+	return t.pending.wait(ctx, id, ch)
+}
+
+func (t *mcpSSETransport) notify(ctx context.Context, message *McpMessage) error {
+	return t.post(ctx, message)
+}
+
+func (t *mcpSSETransport) close() {
+	t.cancel()
+}
+
+// McpClient 与一个 MCP 服务器的已初始化会话
+type McpClient struct {
+	server    *model.McpServer
+	transport mcpTransport
+	nextId    atomic.Int64
+	toolsMu   sync.Mutex
+	tools     []McpTool
+	broken    atomic.Bool
+}
+
+// mcpConnectTimeout 建立会话（连接与 initialize 握手）的超时时间，未配置时默认 30 秒
+func mcpConnectTimeout(server *model.McpServer) time.Duration {
+	if server.Timeout > 0 {
+		return time.Duration(server.Timeout) * time.Second
+	}
+	return 30 * time.Second
+}
+
+func newMcpClient(ctx context.Context, server *model.McpServer) (*McpClient, error) {
+	timeout := mcpConnectTimeout(server)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var transport mcpTransport
+	var err error
+	switch server.Transport {
+	case model.McpTransportStdio:
+		transport, err = newMcpStdioTransport(server)
+	case model.McpTransportSSE:
+		transport, err = newMcpSSETransport(server, timeout)
+	case model.McpTransportHttp:
+		transport = &mcpHttpTransport{url: server.URL, headers: server.GetHeaders()}
+	default:
+		err = fmt.Errorf("unsupported mcp transport: %s", server.Transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+	client := &McpClient{server: server, transport: transport}
+	_, err = client.call(ctx, "initialize", map[string]any{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo": map[string]any{
+			"name":    common.SystemName,
+			"version": common.Version,
+		},
+	})
+	if err == nil {
+		err = transport.notify(ctx, &McpMessage{JsonRPC: "2.0", Method: "notifications/initialized"})
+	}
+	if err != nil {
+		transport.close()
+		return nil, fmt.Errorf("initialize mcp server %s failed: %w", server.Name, err)
+	}
+	return client, nil
+}
+
+func (client *McpClient) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	paramsJson, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	id := client.nextId.Add(1)
+	response, err := client.transport.request(ctx, &McpMessage{
+		JsonRPC: "2.0",
+		Id:      json.RawMessage(fmt.Sprintf("%d", id)),
+		Method:  method,
+		Params:  paramsJson,
+	})
+	if err != nil {
+		// 传输层错误（非 JSON-RPC 错误）时丢弃会话，下次调用重新连接
+		if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+			client.broken.Store(true)
+		}
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	return response.Result, nil
+}
+
+// ListTools 获取服务器提供的工具，结果会被缓存，refresh 为 true 时重新获取
+func (client *McpClient) ListTools(ctx context.Context, refresh bool) ([]McpTool, error) {
+	client.toolsMu.Lock()
+	defer client.toolsMu.Unlock()
+	if client.tools != nil && !refresh {
+		return client.tools, nil
+	}
+	tools := make([]McpTool, 0)
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		result, err := client.call(ctx, "tools/list", params)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Tools      []McpTool `json:"tools"`
+			NextCursor string    `json:"nextCursor,omitempty"`
+		}
+		if err := common.DecodeJson(result, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	client.tools = tools
+	return tools, nil
+}
+
+// CallTool 调用工具并返回原始结果，超时时间由服务器配置决定
+func (client *McpClient) CallTool(ctx context.Context, name string, arguments json.RawMessage) (json.RawMessage, error) {
+	if client.server.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(client.server.Timeout)*time.Second)
+		defer cancel()
+	}
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	return client.call(ctx, "tools/call", map[string]any{
+		"name":      name,
+		"arguments": arguments,
+	})
+}
+
+// mcpClientSlot 单个服务器的会话，建立连接时只锁定该服务器，不影响其他服务器的调用
+type mcpClientSlot struct {
+	mu     sync.Mutex
+	client *McpClient
+}
+
+// MCP 会话池，同一服务器的会话在请求之间复用，配置更新后重新连接
+var mcpClients = struct {
+	sync.Mutex
+	slots map[int]*mcpClientSlot
+}{slots: make(map[int]*mcpClientSlot)}
+
+func getMcpClientSlot(serverId int) *mcpClientSlot {
+	mcpClients.Lock()
+	defer mcpClients.Unlock()
+	slot, ok := mcpClients.slots[serverId]
+	if !ok {
+		slot = &mcpClientSlot{}
+		mcpClients.slots[serverId] = slot
+	}
+	return slot
+}
+
+// GetMcpClient 获取服务器的会话，不存在、已断开或配置已更新时重新建立
+func GetMcpClient(ctx context.Context, server *model.McpServer) (*McpClient, error) {
+	slot := getMcpClientSlot(server.Id)
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	if client := slot.client; client != nil {
+		if !client.broken.Load() && client.server.UpdatedTime == server.UpdatedTime {
+			return client, nil
+		}
+		client.transport.close()
+		slot.client = nil
+	}
+	client, err := newMcpClient(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	slot.client = client
+	return client, nil
+}
+
+// CloseMcpClient 关闭服务器的会话，在服务器被删除或禁用时调用
+func CloseMcpClient(serverId int) {
+	slot := getMcpClientSlot(serverId)
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	if slot.client != nil {
+		slot.client.transport.close()
+		slot.client = nil
+	}
+}
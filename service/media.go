@@ -0,0 +1,319 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/google/uuid"
+)
+
+var ErrMediaStorageLimitExceeded = errors.New("media storage limit exceeded")
+
+// MediaStorageEnabled 是否启用生成结果的持久化存储
+func MediaStorageEnabled() bool {
+	return operation_setting.GetMediaStorageSetting().Enabled
+}
+
+// MediaTaskStorageEnabled 是否在异步任务成功后保存结果
+func MediaTaskStorageEnabled() bool {
+	storageSetting := operation_setting.GetMediaStorageSetting()
+	return storageSetting.Enabled && storageSetting.StoreTaskResults
+}
+
+// MediaImageStorageEnabled 是否保存图片接口的结果
+func MediaImageStorageEnabled() bool {
+	storageSetting := operation_setting.GetMediaStorageSetting()
+	return storageSetting.Enabled && storageSetting.StoreImageGenerations
+}
+
+// GetMediaRetentionDays 返回用户生成结果的保留天数，0 表示永久保留
+func GetMediaRetentionDays(userSetting map[string]interface{}) int {
+	storageSetting := operation_setting.GetMediaStorageSetting()
+	days := storageSetting.DefaultRetentionDays
+	if value, ok := userSetting[constant.UserSettingMediaRetentionDays]; ok {
+		if userDays, ok := value.(float64); ok && userDays > 0 {
+			days = int(userDays)
+		}
+	}
+	if storageSetting.MaxRetentionDays > 0 && (days <= 0 || days > storageSetting.MaxRetentionDays) {
+		days = storageSetting.MaxRetentionDays
+	}
+	return days
+}
+
+func mediaExtension(contentType string, originURL string) string {
+	if originURL != "" {
+		if parsed, err := url.Parse(originURL); err == nil {
+			if ext := path.Ext(parsed.Path); ext != "" && len(ext) <= 6 {
+				return strings.ToLower(ext)
+			}
+		}
+	}
+	if exts, err := mime.ExtensionsByType(contentType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// StoreMedia 保存生成结果并记录，超过用户存储上限时返回 ErrMediaStorageLimitExceeded
+func StoreMedia(ctx context.Context, userId int, source string, taskId string, originURL string, contentType string, data []byte) (*model.MediaObject, error) {
+	storageSetting := operation_setting.GetMediaStorageSetting()
+	if storageSetting.MaxFileSizeMB > 0 && int64(len(data)) > int64(storageSetting.MaxFileSizeMB)<<20 {
+		return nil, fmt.Errorf("media size %d exceeds limit", len(data))
+	}
+	if storageSetting.UserStorageLimitMB > 0 {
+		usage, err := model.GetUserMediaUsage(userId)
+		if err != nil {
+			return nil, err
+		}
+		if usage.Size+int64(len(data)) > int64(storageSetting.UserStorageLimitMB)<<20 {
+			return nil, ErrMediaStorageLimitExceeded
+		}
+	}
+	storage, err := GetMediaStorage()
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	key := fmt.Sprintf("%d/%s/%s%s", userId, time.Now().Format("20060102"), uuid.New().String(), mediaExtension(contentType, originURL))
+	if err := storage.Put(ctx, key, contentType, data); err != nil {
+		return nil, err
+	}
+	object := &model.MediaObject{
+		UserId:      userId,
+		Key:         key,
+		Backend:     storage.Name(),
+		Source:      source,
+		TaskId:      taskId,
+		OriginURL:   originURL,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+	userSetting := map[string]interface{}{}
+	if userCache, err := model.GetUserCache(userId); err == nil {
+		userSetting = userCache.GetSetting()
+	}
+	if days := GetMediaRetentionDays(userSetting); days > 0 {
+		object.ExpiresAt = time.Now().Add(time.Duration(days) * 24 * time.Hour).Unix()
+	}
+	if err := object.Insert(); err != nil {
+		_ = storage.Delete(ctx, key)
+		return nil, err
+	}
+	return object, nil
+}
+
+// StoreMediaFromURL 下载生成结果并保存
+func StoreMediaFromURL(ctx context.Context, client *http.Client, userId int, source string, taskId string, originURL string) (*model.MediaObject, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, originURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download media failed: status %d", resp.StatusCode)
+	}
+	reader := io.Reader(resp.Body)
+	if maxSize := operation_setting.GetMediaStorageSetting().MaxFileSizeMB; maxSize > 0 {
+		// 多读一个字节，由 StoreMedia 判断是否超出上限
+		reader = io.LimitReader(resp.Body, int64(maxSize)<<20+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	if contentType == "" || contentType == "application/octet-stream" || contentType == "binary/octet-stream" {
+		contentType = ""
+	}
+	return StoreMedia(ctx, userId, source, taskId, originURL, contentType, data)
+}
+
+// OpenMedia 读取保存的结果，调用方负责关闭
+func OpenMedia(ctx context.Context, object *model.MediaObject) (io.ReadCloser, error) {
+	storage, err := GetMediaStorage()
+	if err != nil {
+		return nil, err
+	}
+	if storage.Name() != object.Backend {
+		return nil, fmt.Errorf("media stored in %s backend is not available", object.Backend)
+	}
+	return storage.Get(ctx, object.Key)
+}
+
+// DeleteMedia 删除保存的结果及其记录
+func DeleteMedia(ctx context.Context, object *model.MediaObject) error {
+	storage, err := GetMediaStorage()
+	if err != nil {
+		return err
+	}
+	if storage.Name() == object.Backend {
+		if err := storage.Delete(ctx, object.Key); err != nil {
+			return err
+		}
+	}
+	return object.Delete()
+}
+
+func mediaSignature(key string, expires int64) string {
+	return common.GenerateHMAC(fmt.Sprintf("media:%s:%d", key, expires))
+}
+
+// SignMediaURL 生成带签名、会过期的网关访问地址
+func SignMediaURL(object *model.MediaObject) string {
+	ttl := operation_setting.GetMediaStorageSetting().SignedURLTTLMinutes
+	if ttl <= 0 {
+		ttl = 60
+	}
+	expires := time.Now().Add(time.Duration(ttl) * time.Minute).Unix()
+	if object.ExpiresAt > 0 && expires > object.ExpiresAt {
+		expires = object.ExpiresAt
+	}
+	return fmt.Sprintf("%s/media/%s?expires=%d&signature=%s", setting.ServerAddress, object.Key, expires, mediaSignature(object.Key, expires))
+}
+
+// VerifyMediaSignature 校验网关访问地址的签名与有效期
+func VerifyMediaSignature(key string, expiresStr string, signature string) bool {
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || expires < time.Now().Unix() {
+		return false
+	}
+	expected := mediaSignature(key, expires)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// GetTaskMediaURLs 返回任务中已保存结果的原始地址到签名地址的映射
+func GetTaskMediaURLs(source string, taskId string) map[string]string {
+	if taskId == "" {
+		return nil
+	}
+	objects, err := model.GetTaskMediaObjects(source, taskId)
+	if err != nil || len(objects) == 0 {
+		return nil
+	}
+	urls := make(map[string]string, len(objects))
+	for _, object := range objects {
+		if object.OriginURL != "" {
+			urls[object.OriginURL] = SignMediaURL(object)
+		}
+	}
+	return urls
+}
+
+// RewriteMediaURLs 将 JSON 中出现的原始地址替换为签名地址
+func RewriteMediaURLs(data []byte, urls map[string]string) []byte {
+	if len(urls) == 0 || len(data) == 0 {
+		return data
+	}
+	result := string(data)
+	for origin, signed := range urls {
+		signedJson := marshalJsonString(signed, false)
+		// 上游可能对 & 等字符做了 HTML 转义，两种写法都需要替换
+		result = strings.ReplaceAll(result, marshalJsonString(origin, false), signedJson)
+		result = strings.ReplaceAll(result, marshalJsonString(origin, true), signedJson)
+	}
+	return []byte(result)
+}
+
+func marshalJsonString(s string, escapeHTML bool) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(escapeHTML)
+	_ = encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// GetChannelHttpClient 返回使用渠道代理设置的 HTTP 客户端
+func GetChannelHttpClient(channelId int) (*http.Client, error) {
+	if channel, err := model.CacheGetChannel(channelId); err == nil {
+		if proxy, ok := channel.GetSetting()["proxy"]; ok {
+			if proxyURL, ok := proxy.(string); ok && proxyURL != "" {
+				return NewProxyHttpClient(proxyURL)
+			}
+		}
+	}
+	return GetHttpClient(), nil
+}
+
+// ArchiveTaskMedia 在后台保存异步任务的结果，已保存过的地址会被跳过
+func ArchiveTaskMedia(userId int, channelId int, source string, taskId string, urls []string) {
+	if !MediaTaskStorageEnabled() || len(urls) == 0 {
+		return
+	}
+	gopool.Go(func() {
+		ctx := context.Background()
+		client, err := GetChannelHttpClient(channelId)
+		if err != nil {
+			common.SysError(fmt.Sprintf("archive media for task %s: %s", taskId, err.Error()))
+			return
+		}
+		stored := GetTaskMediaURLs(source, taskId)
+		for _, originURL := range urls {
+			if originURL == "" {
+				continue
+			}
+			if _, ok := stored[originURL]; ok {
+				continue
+			}
+			if _, err := StoreMediaFromURL(ctx, client, userId, source, taskId, originURL); err != nil {
+				common.SysError(fmt.Sprintf("archive media %s for task %s: %s", originURL, taskId, err.Error()))
+				if errors.Is(err, ErrMediaStorageLimitExceeded) {
+					return
+				}
+			}
+		}
+	})
+}
+
+// CleanupExpiredMedia 定期删除超过保留期的结果
+func CleanupExpiredMedia() {
+	for {
+		time.Sleep(10 * time.Minute)
+		ctx := context.Background()
+		for {
+			objects, err := model.GetExpiredMediaObjects(time.Now().Unix(), 200)
+			if err != nil {
+				common.SysError("failed to get expired media: " + err.Error())
+				break
+			}
+			deleted := 0
+			for _, object := range objects {
+				if err := DeleteMedia(ctx, object); err != nil {
+					common.SysError(fmt.Sprintf("failed to delete media %s: %s", object.Key, err.Error()))
+					continue
+				}
+				deleted++
+			}
+			if len(objects) < 200 || deleted == 0 {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"one-api/setting/operation_setting"
+)
+
+// MediaStorage 生成结果的存储后端
+type MediaStorage interface {
+	Name() string
+	Put(ctx context.Context, key string, contentType string, data []byte) error
+	// Get 返回对象内容，调用方负责关闭
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// GetMediaStorage 根据当前配置返回存储后端
+func GetMediaStorage() (MediaStorage, error) {
+	storageSetting := operation_setting.GetMediaStorageSetting()
+	switch storageSetting.Backend {
+	case operation_setting.MediaStorageBackendS3:
+		if storageSetting.S3Endpoint == "" || storageSetting.S3Bucket == "" {
+			return nil, errors.New("s3 endpoint and bucket are required")
+		}
+		return &s3MediaStorage{
+			endpoint:  strings.TrimSuffix(storageSetting.S3Endpoint, "/"),
+			region:    storageSetting.S3Region,
+			bucket:    storageSetting.S3Bucket,
+			accessKey: storageSetting.S3AccessKey,
+			secretKey: storageSetting.S3SecretKey,
+			pathStyle: storageSetting.S3PathStyle,
+		}, nil
+	case operation_setting.MediaStorageBackendLocal, "":
+		root := storageSetting.LocalPath
+		if root == "" {
+			root = "media"
+		}
+		return &localMediaStorage{root: root}, nil
+	default:
+		return nil, fmt.Errorf("unsupported media storage backend: %s", storageSetting.Backend)
+	}
+}
+
+// localMediaStorage 保存在本地磁盘
+type localMediaStorage struct {
+	root string
+}
+
+func (s *localMediaStorage) Name() string {
+	return operation_setting.MediaStorageBackendLocal
+}
+
+func (s *localMediaStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if cleaned == "/" {
+		return "", errors.New("invalid media key")
+	}
+	return filepath.Join(s.root, cleaned), nil
+}
+
+func (s *localMediaStorage) Put(ctx context.Context, key string, contentType string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免读取到写了一半的文件
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *localMediaStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *localMediaStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
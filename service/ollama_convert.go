@@ -0,0 +1,364 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"strings"
+	"time"
+)
+
+// OllamaStreamInfo 保存 Chat Completions 流式响应转换为 Ollama NDJSON 时的状态
+type OllamaStreamInfo struct {
+	Generate     bool
+	Model        string
+	StartTime    time.Time
+	FinishReason string
+	Usage        *dto.Usage
+	ToolCalls    []*dto.ToolCallResponse
+}
+
+func NewOllamaStreamInfo(model string, generate bool) *OllamaStreamInfo {
+	return &OllamaStreamInfo{
+		Generate:  generate,
+		Model:     model,
+		StartTime: time.Now(),
+	}
+}
+
+func ollamaImageToMediaContent(image string) (dto.MediaContent, error) {
+	url := image
+	if !strings.HasPrefix(image, "data:") && !strings.HasPrefix(image, "http") {
+		mimeType, data, err := DecodeBase64FileData(image)
+		if err != nil {
+			return dto.MediaContent{}, err
+		}
+		url = fmt.Sprintf("data:%s;base64,%s", mimeType, data)
+	}
+	return dto.MediaContent{
+		Type: dto.ContentTypeImageURL,
+		ImageUrl: &dto.MessageImageUrl{
+			Url:    url,
+			Detail: "auto",
+		},
+	}, nil
+}
+
+func ollamaContentToMessage(role string, content string, images []string) (dto.Message, error) {
+	message := dto.Message{Role: role}
+	if len(images) == 0 {
+		message.SetStringContent(content)
+		return message, nil
+	}
+	mediaContents := []dto.MediaContent{{
+		Type: dto.ContentTypeText,
+		Text: content,
+	}}
+	for _, image := range images {
+		mediaContent, err := ollamaImageToMediaContent(image)
+		if err != nil {
+			return message, err
+		}
+		mediaContents = append(mediaContents, mediaContent)
+	}
+	message.SetMediaContent(mediaContents)
+	return message, nil
+}
+
+func ollamaFormatToResponseFormat(format json.RawMessage) *dto.ResponseFormat {
+	if len(format) == 0 || string(format) == "null" || string(format) == `""` {
+		return nil
+	}
+	var formatStr string
+	if err := json.Unmarshal(format, &formatStr); err == nil {
+		if formatStr == "json" {
+			return &dto.ResponseFormat{Type: "json_object"}
+		}
+		return nil
+	}
+	var schema any
+	if err := json.Unmarshal(format, &schema); err != nil {
+		return nil
+	}
+	return &dto.ResponseFormat{
+		Type: "json_schema",
+		JsonSchema: &dto.FormatJsonSchema{
+			Name:   "response",
+			Schema: schema,
+		},
+	}
+}
+
+func applyOllamaOptions(request *dto.GeneralOpenAIRequest, options *dto.OllamaOptions) {
+	if options == nil {
+		return
+	}
+	request.Temperature = options.Temperature
+	request.TopP = options.TopP
+	request.TopK = options.TopK
+	request.Stop = options.Stop
+	request.Seed = options.Seed
+	request.FrequencyPenalty = options.FrequencyPenalty
+	request.PresencePenalty = options.PresencePenalty
+	if options.NumPredict > 0 {
+		request.MaxTokens = uint(options.NumPredict)
+	}
+}
+
+func OllamaChatToOpenAIRequest(ollamaRequest *dto.OllamaChatRequest) (*dto.GeneralOpenAIRequest, error) {
+	openAIRequest := &dto.GeneralOpenAIRequest{
+		Model:          ollamaRequest.Model,
+		Stream:         ollamaRequest.IsStream(),
+		Tools:          ollamaRequest.Tools,
+		ResponseFormat: ollamaFormatToResponseFormat(ollamaRequest.Format),
+	}
+	applyOllamaOptions(openAIRequest, ollamaRequest.Options)
+	if openAIRequest.Stream {
+		openAIRequest.StreamOptions = &dto.StreamOptions{
+			IncludeUsage: true,
+		}
+	}
+	// Ollama 的工具调用没有 id，按顺序生成并在 tool 消息中回填
+	var pendingCallIds []string
+	for idx, ollamaMessage := range ollamaRequest.Messages {
+		switch ollamaMessage.Role {
+		case "assistant":
+			message := dto.Message{Role: "assistant"}
+			message.SetStringContent(ollamaMessage.Content)
+			if len(ollamaMessage.ToolCalls) > 0 {
+				toolCalls := make([]dto.ToolCallRequest, 0, len(ollamaMessage.ToolCalls))
+				pendingCallIds = pendingCallIds[:0]
+				for callIdx, toolCall := range ollamaMessage.ToolCalls {
+					callId := fmt.Sprintf("call_%d_%d", idx, callIdx)
+					pendingCallIds = append(pendingCallIds, callId)
+					arguments := string(toolCall.Function.Arguments)
+					if arguments == "" || arguments == "null" {
+						arguments = "{}"
+					}
+					toolCalls = append(toolCalls, dto.ToolCallRequest{
+						ID:   callId,
+						Type: "function",
+						Function: dto.FunctionRequest{
+							Name:      toolCall.Function.Name,
+							Arguments: arguments,
+						},
+					})
+				}
+				message.SetToolCalls(toolCalls)
+			}
+			openAIRequest.Messages = append(openAIRequest.Messages, message)
+		case "tool":
+			message := dto.Message{Role: "tool"}
+			message.SetStringContent(ollamaMessage.Content)
+			if len(pendingCallIds) > 0 {
+				message.ToolCallId = pendingCallIds[0]
+				pendingCallIds = pendingCallIds[1:]
+			}
+			openAIRequest.Messages = append(openAIRequest.Messages, message)
+		default:
+			message, err := ollamaContentToMessage(ollamaMessage.Role, ollamaMessage.Content, ollamaMessage.Images)
+			if err != nil {
+				return nil, err
+			}
+			openAIRequest.Messages = append(openAIRequest.Messages, message)
+		}
+	}
+	return openAIRequest, nil
+}
+
+func OllamaGenerateToOpenAIRequest(ollamaRequest *dto.OllamaGenerateRequest) (*dto.GeneralOpenAIRequest, error) {
+	openAIRequest := &dto.GeneralOpenAIRequest{
+		Model:          ollamaRequest.Model,
+		Stream:         ollamaRequest.IsStream(),
+		ResponseFormat: ollamaFormatToResponseFormat(ollamaRequest.Format),
+	}
+	applyOllamaOptions(openAIRequest, ollamaRequest.Options)
+	if openAIRequest.Stream {
+		openAIRequest.StreamOptions = &dto.StreamOptions{
+			IncludeUsage: true,
+		}
+	}
+	if ollamaRequest.System != "" {
+		message := dto.Message{Role: "system"}
+		message.SetStringContent(ollamaRequest.System)
+		openAIRequest.Messages = append(openAIRequest.Messages, message)
+	}
+	message, err := ollamaContentToMessage("user", ollamaRequest.Prompt, ollamaRequest.Images)
+	if err != nil {
+		return nil, err
+	}
+	openAIRequest.Messages = append(openAIRequest.Messages, message)
+	return openAIRequest, nil
+}
+
+func OllamaEmbeddingToOpenAIRequest(ollamaRequest *dto.OllamaEmbeddingRequest) *dto.EmbeddingRequest {
+	embeddingRequest := &dto.EmbeddingRequest{
+		Model: ollamaRequest.Model,
+		Input: ollamaRequest.Input,
+	}
+	if embeddingRequest.Input == nil {
+		embeddingRequest.Input = ollamaRequest.Prompt
+	}
+	return embeddingRequest
+}
+
+func ollamaToolCalls(toolCalls []dto.ToolCallResponse) []dto.OllamaToolCall {
+	ollamaToolCalls := make([]dto.OllamaToolCall, 0, len(toolCalls))
+	for _, toolCall := range toolCalls {
+		arguments := json.RawMessage(toolCall.Function.Arguments)
+		if !json.Valid(arguments) {
+			arguments = json.RawMessage("{}")
+		}
+		ollamaToolCalls = append(ollamaToolCalls, dto.OllamaToolCall{
+			Function: dto.OllamaToolCallFunction{
+				Name:      toolCall.Function.Name,
+				Arguments: arguments,
+			},
+		})
+	}
+	return ollamaToolCalls
+}
+
+func ollamaDoneReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "length"
+	case "", "stop", "tool_calls", "function_call":
+		return "stop"
+	default:
+		return finishReason
+	}
+}
+
+func ollamaDoneInfo(finishReason string, usage *dto.Usage, startTime time.Time) dto.OllamaDoneInfo {
+	doneInfo := dto.OllamaDoneInfo{
+		Done:          true,
+		DoneReason:    ollamaDoneReason(finishReason),
+		TotalDuration: time.Since(startTime).Nanoseconds(),
+	}
+	if usage != nil {
+		doneInfo.PromptEvalCount = usage.PromptTokens
+		doneInfo.EvalCount = usage.CompletionTokens
+	}
+	return doneInfo
+}
+
+func ollamaCreatedAt() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+func newOllamaResponse(info *OllamaStreamInfo, content string, thinking string, toolCalls []dto.OllamaToolCall, doneInfo dto.OllamaDoneInfo) any {
+	if info.Generate {
+		return &dto.OllamaGenerateResponse{
+			Model:          info.Model,
+			CreatedAt:      ollamaCreatedAt(),
+			Response:       content,
+			Thinking:       thinking,
+			OllamaDoneInfo: doneInfo,
+		}
+	}
+	return &dto.OllamaChatResponse{
+		Model:     info.Model,
+		CreatedAt: ollamaCreatedAt(),
+		Message: dto.OllamaMessage{
+			Role:      "assistant",
+			Content:   content,
+			Thinking:  thinking,
+			ToolCalls: toolCalls,
+		},
+		OllamaDoneInfo: doneInfo,
+	}
+}
+
+// ResponseOpenAI2Ollama 将非流式 Chat Completions 响应转换为 Ollama 格式
+func ResponseOpenAI2Ollama(openAIResponse *dto.OpenAITextResponse, info *OllamaStreamInfo) any {
+	var content, thinking, finishReason string
+	var toolCalls []dto.OllamaToolCall
+	if len(openAIResponse.Choices) > 0 {
+		choice := openAIResponse.Choices[0]
+		content = choice.Message.StringContent()
+		thinking = common.GetStringIfEmpty(choice.Message.ReasoningContent, choice.Message.Reasoning)
+		finishReason = choice.FinishReason
+		var responseToolCalls []dto.ToolCallResponse
+		if len(choice.Message.ToolCalls) > 0 {
+			_ = json.Unmarshal(choice.Message.ToolCalls, &responseToolCalls)
+			toolCalls = ollamaToolCalls(responseToolCalls)
+		}
+	}
+	return newOllamaResponse(info, content, thinking, toolCalls,
+		ollamaDoneInfo(finishReason, &openAIResponse.Usage, info.StartTime))
+}
+
+// StreamResponseOpenAI2Ollama 将单个 Chat Completions 流式块转换为 Ollama 流式响应
+// 工具调用参数是分片返回的，累积后在结束时一并输出
+func StreamResponseOpenAI2Ollama(streamResponse *dto.ChatCompletionsStreamResponse, info *OllamaStreamInfo) []any {
+	if streamResponse.Usage != nil {
+		info.Usage = streamResponse.Usage
+	}
+	responses := make([]any, 0)
+	for _, choice := range streamResponse.Choices {
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			info.FinishReason = *choice.FinishReason
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			index := len(info.ToolCalls)
+			if toolCall.Index != nil {
+				index = *toolCall.Index
+			}
+			for len(info.ToolCalls) <= index {
+				info.ToolCalls = append(info.ToolCalls, &dto.ToolCallResponse{})
+			}
+			current := info.ToolCalls[index]
+			if toolCall.Function.Name != "" {
+				current.Function.Name = toolCall.Function.Name
+			}
+			current.Function.Arguments += toolCall.Function.Arguments
+		}
+		content := choice.Delta.GetContentString()
+		thinking := choice.Delta.GetReasoningContent()
+		if content == "" && thinking == "" {
+			continue
+		}
+		responses = append(responses, newOllamaResponse(info, content, thinking, nil, dto.OllamaDoneInfo{}))
+	}
+	return responses
+}
+
+// FinishStreamResponseOpenAI2Ollama 生成 done=true 的最后一条响应
+func FinishStreamResponseOpenAI2Ollama(info *OllamaStreamInfo, usage *dto.Usage) []any {
+	if usage == nil {
+		usage = info.Usage
+	}
+	responses := make([]any, 0, 2)
+	if len(info.ToolCalls) > 0 && !info.Generate {
+		toolCalls := make([]dto.ToolCallResponse, 0, len(info.ToolCalls))
+		for _, toolCall := range info.ToolCalls {
+			toolCalls = append(toolCalls, *toolCall)
+		}
+		responses = append(responses, newOllamaResponse(info, "", "", ollamaToolCalls(toolCalls), dto.OllamaDoneInfo{}))
+	}
+	responses = append(responses, newOllamaResponse(info, "", "", nil,
+		ollamaDoneInfo(info.FinishReason, usage, info.StartTime)))
+	return responses
+}
+
+// EmbeddingResponseOpenAI2Ollama legacy 为 true 时返回 /api/embeddings 的单向量格式
+func EmbeddingResponseOpenAI2Ollama(embeddingResponse *dto.OpenAIEmbeddingResponse, legacy bool) *dto.OllamaEmbeddingResponse {
+	if legacy {
+		response := &dto.OllamaEmbeddingResponse{}
+		if len(embeddingResponse.Data) > 0 {
+			response.Embedding = embeddingResponse.Data[0].Embedding
+		}
+		return response
+	}
+	response := &dto.OllamaEmbeddingResponse{
+		Model:           embeddingResponse.Model,
+		Embeddings:      make([][]float64, 0, len(embeddingResponse.Data)),
+		PromptEvalCount: embeddingResponse.PromptTokens,
+	}
+	for _, item := range embeddingResponse.Data {
+		response.Embeddings = append(response.Embeddings, item.Embedding)
+	}
+	return response
+}
@@ -193,6 +193,9 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
 
+	// 实时会话按消息从余额计费，释放预扣阶段预留的订阅配额
+	NewSubscriptionService().ReleaseReservations(relayInfo)
+
 	logModel := modelName
 	if extraContent != "" {
 		logContent += ", " + extraContent
@@ -206,36 +209,6 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	usage *dto.Usage, preConsumedQuota int, userQuota int, priceData helper.PriceData, extraContent string) {
 
-	// 如果使用了订阅配额，实际消费订阅配额
-	if relayInfo.UsedSubscriptionQuota {
-		subscriptionService := NewSubscriptionService()
-		hasSubscription, _, err := subscriptionService.CheckAndConsumeSubscriptionQuota(ctx, relayInfo, relayInfo.OriginModelName, 1)
-		if err != nil {
-			common.SysError(fmt.Sprintf("消费订阅配额失败: %v", err))
-			// 如果订阅配额消费失败，回退到普通计费方式
-			relayInfo.UsedSubscriptionQuota = false
-		} else if hasSubscription {
-			common.LogInfo(ctx, fmt.Sprintf("用户 %d 使用订阅配额完成Claude请求，订阅ID: %d，模型: %s",
-				relayInfo.UserId, relayInfo.SubscriptionId, relayInfo.OriginModelName))
-
-			// 记录使用记录（包含token信息）
-			if usage != nil {
-				totalTokens := usage.PromptTokens + usage.CompletionTokens
-				err := model.RecordSubscriptionUsage(relayInfo.UserId, relayInfo.SubscriptionId,
-					relayInfo.OriginModelName, 1, totalTokens, relayInfo.RequestId)
-				if err != nil {
-					common.SysError(fmt.Sprintf("更新订阅使用记录失败: %v", err))
-				}
-			}
-			return
-		} else {
-			// 订阅配额不足，回退到普通计费方式
-			relayInfo.UsedSubscriptionQuota = false
-			common.LogInfo(ctx, fmt.Sprintf("用户 %d 订阅配额不足，回退到普通计费，模型: %s",
-				relayInfo.UserId, relayInfo.OriginModelName))
-		}
-	}
-
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
 	completionTokens := usage.CompletionTokens
@@ -281,7 +254,21 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		logContent += fmt.Sprintf("（可能是上游出错）")
 		common.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, modelName, preConsumedQuota))
-	} else {
+	}
+
+	// 使用订阅配额抵扣本次用量，不足部分按余额计费
+	if relayInfo.UsedSubscriptionQuota {
+		var subscriptionContent string
+		quota, subscriptionContent = NewSubscriptionService().ConsumeUsage(ctx, relayInfo, totalTokens, quota)
+		if subscriptionContent != "" {
+			if logContent != "" {
+				logContent += "，"
+			}
+			logContent += subscriptionContent
+		}
+	}
+	if totalTokens != 0 {
+		// 只统计订阅抵扣后按余额计费的额度
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
@@ -303,37 +290,6 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	usage *dto.Usage, preConsumedQuota int, userQuota int, priceData helper.PriceData, extraContent string) {
 
-	// 如果使用了订阅配额，实际消费订阅配额
-	if relayInfo.UsedSubscriptionQuota {
-		subscriptionService := NewSubscriptionService()
-		hasSubscription, _, err := subscriptionService.CheckAndConsumeSubscriptionQuota(ctx, relayInfo, relayInfo.OriginModelName, 1)
-		if err != nil {
-			common.SysError(fmt.Sprintf("消费订阅配额失败: %v", err))
-			// 如果订阅配额消费失败，回退到普通计费方式
-			relayInfo.UsedSubscriptionQuota = false
-		} else if hasSubscription {
-			common.LogInfo(ctx, fmt.Sprintf("用户 %d 使用订阅配额完成Audio请求，订阅ID: %d，模型: %s",
-				relayInfo.UserId, relayInfo.SubscriptionId, relayInfo.OriginModelName))
-
-			// 记录使用记录（包含token信息）
-			if usage != nil {
-				totalTokens := usage.PromptTokensDetails.TextTokens + usage.CompletionTokenDetails.TextTokens +
-					usage.PromptTokensDetails.AudioTokens + usage.CompletionTokenDetails.AudioTokens
-				err := model.RecordSubscriptionUsage(relayInfo.UserId, relayInfo.SubscriptionId,
-					relayInfo.OriginModelName, 1, totalTokens, relayInfo.RequestId)
-				if err != nil {
-					common.SysError(fmt.Sprintf("更新订阅使用记录失败: %v", err))
-				}
-			}
-			return
-		} else {
-			// 订阅配额不足，回退到普通计费方式
-			relayInfo.UsedSubscriptionQuota = false
-			common.LogInfo(ctx, fmt.Sprintf("用户 %d 订阅配额不足，回退到普通计费，模型: %s",
-				relayInfo.UserId, relayInfo.OriginModelName))
-		}
-	}
-
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.PromptTokensDetails.TextTokens
 	textOutTokens := usage.CompletionTokenDetails.TextTokens
@@ -391,7 +347,18 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		logContent += fmt.Sprintf("（可能是上游超时）")
 		common.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, relayInfo.OriginModelName, preConsumedQuota))
-	} else {
+	}
+
+	// 使用订阅配额抵扣本次用量，不足部分按余额计费
+	if relayInfo.UsedSubscriptionQuota {
+		var subscriptionContent string
+		quota, subscriptionContent = NewSubscriptionService().ConsumeUsage(ctx, relayInfo, totalTokens, quota)
+		if subscriptionContent != "" {
+			logContent += "，" + subscriptionContent
+		}
+	}
+	if totalTokens != 0 {
+		// 只统计订阅抵扣后按余额计费的额度
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const responseCacheRedisPrefix = "response_cache:"
+
+// ResponseCacheEntry 缓存的上游响应：非流式保存响应体，流式保存每个 SSE data 以便回放
+type ResponseCacheEntry struct {
+	Stream      bool      `json:"stream"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        string    `json:"body,omitempty"`
+	Chunks      []string  `json:"chunks,omitempty"`
+	Usage       dto.Usage `json:"usage"`
+	CreatedAt   int64     `json:"created_at"`
+	ExpiresAt   int64     `json:"expires_at"`
+}
+
+// 不影响响应内容的字段，计算缓存键时忽略
+var responseCacheIgnoredFields = []string{"stream_options", "user", "metadata", "store"}
+
+// ResponseCacheKey 根据规范化后的请求体、请求路径、模型与分组计算缓存键
+func ResponseCacheKey(path string, group string, model string, body []byte) (string, error) {
+	var request map[string]any
+	if err := json.Unmarshal(body, &request); err != nil {
+		return "", err
+	}
+	for _, field := range responseCacheIgnoredFields {
+		delete(request, field)
+	}
+	// 重新序列化，消除字段顺序与空白的差异
+	normalized, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(path + "\n" + group + "\n" + model + "\n"))
+	hash.Write(normalized)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func useRedisResponseCache() bool {
+	return common.RedisEnabled && operation_setting.GetResponseCacheSetting().Storage != "disk"
+}
+
+func responseCacheFilePath(key string) string {
+	return filepath.Join(operation_setting.GetResponseCacheSetting().DiskPath, key[:2], key+".json")
+}
+
+// GetResponseCache 读取缓存，未命中或已过期时返回 nil
+func GetResponseCache(key string) (*ResponseCacheEntry, error) {
+	var data []byte
+	if useRedisResponseCache() {
+		value, err := common.RedisGet(responseCacheRedisPrefix + key)
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		data = []byte(value)
+	} else {
+		value, err := os.ReadFile(responseCacheFilePath(key))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		data = value
+	}
+	var entry ResponseCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	if entry.ExpiresAt > 0 && entry.ExpiresAt < time.Now().Unix() {
+		if !useRedisResponseCache() {
+			_ = os.Remove(responseCacheFilePath(key))
+		}
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// SetResponseCache 写入缓存，超过单条大小限制时不缓存
+func SetResponseCache(key string, entry *ResponseCacheEntry, ttl time.Duration) error {
+	entry.CreatedAt = time.Now().Unix()
+	entry.ExpiresAt = time.Now().Add(ttl).Unix()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if maxBytes := operation_setting.GetResponseCacheSetting().MaxEntryBytes; maxBytes > 0 && len(data) > maxBytes {
+		return nil
+	}
+	if useRedisResponseCache() {
+		return common.RedisSet(responseCacheRedisPrefix+key, string(data), ttl)
+	}
+	path := responseCacheFilePath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免并发读到不完整的内容
+	tmpPath := fmt.Sprintf("%s.%d.tmp", path, time.Now().UnixNano())
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// CleanupExpiredResponseCache 定期清理磁盘上过期的响应缓存，Redis 缓存依赖 TTL 自动过期
+func CleanupExpiredResponseCache() {
+	defer func() {
+		if r := recover(); r != nil {
+			common.SysError(fmt.Sprintf("CleanupExpiredResponseCache panic: %v", r))
+		}
+	}()
+	for {
+		time.Sleep(time.Hour)
+		setting := operation_setting.GetResponseCacheSetting()
+		if !setting.Enabled || useRedisResponseCache() {
+			continue
+		}
+		now := time.Now().Unix()
+		count := 0
+		_ = filepath.Walk(setting.DiskPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(path, ".json") {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			var entry ResponseCacheEntry
+			if json.Unmarshal(data, &entry) != nil || (entry.ExpiresAt > 0 && entry.ExpiresAt < now) {
+				if os.Remove(path) == nil {
+					count++
+				}
+			}
+			return nil
+		})
+		if count > 0 {
+			common.SysLog(fmt.Sprintf("cleaned up %d expired response cache entries", count))
+		}
+	}
+}
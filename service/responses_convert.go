@@ -0,0 +1,866 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"strings"
+	"time"
+)
+
+// ResponsesToOpenAIRequest 将 /v1/responses 请求转换为 Chat Completions 请求，用于不支持 Responses API 的渠道
+func ResponsesToOpenAIRequest(responsesRequest dto.OpenAIResponsesRequest, info *relaycommon.RelayInfo) (*dto.GeneralOpenAIRequest, error) {
+	openAIRequest := dto.GeneralOpenAIRequest{
+		Model:     responsesRequest.Model,
+		MaxTokens: responsesRequest.MaxOutputTokens,
+		TopP:      responsesRequest.TopP,
+		Stream:    responsesRequest.Stream,
+		User:      responsesRequest.User,
+	}
+	if responsesRequest.Temperature != 0 {
+		openAIRequest.Temperature = common.GetPointer[float64](responsesRequest.Temperature)
+	}
+	if responsesRequest.Reasoning != nil && responsesRequest.Reasoning.Effort != "" {
+		openAIRequest.ReasoningEffort = responsesRequest.Reasoning.Effort
+	}
+	if openAIRequest.Stream && info.SupportStreamOptions {
+		openAIRequest.StreamOptions = &dto.StreamOptions{
+			IncludeUsage: true,
+		}
+	}
+
+	// Convert tools, built-in tools can not be executed by chat completions upstreams
+	for _, tool := range responsesRequest.Tools {
+		if tool.Type != "function" {
+			continue
+		}
+		var parameters any
+		if len(tool.Parameters) > 0 {
+			if err := json.Unmarshal(tool.Parameters, &parameters); err != nil {
+				return nil, fmt.Errorf("invalid parameters of tool %s: %w", tool.Name, err)
+			}
+		}
+		openAIRequest.Tools = append(openAIRequest.Tools, dto.ToolCallRequest{
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  parameters,
+			},
+		})
+	}
+	if len(openAIRequest.Tools) > 0 {
+		openAIRequest.ToolChoice = responsesToolChoiceToOpenAI(responsesRequest.ToolChoice)
+	}
+
+	if len(responsesRequest.Text) > 0 {
+		var text dto.ResponsesText
+		if err := json.Unmarshal(responsesRequest.Text, &text); err == nil && text.Format != nil {
+			switch text.Format.Type {
+			case "json_schema":
+				openAIRequest.ResponseFormat = &dto.ResponseFormat{
+					Type: "json_schema",
+					JsonSchema: &dto.FormatJsonSchema{
+						Name:        text.Format.Name,
+						Description: text.Format.Description,
+						Schema:      text.Format.Schema,
+						Strict:      text.Format.Strict,
+					},
+				}
+			case "json_object":
+				openAIRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+			}
+		}
+	}
+
+	// Convert input items to messages
+	openAIMessages := make([]dto.Message, 0)
+	if instructions := responsesRequest.GetInstructions(); instructions != "" {
+		systemMessage := dto.Message{Role: "system"}
+		systemMessage.SetStringContent(instructions)
+		openAIMessages = append(openAIMessages, systemMessage)
+	}
+	items, err := responsesRequest.ParseInput()
+	if err != nil {
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+	messages, err := ResponsesInputToMessages(items)
+	if err != nil {
+		return nil, err
+	}
+	openAIRequest.Messages = append(openAIMessages, messages...)
+	return &openAIRequest, nil
+}
+
+// ResponsesInputToMessages 将 Responses API 的 input 条目转换为 Chat Completions 消息
+func ResponsesInputToMessages(items []dto.ResponsesInputItem) ([]dto.Message, error) {
+	messages := make([]dto.Message, 0, len(items))
+	for _, item := range items {
+		switch item.Type {
+		case dto.ResponsesItemTypeMessage:
+			role := item.Role
+			if role == "developer" {
+				role = "system"
+			}
+			message := dto.Message{Role: role}
+			if item.IsStringContent() {
+				message.SetStringContent(item.GetStringContent())
+			} else {
+				mediaContents := make([]dto.MediaContent, 0)
+				for _, content := range item.ParseContent() {
+					switch content.Type {
+					case dto.ResponsesContentTypeInputText, dto.ResponsesContentTypeOutputText, dto.ResponsesContentTypeRefusal:
+						mediaContents = append(mediaContents, dto.MediaContent{
+							Type: dto.ContentTypeText,
+							Text: content.Text,
+						})
+					case dto.ResponsesContentTypeInputImage:
+						detail := content.Detail
+						if detail == "" {
+							detail = "auto"
+						}
+						mediaContents = append(mediaContents, dto.MediaContent{
+							Type: dto.ContentTypeImageURL,
+							ImageUrl: &dto.MessageImageUrl{
+								Url:    content.ImageUrl,
+								Detail: detail,
+							},
+						})
+					case dto.ResponsesContentTypeInputFile:
+						mediaContents = append(mediaContents, dto.MediaContent{
+							Type: dto.ContentTypeFile,
+							File: &dto.MessageFile{
+								FileName: content.Filename,
+								FileData: content.FileData,
+								FileId:   content.FileId,
+							},
+						})
+					}
+				}
+				if len(mediaContents) == 1 && mediaContents[0].Type == dto.ContentTypeText {
+					message.SetStringContent(mediaContents[0].Text)
+				} else {
+					message.SetMediaContent(mediaContents)
+				}
+			}
+			messages = append(messages, message)
+		case dto.ResponsesItemTypeFunctionCall:
+			toolCall := dto.ToolCallRequest{
+				ID:   item.CallId,
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			}
+			// 连续的 function_call 合并到同一条 assistant 消息中
+			if len(messages) > 0 && messages[len(messages)-1].Role == "assistant" {
+				last := &messages[len(messages)-1]
+				toolCalls := append(last.ParseToolCalls(), toolCall)
+				last.SetToolCalls(toolCalls)
+				continue
+			}
+			message := dto.Message{Role: "assistant"}
+			message.SetToolCalls([]dto.ToolCallRequest{toolCall})
+			messages = append(messages, message)
+		case dto.ResponsesItemTypeFunctionCallOutput:
+			message := dto.Message{
+				Role:       "tool",
+				ToolCallId: item.CallId,
+			}
+			message.SetStringContent(item.GetStringOutput())
+			messages = append(messages, message)
+		case dto.ResponsesItemTypeReasoning:
+			// reasoning 条目只对产生它的上游有意义，转换时丢弃
+			continue
+		default:
+			return nil, fmt.Errorf("unsupported input item type: %s", item.Type)
+		}
+	}
+	return messages, nil
+}
+
+func responsesToolChoiceToOpenAI(toolChoice json.RawMessage) any {
+	if len(toolChoice) == 0 {
+		return nil
+	}
+	var choiceStr string
+	if err := json.Unmarshal(toolChoice, &choiceStr); err == nil {
+		return choiceStr
+	}
+	var choice struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(toolChoice, &choice); err == nil && choice.Type == "function" {
+		return map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name": choice.Name,
+			},
+		}
+	}
+	return "auto"
+}
+
+// OpenAIToResponsesRequest 将 Chat Completions 请求转换为 /v1/responses 请求，用于仅支持 Responses API 的模型
+func OpenAIToResponsesRequest(openAIRequest *dto.GeneralOpenAIRequest) (*dto.OpenAIResponsesRequest, error) {
+	responsesRequest := dto.OpenAIResponsesRequest{
+		Model:  openAIRequest.Model,
+		TopP:   openAIRequest.TopP,
+		Stream: openAIRequest.Stream,
+		User:   openAIRequest.User,
+	}
+	responsesRequest.MaxOutputTokens = openAIRequest.MaxCompletionTokens
+	if responsesRequest.MaxOutputTokens == 0 {
+		responsesRequest.MaxOutputTokens = openAIRequest.MaxTokens
+	}
+	if openAIRequest.Temperature != nil {
+		responsesRequest.Temperature = *openAIRequest.Temperature
+	}
+	if openAIRequest.ReasoningEffort != "" {
+		responsesRequest.Reasoning = &dto.Reasoning{Effort: openAIRequest.ReasoningEffort}
+	}
+	if openAIRequest.ParallelTooCalls != nil {
+		responsesRequest.ParallelToolCalls = *openAIRequest.ParallelTooCalls
+	}
+
+	for _, tool := range openAIRequest.Tools {
+		parameters, err := json.Marshal(tool.Function.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameters of tool %s: %w", tool.Function.Name, err)
+		}
+		responsesRequest.Tools = append(responsesRequest.Tools, dto.ResponsesToolsCall{
+			Type:        "function",
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  parameters,
+		})
+	}
+	if openAIRequest.WebSearchOptions != nil {
+		responsesRequest.Tools = append(responsesRequest.Tools, dto.ResponsesToolsCall{
+			Type:              dto.BuildInToolWebSearchPreview,
+			SearchContextSize: openAIRequest.WebSearchOptions.SearchContextSize,
+			UserLocation:      openAIRequest.WebSearchOptions.UserLocation,
+		})
+	}
+	if openAIRequest.ToolChoice != nil {
+		toolChoice := openAIRequest.ToolChoice
+		if choiceMap, ok := toolChoice.(map[string]any); ok {
+			if function, ok := choiceMap["function"].(map[string]any); ok {
+				toolChoice = map[string]any{
+					"type": "function",
+					"name": function["name"],
+				}
+			}
+		}
+		toolChoiceJson, err := json.Marshal(toolChoice)
+		if err != nil {
+			return nil, err
+		}
+		responsesRequest.ToolChoice = toolChoiceJson
+	}
+
+	if openAIRequest.ResponseFormat != nil {
+		text := dto.ResponsesText{
+			Format: &dto.ResponsesTextFormat{Type: openAIRequest.ResponseFormat.Type},
+		}
+		if schema := openAIRequest.ResponseFormat.JsonSchema; schema != nil {
+			text.Format.Name = schema.Name
+			text.Format.Description = schema.Description
+			text.Format.Schema = schema.Schema
+			text.Format.Strict = schema.Strict
+		}
+		textJson, err := json.Marshal(text)
+		if err != nil {
+			return nil, err
+		}
+		responsesRequest.Text = textJson
+	}
+
+	items := MessagesToResponsesInput(openAIRequest.Messages)
+	input, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	responsesRequest.Input = input
+	return &responsesRequest, nil
+}
+
+// MessagesToResponsesInput 将 Chat Completions 消息转换为 Responses API 的 input 条目
+func MessagesToResponsesInput(messages []dto.Message) []dto.ResponsesInputItem {
+	items := make([]dto.ResponsesInputItem, 0, len(messages))
+	for _, message := range messages {
+		switch message.Role {
+		case "tool":
+			output, _ := json.Marshal(message.StringContent())
+			items = append(items, dto.ResponsesInputItem{
+				Type:   dto.ResponsesItemTypeFunctionCallOutput,
+				CallId: message.ToolCallId,
+				Output: output,
+			})
+			continue
+		}
+
+		textType := dto.ResponsesContentTypeInputText
+		if message.Role == "assistant" {
+			textType = dto.ResponsesContentTypeOutputText
+		}
+		contents := make([]dto.ResponsesInputContent, 0)
+		for _, mediaContent := range message.ParseContent() {
+			switch mediaContent.Type {
+			case dto.ContentTypeText:
+				if mediaContent.Text == "" {
+					continue
+				}
+				contents = append(contents, dto.ResponsesInputContent{
+					Type: textType,
+					Text: mediaContent.Text,
+				})
+			case dto.ContentTypeImageURL:
+				if image := mediaContent.GetImageMedia(); image != nil {
+					contents = append(contents, dto.ResponsesInputContent{
+						Type:     dto.ResponsesContentTypeInputImage,
+						ImageUrl: image.Url,
+						Detail:   image.Detail,
+					})
+				}
+			case dto.ContentTypeFile:
+				if file := mediaContent.GetFile(); file != nil {
+					contents = append(contents, dto.ResponsesInputContent{
+						Type:     dto.ResponsesContentTypeInputFile,
+						FileId:   file.FileId,
+						FileData: file.FileData,
+						Filename: file.FileName,
+					})
+				}
+			}
+		}
+		if len(contents) > 0 {
+			content, _ := json.Marshal(contents)
+			items = append(items, dto.ResponsesInputItem{
+				Type:    dto.ResponsesItemTypeMessage,
+				Role:    message.Role,
+				Content: content,
+			})
+		}
+		for _, toolCall := range message.ParseToolCalls() {
+			items = append(items, dto.ResponsesInputItem{
+				Type:      dto.ResponsesItemTypeFunctionCall,
+				CallId:    toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: toolCall.Function.Arguments,
+			})
+		}
+	}
+	return items
+}
+
+// UsageOpenAI2Responses 将 Chat Completions 的用量转换为 Responses API 格式
+func UsageOpenAI2Responses(usage *dto.Usage) *dto.Usage {
+	if usage == nil {
+		return nil
+	}
+	return &dto.Usage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.PromptTokens + usage.CompletionTokens,
+		InputTokensDetails: &dto.InputTokenDetails{
+			CachedTokens: usage.PromptTokensDetails.CachedTokens,
+		},
+		OutputTokensDetails: &dto.OutputTokenDetails{
+			ReasoningTokens: usage.CompletionTokenDetails.ReasoningTokens,
+		},
+	}
+}
+
+// UsageResponses2OpenAI 将 Responses API 的用量转换为 Chat Completions 格式
+func UsageResponses2OpenAI(usage *dto.Usage) *dto.Usage {
+	if usage == nil {
+		return &dto.Usage{}
+	}
+	openAIUsage := &dto.Usage{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.InputTokens + usage.OutputTokens,
+	}
+	if usage.InputTokensDetails != nil {
+		openAIUsage.PromptTokensDetails.CachedTokens = usage.InputTokensDetails.CachedTokens
+	}
+	if usage.OutputTokensDetails != nil {
+		openAIUsage.CompletionTokenDetails.ReasoningTokens = usage.OutputTokensDetails.ReasoningTokens
+	}
+	return openAIUsage
+}
+
+func responsesOutputText(text string) dto.ResponsesOutputContent {
+	return dto.ResponsesOutputContent{
+		Type:        dto.ResponsesContentTypeOutputText,
+		Text:        text,
+		Annotations: []interface{}{},
+	}
+}
+
+// ResponseOpenAI2Responses 将 Chat Completions 非流式响应转换为 Responses API 响应
+func ResponseOpenAI2Responses(openAIResponse *dto.OpenAITextResponse, info *relaycommon.RelayInfo) *dto.OpenAIResponsesResponse {
+	response := &dto.OpenAIResponsesResponse{
+		ID:        fmt.Sprintf("resp_%s", common.GetUUID()),
+		Object:    "response",
+		CreatedAt: int(openAIResponse.Created),
+		Status:    "completed",
+		Model:     openAIResponse.Model,
+		Output:    make([]dto.ResponsesOutput, 0),
+		Tools:     make([]dto.ResponsesToolsCall, 0),
+		Usage:     UsageOpenAI2Responses(&openAIResponse.Usage),
+	}
+	if response.CreatedAt == 0 {
+		response.CreatedAt = int(time.Now().Unix())
+	}
+	if response.Model == "" {
+		response.Model = info.UpstreamModelName
+	}
+	if len(openAIResponse.Choices) == 0 {
+		return response
+	}
+	choice := openAIResponse.Choices[0]
+	reasoning := choice.Message.ReasoningContent
+	if reasoning == "" {
+		reasoning = choice.Message.Reasoning
+	}
+	if reasoning != "" {
+		response.Output = append(response.Output, dto.ResponsesOutput{
+			Type: dto.ResponsesItemTypeReasoning,
+			ID:   fmt.Sprintf("rs_%s", common.GetUUID()),
+			Summary: []dto.ResponsesOutputContent{{
+				Type: dto.ResponsesContentTypeSummaryText,
+				Text: reasoning,
+			}},
+		})
+	}
+	if content := choice.Message.StringContent(); content != "" {
+		response.Output = append(response.Output, dto.ResponsesOutput{
+			Type:    dto.ResponsesItemTypeMessage,
+			ID:      fmt.Sprintf("msg_%s", common.GetUUID()),
+			Status:  "completed",
+			Role:    "assistant",
+			Content: []dto.ResponsesOutputContent{responsesOutputText(content)},
+		})
+	}
+	for _, toolCall := range choice.Message.ParseToolCalls() {
+		response.Output = append(response.Output, dto.ResponsesOutput{
+			Type:      dto.ResponsesItemTypeFunctionCall,
+			ID:        fmt.Sprintf("fc_%s", common.GetUUID()),
+			Status:    "completed",
+			CallId:    toolCall.ID,
+			Name:      toolCall.Function.Name,
+			Arguments: toolCall.Function.Arguments,
+		})
+	}
+	if choice.FinishReason == "length" {
+		response.Status = "incomplete"
+		response.IncompleteDetails = &dto.IncompleteDetails{Reasoning: "max_output_tokens"}
+	}
+	return response
+}
+
+// ResponseResponses2OpenAI 将 Responses API 非流式响应转换为 Chat Completions 响应
+func ResponseResponses2OpenAI(responsesResponse *dto.OpenAIResponsesResponse, info *relaycommon.RelayInfo) *dto.OpenAITextResponse {
+	var contentBuilder strings.Builder
+	var reasoningBuilder strings.Builder
+	toolCalls := make([]dto.ToolCallRequest, 0)
+	for _, output := range responsesResponse.Output {
+		switch output.Type {
+		case dto.ResponsesItemTypeMessage:
+			for _, content := range output.Content {
+				if content.Type == dto.ResponsesContentTypeOutputText {
+					contentBuilder.WriteString(content.Text)
+				}
+			}
+		case dto.ResponsesItemTypeReasoning:
+			for _, summary := range output.Summary {
+				reasoningBuilder.WriteString(summary.Text)
+			}
+		case dto.ResponsesItemTypeFunctionCall:
+			toolCalls = append(toolCalls, dto.ToolCallRequest{
+				ID:   output.CallId,
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:      output.Name,
+					Arguments: output.Arguments,
+				},
+			})
+		}
+	}
+	finishReason := "stop"
+	if len(toolCalls) > 0 {
+		finishReason = "tool_calls"
+	} else if responsesResponse.Status == "incomplete" {
+		finishReason = "length"
+	}
+	message := dto.Message{
+		Role:             "assistant",
+		ReasoningContent: reasoningBuilder.String(),
+	}
+	message.SetStringContent(contentBuilder.String())
+	if len(toolCalls) > 0 {
+		message.SetToolCalls(toolCalls)
+	}
+	model := responsesResponse.Model
+	if model == "" {
+		model = info.UpstreamModelName
+	}
+	return &dto.OpenAITextResponse{
+		Id:      fmt.Sprintf("chatcmpl-%s", strings.TrimPrefix(responsesResponse.ID, "resp_")),
+		Object:  "chat.completion",
+		Created: int64(responsesResponse.CreatedAt),
+		Model:   model,
+		Choices: []dto.OpenAITextResponseChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: finishReason,
+		}},
+		Usage: *UsageResponses2OpenAI(responsesResponse.Usage),
+	}
+}
+
+func newResponsesEvent(info *relaycommon.RelayInfo, eventType string) *dto.ResponsesStreamResponse {
+	event := &dto.ResponsesStreamResponse{
+		Type:           eventType,
+		SequenceNumber: info.ResponsesConvertInfo.Sequence,
+	}
+	info.ResponsesConvertInfo.Sequence++
+	return event
+}
+
+func buildStreamResponsesResponse(info *relaycommon.RelayInfo, status string) *dto.OpenAIResponsesResponse {
+	convertInfo := info.ResponsesConvertInfo
+	response := &dto.OpenAIResponsesResponse{
+		ID:        convertInfo.ResponseId,
+		Object:    "response",
+		CreatedAt: int(convertInfo.CreatedAt),
+		Status:    status,
+		Model:     convertInfo.Model,
+		Output:    make([]dto.ResponsesOutput, 0, len(convertInfo.Output)),
+		Tools:     make([]dto.ResponsesToolsCall, 0),
+	}
+	for _, output := range convertInfo.Output {
+		response.Output = append(response.Output, *output)
+	}
+	return response
+}
+
+// openResponsesItem 追加一个新的输出条目并发送 output_item.added
+func openResponsesItem(info *relaycommon.RelayInfo, item *dto.ResponsesOutput) (int, []*dto.ResponsesStreamResponse) {
+	convertInfo := info.ResponsesConvertInfo
+	outputIndex := len(convertInfo.Output)
+	convertInfo.Output = append(convertInfo.Output, item)
+	event := newResponsesEvent(info, dto.ResponsesOutputTypeItemAdded)
+	event.OutputIndex = common.GetPointer[int](outputIndex)
+	added := *item
+	event.Item = &added
+	events := []*dto.ResponsesStreamResponse{event}
+	switch item.Type {
+	case dto.ResponsesItemTypeMessage:
+		partEvent := newResponsesEvent(info, dto.ResponsesEventContentPartAdded)
+		partEvent.ItemId = item.ID
+		partEvent.OutputIndex = common.GetPointer[int](outputIndex)
+		partEvent.ContentIndex = common.GetPointer[int](0)
+		part := responsesOutputText("")
+		partEvent.Part = &part
+		events = append(events, partEvent)
+	case dto.ResponsesItemTypeReasoning:
+		partEvent := newResponsesEvent(info, "response.reasoning_summary_part.added")
+		partEvent.ItemId = item.ID
+		partEvent.OutputIndex = common.GetPointer[int](outputIndex)
+		partEvent.SummaryIndex = common.GetPointer[int](0)
+		partEvent.Part = &dto.ResponsesOutputContent{Type: dto.ResponsesContentTypeSummaryText}
+		events = append(events, partEvent)
+	}
+	return outputIndex, events
+}
+
+// closeResponsesItems 结束所有进行中的输出条目
+func closeResponsesItems(info *relaycommon.RelayInfo) []*dto.ResponsesStreamResponse {
+	convertInfo := info.ResponsesConvertInfo
+	events := make([]*dto.ResponsesStreamResponse, 0)
+	for outputIndex, item := range convertInfo.Output {
+		if item.Status != "in_progress" {
+			continue
+		}
+		item.Status = "completed"
+		switch item.Type {
+		case dto.ResponsesItemTypeMessage:
+			textEvent := newResponsesEvent(info, dto.ResponsesEventOutputTextDone)
+			textEvent.ItemId = item.ID
+			textEvent.OutputIndex = common.GetPointer[int](outputIndex)
+			textEvent.ContentIndex = common.GetPointer[int](0)
+			textEvent.Text = item.Content[0].Text
+			partEvent := newResponsesEvent(info, dto.ResponsesEventContentPartDone)
+			partEvent.ItemId = item.ID
+			partEvent.OutputIndex = common.GetPointer[int](outputIndex)
+			partEvent.ContentIndex = common.GetPointer[int](0)
+			part := item.Content[0]
+			partEvent.Part = &part
+			events = append(events, textEvent, partEvent)
+			convertInfo.MessageIndex = -1
+		case dto.ResponsesItemTypeReasoning:
+			textEvent := newResponsesEvent(info, dto.ResponsesEventReasoningSummaryTextDone)
+			textEvent.ItemId = item.ID
+			textEvent.OutputIndex = common.GetPointer[int](outputIndex)
+			textEvent.SummaryIndex = common.GetPointer[int](0)
+			textEvent.Text = item.Summary[0].Text
+			partEvent := newResponsesEvent(info, "response.reasoning_summary_part.done")
+			partEvent.ItemId = item.ID
+			partEvent.OutputIndex = common.GetPointer[int](outputIndex)
+			partEvent.SummaryIndex = common.GetPointer[int](0)
+			part := item.Summary[0]
+			partEvent.Part = &part
+			events = append(events, textEvent, partEvent)
+			// reasoning 条目没有 status 字段
+			item.Status = ""
+			convertInfo.ReasoningIndex = -1
+		case dto.ResponsesItemTypeFunctionCall:
+			argsEvent := newResponsesEvent(info, dto.ResponsesEventFunctionCallArgsDone)
+			argsEvent.ItemId = item.ID
+			argsEvent.OutputIndex = common.GetPointer[int](outputIndex)
+			argsEvent.Arguments = item.Arguments
+			events = append(events, argsEvent)
+		}
+		doneEvent := newResponsesEvent(info, dto.ResponsesOutputTypeItemDone)
+		doneEvent.OutputIndex = common.GetPointer[int](outputIndex)
+		done := *item
+		doneEvent.Item = &done
+		events = append(events, doneEvent)
+	}
+	return events
+}
+
+// StreamResponseOpenAI2Responses 将 Chat Completions 流式块转换为 Responses API 流式事件
+func StreamResponseOpenAI2Responses(openAIResponse *dto.ChatCompletionsStreamResponse, info *relaycommon.RelayInfo) []*dto.ResponsesStreamResponse {
+	convertInfo := info.ResponsesConvertInfo
+	events := make([]*dto.ResponsesStreamResponse, 0)
+	if !convertInfo.Started {
+		convertInfo.Started = true
+		convertInfo.ResponseId = fmt.Sprintf("resp_%s", common.GetUUID())
+		convertInfo.Model = openAIResponse.Model
+		if convertInfo.Model == "" {
+			convertInfo.Model = info.UpstreamModelName
+		}
+		convertInfo.CreatedAt = openAIResponse.Created
+		if convertInfo.CreatedAt == 0 {
+			convertInfo.CreatedAt = time.Now().Unix()
+		}
+		created := newResponsesEvent(info, dto.ResponsesEventCreated)
+		created.Response = buildStreamResponsesResponse(info, "in_progress")
+		inProgress := newResponsesEvent(info, dto.ResponsesEventInProgress)
+		inProgress.Response = buildStreamResponsesResponse(info, "in_progress")
+		events = append(events, created, inProgress)
+	}
+	if openAIResponse.Usage != nil && ValidUsage(openAIResponse.Usage) {
+		convertInfo.Usage = openAIResponse.Usage
+	}
+	for _, choice := range openAIResponse.Choices {
+		// Responses API 只有一个输出序列
+		if choice.Index != 0 {
+			continue
+		}
+		if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+			if convertInfo.ReasoningIndex < 0 {
+				events = append(events, closeResponsesItems(info)...)
+				var itemEvents []*dto.ResponsesStreamResponse
+				convertInfo.ReasoningIndex, itemEvents = openResponsesItem(info, &dto.ResponsesOutput{
+					Type:    dto.ResponsesItemTypeReasoning,
+					ID:      fmt.Sprintf("rs_%s", common.GetUUID()),
+					Status:  "in_progress",
+					Summary: []dto.ResponsesOutputContent{{Type: dto.ResponsesContentTypeSummaryText}},
+				})
+				events = append(events, itemEvents...)
+			}
+			item := convertInfo.Output[convertInfo.ReasoningIndex]
+			item.Summary[0].Text += reasoning
+			event := newResponsesEvent(info, dto.ResponsesEventReasoningSummaryTextDelta)
+			event.ItemId = item.ID
+			event.OutputIndex = common.GetPointer[int](convertInfo.ReasoningIndex)
+			event.SummaryIndex = common.GetPointer[int](0)
+			event.Delta = reasoning
+			events = append(events, event)
+		}
+		if content := choice.Delta.GetContentString(); content != "" {
+			if convertInfo.MessageIndex < 0 {
+				events = append(events, closeResponsesItems(info)...)
+				var itemEvents []*dto.ResponsesStreamResponse
+				convertInfo.MessageIndex, itemEvents = openResponsesItem(info, &dto.ResponsesOutput{
+					Type:    dto.ResponsesItemTypeMessage,
+					ID:      fmt.Sprintf("msg_%s", common.GetUUID()),
+					Status:  "in_progress",
+					Role:    "assistant",
+					Content: []dto.ResponsesOutputContent{responsesOutputText("")},
+				})
+				events = append(events, itemEvents...)
+			}
+			item := convertInfo.Output[convertInfo.MessageIndex]
+			item.Content[0].Text += content
+			event := newResponsesEvent(info, dto.ResponsesEventOutputTextDelta)
+			event.ItemId = item.ID
+			event.OutputIndex = common.GetPointer[int](convertInfo.MessageIndex)
+			event.ContentIndex = common.GetPointer[int](0)
+			event.Delta = content
+			events = append(events, event)
+		}
+		for i, toolCall := range choice.Delta.ToolCalls {
+			toolIndex := i
+			if toolCall.Index != nil {
+				toolIndex = *toolCall.Index
+			}
+			outputIndex, exists := convertInfo.ToolCallItems[toolIndex]
+			if !exists {
+				if convertInfo.MessageIndex >= 0 || convertInfo.ReasoningIndex >= 0 {
+					events = append(events, closeResponsesItems(info)...)
+				}
+				var itemEvents []*dto.ResponsesStreamResponse
+				outputIndex, itemEvents = openResponsesItem(info, &dto.ResponsesOutput{
+					Type:   dto.ResponsesItemTypeFunctionCall,
+					ID:     fmt.Sprintf("fc_%s", common.GetUUID()),
+					Status: "in_progress",
+					CallId: toolCall.ID,
+					Name:   toolCall.Function.Name,
+				})
+				convertInfo.ToolCallItems[toolIndex] = outputIndex
+				events = append(events, itemEvents...)
+			}
+			item := convertInfo.Output[outputIndex]
+			if toolCall.Function.Arguments != "" {
+				item.Arguments += toolCall.Function.Arguments
+				event := newResponsesEvent(info, dto.ResponsesEventFunctionCallArgsDelta)
+				event.ItemId = item.ID
+				event.OutputIndex = common.GetPointer[int](outputIndex)
+				event.Delta = toolCall.Function.Arguments
+				events = append(events, event)
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			convertInfo.FinishReason = *choice.FinishReason
+		}
+	}
+	return events
+}
+
+// FinishStreamResponseOpenAI2Responses 结束转换后的流，发送 response.completed 事件
+func FinishStreamResponseOpenAI2Responses(info *relaycommon.RelayInfo, usage *dto.Usage) []*dto.ResponsesStreamResponse {
+	convertInfo := info.ResponsesConvertInfo
+	events := make([]*dto.ResponsesStreamResponse, 0)
+	if !convertInfo.Started {
+		events = append(events, StreamResponseOpenAI2Responses(&dto.ChatCompletionsStreamResponse{}, info)...)
+	}
+	events = append(events, closeResponsesItems(info)...)
+	if usage == nil {
+		usage = convertInfo.Usage
+	}
+	status := "completed"
+	eventType := dto.ResponsesEventCompleted
+	if convertInfo.FinishReason == "length" {
+		status = "incomplete"
+		eventType = dto.ResponsesEventIncomplete
+	}
+	event := newResponsesEvent(info, eventType)
+	event.Response = buildStreamResponsesResponse(info, status)
+	event.Response.Usage = UsageOpenAI2Responses(usage)
+	if status == "incomplete" {
+		event.Response.IncompleteDetails = &dto.IncompleteDetails{Reasoning: "max_output_tokens"}
+	}
+	events = append(events, event)
+	return events
+}
+
+func newChatStreamResponse(info *relaycommon.RelayInfo) *dto.ChatCompletionsStreamResponse {
+	convertInfo := info.ResponsesConvertInfo
+	return &dto.ChatCompletionsStreamResponse{
+		Id:      fmt.Sprintf("chatcmpl-%s", strings.TrimPrefix(convertInfo.ResponseId, "resp_")),
+		Object:  "chat.completion.chunk",
+		Created: convertInfo.CreatedAt,
+		Model:   convertInfo.Model,
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: 0}},
+	}
+}
+
+// StreamResponseResponses2OpenAI 将 Responses API 流式事件转换为 Chat Completions 流式块
+func StreamResponseResponses2OpenAI(responsesResponse *dto.ResponsesStreamResponse, info *relaycommon.RelayInfo) []*dto.ChatCompletionsStreamResponse {
+	convertInfo := info.ResponsesConvertInfo
+	responses := make([]*dto.ChatCompletionsStreamResponse, 0)
+	switch responsesResponse.Type {
+	case dto.ResponsesEventCreated:
+		if responsesResponse.Response != nil {
+			convertInfo.ResponseId = responsesResponse.Response.ID
+			convertInfo.Model = responsesResponse.Response.Model
+			convertInfo.CreatedAt = int64(responsesResponse.Response.CreatedAt)
+		}
+		if convertInfo.Model == "" {
+			convertInfo.Model = info.UpstreamModelName
+		}
+		if convertInfo.CreatedAt == 0 {
+			convertInfo.CreatedAt = time.Now().Unix()
+		}
+		convertInfo.SentRole = true
+		response := newChatStreamResponse(info)
+		response.Choices[0].Delta.Role = "assistant"
+		response.Choices[0].Delta.SetContentString("")
+		responses = append(responses, response)
+	case dto.ResponsesEventOutputTextDelta:
+		response := newChatStreamResponse(info)
+		response.Choices[0].Delta.SetContentString(responsesResponse.Delta)
+		responses = append(responses, response)
+	case dto.ResponsesEventReasoningSummaryTextDelta:
+		response := newChatStreamResponse(info)
+		response.Choices[0].Delta.ReasoningContent = common.GetPointer[string](responsesResponse.Delta)
+		responses = append(responses, response)
+	case dto.ResponsesOutputTypeItemAdded:
+		if responsesResponse.Item == nil || responsesResponse.Item.Type != dto.ResponsesItemTypeFunctionCall {
+			break
+		}
+		toolIndex := len(convertInfo.ToolCallIndex)
+		convertInfo.ToolCallIndex[responsesResponse.Item.ID] = toolIndex
+		toolCall := dto.ToolCallResponse{
+			ID:   responsesResponse.Item.CallId,
+			Type: "function",
+			Function: dto.FunctionResponse{
+				Name:      responsesResponse.Item.Name,
+				Arguments: responsesResponse.Item.Arguments,
+			},
+		}
+		toolCall.SetIndex(toolIndex)
+		response := newChatStreamResponse(info)
+		response.Choices[0].Delta.ToolCalls = []dto.ToolCallResponse{toolCall}
+		responses = append(responses, response)
+	case dto.ResponsesEventFunctionCallArgsDelta:
+		toolIndex, ok := convertInfo.ToolCallIndex[responsesResponse.ItemId]
+		if !ok {
+			break
+		}
+		toolCall := dto.ToolCallResponse{
+			Function: dto.FunctionResponse{
+				Arguments: responsesResponse.Delta,
+			},
+		}
+		toolCall.SetIndex(toolIndex)
+		response := newChatStreamResponse(info)
+		response.Choices[0].Delta.ToolCalls = []dto.ToolCallResponse{toolCall}
+		responses = append(responses, response)
+	case dto.ResponsesEventCompleted, dto.ResponsesEventIncomplete:
+		finishReason := "stop"
+		if len(convertInfo.ToolCallIndex) > 0 {
+			finishReason = "tool_calls"
+		} else if responsesResponse.Type == dto.ResponsesEventIncomplete {
+			finishReason = "length"
+		}
+		convertInfo.FinishReason = finishReason
+		if responsesResponse.Response != nil && responsesResponse.Response.Usage != nil {
+			convertInfo.Usage = UsageResponses2OpenAI(responsesResponse.Response.Usage)
+		}
+		response := newChatStreamResponse(info)
+		response.Choices[0].FinishReason = &finishReason
+		responses = append(responses, response)
+	}
+	return responses
+}
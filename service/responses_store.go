@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/model_setting"
+	"time"
+)
+
+// 续接对话时最多回溯的响应数量，防止异常数据导致无限循环
+const maxResponsesChainDepth = 100
+
+// AssignResponsesInputItemIds 为缺少 id 的输入条目生成 id，便于 input_items 列表查询
+func AssignResponsesInputItemIds(items []dto.ResponsesInputItem) {
+	for idx := range items {
+		if items[idx].ID != "" {
+			continue
+		}
+		switch items[idx].Type {
+		case dto.ResponsesItemTypeMessage:
+			items[idx].ID = fmt.Sprintf("msg_%s", common.GetUUID())
+		case dto.ResponsesItemTypeFunctionCall:
+			items[idx].ID = fmt.Sprintf("fc_%s", common.GetUUID())
+		case dto.ResponsesItemTypeFunctionCallOutput:
+			items[idx].ID = fmt.Sprintf("fco_%s", common.GetUUID())
+		}
+	}
+}
+
+// ResponsesOutputToInputItems 将响应的 output 转换为下一轮请求可用的 input 条目
+// reasoning 条目只对产生它的上游账号有效，重建上下文时丢弃
+func ResponsesOutputToInputItems(output []dto.ResponsesOutput) []dto.ResponsesInputItem {
+	items := make([]dto.ResponsesInputItem, 0, len(output))
+	for _, out := range output {
+		switch out.Type {
+		case dto.ResponsesItemTypeMessage:
+			content, _ := json.Marshal(out.Content)
+			items = append(items, dto.ResponsesInputItem{
+				Type:    dto.ResponsesItemTypeMessage,
+				Role:    common.GetStringIfEmpty(out.Role, "assistant"),
+				Content: content,
+			})
+		case dto.ResponsesItemTypeFunctionCall:
+			items = append(items, dto.ResponsesInputItem{
+				Type:      dto.ResponsesItemTypeFunctionCall,
+				CallId:    out.CallId,
+				Name:      out.Name,
+				Arguments: out.Arguments,
+			})
+		}
+	}
+	return items
+}
+
+// GetResponsesRecordInput 返回记录中保存的本轮输入条目
+func GetResponsesRecordInput(record *model.ResponseRecord) ([]dto.ResponsesInputItem, error) {
+	var items []dto.ResponsesInputItem
+	if record.Input == "" {
+		return items, nil
+	}
+	err := json.Unmarshal([]byte(record.Input), &items)
+	return items, err
+}
+
+// BuildResponsesHistory 根据 previous_response_id 回溯已保存的响应，按时间顺序重建完整的对话条目
+func BuildResponsesHistory(userId int, previousResponseId string) ([]dto.ResponsesInputItem, error) {
+	var turns [][]dto.ResponsesInputItem
+	responseId := previousResponseId
+	for depth := 0; responseId != "" && depth < maxResponsesChainDepth; depth++ {
+		record, err := model.GetResponseRecord(userId, responseId)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			if depth == 0 {
+				return nil, fmt.Errorf("previous response with id '%s' not found", previousResponseId)
+			}
+			// 更早的响应已过期清理，只保留能找到的部分
+			break
+		}
+		input, err := GetResponsesRecordInput(record)
+		if err != nil {
+			return nil, err
+		}
+		var response dto.OpenAIResponsesResponse
+		if err = json.Unmarshal([]byte(record.Response), &response); err != nil {
+			return nil, err
+		}
+		turn := append(input, ResponsesOutputToInputItems(response.Output)...)
+		turns = append(turns, turn)
+		responseId = record.PreviousResponseId
+	}
+	history := make([]dto.ResponsesInputItem, 0)
+	for idx := len(turns) - 1; idx >= 0; idx-- {
+		for _, item := range turns[idx] {
+			if item.Type == dto.ResponsesItemTypeReasoning {
+				continue
+			}
+			// 条目 id 只在网关内有效，发往上游时去掉
+			item.ID = ""
+			history = append(history, item)
+		}
+	}
+	return history, nil
+}
+
+// SaveResponsesRecord 保存响应对象及本轮输入条目
+func SaveResponsesRecord(info *relaycommon.RelayInfo, previousResponseId string, input []dto.ResponsesInputItem, response json.RawMessage) error {
+	var meta struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(response, &meta); err != nil {
+		return err
+	}
+	if meta.ID == "" {
+		return errors.New("response id is empty")
+	}
+	inputData, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	return model.InsertResponseRecord(&model.ResponseRecord{
+		ResponseId:         meta.ID,
+		PreviousResponseId: previousResponseId,
+		UserId:             info.UserId,
+		TokenId:            info.TokenId,
+		ChannelId:          info.ChannelId,
+		Model:              info.OriginModelName,
+		Input:              string(inputData),
+		Response:           string(response),
+		CreatedAt:          common.GetTimestamp(),
+	})
+}
+
+// CleanupExpiredResponses 定期删除超过保留天数的响应记录
+func CleanupExpiredResponses() {
+	defer func() {
+		if r := recover(); r != nil {
+			common.SysError(fmt.Sprintf("CleanupExpiredResponses panic: %v", r))
+		}
+	}()
+	for {
+		retentionDays := model_setting.GetResponsesSettings().StoreRetentionDays
+		if retentionDays > 0 {
+			before := time.Now().AddDate(0, 0, -retentionDays).Unix()
+			count, err := model.DeleteResponseRecordsBefore(before)
+			if err != nil {
+				common.SysError("failed to cleanup expired responses: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("cleaned up %d expired responses", count))
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
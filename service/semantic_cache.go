@@ -0,0 +1,239 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SemanticCacheEntry 语义缓存条目：最后一条用户消息的向量与对应的回答
+type SemanticCacheEntry struct {
+	Partition    string    `json:"partition"`
+	Vector       []float64 `json:"vector"`
+	Content      string    `json:"content"`
+	FinishReason string    `json:"finish_reason"`
+	Usage        dto.Usage `json:"usage"`
+	CreatedAt    int64     `json:"created_at"`
+	ExpiresAt    int64     `json:"expires_at"`
+	norm         float64
+}
+
+// SemanticCacheStats 语义缓存命中统计
+type SemanticCacheStats struct {
+	Entries int     `json:"entries"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// 内存向量索引，按写入顺序保存，超出上限时淘汰最早的条目
+var semanticCache struct {
+	sync.RWMutex
+	entries []*SemanticCacheEntry
+	dirty   bool
+}
+
+var (
+	semanticCacheHits   atomic.Int64
+	semanticCacheMisses atomic.Int64
+)
+
+func vectorNorm(vector []float64) float64 {
+	var sum float64
+	for _, v := range vector {
+		sum += v * v
+	}
+	return math.Sqrt(sum)
+}
+
+func cosineSimilarity(a []float64, aNorm float64, b []float64, bNorm float64) float64 {
+	if len(a) != len(b) || aNorm == 0 || bNorm == 0 {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot / (aNorm * bNorm)
+}
+
+// SemanticCachePartition 计算缓存分区：只有共享范围、模型与上下文（系统提示词及历史消息）完全一致的请求之间才比较相似度
+func SemanticCachePartition(scope string, model string, context []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(scope + "\n" + model + "\n"))
+	hash.Write(context)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// SearchSemanticCache 在分区内查找与向量最相似且不低于阈值的条目，未命中时返回 nil
+func SearchSemanticCache(partition string, vector []float64) (*SemanticCacheEntry, float64) {
+	threshold := operation_setting.GetSemanticCacheSetting().SimilarityThreshold
+	norm := vectorNorm(vector)
+	now := time.Now().Unix()
+
+	var best *SemanticCacheEntry
+	var bestSimilarity float64
+	semanticCache.RLock()
+	for _, entry := range semanticCache.entries {
+		if entry.Partition != partition || (entry.ExpiresAt > 0 && entry.ExpiresAt < now) {
+			continue
+		}
+		similarity := cosineSimilarity(vector, norm, entry.Vector, entry.norm)
+		if similarity >= threshold && similarity > bestSimilarity {
+			best = entry
+			bestSimilarity = similarity
+		}
+	}
+	semanticCache.RUnlock()
+
+	if best == nil {
+		semanticCacheMisses.Add(1)
+		return nil, 0
+	}
+	semanticCacheHits.Add(1)
+	return best, bestSimilarity
+}
+
+// AddSemanticCache 写入语义缓存
+func AddSemanticCache(entry *SemanticCacheEntry) {
+	setting := operation_setting.GetSemanticCacheSetting()
+	entry.norm = vectorNorm(entry.Vector)
+	entry.CreatedAt = time.Now().Unix()
+	entry.ExpiresAt = entry.CreatedAt + int64(setting.TTLSeconds)
+
+	semanticCache.Lock()
+	defer semanticCache.Unlock()
+	semanticCache.entries = append(semanticCache.entries, entry)
+	if setting.MaxEntries > 0 && len(semanticCache.entries) > setting.MaxEntries {
+		overflow := len(semanticCache.entries) - setting.MaxEntries
+		semanticCache.entries = append([]*SemanticCacheEntry{}, semanticCache.entries[overflow:]...)
+	}
+	semanticCache.dirty = true
+}
+
+// ClearSemanticCache 清空语义缓存与统计
+func ClearSemanticCache() {
+	semanticCache.Lock()
+	semanticCache.entries = nil
+	semanticCache.dirty = true
+	semanticCache.Unlock()
+	semanticCacheHits.Store(0)
+	semanticCacheMisses.Store(0)
+}
+
+func GetSemanticCacheStats() SemanticCacheStats {
+	semanticCache.RLock()
+	entries := len(semanticCache.entries)
+	semanticCache.RUnlock()
+	stats := SemanticCacheStats{
+		Entries: entries,
+		Hits:    semanticCacheHits.Load(),
+		Misses:  semanticCacheMisses.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+func loadSemanticCache(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var entries []*SemanticCacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	loaded := make([]*SemanticCacheEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.ExpiresAt > 0 && entry.ExpiresAt < now {
+			continue
+		}
+		entry.norm = vectorNorm(entry.Vector)
+		loaded = append(loaded, entry)
+	}
+	semanticCache.Lock()
+	semanticCache.entries = append(loaded, semanticCache.entries...)
+	semanticCache.Unlock()
+	common.SysLog(fmt.Sprintf("loaded %d semantic cache entries", len(loaded)))
+	return nil
+}
+
+func saveSemanticCache(path string) error {
+	semanticCache.RLock()
+	data, err := json.Marshal(semanticCache.entries)
+	semanticCache.RUnlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免中途退出导致文件损坏
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// removeExpiredSemanticCache 移除过期条目，返回是否需要持久化
+func removeExpiredSemanticCache() bool {
+	now := time.Now().Unix()
+	semanticCache.Lock()
+	defer semanticCache.Unlock()
+	entries := semanticCache.entries[:0]
+	for _, entry := range semanticCache.entries {
+		if entry.ExpiresAt > 0 && entry.ExpiresAt < now {
+			semanticCache.dirty = true
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	for i := len(entries); i < len(semanticCache.entries); i++ {
+		semanticCache.entries[i] = nil
+	}
+	semanticCache.entries = entries
+	dirty := semanticCache.dirty
+	semanticCache.dirty = false
+	return dirty
+}
+
+// RunSemanticCache 启动时从磁盘恢复索引，之后定期清理过期条目并持久化
+func RunSemanticCache() {
+	defer func() {
+		if r := recover(); r != nil {
+			common.SysError(fmt.Sprintf("RunSemanticCache panic: %v", r))
+		}
+	}()
+	if setting := operation_setting.GetSemanticCacheSetting(); setting.Persist {
+		if err := loadSemanticCache(setting.PersistPath); err != nil {
+			common.SysError("failed to load semantic cache: " + err.Error())
+		}
+	}
+	for {
+		time.Sleep(5 * time.Minute)
+		setting := operation_setting.GetSemanticCacheSetting()
+		if !removeExpiredSemanticCache() || !setting.Persist {
+			continue
+		}
+		if err := saveSemanticCache(setting.PersistPath); err != nil {
+			common.SysError("failed to save semantic cache: " + err.Error())
+		}
+	}
+}
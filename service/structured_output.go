@@ -0,0 +1,231 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"strings"
+)
+
+const structuredOutputDefaultName = "response"
+
+// IsStructuredOutputRequest 判断请求是否要求 JSON 格式输出 (json_schema / json_object)
+func IsStructuredOutputRequest(request *dto.GeneralOpenAIRequest) bool {
+	if request.ResponseFormat == nil {
+		return false
+	}
+	return request.ResponseFormat.Type == "json_schema" || request.ResponseFormat.Type == "json_object"
+}
+
+// StructuredOutputToolName 返回以工具调用模拟 json_schema 时使用的工具名
+func StructuredOutputToolName(format *dto.ResponseFormat) string {
+	if format.JsonSchema != nil && format.JsonSchema.Name != "" {
+		return format.JsonSchema.Name
+	}
+	return structuredOutputDefaultName
+}
+
+// structuredOutputInstruction 生成注入到系统提示中的格式说明
+func structuredOutputInstruction(format *dto.ResponseFormat) string {
+	if format.JsonSchema == nil || format.JsonSchema.Schema == nil {
+		return "You must respond with a single valid JSON object only. " +
+			"Do not include any explanation, markdown formatting or code fences."
+	}
+	schema, _ := json.Marshal(format.JsonSchema.Schema)
+	var sb strings.Builder
+	sb.WriteString("You must respond with a single valid JSON value that strictly conforms to the following JSON Schema")
+	if format.JsonSchema.Name != "" {
+		sb.WriteString(fmt.Sprintf(" named %q", format.JsonSchema.Name))
+	}
+	sb.WriteString(".\n")
+	if format.JsonSchema.Description != "" {
+		sb.WriteString("Schema description: " + format.JsonSchema.Description + "\n")
+	}
+	sb.WriteString("JSON Schema:\n")
+	sb.Write(schema)
+	sb.WriteString("\nInclude every required property and no properties that the schema does not allow. " +
+		"Output only the JSON, without any explanation, markdown formatting or code fences.")
+	return sb.String()
+}
+
+// ApplyStructuredOutputPrompt 把 response_format 改写为系统提示，用于不支持 json_schema 的上游
+func ApplyStructuredOutputPrompt(request *dto.GeneralOpenAIRequest, format *dto.ResponseFormat) {
+	instruction := structuredOutputInstruction(format)
+	request.ResponseFormat = nil
+	if len(request.Messages) > 0 && request.Messages[0].Role == "system" {
+		content := request.Messages[0].StringContent()
+		if content != "" {
+			content += "\n\n"
+		}
+		request.Messages[0].SetStringContent(content + instruction)
+		return
+	}
+	systemMessage := dto.Message{Role: "system"}
+	systemMessage.SetStringContent(instruction)
+	request.Messages = append([]dto.Message{systemMessage}, request.Messages...)
+}
+
+// ApplyStructuredOutputTool 把 json_schema 改写为强制调用的工具，工具参数即为结构化输出（Claude 等原生支持 tool_choice 的上游）
+func ApplyStructuredOutputTool(request *dto.GeneralOpenAIRequest, format *dto.ResponseFormat) {
+	toolName := StructuredOutputToolName(format)
+	var parameters any = map[string]any{"type": "object"}
+	description := "Respond with the final answer as the arguments of this tool."
+	if format.JsonSchema != nil {
+		if format.JsonSchema.Schema != nil {
+			parameters = format.JsonSchema.Schema
+		}
+		if format.JsonSchema.Description != "" {
+			description = format.JsonSchema.Description
+		}
+	}
+	request.ResponseFormat = nil
+	request.Tools = append(request.Tools, dto.ToolCallRequest{
+		Type: "function",
+		Function: dto.FunctionRequest{
+			Name:        toolName,
+			Description: description,
+			Parameters:  parameters,
+		},
+	})
+	request.ToolChoice = map[string]any{
+		"type": "function",
+		"function": map[string]any{
+			"name": toolName,
+		},
+	}
+}
+
+// StructuredOutputToolToContent 把强制工具调用的参数改写为消息内容，并去除该工具调用
+func StructuredOutputToolToContent(response *dto.OpenAITextResponse, toolName string) {
+	for i := range response.Choices {
+		message := &response.Choices[i].Message
+		toolCalls := message.ParseToolCalls()
+		if len(toolCalls) == 0 {
+			continue
+		}
+		remaining := make([]dto.ToolCallRequest, 0, len(toolCalls))
+		for _, toolCall := range toolCalls {
+			if toolCall.Function.Name == toolName {
+				message.SetStringContent(toolCall.Function.Arguments)
+				continue
+			}
+			remaining = append(remaining, toolCall)
+		}
+		if len(remaining) > 0 {
+			message.SetToolCalls(remaining)
+		} else {
+			message.ToolCalls = nil
+			if response.Choices[i].FinishReason == "tool_calls" {
+				response.Choices[i].FinishReason = "stop"
+			}
+		}
+	}
+}
+
+// extractJsonText 去除模型输出中常见的 markdown 代码块与前后说明文字
+func extractJsonText(content string) string {
+	text := strings.TrimSpace(content)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if idx := strings.Index(text, "\n"); idx >= 0 {
+			text = text[idx+1:]
+		}
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+		text = strings.TrimSpace(text)
+	}
+	if json.Valid([]byte(text)) {
+		return text
+	}
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start >= 0 && end > start {
+		return text[start : end+1]
+	}
+	return text
+}
+
+// ValidateStructuredOutput 解析并校验模型输出，成功时返回规范化后的 JSON 文本
+func ValidateStructuredOutput(content string, format *dto.ResponseFormat) (string, error) {
+	text := extractJsonText(content)
+	if text == "" {
+		return "", errors.New("the response is empty")
+	}
+	var value any
+	if err := common.DecodeJsonStr(text, &value); err != nil {
+		return "", fmt.Errorf("the response is not valid JSON: %s", err.Error())
+	}
+	if format.JsonSchema != nil && format.JsonSchema.Schema != nil {
+		if err := ValidateJsonSchema(value, format.JsonSchema.Schema); err != nil {
+			// schema 本身无效时重试没有意义，原样返回由调用方识别
+			if errors.Is(err, ErrInvalidJsonSchema) {
+				return "", err
+			}
+			return "", fmt.Errorf("the response does not match the JSON Schema: %s", err.Error())
+		}
+	} else if _, ok := value.(map[string]any); !ok {
+		return "", errors.New("the response must be a JSON object")
+	}
+	return text, nil
+}
+
+// StructuredOutputRetryMessages 生成携带校验错误的重试消息
+func StructuredOutputRetryMessages(content string, validateErr error) []dto.Message {
+	assistantMessage := dto.Message{Role: "assistant"}
+	assistantMessage.SetStringContent(content)
+	userMessage := dto.Message{Role: "user"}
+	userMessage.SetStringContent(fmt.Sprintf("Your previous response was invalid: %s\n"+
+		"Respond again with only the corrected JSON that strictly conforms to the required JSON Schema.", validateErr.Error()))
+	return []dto.Message{assistantMessage, userMessage}
+}
+
+// ResponseOpenAI2StreamResponses 把完整的 Chat Completions 响应拆分为流式 chunk，用于向流式请求回放非流式结果
+func ResponseOpenAI2StreamResponses(response *dto.OpenAITextResponse) []*dto.ChatCompletionsStreamResponse {
+	chunks := make([]*dto.ChatCompletionsStreamResponse, 0, len(response.Choices)*2)
+	newChunk := func(choice dto.ChatCompletionsStreamResponseChoice) *dto.ChatCompletionsStreamResponse {
+		return &dto.ChatCompletionsStreamResponse{
+			Id:      response.Id,
+			Object:  "chat.completion.chunk",
+			Created: response.Created,
+			Model:   response.Model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{choice},
+		}
+	}
+	for _, choice := range response.Choices {
+		delta := dto.ChatCompletionsStreamResponseChoiceDelta{
+			Role: "assistant",
+		}
+		if content := choice.Message.StringContent(); content != "" {
+			delta.SetContentString(content)
+		}
+		if reasoning := choice.Message.ReasoningContent; reasoning != "" {
+			delta.ReasoningContent = &reasoning
+		}
+		for i, toolCall := range choice.Message.ParseToolCalls() {
+			toolCallResponse := dto.ToolCallResponse{
+				ID:   toolCall.ID,
+				Type: toolCall.Type,
+				Function: dto.FunctionResponse{
+					Name:      toolCall.Function.Name,
+					Arguments: toolCall.Function.Arguments,
+				},
+			}
+			toolCallResponse.SetIndex(i)
+			delta.ToolCalls = append(delta.ToolCalls, toolCallResponse)
+		}
+		chunks = append(chunks, newChunk(dto.ChatCompletionsStreamResponseChoice{
+			Index: choice.Index,
+			Delta: delta,
+		}))
+		finishReason := choice.FinishReason
+		if finishReason == "" {
+			finishReason = "stop"
+		}
+		chunks = append(chunks, newChunk(dto.ChatCompletionsStreamResponseChoice{
+			Index:        choice.Index,
+			FinishReason: &finishReason,
+		}))
+	}
+	return chunks
+}
@@ -6,9 +6,13 @@ import (
 	"one-api/common"
 	"one-api/model"
 	relaycommon "one-api/relay/common"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 // SubscriptionService 订阅服务
@@ -19,86 +23,216 @@ func NewSubscriptionService() *SubscriptionService {
 	return &SubscriptionService{}
 }
 
-// CheckAndConsumeSubscriptionQuota 检查并消费订阅配额
-// 返回值：是否使用了订阅配额，消费的配额数量，错误信息
-func (s *SubscriptionService) CheckAndConsumeSubscriptionQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string, usageCount int) (bool, int, error) {
-	userId := relayInfo.UserId
-	if userId == 0 {
-		return false, 0, errors.New("用户ID为空")
+// ConsumeUsage 按本次请求的实际用量结算订阅配额，优先使用即将过期的订阅，
+// 同一订阅内按 MatchQuotas 的顺序依次使用匹配的配额。
+// 预扣阶段的预留在此一并结算，每个订阅的扣减都是带条件的原子更新，并发请求不会超额使用。
+// tokens 为输入与输出 token 总数，quota 为按模型价格计算出的额度，
+// 返回订阅抵扣后仍需按余额计费的额度，以及写入消费日志的说明
+func (s *SubscriptionService) ConsumeUsage(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, tokens int, quota int) (int, string) {
+	if tokens == 0 {
+		// 请求出错没有实际用量，释放预留的订阅配额
+		s.ReleaseReservations(relayInfo)
+		return quota, ""
 	}
-
-	// 获取用户的激活订阅
-	subscriptions, err := model.GetActiveUserSubscriptions(userId)
+	modelName := relayInfo.OriginModelName
+	reservations, err := model.GetSubscriptionQuotaReservations(relayInfo.SubscriptionReservationIds)
 	if err != nil {
-		common.SysError(fmt.Sprintf("获取用户订阅失败: %v", err))
-		return false, 0, nil // 不阻断请求，继续使用原有计费方式
+		common.LogError(ctx, fmt.Sprintf("获取订阅配额预留失败: %v", err))
 	}
-
-	if len(subscriptions) == 0 {
-		// 没有激活的订阅，使用原有计费方式
-		return false, 0, nil
+	held := make(map[int]*model.SubscriptionQuotaReservation, len(reservations))
+	for _, reservation := range reservations {
+		held[reservation.SubscriptionQuotaId] = reservation
 	}
+	relayInfo.SubscriptionReservationIds = nil
+	defer func() {
+		// 释放未被结算的预留，例如订阅在请求期间过期
+		for _, reservation := range held {
+			if _, err := model.SettleSubscriptionQuota(reservation.SubscriptionQuotaId, reservation, 0); err != nil {
+				common.SysError(fmt.Sprintf("释放订阅配额预留失败: %v", err))
+			}
+		}
+	}()
 
-	// 查找包含该模型的订阅，按结束时间排序（优先使用即将过期的）
-	var availableSubscription *model.UserSubscription
-	var maxQuota int
+	subscriptions, err := model.GetActiveUserSubscriptions(relayInfo.UserId)
+	if err != nil {
+		common.LogError(ctx, fmt.Sprintf("获取用户订阅失败: %v", err))
+		relayInfo.UsedSubscriptionQuota = false
+		return quota, ""
+	}
 
+	// 尚未被订阅抵扣的比例
+	uncovered := decimal.NewFromInt(1)
+	var consumed []string
 	for _, subscription := range subscriptions {
-		// 检查并更新过期状态
-		err := subscription.CheckAndUpdateExpiredStatus()
+		if !uncovered.IsPositive() {
+			break
+		}
+		if !subscription.IsActive() {
+			continue
+		}
+		subscriptionQuotas, err := subscription.MatchQuotas(modelName, relayInfo.UpstreamModelName)
 		if err != nil {
-			common.SysError(fmt.Sprintf("更新订阅状态失败: %v", err))
 			continue
 		}
+		for _, subscriptionQuota := range subscriptionQuotas {
+			if !uncovered.IsPositive() {
+				break
+			}
+			reservation := held[subscriptionQuota.Id]
+			available := subscriptionQuota.Remaining()
+			if reservation != nil {
+				available += reservation.Amount
+			}
+			if available <= 0 {
+				continue
+			}
+			amount, covered := subscriptionUsageAmount(subscriptionQuota.Unit, tokens, quota, uncovered, available)
+			if amount <= 0 {
+				continue
+			}
+			delete(held, subscriptionQuota.Id)
+			ok, err := model.SettleSubscriptionQuota(subscriptionQuota.Id, reservation, amount)
+			if err != nil {
+				common.LogError(ctx, fmt.Sprintf("消费订阅配额失败: %v", err))
+				continue
+			}
+			if !ok {
+				// 并发请求已用完剩余配额，该部分按余额计费
+				continue
+			}
+			uncovered = uncovered.Sub(covered)
+			relayInfo.SubscriptionId = subscription.Id
+			if err := model.RecordSubscriptionUsage(relayInfo.UserId, subscription.Id, modelName, amount, tokens, relayInfo.RequestId); err != nil {
+				common.SysError(fmt.Sprintf("记录订阅使用失败: %v", err))
+			}
+			pool := ""
+			if subscriptionQuota.ModelName != modelName {
+				pool = fmt.Sprintf("（%s）", subscriptionQuota.ModelName)
+			}
+			consumed = append(consumed, fmt.Sprintf("订阅 %d%s 抵扣 %s", subscription.Id, pool, FormatSubscriptionQuota(subscriptionQuota.Unit, amount)))
+		}
+	}
+
+	if len(consumed) == 0 {
+		// 订阅配额已用完，全部按余额计费
+		relayInfo.UsedSubscriptionQuota = false
+		common.LogInfo(ctx, fmt.Sprintf("用户 %d 订阅配额不足，回退到普通计费，模型: %s", relayInfo.UserId, modelName))
+		return quota, ""
+	}
+
+	remaining := uncoveredQuota(quota, uncovered)
+	common.LogInfo(ctx, fmt.Sprintf("用户 %d 使用订阅配额完成请求，订阅ID: %d，模型: %s，余额计费: %d",
+		relayInfo.UserId, relayInfo.SubscriptionId, modelName, remaining))
+	content := strings.Join(consumed, "，")
+	if remaining > 0 {
+		content += fmt.Sprintf("，超出订阅部分按余额计费 %s", common.LogQuota(remaining))
+	}
+	return remaining, content
+}
 
+// ReserveUsage 按预估用量原子预留订阅配额，返回是否有可用的订阅配额，以及预留未能覆盖、仍需按余额预扣的预估额度。
+// 预留记录保存在 relayInfo 中，请求完成后由 ConsumeUsage 结算，失败时由 ReleaseReservations 释放
+func (s *SubscriptionService) ReserveUsage(relayInfo *relaycommon.RelayInfo, tokens int, quota int) (bool, int, error) {
+	subscriptions, err := model.GetActiveUserSubscriptions(relayInfo.UserId)
+	if err != nil {
+		return false, quota, err
+	}
+
+	hasQuota := false
+	uncovered := decimal.NewFromInt(1)
+	for _, subscription := range subscriptions {
+		if !uncovered.IsPositive() {
+			break
+		}
 		if !subscription.IsActive() {
 			continue
 		}
-
-		// 获取该模型的配额信息
-		quotaInfo, err := subscription.GetModelQuotaInfo(modelName)
+		subscriptionQuotas, err := subscription.MatchQuotas(relayInfo.OriginModelName, relayInfo.UpstreamModelName)
 		if err != nil {
-			common.SysError(fmt.Sprintf("获取模型配额信息失败: %v", err))
 			continue
 		}
-
-		if quotaInfo.Remaining >= usageCount {
-			if availableSubscription == nil || quotaInfo.Remaining > maxQuota {
-				availableSubscription = subscription
-				maxQuota = quotaInfo.Remaining
+		for _, subscriptionQuota := range subscriptionQuotas {
+			if !uncovered.IsPositive() {
+				break
+			}
+			if subscriptionQuota.Remaining() <= 0 {
+				continue
+			}
+			hasQuota = true
+			amount, covered := subscriptionUsageAmount(subscriptionQuota.Unit, tokens, quota, uncovered, subscriptionQuota.Remaining())
+			if amount <= 0 {
+				// 无法预估用量（例如没有输入 token），请求完成后按实际用量直接扣减
+				continue
+			}
+			reservation, err := model.ReserveSubscriptionQuota(subscriptionQuota, amount)
+			if err != nil {
+				return hasQuota, uncoveredQuota(quota, uncovered), err
 			}
+			if reservation == nil {
+				// 并发请求已占用剩余配额
+				continue
+			}
+			relayInfo.SubscriptionReservationIds = append(relayInfo.SubscriptionReservationIds, reservation.Id)
+			uncovered = uncovered.Sub(covered)
 		}
 	}
+	return hasQuota, uncoveredQuota(quota, uncovered), nil
+}
 
-	if availableSubscription == nil {
-		// 没有足够配额的订阅，使用原有计费方式
-		return false, 0, nil
+// uncoveredQuota 计算订阅未能覆盖的比例对应的额度，向上取整
+func uncoveredQuota(quota int, uncovered decimal.Decimal) int {
+	if !uncovered.IsPositive() {
+		return 0
 	}
+	return int(decimal.NewFromInt(int64(quota)).Mul(uncovered).Ceil().IntPart())
+}
 
-	// 消费订阅配额
-	err = availableSubscription.ConsumeModelQuota(modelName, usageCount)
-	if err != nil {
-		common.SysError(fmt.Sprintf("消费订阅配额失败: %v", err))
-		return false, 0, nil // 不阻断请求，继续使用原有计费方式
+// ReleaseReservations 释放请求预留的订阅配额，用于请求失败或不按订阅计费的场景
+func (s *SubscriptionService) ReleaseReservations(relayInfo *relaycommon.RelayInfo) {
+	if len(relayInfo.SubscriptionReservationIds) == 0 {
+		return
 	}
-
-	// 记录使用记录
-	err = model.RecordSubscriptionUsage(userId, availableSubscription.Id, modelName, usageCount, 0, relayInfo.RequestId)
+	err := model.ReleaseSubscriptionQuotaReservations(relayInfo.SubscriptionReservationIds)
 	if err != nil {
-		common.SysError(fmt.Sprintf("记录订阅使用失败: %v", err))
-		// 这里不回滚配额消费，因为记录失败不应该影响用户使用
+		common.SysError(fmt.Sprintf("释放订阅配额预留失败: %v", err))
+		return
 	}
+	relayInfo.SubscriptionReservationIds = nil
+}
 
-	// 记录日志
-	model.RecordLog(userId, model.LogTypeConsumeQuota,
-		fmt.Sprintf("使用订阅配额: 模型 %s，次数 %d，订阅ID %d", modelName, usageCount, availableSubscription.Id))
-
-	// 设置RelayInfo标记（只在实际消费时设置）
-	if relayInfo != nil {
-		relayInfo.SubscriptionId = availableSubscription.Id
+// subscriptionUsageAmount 计算一个订阅在其计量单位下需要抵扣的数量，以及抵扣后覆盖的请求比例
+func subscriptionUsageAmount(unit string, tokens int, quota int, uncovered decimal.Decimal, remaining int) (int, decimal.Decimal) {
+	var full int
+	switch unit {
+	case model.SubscriptionQuotaUnitTokens:
+		full = tokens
+	case model.SubscriptionQuotaUnitQuota:
+		full = quota
+	default:
+		// 按次计费的请求不可拆分，一次抵扣覆盖剩余的全部用量
+		return 1, uncovered
+	}
+	if full <= 0 {
+		return 0, decimal.Zero
+	}
+	dFull := decimal.NewFromInt(int64(full))
+	needed := int(dFull.Mul(uncovered).Ceil().IntPart())
+	if needed <= remaining {
+		return needed, uncovered
 	}
+	return remaining, decimal.NewFromInt(int64(remaining)).Div(dFull)
+}
 
-	return true, usageCount, nil
+// FormatSubscriptionQuota 按计量单位格式化订阅配额数量
+func FormatSubscriptionQuota(unit string, amount int) string {
+	switch unit {
+	case model.SubscriptionQuotaUnitTokens:
+		return fmt.Sprintf("%d tokens", amount)
+	case model.SubscriptionQuotaUnitQuota:
+		return common.LogQuota(amount)
+	default:
+		return fmt.Sprintf("%d 次", amount)
+	}
 }
 
 // GetUserSubscriptionQuotas 获取用户所有订阅的配额信息
@@ -168,14 +302,15 @@ func (s *SubscriptionService) CheckModelQuotaAvailable(userId int, modelName str
 			continue
 		}
 
-		quotaInfo, err := subscription.GetModelQuotaInfo(modelName)
+		quotas, err := subscription.MatchQuotas(modelName)
 		if err != nil {
 			continue
 		}
-
-		totalQuota += quotaInfo.Total
-		totalUsed += quotaInfo.Used
-		totalRemaining += quotaInfo.Remaining
+		for _, quota := range quotas {
+			totalQuota += quota.Total
+			totalUsed += quota.Used
+			totalRemaining += quota.Remaining()
+		}
 	}
 
 	quotaInfo := &model.ModelQuotaInfo{
@@ -210,6 +345,10 @@ func (s *SubscriptionService) CleanupExpiredSubscriptions() error {
 
 	for _, subscription := range expiredSubscriptions {
 		subscription.Status = model.SubscriptionStatusExpired
+		if subscription.CancelAtPeriodEnd {
+			// 设置了到期取消的订阅到期后记为已取消
+			subscription.Status = model.SubscriptionStatusCanceled
+		}
 		err := subscription.Update()
 		if err != nil {
 			common.SysError(fmt.Sprintf("更新过期订阅状态失败: %v", err))
@@ -220,6 +359,91 @@ func (s *SubscriptionService) CleanupExpiredSubscriptions() error {
 	return nil
 }
 
+// GetSubscriptionQuotaPrice 将套餐价格（元）换算为账户额度
+func GetSubscriptionQuotaPrice(plan *model.SubscriptionPlan) int {
+	return subscriptionPriceToQuota(plan.Price)
+}
+
+// subscriptionPriceToQuota 将金额（元）按充值价格换算为账户额度，向上取整
+func subscriptionPriceToQuota(price float64) int {
+	if setting.Price <= 0 {
+		return 0
+	}
+	dPrice := decimal.NewFromFloat(price)
+	dUnitPrice := decimal.NewFromFloat(setting.Price)
+	dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
+	return int(dPrice.Div(dUnitPrice).Mul(dQuotaPerUnit).Ceil().IntPart())
+}
+
+// RenewDueSubscriptions 对即将到期且开启了自动续费的订阅从账户余额扣费续期。
+// 余额不足等失败会在下次调度时重试，首次失败时通知用户，返回成功续费的数量
+func (s *SubscriptionService) RenewDueSubscriptions() (int, error) {
+	subscriptionSetting := operation_setting.GetSubscriptionSetting()
+	if !subscriptionSetting.AutoRenewEnabled || subscriptionSetting.RenewBeforeHours <= 0 {
+		return 0, nil
+	}
+	before := time.Now().Add(time.Duration(subscriptionSetting.RenewBeforeHours) * time.Hour).Unix()
+	subscriptions, err := model.GetSubscriptionsDueForRenewal(before)
+	if err != nil {
+		return 0, err
+	}
+
+	renewed := 0
+	for _, subscription := range subscriptions {
+		if s.renewSubscription(subscription) {
+			renewed++
+		}
+	}
+	return renewed, nil
+}
+
+// renewSubscription 续费单个订阅，返回是否续费成功
+func (s *SubscriptionService) renewSubscription(subscription *model.UserSubscription) bool {
+	plan := subscription.SubscriptionPlan
+	if plan == nil {
+		common.SysError(fmt.Sprintf("订阅 %d 的套餐不存在，无法自动续费", subscription.Id))
+		return false
+	}
+	if plan.IsTrial {
+		// 试用套餐不支持续费，关闭在套餐设为试用前开启的自动续费
+		if err := model.SetUserSubscriptionAutoRenew(subscription.Id, subscription.UserId, false); err != nil {
+			common.SysError(fmt.Sprintf("关闭试用订阅 %d 的自动续费失败: %v", subscription.Id, err))
+		}
+		return false
+	}
+	var err error
+	quota := 0
+	if plan.Price > 0 && setting.Price <= 0 {
+		err = errors.New("管理员未配置充值价格")
+	} else {
+		quota = GetSubscriptionQuotaPrice(plan)
+		err = subscription.RenewWithQuota(quota)
+	}
+	if err == nil {
+		model.RecordLog(subscription.UserId, model.LogTypeSystem, fmt.Sprintf("自动续费订阅套餐: %s，价格: %.2f元，扣除额度: %s，新到期时间: %s",
+			plan.Name, plan.Price, common.LogQuota(quota), time.Unix(subscription.EndTime, 0).Format("2006-01-02 15:04:05")))
+		return true
+	}
+	if errors.Is(err, model.ErrSubscriptionRenewSkipped) {
+		return false
+	}
+
+	reason := err.Error()
+	if errors.Is(err, model.ErrInsufficientUserQuota) {
+		reason = fmt.Sprintf("余额不足，续费需要 %s", common.LogQuota(quota))
+	}
+	common.SysLog(fmt.Sprintf("订阅 %d 自动续费失败: %s", subscription.Id, reason))
+	failCount, countErr := subscription.IncreaseRenewFailCount()
+	if countErr != nil {
+		common.SysError(fmt.Sprintf("记录订阅 %d 续费失败次数失败: %v", subscription.Id, countErr))
+	}
+	if failCount == 1 {
+		model.RecordLog(subscription.UserId, model.LogTypeSystem, fmt.Sprintf("订阅套餐 %s 自动续费失败: %s", plan.Name, reason))
+		NotifySubscriptionRenewFailed(subscription.UserId, plan.Name, subscription.EndTime, reason)
+	}
+	return false
+}
+
 // SendQuotaWarning 发送配额预警
 func (s *SubscriptionService) SendQuotaWarning(userId int, modelName string, remaining int, total int) {
 	if total == 0 {
@@ -277,7 +501,11 @@ func (s *SubscriptionService) ValidateSubscriptionPlan(plan *model.SubscriptionP
 		}
 	}
 
-	return nil
+	groups, err := plan.GetModelGroupsMap()
+	if err != nil {
+		return err
+	}
+	return model.ValidateModelGroups(groups, quotas)
 }
 
 // GetSubscriptionSummary 获取订阅摘要信息
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// SubscriptionCancelOptions 取消订阅的参数
+type SubscriptionCancelOptions struct {
+	Immediate    bool     // 是否立即取消，否则到期后取消
+	RefundMethod string   // 立即取消时的退款方式
+	RefundMoney  *float64 // 管理员指定的退款金额（元），为空时按比例计算
+	Reason       string   // 取消原因
+	OperatorId   int      // 操作的管理员ID，用户自行取消时为0
+}
+
+// SubscriptionRefundQuote 立即取消订阅的退款报价
+type SubscriptionRefundQuote struct {
+	RefundMoney    float64  `json:"refund_money"`         // 按比例可退金额（元）
+	RefundQuota    int      `json:"refund_quota"`         // 退还到余额时的额度
+	RefundMethods  []string `json:"refund_methods"`       // 可选的退款方式
+	RefundEnabled  bool     `json:"refund_enabled"`       // 是否允许退款
+	CancelAtPeriod bool     `json:"cancel_at_period_end"` // 当前是否已设置到期取消
+}
+
+// QuoteSubscriptionRefund 按配置的折算方式计算立即取消订阅可退还的金额与额度
+func QuoteSubscriptionRefund(us *model.UserSubscription) (*SubscriptionRefundQuote, error) {
+	if !us.IsActive() {
+		return nil, errors.New("只能取消生效中的订阅")
+	}
+	subscriptionSetting := operation_setting.GetSubscriptionSetting()
+	quote := &SubscriptionRefundQuote{
+		RefundMethods:  subscriptionRefundMethods(us),
+		RefundEnabled:  subscriptionSetting.CancelRefundEnabled,
+		CancelAtPeriod: us.CancelAtPeriodEnd,
+	}
+	now := time.Now().Unix()
+	if _, err := us.RefreshQuotaPeriods(now); err != nil {
+		return nil, err
+	}
+	if us.PurchasePrice <= 0 && us.RenewedTermPrice <= 0 {
+		return quote, nil
+	}
+	fraction, err := subscriptionCreditFraction(us, subscriptionSetting.CancelRefundMode, now)
+	if err != nil {
+		return nil, err
+	}
+	quote.RefundMoney, quote.RefundQuota = subscriptionRefundAmount(us, fraction)
+	return quote, nil
+}
+
+// subscriptionRefundAmount 按比例计算退款金额（元，向下取整到分）及对应的余额额度，
+// 已提前续费但尚未开始的下一期全额退还
+func subscriptionRefundAmount(us *model.UserSubscription, fraction decimal.Decimal) (float64, int) {
+	money, _ := decimal.NewFromFloat(us.PurchasePrice).Mul(fraction).
+		Add(decimal.NewFromFloat(us.RenewedTermPrice)).RoundFloor(2).Float64()
+	quota := subscriptionCreditQuota(us, fraction)
+	return money, quota
+}
+
+// subscriptionCreditQuota 按比例折算本期价格对应的余额额度，加上已提前续费的下一期价格
+func subscriptionCreditQuota(us *model.UserSubscription, fraction decimal.Decimal) int {
+	credit := fraction.Mul(decimal.NewFromInt(int64(subscriptionPriceToQuota(us.PurchasePrice)))).Floor().IntPart()
+	return int(credit) + subscriptionPriceToQuota(us.RenewedTermPrice)
+}
+
+// subscriptionRefundMethods 用户可选的退款方式，在线支付的订阅可以原路退款
+func subscriptionRefundMethods(us *model.UserSubscription) []string {
+	methods := []string{model.SubscriptionRefundBalance}
+	if us.PaymentMethod != "" && us.PaymentMethod != model.SubscriptionPaymentBalance && us.PaymentMethod != model.SubscriptionPaymentRedemption {
+		methods = append(methods, model.SubscriptionRefundProvider)
+	}
+	return methods
+}
+
+// CancelSubscription 取消订阅。userId 不为0时只能取消该用户自己的订阅，
+// 管理员取消（OperatorId 不为0）时可以指定退款金额或记录线下手动退款
+func CancelSubscription(userId int, subscriptionId int, opts SubscriptionCancelOptions) (*model.UserSubscription, *model.SubscriptionCancellation, error) {
+	subscriptionSetting := operation_setting.GetSubscriptionSetting()
+	isAdmin := opts.OperatorId != 0
+	if !isAdmin && !subscriptionSetting.CancelEnabled {
+		return nil, nil, errors.New("当前未开启取消订阅")
+	}
+	subscription, err := model.GetUserSubscriptionById(subscriptionId)
+	if err != nil || (userId != 0 && subscription.UserId != userId) {
+		return nil, nil, errors.New("订阅不存在")
+	}
+	if !subscription.IsActive() {
+		return nil, nil, errors.New("只能取消生效中的订阅")
+	}
+
+	planName := ""
+	if subscription.SubscriptionPlan != nil {
+		planName = subscription.SubscriptionPlan.Name
+	}
+	record := &model.SubscriptionCancellation{
+		UserId:             subscription.UserId,
+		UserSubscriptionId: subscription.Id,
+		PlanId:             subscription.SubscriptionPlanId,
+		PlanName:           planName,
+		OperatorId:         opts.OperatorId,
+		Reason:             opts.Reason,
+	}
+
+	if !opts.Immediate {
+		if subscription.CancelAtPeriodEnd {
+			return nil, nil, errors.New("订阅已设置到期取消")
+		}
+		if err := model.ScheduleUserSubscriptionCancel(subscription, record); err != nil {
+			return nil, nil, err
+		}
+		recordSubscriptionCancelLog(subscription, record, fmt.Sprintf("订阅套餐 %s 将于 %s 到期后取消",
+			planName, time.Unix(subscription.EndTime, 0).Format("2006-01-02 15:04:05")))
+		subscription.User = nil
+		return subscription, record, nil
+	}
+
+	if err := fillSubscriptionRefund(subscription, record, opts, isAdmin); err != nil {
+		return nil, nil, err
+	}
+	if err := model.CancelUserSubscriptionNow(subscription, record); err != nil {
+		return nil, nil, err
+	}
+
+	settlement := "不退款"
+	switch record.RefundMethod {
+	case model.SubscriptionRefundBalance:
+		settlement = "退还额度: " + common.LogQuota(record.RefundQuota)
+	case model.SubscriptionRefundProvider:
+		settlement = fmt.Sprintf("原路退款 %.2f 元，待管理员处理", record.RefundMoney)
+	case model.SubscriptionRefundManual:
+		settlement = fmt.Sprintf("管理员已手动退款 %.2f 元", record.RefundMoney)
+	}
+	recordSubscriptionCancelLog(subscription, record, fmt.Sprintf("立即取消订阅套餐 %s，%s", planName, settlement))
+	subscription.User = nil
+	return subscription, record, nil
+}
+
+// fillSubscriptionRefund 校验退款方式并计算立即取消时的退款金额
+func fillSubscriptionRefund(us *model.UserSubscription, record *model.SubscriptionCancellation, opts SubscriptionCancelOptions, isAdmin bool) error {
+	method := opts.RefundMethod
+	if method == "" {
+		method = model.SubscriptionRefundBalance
+	}
+	if !isAdmin && !operation_setting.GetSubscriptionSetting().CancelRefundEnabled {
+		method = model.SubscriptionRefundNone
+	}
+	switch method {
+	case model.SubscriptionRefundNone:
+		record.RefundMethod = method
+		return nil
+	case model.SubscriptionRefundBalance:
+	case model.SubscriptionRefundProvider:
+		if us.PaymentMethod == "" || us.PaymentMethod == model.SubscriptionPaymentBalance {
+			return errors.New("该订阅使用余额购买，只能退款到余额")
+		}
+		if us.PaymentMethod == model.SubscriptionPaymentRedemption {
+			return errors.New("该订阅通过兑换码获得，无法原路退款")
+		}
+	case model.SubscriptionRefundManual:
+		if !isAdmin {
+			return errors.New("无效的退款方式")
+		}
+	default:
+		return errors.New("无效的退款方式")
+	}
+	record.RefundMethod = method
+
+	if isAdmin && opts.RefundMoney != nil {
+		money := *opts.RefundMoney
+		paid := us.PurchasePrice + us.RenewedTermPrice
+		if money < 0 || money > paid {
+			return fmt.Errorf("退款金额必须在 0 到 %.2f 元之间", paid)
+		}
+		record.RefundMoney = decimal.NewFromFloat(money).Round(2).InexactFloat64()
+		record.RefundQuota = subscriptionPriceToQuota(record.RefundMoney)
+	} else {
+		quote, err := QuoteSubscriptionRefund(us)
+		if err != nil {
+			return err
+		}
+		record.RefundMoney = quote.RefundMoney
+		record.RefundQuota = quote.RefundQuota
+	}
+	if method == model.SubscriptionRefundBalance && record.RefundMoney > 0 && setting.Price <= 0 {
+		return errors.New("当前管理员未配置充值价格，无法退款到余额")
+	}
+	return nil
+}
+
+// ResumeSubscription 撤销订阅的到期取消
+func ResumeSubscription(userId int, subscriptionId int, operatorId int) (*model.UserSubscription, error) {
+	subscription, err := model.GetUserSubscriptionById(subscriptionId)
+	if err != nil || (userId != 0 && subscription.UserId != userId) {
+		return nil, errors.New("订阅不存在")
+	}
+	if !subscription.IsActive() || !subscription.CancelAtPeriodEnd {
+		return nil, errors.New("订阅未设置到期取消")
+	}
+	planName := ""
+	if subscription.SubscriptionPlan != nil {
+		planName = subscription.SubscriptionPlan.Name
+	}
+	record := &model.SubscriptionCancellation{
+		UserId:             subscription.UserId,
+		UserSubscriptionId: subscription.Id,
+		PlanId:             subscription.SubscriptionPlanId,
+		PlanName:           planName,
+		OperatorId:         operatorId,
+	}
+	if err := model.ResumeUserSubscriptionCancel(subscription, record); err != nil {
+		return nil, err
+	}
+	recordSubscriptionCancelLog(subscription, record, fmt.Sprintf("撤销订阅套餐 %s 的到期取消", planName))
+	subscription.User = nil
+	return subscription, nil
+}
+
+// recordSubscriptionCancelLog 记录取消相关的用户日志，管理员操作记为管理日志
+func recordSubscriptionCancelLog(us *model.UserSubscription, record *model.SubscriptionCancellation, content string) {
+	if record.Reason != "" {
+		content += "，原因: " + record.Reason
+	}
+	if record.OperatorId != 0 {
+		model.RecordLog(us.UserId, model.LogTypeManage, fmt.Sprintf("管理员(ID: %d)%s", record.OperatorId, content))
+		return
+	}
+	model.RecordLog(us.UserId, model.LogTypeSystem, content)
+}
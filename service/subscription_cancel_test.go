@@ -0,0 +1,54 @@
+package service
+
+import (
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSubscriptionRefundAmount(t *testing.T) {
+	const day = int64(24 * 3600)
+	now := int64(100 * day)
+	tests := []struct {
+		name      string
+		us        *model.UserSubscription
+		wantMoney float64
+	}{
+		{
+			name:      "half of the current term left",
+			us:        &model.UserSubscription{StartTime: now - 15*day, EndTime: now + 15*day, PurchasePrice: 10},
+			wantMoney: 5,
+		},
+		{
+			name:      "renewed term restarts the proration",
+			us:        &model.UserSubscription{StartTime: now - 45*day, TermStartTime: now - 15*day, EndTime: now + 15*day, PurchasePrice: 10},
+			wantMoney: 5,
+		},
+		{
+			name: "prepaid renewal not started yet is refunded in full",
+			us: &model.UserSubscription{StartTime: now - 20*day, EndTime: now + 40*day, PurchasePrice: 10,
+				RenewedTermStart: now + 10*day, RenewedTermPrice: 10},
+			wantMoney: 10 + 10.0/3,
+		},
+		{
+			name:      "expired term",
+			us:        &model.UserSubscription{StartTime: now - 30*day, EndTime: now, PurchasePrice: 10},
+			wantMoney: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fraction, err := subscriptionCreditFraction(tt.us, operation_setting.PlanChangeCreditByTime, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			money, _ := subscriptionRefundAmount(tt.us, fraction)
+			want, _ := decimal.NewFromFloat(tt.wantMoney).RoundFloor(2).Float64()
+			if money != want {
+				t.Fatalf("refund money = %v, want %v", money, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// SubscriptionPlanChangeQuote 套餐变更报价，包含待保存的变更记录及变更后的配额
+type SubscriptionPlanChangeQuote struct {
+	Change      *model.SubscriptionPlanChange `json:"change"`
+	ModelQuotas model.ModelQuotaMap           `json:"model_quotas"` // 变更后的剩余配额
+	UsedQuotas  model.ModelQuotaMap           `json:"used_quotas"`  // 变更后的已用配额
+}
+
+// QuoteSubscriptionPlanChange 计算将订阅变更为新套餐的费用：原套餐按配置的折算方式抵扣，
+// 新套餐按全价计费并从变更时开始新的一期，差额从余额扣除或退还
+func QuoteSubscriptionPlanChange(us *model.UserSubscription, plan *model.SubscriptionPlan) (*SubscriptionPlanChangeQuote, error) {
+	subscriptionSetting := operation_setting.GetSubscriptionSetting()
+	if !subscriptionSetting.PlanChangeEnabled {
+		return nil, errors.New("当前未开启套餐变更")
+	}
+	if !us.IsActive() {
+		return nil, errors.New("只能变更生效中的订阅")
+	}
+	if us.SubscriptionPlanId == plan.Id {
+		return nil, errors.New("新套餐与当前套餐相同")
+	}
+	if !plan.IsActive() {
+		return nil, errors.New("套餐未启用")
+	}
+	if plan.IsTrial {
+		return nil, errors.New("不能变更为试用套餐")
+	}
+	eligibility, err := CheckSubscriptionEligibility(us.UserId, plan)
+	if err != nil {
+		return nil, err
+	}
+	if !eligibility.Eligible {
+		return nil, errors.New(eligibility.Reason)
+	}
+	if (plan.Price > 0 || us.PurchasePrice > 0 || us.RenewedTermPrice > 0) && setting.Price <= 0 {
+		return nil, errors.New("当前管理员未配置充值价格，无法变更套餐")
+	}
+
+	now := time.Now().Unix()
+	if _, err := us.RefreshQuotaPeriods(now); err != nil {
+		return nil, err
+	}
+
+	fraction, err := subscriptionCreditFraction(us, subscriptionSetting.PlanChangeCreditMode, now)
+	if err != nil {
+		return nil, err
+	}
+	credit := subscriptionCreditQuota(us, fraction)
+	cost := GetSubscriptionQuotaPrice(plan)
+	amount := cost - credit
+	if amount < 0 && !subscriptionSetting.PlanChangeRefundEnabled {
+		amount = 0
+	}
+
+	modelQuotas, usedQuotas, err := carryOverSubscriptionUsage(us, plan, subscriptionSetting.PlanChangeUsageMode)
+	if err != nil {
+		return nil, err
+	}
+
+	fromPlanName := ""
+	if us.SubscriptionPlan != nil {
+		fromPlanName = us.SubscriptionPlan.Name
+	}
+	return &SubscriptionPlanChangeQuote{
+		Change: &model.SubscriptionPlanChange{
+			UserId:             us.UserId,
+			UserSubscriptionId: us.Id,
+			FromPlanId:         us.SubscriptionPlanId,
+			FromPlanName:       fromPlanName,
+			ToPlanId:           plan.Id,
+			ToPlanName:         plan.Name,
+			Credit:             credit,
+			Cost:               cost,
+			Amount:             amount,
+			CreditMode:         subscriptionSetting.PlanChangeCreditMode,
+			UsageMode:          subscriptionSetting.PlanChangeUsageMode,
+			OldEndTime:         us.EndTime,
+			NewEndTime:         now + int64(plan.Duration*24*3600),
+		},
+		ModelQuotas: modelQuotas,
+		UsedQuotas:  usedQuotas,
+	}, nil
+}
+
+// subscriptionCreditFraction 计算当前付费周期可折算的比例（0~1）。
+// 按时间折算时只计算当前付费周期，提前续费的下一期另行全额折算（见 subscriptionCreditQuota）
+func subscriptionCreditFraction(us *model.UserSubscription, mode string, now int64) (decimal.Decimal, error) {
+	timeFraction := decimal.Zero
+	termStart := us.GetTermStartTime()
+	termEnd := us.GetTermEndTime()
+	if termEnd > termStart && termEnd > now {
+		timeFraction = decimal.NewFromInt(termEnd - now).Div(decimal.NewFromInt(termEnd - termStart))
+		if timeFraction.GreaterThan(decimal.NewFromInt(1)) {
+			timeFraction = decimal.NewFromInt(1)
+		}
+	}
+	if mode != operation_setting.PlanChangeCreditByQuota && mode != operation_setting.PlanChangeCreditByMin {
+		return timeFraction, nil
+	}
+
+	quotaFraction, ok, err := subscriptionRemainingFraction(us)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if !ok {
+		// 套餐没有配置任何模型配额时只能按时间折算
+		return timeFraction, nil
+	}
+	if mode == operation_setting.PlanChangeCreditByMin && timeFraction.LessThan(quotaFraction) {
+		return timeFraction, nil
+	}
+	return quotaFraction, nil
+}
+
+// subscriptionRemainingFraction 计算各模型剩余配额占本期配额比例的平均值
+func subscriptionRemainingFraction(us *model.UserSubscription) (decimal.Decimal, bool, error) {
+	if err := us.LoadQuotas(); err != nil {
+		return decimal.Zero, false, err
+	}
+	sum := decimal.Zero
+	count := 0
+	for _, quota := range us.Quotas {
+		if quota.Total <= 0 {
+			continue
+		}
+		sum = sum.Add(decimal.NewFromInt(int64(quota.Remaining())).Div(decimal.NewFromInt(int64(quota.Total))))
+		count++
+	}
+	if count == 0 {
+		return decimal.Zero, false, nil
+	}
+	return sum.Div(decimal.NewFromInt(int64(count))), true, nil
+}
+
+// carryOverSubscriptionUsage 按配置计算变更后的剩余与已用配额。
+// carry 模式下计量单位相同的模型沿用原订阅的已用量，其余模型按新套餐配额全部发放
+func carryOverSubscriptionUsage(us *model.UserSubscription, plan *model.SubscriptionPlan, mode string) (model.ModelQuotaMap, model.ModelQuotaMap, error) {
+	planQuotas, err := plan.GetModelQuotasMap()
+	if err != nil {
+		return nil, nil, err
+	}
+	modelQuotas := make(model.ModelQuotaMap, len(planQuotas))
+	usedQuotas := make(model.ModelQuotaMap)
+	for modelName, total := range planQuotas {
+		modelQuotas[modelName] = total
+	}
+	if mode != operation_setting.PlanChangeUsageCarry {
+		return modelQuotas, usedQuotas, nil
+	}
+
+	newUnits, err := plan.GetQuotaUnitsMap()
+	if err != nil {
+		return nil, nil, err
+	}
+	for modelName, total := range planQuotas {
+		oldQuota, err := us.GetQuota(modelName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if oldQuota == nil || oldQuota.Used <= 0 || oldQuota.Unit != newUnits.GetUnit(modelName) {
+			continue
+		}
+		used := oldQuota.Used
+		usedQuotas[modelName] = used
+		remaining := total - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		modelQuotas[modelName] = remaining
+	}
+	return modelQuotas, usedQuotas, nil
+}
+
+// ChangeSubscriptionPlan 将用户的订阅升级或降级为新套餐，并记录变更历史
+func ChangeSubscriptionPlan(userId int, subscriptionId int, planId int) (*model.UserSubscription, *model.SubscriptionPlanChange, error) {
+	subscription, err := model.GetUserSubscriptionById(subscriptionId)
+	if err != nil || subscription.UserId != userId {
+		return nil, nil, errors.New("订阅不存在")
+	}
+	plan, err := model.GetSubscriptionPlanById(planId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取套餐信息失败: %v", err)
+	}
+	quote, err := QuoteSubscriptionPlanChange(subscription, plan)
+	if err != nil {
+		return nil, nil, err
+	}
+	change := quote.Change
+	err = model.ChangeUserSubscriptionPlan(subscription, plan, change, quote.UsedQuotas)
+	if err != nil {
+		if errors.Is(err, model.ErrInsufficientUserQuota) {
+			return nil, nil, fmt.Errorf("余额不足，变更套餐需要补差价 %s", common.LogQuota(change.Amount))
+		}
+		if errors.Is(err, model.ErrSubscriptionPurchaseLimit) {
+			return nil, nil, errors.New(plan.GetPurchaseLimitReason())
+		}
+		return nil, nil, err
+	}
+
+	settlement := "无需补差价"
+	if change.Amount > 0 {
+		settlement = "扣除额度: " + common.LogQuota(change.Amount)
+	} else if change.Amount < 0 {
+		settlement = "退还额度: " + common.LogQuota(-change.Amount)
+	}
+	model.RecordLog(userId, model.LogTypeSystem, fmt.Sprintf("订阅套餐变更: %s -> %s，原套餐折算: %s，新套餐价格: %s，%s",
+		change.FromPlanName, change.ToPlanName, common.LogQuota(change.Credit), common.LogQuota(change.Cost), settlement))
+	subscription.User = nil
+	return subscription, change, nil
+}
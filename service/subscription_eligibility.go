@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+)
+
+// CheckSubscriptionEligibility 检查用户是否满足套餐的购买条件：用户分组、注册时间、邮箱与第三方账号绑定以及限购次数
+func CheckSubscriptionEligibility(userId int, plan *model.SubscriptionPlan) (*model.SubscriptionPlanEligibility, error) {
+	eligibility := &model.SubscriptionPlanEligibility{Remaining: -1}
+	if !plan.HasPurchaseRestriction() {
+		eligibility.Eligible = true
+		return eligibility, nil
+	}
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := plan.GetPurchaseLimit()
+	if limit > 0 {
+		purchased, err := model.CountUserPlanPurchases(userId, plan.Id)
+		if err != nil {
+			return nil, err
+		}
+		eligibility.Purchased = purchased
+		eligibility.Remaining = limit - purchased
+		if eligibility.Remaining < 0 {
+			eligibility.Remaining = 0
+		}
+	}
+
+	groups, err := plan.GetAllowedGroups()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(groups) > 0 && !common.StringsContains(groups, user.Group):
+		eligibility.Reason = "当前用户分组不能购买该套餐"
+	case plan.NewUserDays > 0 && (user.CreatedTime == 0 || common.GetTimestamp()-user.CreatedTime > int64(plan.NewUserDays*24*3600)):
+		eligibility.Reason = fmt.Sprintf("该套餐仅限注册 %d 天内的新用户购买", plan.NewUserDays)
+	case plan.RequireEmail && user.Email == "":
+		eligibility.Reason = "购买该套餐需要先绑定邮箱"
+	case plan.RequireOAuth && !hasOAuthBinding(user):
+		eligibility.Reason = "购买该套餐需要先绑定第三方账号"
+	case limit > 0 && eligibility.Remaining == 0:
+		eligibility.Reason = plan.GetPurchaseLimitReason()
+	default:
+		eligibility.Eligible = true
+	}
+	return eligibility, nil
+}
+
+// hasOAuthBinding 用户是否绑定了任意第三方账号
+func hasOAuthBinding(user *model.User) bool {
+	return user.GitHubId != "" || user.OidcId != "" || user.WeChatId != "" || user.TelegramId != "" || user.LinuxDOId != ""
+}
+
+// FillSubscriptionEligibility 为套餐列表填充用户的购买资格
+func FillSubscriptionEligibility(userId int, plans []*model.SubscriptionPlan) error {
+	for _, plan := range plans {
+		eligibility, err := CheckSubscriptionEligibility(userId, plan)
+		if err != nil {
+			return err
+		}
+		plan.Eligibility = eligibility
+	}
+	return nil
+}
+
+// EnsureSubscriptionEligibility 检查购买资格，资格不满足时返回原因。
+// 限购次数在下单事务中还会锁定用户行再次校验，并发下单不会超出限购
+func EnsureSubscriptionEligibility(userId int, plan *model.SubscriptionPlan) error {
+	eligibility, err := CheckSubscriptionEligibility(userId, plan)
+	if err != nil {
+		return err
+	}
+	if !eligibility.Eligible {
+		return errors.New(eligibility.Reason)
+	}
+	return nil
+}
@@ -64,7 +64,7 @@ func (s *SubscriptionMonitorService) checkSubscriptionQuotaWarning(subscription
 	}
 	
 	// 检查每个模型的配额使用情况
-	for modelName, totalQuota := range planQuotas {
+	for modelName := range planQuotas {
 		quotaInfo, err := subscription.GetModelQuotaInfo(modelName)
 		if err != nil {
 			continue
@@ -117,7 +117,9 @@ func (s *SubscriptionMonitorService) GenerateSubscriptionReport(startTime, endTi
 	
 	// 获取时间范围内的订阅数据
 	var subscriptions []*model.UserSubscription
-	query := model.DB.Preload("User").Preload("SubscriptionPlan")
+	// 未完成支付的订单不计入销售
+	query := model.DB.Preload("User").Preload("SubscriptionPlan").
+		Where("status NOT IN ?", []int{model.SubscriptionStatusPending, model.SubscriptionStatusPaymentExpired})
 	
 	if startTime > 0 {
 		query = query.Where("created_time >= ?", startTime)
@@ -276,6 +278,7 @@ func (s *SubscriptionMonitorService) GetSystemSubscriptionStats() (*SystemSubscr
 	err = model.DB.Table("user_subscriptions").
 		Select("subscription_plan_id as plan_id, subscription_plans.name as plan_name, COUNT(*) as count, SUM(purchase_price) as revenue").
 		Joins("LEFT JOIN subscription_plans ON user_subscriptions.subscription_plan_id = subscription_plans.id").
+		Where("user_subscriptions.status NOT IN ?", []int{model.SubscriptionStatusPending, model.SubscriptionStatusPaymentExpired}).
 		Group("subscription_plan_id, subscription_plans.name").
 		Scan(&planResults).Error
 	if err != nil {
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"fmt"
 	"one-api/common"
 	"one-api/model"
+	"one-api/setting/operation_setting"
 	"time"
 )
 
@@ -62,12 +64,41 @@ func (s *SubscriptionScheduler) runMonitorTasks() {
 		common.SysError("监控订阅配额失败: " + err.Error())
 	}
 	
-	// 2. 清理过期订阅
+	// 2. 为即将到期且开启自动续费的订阅续费，仅在主节点执行以免重复通知
+	if common.IsMasterNode {
+		renewed, err := s.monitorService.subscriptionService.RenewDueSubscriptions()
+		if err != nil {
+			common.SysError("自动续费订阅失败: " + err.Error())
+		} else if renewed > 0 {
+			common.SysLog(fmt.Sprintf("自动续费了 %d 个订阅", renewed))
+		}
+	}
+	
+	// 3. 清理过期订阅
 	err = s.monitorService.CleanupExpiredSubscriptions()
 	if err != nil {
 		common.SysError("清理过期订阅失败: " + err.Error())
 	}
 	
+	// 4. 重置已进入新周期的订阅配额，并开始已到达的提前续费周期（读取订阅时也会按需处理）
+	count, err := model.ResetSubscriptionQuotaPeriods()
+	if err != nil {
+		common.SysError("重置订阅周期配额失败: " + err.Error())
+	} else if count > 0 {
+		common.SysLog(fmt.Sprintf("重置了 %d 个订阅的周期配额", count))
+	}
+	
+	// 5. 回收超时未结算的订阅配额预留（例如节点在请求过程中重启）
+	timeoutMinutes := operation_setting.GetSubscriptionSetting().ReservationTimeoutMinutes
+	if timeoutMinutes > 0 {
+		reaped, err := model.ReapExpiredSubscriptionReservations(time.Now().Add(-time.Duration(timeoutMinutes) * time.Minute).Unix())
+		if err != nil {
+			common.SysError("回收订阅配额预留失败: " + err.Error())
+		} else if reaped > 0 {
+			common.SysLog(fmt.Sprintf("回收了 %d 个超时的订阅配额预留", reaped))
+		}
+	}
+	
 	common.SysLog("订阅监控任务执行完成")
 }
 
@@ -107,3 +138,22 @@ func (s *SubscriptionScheduler) StartDailyScheduler() {
 		}
 	}()
 }
+
+// ExpirePendingSubscriptionOrders 定期将超过支付有效期仍未支付的订阅订单标记为支付超时
+func ExpirePendingSubscriptionOrders() {
+	for {
+		time.Sleep(time.Minute)
+		expireMinutes := operation_setting.GetSubscriptionSetting().PaymentExpireMinutes
+		if expireMinutes <= 0 {
+			continue
+		}
+		count, err := model.ExpirePendingSubscriptions(time.Now().Add(-time.Duration(expireMinutes) * time.Minute).Unix())
+		if err != nil {
+			common.SysError("failed to expire pending subscriptions: " + err.Error())
+			continue
+		}
+		if count > 0 {
+			common.SysLog(fmt.Sprintf("%d 个订阅订单超时未支付", count))
+		}
+	}
+}
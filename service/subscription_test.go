@@ -0,0 +1,129 @@
+package service
+
+import (
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupSubscriptionTestDB 使用内存 SQLite 作为数据库，并关闭 Redis 缓存
+func setupSubscriptionTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	// 内存数据库每个连接相互独立，只保留一个连接
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	err = db.AutoMigrate(&model.User{}, &model.Log{}, &model.SubscriptionPlan{}, &model.UserSubscription{},
+		&model.SubscriptionQuota{}, &model.SubscriptionQuotaReservation{}, &model.SubscriptionUsage{})
+	if err != nil {
+		t.Fatalf("migrate test db: %v", err)
+	}
+	redisEnabled := common.RedisEnabled
+	common.RedisEnabled = false
+	model.DB, model.LOG_DB = db, db
+	t.Cleanup(func() { common.RedisEnabled = redisEnabled })
+}
+
+func TestSubscriptionReserveAndConsumeUsage(t *testing.T) {
+	tests := []struct {
+		name          string
+		unit          string // 为空表示按请求次数
+		total         int
+		estimateToken int
+		estimateQuota int
+		usedTokens    int
+		usedQuota     int
+		wantUncovered int // 预扣时仍需按余额预扣的额度
+		wantSpill     int // 结算后按余额计费的额度
+		wantUsed      int
+	}{
+		{name: "quota unit fully covered", unit: "quota", total: 1000, estimateToken: 100, estimateQuota: 400,
+			usedTokens: 150, usedQuota: 600, wantUsed: 600},
+		{name: "quota unit partly covered", unit: "quota", total: 300, estimateToken: 100, estimateQuota: 400,
+			usedTokens: 150, usedQuota: 600, wantUncovered: 100, wantSpill: 300, wantUsed: 300},
+		{name: "completion spills beyond token quota", unit: "tokens", total: 1000, estimateToken: 500, estimateQuota: 1000,
+			usedTokens: 1500, usedQuota: 3000, wantSpill: 1000, wantUsed: 1000},
+		{name: "token estimate beyond quota", unit: "tokens", total: 250, estimateToken: 1000, estimateQuota: 2000,
+			usedTokens: 200, usedQuota: 400, wantUncovered: 1500, wantUsed: 200},
+		{name: "request unit covers the whole request", total: 10, estimateToken: 100, estimateQuota: 400,
+			usedTokens: 5000, usedQuota: 20000, wantUsed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupSubscriptionTestDB(t)
+			us := createServiceTestSubscription(t, tt.unit, tt.total)
+			relayInfo := &relaycommon.RelayInfo{UserId: us.UserId, OriginModelName: "gpt-4", UpstreamModelName: "gpt-4"}
+			s := NewSubscriptionService()
+
+			hasQuota, uncovered, err := s.ReserveUsage(relayInfo, tt.estimateToken, tt.estimateQuota)
+			if err != nil || !hasQuota {
+				t.Fatalf("reserve: hasQuota=%v err=%v", hasQuota, err)
+			}
+			if uncovered != tt.wantUncovered {
+				t.Fatalf("uncovered estimate = %d, want %d", uncovered, tt.wantUncovered)
+			}
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+			spill, _ := s.ConsumeUsage(c, relayInfo, tt.usedTokens, tt.usedQuota)
+			if spill != tt.wantSpill {
+				t.Fatalf("spill = %d, want %d", spill, tt.wantSpill)
+			}
+			var quota model.SubscriptionQuota
+			if err := model.DB.Where("user_subscription_id = ?", us.Id).First(&quota).Error; err != nil {
+				t.Fatalf("get quota: %v", err)
+			}
+			if quota.Used != tt.wantUsed || quota.Reserved != 0 {
+				t.Fatalf("used = %d reserved = %d, want used %d and nothing reserved", quota.Used, quota.Reserved, tt.wantUsed)
+			}
+		})
+	}
+}
+
+func TestSubscriptionReserveUsageWithoutSubscription(t *testing.T) {
+	setupSubscriptionTestDB(t)
+	relayInfo := &relaycommon.RelayInfo{UserId: 1, OriginModelName: "gpt-4"}
+	hasQuota, uncovered, err := NewSubscriptionService().ReserveUsage(relayInfo, 100, 400)
+	if err != nil || hasQuota || uncovered != 400 {
+		t.Fatalf("hasQuota=%v uncovered=%d err=%v, want the whole estimate uncovered", hasQuota, uncovered, err)
+	}
+}
+
+// createServiceTestSubscription 创建一个只包含 gpt-4 配额的生效订阅
+func createServiceTestSubscription(t *testing.T, unit string, total int) *model.UserSubscription {
+	t.Helper()
+	user := &model.User{Username: "tester", Status: common.UserStatusEnabled}
+	if err := model.DB.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	plan := &model.SubscriptionPlan{Name: "plan", Price: 10, Duration: 30, Status: 1}
+	if err := plan.SetModelQuotasMap(model.ModelQuotaMap{"gpt-4": total}); err != nil {
+		t.Fatalf("set plan quotas: %v", err)
+	}
+	if unit != "" {
+		if err := plan.SetQuotaUnitsMap(model.QuotaUnitMap{"gpt-4": unit}); err != nil {
+			t.Fatalf("set plan units: %v", err)
+		}
+	}
+	if err := model.DB.Create(plan).Error; err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	us, err := model.CreateUserSubscription(user.Id, plan.Id, model.SubscriptionPaymentBalance, "")
+	if err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	return us
+}
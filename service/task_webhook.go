@@ -0,0 +1,184 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskWebhookPayload 异步任务状态变化时推送给 callback_url 的内容
+type TaskWebhookPayload struct {
+	EventId   string `json:"event_id"` // 同一事件重试投递时不变，可用于去重
+	Type      string `json:"type"`
+	Platform  string `json:"platform"`
+	TaskId    string `json:"task_id"`
+	Status    string `json:"status"`
+	Data      any    `json:"data"` // 与任务查询接口返回的任务内容一致
+	Timestamp int64  `json:"timestamp"`
+}
+
+const TaskWebhookEventStatusChanged = "task.status_changed"
+
+var taskWebhookWakeup = make(chan struct{}, 1)
+
+// ValidateTaskCallbackURL 校验回调地址
+func ValidateTaskCallbackURL(callbackURL string) error {
+	if len(callbackURL) > 1024 {
+		return errors.New("callback_url is too long")
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("callback_url must be an http or https url")
+	}
+	return nil
+}
+
+// GetTaskCallbackURL 获取任务的回调地址：请求体中的 callback_url 优先，其次为令牌的默认回调地址
+func GetTaskCallbackURL(c *gin.Context) (string, error) {
+	var request struct {
+		CallbackURL string `json:"callback_url"`
+	}
+	if requestBody, err := common.GetRequestBody(c); err == nil && len(requestBody) > 0 {
+		_ = common.DecodeJson(requestBody, &request)
+	}
+	callbackURL := request.CallbackURL
+	if callbackURL == "" {
+		callbackURL = c.GetString("token_callback_url")
+	}
+	if callbackURL == "" {
+		return "", nil
+	}
+	if err := ValidateTaskCallbackURL(callbackURL); err != nil {
+		return "", err
+	}
+	return callbackURL, nil
+}
+
+// EnqueueTaskWebhook 记录一次任务状态推送，由投递协程发送并在失败时重试
+func EnqueueTaskWebhook(userId int, callbackURL string, platform constant.TaskPlatform, taskId string, status string, data any) {
+	if callbackURL == "" || !operation_setting.GetTaskWebhookSetting().Enabled {
+		return
+	}
+	payload := TaskWebhookPayload{
+		EventId:   common.GetUUID(),
+		Type:      TaskWebhookEventStatusChanged,
+		Platform:  string(platform),
+		TaskId:    taskId,
+		Status:    status,
+		Data:      data,
+		Timestamp: time.Now().Unix(),
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		common.SysError("failed to marshal task webhook payload: " + err.Error())
+		return
+	}
+	delivery := &model.TaskWebhookDelivery{
+		UserId:        userId,
+		EventId:       payload.EventId,
+		Platform:      payload.Platform,
+		TaskId:        taskId,
+		TaskStatus:    status,
+		CallbackURL:   callbackURL,
+		Payload:       string(payloadBytes),
+		Status:        model.TaskWebhookStatusPending,
+		NextAttemptAt: payload.Timestamp,
+	}
+	if err := delivery.Insert(); err != nil {
+		common.SysError("failed to insert task webhook delivery: " + err.Error())
+		return
+	}
+	select {
+	case taskWebhookWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// RunTaskWebhookDelivery 投递待发送的任务回调，新记录入队时立即投递，重试按退避时间进行
+func RunTaskWebhookDelivery() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-taskWebhookWakeup:
+		}
+		deliveries, err := model.GetDueTaskWebhookDeliveries(time.Now().Unix(), 100)
+		if err != nil {
+			common.SysError("failed to get task webhook deliveries: " + err.Error())
+			continue
+		}
+		for _, delivery := range deliveries {
+			deliverTaskWebhook(delivery)
+		}
+	}
+}
+
+func deliverTaskWebhook(delivery *model.TaskWebhookDelivery) {
+	webhookSetting := operation_setting.GetTaskWebhookSetting()
+	// 使用用户通知设置中的 webhook 密钥签名
+	var secret string
+	if userSetting, err := model.GetUserSetting(delivery.UserId, false); err == nil {
+		secret, _ = userSetting[constant.UserSettingWebhookSecret].(string)
+	}
+	client := &http.Client{Timeout: time.Duration(webhookSetting.TimeoutSeconds) * time.Second}
+	err := sendWebhook(client, delivery.CallbackURL, secret, []byte(delivery.Payload), map[string]string{
+		"X-Webhook-Event": TaskWebhookEventStatusChanged,
+		"X-Webhook-Id":    delivery.EventId,
+	})
+	delivery.Attempts++
+	if err == nil {
+		delivery.Status = model.TaskWebhookStatusSuccess
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= webhookSetting.MaxAttempts {
+			delivery.Status = model.TaskWebhookStatusFailed
+			common.SysLog(fmt.Sprintf("task webhook %s for task %s failed after %d attempts: %s", delivery.EventId, delivery.TaskId, delivery.Attempts, err.Error()))
+		} else {
+			delivery.NextAttemptAt = time.Now().Unix() + taskWebhookBackoff(delivery.Attempts, webhookSetting)
+		}
+	}
+	if err := delivery.Update(); err != nil {
+		common.SysError("failed to update task webhook delivery: " + err.Error())
+	}
+}
+
+// taskWebhookBackoff 第 n 次失败后的等待时间：首次等待时间翻倍 n-1 次，不超过上限
+func taskWebhookBackoff(attempts int, webhookSetting *operation_setting.TaskWebhookSetting) int64 {
+	backoff := int64(webhookSetting.InitialBackoffSeconds)
+	if backoff <= 0 {
+		backoff = 1
+	}
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if webhookSetting.MaxBackoffSeconds > 0 && backoff >= int64(webhookSetting.MaxBackoffSeconds) {
+			return int64(webhookSetting.MaxBackoffSeconds)
+		}
+	}
+	return backoff
+}
+
+// RetryTaskWebhookDelivery 将投递记录重新置为待投递并立即唤醒投递协程
+func RetryTaskWebhookDelivery(delivery *model.TaskWebhookDelivery) error {
+	delivery.Status = model.TaskWebhookStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now().Unix()
+	if err := delivery.Update(); err != nil {
+		return err
+	}
+	select {
+	case taskWebhookWakeup <- struct{}{}:
+	default:
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"strings"
+)
+
+// 模拟工具调用时要求模型使用的输出格式
+const (
+	toolCallsStartTag = "<tool_calls>"
+	toolCallsEndTag   = "</tool_calls>"
+)
+
+type emulatedToolCall struct {
+	Name      string `json:"name"`
+	Arguments any    `json:"arguments"`
+}
+
+// toolCallInstruction 把工具定义渲染为系统提示
+func toolCallInstruction(request *dto.GeneralOpenAIRequest) string {
+	var sb strings.Builder
+	sb.WriteString("You have access to the following tools:\n")
+	for _, tool := range request.Tools {
+		sb.WriteString("\n- name: " + tool.Function.Name + "\n")
+		if tool.Function.Description != "" {
+			sb.WriteString("  description: " + tool.Function.Description + "\n")
+		}
+		if tool.Function.Parameters != nil {
+			parameters, _ := json.Marshal(tool.Function.Parameters)
+			sb.WriteString("  parameters (JSON Schema): " + string(parameters) + "\n")
+		}
+	}
+	sb.WriteString("\nTo call tools, reply with exactly the following format and nothing after it:\n")
+	sb.WriteString(toolCallsStartTag + "\n")
+	sb.WriteString(`[{"name": "<tool name>", "arguments": {<arguments matching the tool parameters>}}]` + "\n")
+	sb.WriteString(toolCallsEndTag + "\n")
+	sb.WriteString("You may call several tools at once by adding more objects to the array. " +
+		"Tool results will be sent back to you inside <tool_result> tags. ")
+	switch choice := request.ToolChoice.(type) {
+	case string:
+		if choice == "required" {
+			sb.WriteString("You must call at least one tool in your reply.")
+		} else {
+			sb.WriteString("If no tool is needed, answer directly without using this format.")
+		}
+	case map[string]any:
+		name := ""
+		if function, ok := choice["function"].(map[string]any); ok {
+			name, _ = function["name"].(string)
+		}
+		if name != "" {
+			sb.WriteString(fmt.Sprintf("You must call the tool %q in your reply.", name))
+		} else {
+			sb.WriteString("If no tool is needed, answer directly without using this format.")
+		}
+	default:
+		sb.WriteString("If no tool is needed, answer directly without using this format.")
+	}
+	return sb.String()
+}
+
+// renderEmulatedToolCalls 把历史消息中的 tool_calls 渲染为模拟格式的文本
+func renderEmulatedToolCalls(toolCalls []dto.ToolCallRequest) string {
+	calls := make([]emulatedToolCall, 0, len(toolCalls))
+	for _, toolCall := range toolCalls {
+		var arguments any = toolCall.Function.Arguments
+		if json.Valid([]byte(toolCall.Function.Arguments)) {
+			arguments = json.RawMessage(toolCall.Function.Arguments)
+		}
+		calls = append(calls, emulatedToolCall{
+			Name:      toolCall.Function.Name,
+			Arguments: arguments,
+		})
+	}
+	data, _ := json.Marshal(calls)
+	return toolCallsStartTag + "\n" + string(data) + "\n" + toolCallsEndTag
+}
+
+// ApplyToolCallPrompt 把 tools 改写为系统提示，并把历史中的工具调用与工具结果改写为普通文本消息，
+// 用于不支持函数调用的上游
+func ApplyToolCallPrompt(request *dto.GeneralOpenAIRequest) {
+	// tool_choice 为 none 时无需模拟，直接去掉工具定义
+	if choice, ok := request.ToolChoice.(string); !ok || choice != "none" {
+		instruction := toolCallInstruction(request)
+		if len(request.Messages) > 0 && request.Messages[0].Role == "system" {
+			content := request.Messages[0].StringContent()
+			if content != "" {
+				content += "\n\n"
+			}
+			request.Messages[0].SetStringContent(content + instruction)
+		} else {
+			systemMessage := dto.Message{Role: "system"}
+			systemMessage.SetStringContent(instruction)
+			request.Messages = append([]dto.Message{systemMessage}, request.Messages...)
+		}
+	}
+	request.Tools = nil
+	request.ToolChoice = nil
+	request.ParallelTooCalls = nil
+
+	toolNames := make(map[string]string)
+	messages := make([]dto.Message, 0, len(request.Messages))
+	for _, message := range request.Messages {
+		switch {
+		case message.Role == "assistant" && message.ToolCalls != nil:
+			toolCalls := message.ParseToolCalls()
+			for _, toolCall := range toolCalls {
+				toolNames[toolCall.ID] = toolCall.Function.Name
+			}
+			content := message.StringContent()
+			if content != "" {
+				content += "\n"
+			}
+			assistantMessage := dto.Message{Role: "assistant"}
+			assistantMessage.SetStringContent(content + renderEmulatedToolCalls(toolCalls))
+			messages = append(messages, assistantMessage)
+		case message.Role == "tool":
+			result := fmt.Sprintf("<tool_result name=%q id=%q>\n%s\n</tool_result>",
+				toolNames[message.ToolCallId], message.ToolCallId, message.StringContent())
+			// 连续的工具结果合并为一条用户消息
+			if last := len(messages) - 1; last >= 0 && messages[last].Role == "user" &&
+				strings.HasPrefix(messages[last].StringContent(), "<tool_result") {
+				messages[last].SetStringContent(messages[last].StringContent() + "\n" + result)
+				continue
+			}
+			userMessage := dto.Message{Role: "user"}
+			userMessage.SetStringContent(result)
+			messages = append(messages, userMessage)
+		default:
+			messages = append(messages, message)
+		}
+	}
+	request.Messages = messages
+}
+
+// ParseEmulatedToolCalls 从模型输出中解析模拟格式的工具调用，返回去除工具调用后的文本
+func ParseEmulatedToolCalls(content string) (string, []dto.ToolCallResponse) {
+	start := strings.Index(content, toolCallsStartTag)
+	if start < 0 {
+		return content, nil
+	}
+	body := content[start+len(toolCallsStartTag):]
+	if end := strings.Index(body, toolCallsEndTag); end >= 0 {
+		body = body[:end]
+	}
+	body = strings.TrimSpace(body)
+	body = strings.TrimPrefix(body, "```json")
+	body = strings.TrimPrefix(body, "```")
+	body = strings.TrimSuffix(body, "```")
+	body = strings.TrimSpace(body)
+
+	var calls []emulatedToolCall
+	if strings.HasPrefix(body, "{") {
+		var call emulatedToolCall
+		if err := common.DecodeJsonStr(body, &call); err != nil {
+			return content, nil
+		}
+		calls = append(calls, call)
+	} else if err := common.DecodeJsonStr(body, &calls); err != nil {
+		return content, nil
+	}
+
+	toolCalls := make([]dto.ToolCallResponse, 0, len(calls))
+	for _, call := range calls {
+		if call.Name == "" {
+			continue
+		}
+		arguments, ok := call.Arguments.(string)
+		if !ok {
+			if call.Arguments == nil {
+				call.Arguments = map[string]any{}
+			}
+			data, _ := json.Marshal(call.Arguments)
+			arguments = string(data)
+		}
+		toolCalls = append(toolCalls, dto.ToolCallResponse{
+			ID:   fmt.Sprintf("call_%s", common.GetUUID()),
+			Type: "function",
+			Function: dto.FunctionResponse{
+				Name:      call.Name,
+				Arguments: arguments,
+			},
+		})
+	}
+	if len(toolCalls) == 0 {
+		return content, nil
+	}
+	return strings.TrimSpace(content[:start]), toolCalls
+}
+
+// ResponseToolCallEmulation 把非流式响应中模拟格式的工具调用改写为标准 tool_calls
+func ResponseToolCallEmulation(response *dto.OpenAITextResponse) {
+	for i := range response.Choices {
+		message := &response.Choices[i].Message
+		text, toolCalls := ParseEmulatedToolCalls(message.StringContent())
+		if len(toolCalls) == 0 {
+			continue
+		}
+		if text == "" {
+			message.SetNullContent()
+		} else {
+			message.SetStringContent(text)
+		}
+		message.SetToolCalls(toolCalls)
+		response.Choices[i].FinishReason = "tool_calls"
+	}
+}
+
+// ToolCallStreamParser 在流式输出中识别模拟格式的工具调用：
+// 普通文本即时输出，疑似工具调用的部分暂存，结束时解析为 tool_calls
+type ToolCallStreamParser struct {
+	pending   string
+	capturing bool
+	buffer    strings.Builder
+}
+
+// Feed 输入一段增量文本，返回可以立即输出的文本
+func (p *ToolCallStreamParser) Feed(text string) string {
+	if p.capturing {
+		p.buffer.WriteString(text)
+		return ""
+	}
+	data := p.pending + text
+	p.pending = ""
+	if idx := strings.Index(data, toolCallsStartTag); idx >= 0 {
+		p.capturing = true
+		p.buffer.WriteString(data[idx:])
+		return data[:idx]
+	}
+	// 末尾可能是被拆开的开始标签，暂不输出
+	for i := len(toolCallsStartTag) - 1; i > 0; i-- {
+		if strings.HasSuffix(data, toolCallsStartTag[:i]) {
+			p.pending = data[len(data)-i:]
+			return data[:len(data)-i]
+		}
+	}
+	return data
+}
+
+// Capturing 是否已经识别到工具调用
+func (p *ToolCallStreamParser) Capturing() bool {
+	return p.capturing
+}
+
+// Finish 结束解析，返回剩余需要输出的文本与解析出的工具调用；解析失败时原样返回文本
+func (p *ToolCallStreamParser) Finish() (string, []dto.ToolCallResponse) {
+	if !p.capturing {
+		text := p.pending
+		p.pending = ""
+		return text, nil
+	}
+	content := p.buffer.String()
+	p.buffer.Reset()
+	p.capturing = false
+	text, toolCalls := ParseEmulatedToolCalls(content)
+	if len(toolCalls) == 0 {
+		return content, nil
+	}
+	return text, toolCalls
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"encoding/json"
+	"one-api/dto"
+	"strings"
+	"testing"
+)
+
+func TestParseEmulatedToolCalls(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantText  string
+		wantCalls []string // 期望的 "name arguments"
+	}{
+		{name: "plain text", content: "hello", wantText: "hello"},
+		{
+			name:      "array of calls",
+			content:   "Let me check.\n<tool_calls>\n[{\"name\":\"weather\",\"arguments\":{\"city\":\"Paris\"}},{\"name\":\"time\",\"arguments\":{}}]\n</tool_calls>",
+			wantText:  "Let me check.",
+			wantCalls: []string{`weather {"city":"Paris"}`, `time {}`},
+		},
+		{
+			name:      "single object in a code fence",
+			content:   "<tool_calls>\n```json\n{\"name\":\"weather\",\"arguments\":{\"city\":\"Paris\"}}\n```\n</tool_calls>",
+			wantCalls: []string{`weather {"city":"Paris"}`},
+		},
+		{
+			name:      "string arguments and missing end tag",
+			content:   `<tool_calls>[{"name":"search","arguments":"{\"q\":\"go\"}"}]`,
+			wantCalls: []string{`search {"q":"go"}`},
+		},
+		{
+			name:      "missing arguments",
+			content:   `<tool_calls>[{"name":"now"}]</tool_calls>`,
+			wantCalls: []string{`now {}`},
+		},
+		{name: "invalid json is kept as text", content: "<tool_calls>[{\"name\":</tool_calls>", wantText: "<tool_calls>[{\"name\":</tool_calls>"},
+		{name: "calls without a name are kept as text", content: `<tool_calls>[{"arguments":{}}]</tool_calls>`, wantText: `<tool_calls>[{"arguments":{}}]</tool_calls>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, toolCalls := ParseEmulatedToolCalls(tt.content)
+			if text != tt.wantText {
+				t.Fatalf("text = %q, want %q", text, tt.wantText)
+			}
+			assertToolCalls(t, toolCalls, tt.wantCalls)
+		})
+	}
+}
+
+func TestToolCallStreamParser(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunks    []string
+		wantText  string
+		wantCalls []string
+	}{
+		{name: "plain text", chunks: []string{"Hel", "lo"}, wantText: "Hello"},
+		{name: "text that looks like a tag prefix", chunks: []string{"a <to", "day"}, wantText: "a <today"},
+		{name: "tag prefix at the end of the stream", chunks: []string{"a <tool_"}, wantText: "a <tool_"},
+		{
+			name:      "start tag split across chunks",
+			chunks:    []string{"Sure. <tool", "_calls>[{\"name\":\"weather\",", "\"arguments\":{\"city\":\"Paris\"}}]</tool_calls>"},
+			wantText:  "Sure. ",
+			wantCalls: []string{`weather {"city":"Paris"}`},
+		},
+		{
+			name:     "unparsable capture is emitted as text",
+			chunks:   []string{"<tool_calls>", "not json"},
+			wantText: "<tool_calls>not json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var parser ToolCallStreamParser
+			var sb strings.Builder
+			for _, chunk := range tt.chunks {
+				sb.WriteString(parser.Feed(chunk))
+			}
+			text, toolCalls := parser.Finish()
+			sb.WriteString(text)
+			if sb.String() != tt.wantText {
+				t.Fatalf("text = %q, want %q", sb.String(), tt.wantText)
+			}
+			assertToolCalls(t, toolCalls, tt.wantCalls)
+		})
+	}
+}
+
+func TestResponseToolCallEmulation(t *testing.T) {
+	tests := []struct {
+		name             string
+		content          string
+		wantContent      any
+		wantFinishReason string
+		wantCalls        []string
+	}{
+		{name: "plain answer", content: "hello", wantContent: "hello", wantFinishReason: "stop"},
+		{
+			name:             "text with a tool call",
+			content:          "Checking.\n<tool_calls>[{\"name\":\"weather\",\"arguments\":{}}]</tool_calls>",
+			wantContent:      "Checking.",
+			wantFinishReason: "tool_calls",
+			wantCalls:        []string{`weather {}`},
+		},
+		{
+			name:             "tool call only",
+			content:          `<tool_calls>[{"name":"weather","arguments":{}}]</tool_calls>`,
+			wantContent:      nil,
+			wantFinishReason: "tool_calls",
+			wantCalls:        []string{`weather {}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			choice := dto.OpenAITextResponseChoice{FinishReason: "stop"}
+			choice.Message.SetStringContent(tt.content)
+			response := &dto.OpenAITextResponse{Choices: []dto.OpenAITextResponseChoice{choice}}
+			ResponseToolCallEmulation(response)
+			got := response.Choices[0]
+			if got.FinishReason != tt.wantFinishReason {
+				t.Fatalf("finish reason = %q, want %q", got.FinishReason, tt.wantFinishReason)
+			}
+			data, _ := json.Marshal(got.Message)
+			var message struct {
+				Content any `json:"content"`
+			}
+			_ = json.Unmarshal(data, &message)
+			if message.Content != tt.wantContent {
+				t.Fatalf("content = %#v, want %#v", message.Content, tt.wantContent)
+			}
+			assertToolCalls(t, got.Message.ParseToolCalls(), tt.wantCalls)
+		})
+	}
+}
+
+func TestApplyToolCallPrompt(t *testing.T) {
+	newMessage := func(role, content string) dto.Message {
+		message := dto.Message{Role: role}
+		message.SetStringContent(content)
+		return message
+	}
+	assistant := newMessage("assistant", "")
+	assistant.ToolCalls = json.RawMessage(`[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}},` +
+		`{"id":"call_2","type":"function","function":{"name":"time","arguments":"{}"}}]`)
+	toolResult1 := newMessage("tool", "sunny")
+	toolResult1.ToolCallId = "call_1"
+	toolResult2 := newMessage("tool", "noon")
+	toolResult2.ToolCallId = "call_2"
+	tools := []dto.ToolCallRequest{{Type: "function", Function: dto.FunctionRequest{Name: "weather", Description: "Get the weather"}}}
+
+	tests := []struct {
+		name         string
+		request      dto.GeneralOpenAIRequest
+		wantRoles    []string
+		wantContains []string // 按消息顺序，期望各消息包含的文本
+	}{
+		{
+			name: "instruction merged into the system message",
+			request: dto.GeneralOpenAIRequest{Tools: tools, Messages: []dto.Message{
+				newMessage("system", "Be brief."), newMessage("user", "weather?")}},
+			wantRoles:    []string{"system", "user"},
+			wantContains: []string{"Be brief.\n\nYou have access to the following tools", "weather?"},
+		},
+		{
+			name:         "instruction added as a system message",
+			request:      dto.GeneralOpenAIRequest{Tools: tools, ToolChoice: "required", Messages: []dto.Message{newMessage("user", "weather?")}},
+			wantRoles:    []string{"system", "user"},
+			wantContains: []string{"You must call at least one tool", "weather?"},
+		},
+		{
+			name:         "tool_choice none drops the tools",
+			request:      dto.GeneralOpenAIRequest{Tools: tools, ToolChoice: "none", Messages: []dto.Message{newMessage("user", "weather?")}},
+			wantRoles:    []string{"user"},
+			wantContains: []string{"weather?"},
+		},
+		{
+			name: "tool history rewritten as text",
+			request: dto.GeneralOpenAIRequest{Tools: tools, ToolChoice: "none", Messages: []dto.Message{
+				newMessage("user", "weather?"), assistant, toolResult1, toolResult2}},
+			wantRoles: []string{"user", "assistant", "user"},
+			wantContains: []string{"weather?", `<tool_calls>` + "\n" + `[{"name":"weather","arguments":{"city":"Paris"}},{"name":"time","arguments":{}}]`,
+				`<tool_result name="weather" id="call_1">` + "\nsunny\n</tool_result>\n" + `<tool_result name="time" id="call_2">`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := tt.request
+			ApplyToolCallPrompt(&request)
+			if request.Tools != nil || request.ToolChoice != nil {
+				t.Fatalf("tools should be removed from the request")
+			}
+			if len(request.Messages) != len(tt.wantRoles) {
+				t.Fatalf("got %d messages, want %d", len(request.Messages), len(tt.wantRoles))
+			}
+			for i, message := range request.Messages {
+				if message.Role != tt.wantRoles[i] || message.ToolCalls != nil {
+					t.Fatalf("message %d role = %q, want %q without tool_calls", i, message.Role, tt.wantRoles[i])
+				}
+				if !strings.Contains(message.StringContent(), tt.wantContains[i]) {
+					t.Fatalf("message %d = %q, want it to contain %q", i, message.StringContent(), tt.wantContains[i])
+				}
+			}
+		})
+	}
+}
+
+// assertToolCalls 按 "name arguments" 比较解析出的工具调用
+func assertToolCalls[T dto.ToolCallResponse | dto.ToolCallRequest](t *testing.T, toolCalls []T, want []string) {
+	t.Helper()
+	if len(toolCalls) != len(want) {
+		t.Fatalf("got %d tool calls, want %d", len(toolCalls), len(want))
+	}
+	for i, toolCall := range toolCalls {
+		data, _ := json.Marshal(toolCall)
+		var call struct {
+			Id       string `json:"id"`
+			Function struct {
+				Name      string `json:"name"`
+				Arguments string `json:"arguments"`
+			} `json:"function"`
+		}
+		_ = json.Unmarshal(data, &call)
+		if got := call.Function.Name + " " + call.Function.Arguments; got != want[i] || call.Id == "" {
+			t.Fatalf("tool call %d = %q (id %q), want %q", i, got, call.Id, want[i])
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"one-api/dto"
 	"one-api/model"
 	"strings"
+	"time"
 )
 
 func NotifyRootUser(t string, subject string, content string) {
@@ -17,6 +18,36 @@ func NotifyRootUser(t string, subject string, content string) {
 	}
 }
 
+// NotifyTaskRefund 通知用户其异步任务因超时或管理员操作被标记失败并退还了额度
+func NotifyTaskRefund(userId int, taskCount int, quota int, reason string) {
+	user, err := model.GetUserCache(userId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to get user %d for task refund notify: %s", userId, err.Error()))
+		return
+	}
+	prompt := "异步任务退款"
+	content := "您有 {{value}} 个异步任务{{value}}，已标记为失败并退还额度 {{value}}"
+	err = NotifyUser(userId, user.Email, user.GetSetting(), dto.NewNotify(dto.NotifyTypeTaskRefund, prompt, content, []interface{}{taskCount, reason, common.FormatQuota(quota)}))
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to send task refund notify to user %d: %s", userId, err.Error()))
+	}
+}
+
+// NotifySubscriptionRenewFailed 通知用户订阅自动续费失败，到期前仍会继续重试
+func NotifySubscriptionRenewFailed(userId int, planName string, endTime int64, reason string) {
+	user, err := model.GetUserCache(userId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to get user %d for subscription renew notify: %s", userId, err.Error()))
+		return
+	}
+	prompt := "订阅自动续费失败"
+	content := "您的订阅套餐 {{value}} 自动续费失败：{{value}}。订阅将于 {{value}} 到期，到期前系统会继续尝试续费"
+	err = NotifyUser(userId, user.Email, user.GetSetting(), dto.NewNotify(dto.NotifyTypeSubscriptionRenew, prompt, content, []interface{}{planName, reason, time.Unix(endTime, 0).Format("2006-01-02 15:04:05")}))
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to send subscription renew notify to user %d: %s", userId, err.Error()))
+	}
+}
+
 func NotifyUser(userId int, userEmail string, userSetting map[string]interface{}, data dto.Notify) error {
 	notifyType, ok := userSetting[constant.UserSettingNotifyType]
 	if !ok {
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/setting/model_setting"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebSearchToolName 由网关执行的联网搜索工具的函数名
+const WebSearchToolName = "web_search"
+
+// WebSearchResult 单条搜索结果
+type WebSearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Content string `json:"content"`
+}
+
+// WebSearchProvider 搜索后端
+type WebSearchProvider interface {
+	Search(ctx context.Context, query string, maxResults int) ([]WebSearchResult, error)
+}
+
+// customWebSearchProvider 通用 HTTP 搜索后端：POST {"query","max_results"}，返回 {"results":[{"title","url","content"}]}
+type customWebSearchProvider struct {
+	baseURL string
+	apiKey  string
+}
+
+// searxngWebSearchProvider SearXNG 的 JSON 搜索接口
+type searxngWebSearchProvider struct {
+	baseURL string
+}
+
+// tavilyWebSearchProvider Tavily 搜索接口
+type tavilyWebSearchProvider struct {
+	baseURL string
+	apiKey  string
+}
+
+// braveWebSearchProvider Brave Search 搜索接口
+type braveWebSearchProvider struct {
+	baseURL string
+	apiKey  string
+}
+
+type webSearchResultsResponse struct {
+	Results []WebSearchResult `json:"results"`
+}
+
+func doWebSearchRequest(req *http.Request, v any) error {
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("search backend returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return common.DecodeJson(body, v)
+}
+
+func (p *customWebSearchProvider) Search(ctx context.Context, query string, maxResults int) ([]WebSearchResult, error) {
+	body, err := json.Marshal(map[string]any{
+		"query":       query,
+		"max_results": maxResults,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	var response webSearchResultsResponse
+	if err := doWebSearchRequest(req, &response); err != nil {
+		return nil, err
+	}
+	return response.Results, nil
+}
+
+func (p *searxngWebSearchProvider) Search(ctx context.Context, query string, maxResults int) ([]WebSearchResult, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("format", "json")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.baseURL, "/")+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var response webSearchResultsResponse
+	if err := doWebSearchRequest(req, &response); err != nil {
+		return nil, err
+	}
+	return response.Results, nil
+}
+
+func (p *tavilyWebSearchProvider) Search(ctx context.Context, query string, maxResults int) ([]WebSearchResult, error) {
+	body, err := json.Marshal(map[string]any{
+		"query":       query,
+		"max_results": maxResults,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, common.GetStringIfEmpty(p.baseURL, "https://api.tavily.com")+"/search", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	var response webSearchResultsResponse
+	if err := doWebSearchRequest(req, &response); err != nil {
+		return nil, err
+	}
+	return response.Results, nil
+}
+
+func (p *braveWebSearchProvider) Search(ctx context.Context, query string, maxResults int) ([]WebSearchResult, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("count", strconv.Itoa(maxResults))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, common.GetStringIfEmpty(p.baseURL, "https://api.search.brave.com")+"/res/v1/web/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Subscription-Token", p.apiKey)
+	var response struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := doWebSearchRequest(req, &response); err != nil {
+		return nil, err
+	}
+	results := make([]WebSearchResult, 0, len(response.Web.Results))
+	for _, result := range response.Web.Results {
+		results = append(results, WebSearchResult{
+			Title:   result.Title,
+			URL:     result.URL,
+			Content: result.Description,
+		})
+	}
+	return results, nil
+}
+
+// GetWebSearchProvider 根据配置创建搜索后端
+func GetWebSearchProvider(settings *model_setting.WebSearchSettings) (WebSearchProvider, error) {
+	switch settings.Provider {
+	case "", "custom":
+		if settings.BaseURL == "" {
+			return nil, errors.New("web search base_url is required for custom provider")
+		}
+		return &customWebSearchProvider{baseURL: settings.BaseURL, apiKey: settings.APIKey}, nil
+	case "searxng":
+		if settings.BaseURL == "" {
+			return nil, errors.New("web search base_url is required for searxng provider")
+		}
+		return &searxngWebSearchProvider{baseURL: settings.BaseURL}, nil
+	case "tavily":
+		return &tavilyWebSearchProvider{baseURL: strings.TrimSuffix(settings.BaseURL, "/"), apiKey: settings.APIKey}, nil
+	case "brave":
+		return &braveWebSearchProvider{baseURL: strings.TrimSuffix(settings.BaseURL, "/"), apiKey: settings.APIKey}, nil
+	}
+	return nil, fmt.Errorf("unsupported web search provider: %s", settings.Provider)
+}
+
+// WebSearch 使用配置的搜索后端执行一次搜索
+func WebSearch(ctx context.Context, query string) ([]WebSearchResult, error) {
+	settings := model_setting.GetWebSearchSettings()
+	provider, err := GetWebSearchProvider(settings)
+	if err != nil {
+		return nil, err
+	}
+	if settings.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(settings.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+	maxResults := settings.MaxResults
+	if maxResults <= 0 {
+		maxResults = 5
+	}
+	results, err := provider.Search(ctx, query, maxResults)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) > maxResults {
+		results = results[:maxResults]
+	}
+	return results, nil
+}
+
+// IsWebSearchTool 判断客户端请求的工具是否为内置联网搜索
+func IsWebSearchTool(tool dto.ToolCallRequest) bool {
+	return tool.Type == WebSearchToolName || tool.Type == dto.BuildInToolWebSearchPreview
+}
+
+// WebSearchFunctionTool 交给模型调用的联网搜索函数定义
+func WebSearchFunctionTool() dto.ToolCallRequest {
+	return dto.ToolCallRequest{
+		Type: "function",
+		Function: dto.FunctionRequest{
+			Name:        WebSearchToolName,
+			Description: "Search the web for up-to-date information. Use it when the question needs current events or facts you are unsure about.",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"query": map[string]any{
+						"type":        "string",
+						"description": "The search query.",
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+	}
+}
+
+// ParseWebSearchQuery 从工具调用参数中读取搜索关键词
+func ParseWebSearchQuery(arguments string) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+	}
+	if err := common.DecodeJsonStr(arguments, &args); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(args.Query) == "" {
+		return "", errors.New("query is empty")
+	}
+	return args.Query, nil
+}
+
+// RenderWebSearchResults 把搜索结果渲染为工具消息内容
+func RenderWebSearchResults(results []WebSearchResult) string {
+	if len(results) == 0 {
+		return "No results found."
+	}
+	data, err := json.Marshal(results)
+	if err != nil {
+		return "No results found."
+	}
+	return string(data)
+}
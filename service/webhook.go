@@ -52,9 +52,14 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 		return fmt.Errorf("failed to marshal webhook payload: %v", err)
 	}
 
-	// 创建 HTTP 请求
+	return sendWebhook(GetImpatientHttpClient(), webhookURL, secret, payloadBytes, nil)
+}
+
+// sendWebhook 发送 webhook 请求，有 secret 时对请求体做 HMAC-SHA256 签名
+func sendWebhook(client *http.Client, webhookURL string, secret string, payloadBytes []byte, headers map[string]string) error {
 	var req *http.Request
 	var resp *http.Response
+	var err error
 
 	if setting.EnableWorker() {
 		// 构建worker请求数据
@@ -67,6 +72,9 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 			},
 			Body: payloadBytes,
 		}
+		for k, v := range headers {
+			workerReq.Headers[k] = v
+		}
 
 		// 如果有secret，添加签名到headers
 		if secret != "" {
@@ -93,6 +101,9 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 
 		// 设置请求头
 		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
 
 		// 如果有 secret，生成签名
 		if secret != "" {
@@ -101,7 +112,6 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 		}
 
 		// 发送请求
-		client := GetImpatientHttpClient()
 		resp, err = client.Do(req)
 		if err != nil {
 			return fmt.Errorf("failed to send webhook request: %v", err)
@@ -0,0 +1,51 @@
+package model_setting
+
+import (
+	"one-api/common"
+	"one-api/setting/config"
+)
+
+// FanOutSettings 定义 n>1 请求并发模拟的配置
+type FanOutSettings struct {
+	// 是否为不支持 n 参数的渠道并发请求 n 次并合并结果，默认关闭，也可以在渠道设置中单独开启
+	Enabled bool `json:"enabled"`
+	// 单次请求允许模拟的最大 n
+	MaxN int `json:"max_n"`
+	// 原生支持 n 参数的渠道类型，这些渠道直接转发 n
+	NativeChannelTypes []int `json:"native_channel_types"`
+}
+
+// 默认配置
+var defaultFanOutSettings = FanOutSettings{
+	Enabled: false,
+	MaxN:    8,
+	NativeChannelTypes: []int{
+		common.ChannelTypeOpenAI,
+		common.ChannelTypeAzure,
+		common.ChannelTypeCustom,
+		common.ChannelTypeOpenRouter,
+	},
+}
+
+// 全局实例
+var fanOutSettings = defaultFanOutSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("fan_out", &fanOutSettings)
+}
+
+// GetFanOutSettings 获取 n>1 并发模拟配置
+func GetFanOutSettings() *FanOutSettings {
+	return &fanOutSettings
+}
+
+// IsNativeChannelType 判断渠道类型是否原生支持 n 参数
+func (s *FanOutSettings) IsNativeChannelType(channelType int) bool {
+	for _, t := range s.NativeChannelTypes {
+		if t == channelType {
+			return true
+		}
+	}
+	return false
+}
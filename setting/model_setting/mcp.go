@@ -0,0 +1,30 @@
+package model_setting
+
+import "one-api/setting/config"
+
+// McpSettings 定义网关执行 MCP 工具调用的配置
+type McpSettings struct {
+	// 单次请求中模型最多可以发起工具调用的轮数，达到后要求模型直接回答
+	MaxRounds int `json:"max_rounds"`
+	// 单次请求可以使用的最多工具数
+	MaxTools int `json:"max_tools"`
+}
+
+// 默认配置
+var defaultMcpSettings = McpSettings{
+	MaxRounds: 5,
+	MaxTools:  64,
+}
+
+// 全局实例
+var mcpSettings = defaultMcpSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("mcp", &mcpSettings)
+}
+
+// GetMcpSettings 获取 MCP 配置
+func GetMcpSettings() *McpSettings {
+	return &mcpSettings
+}
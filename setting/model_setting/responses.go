@@ -0,0 +1,56 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+	"strings"
+)
+
+// ResponsesSettings 定义 Responses API 与 Chat Completions 桥接的配置
+type ResponsesSettings struct {
+	// 仅能通过 Responses API 调用的模型（前缀匹配），/v1/chat/completions 请求会被转换为 /v1/responses
+	ResponsesOnlyModels []string `json:"responses_only_models"`
+	// 是否允许把 /v1/responses 请求转换为 Chat Completions 发往不支持 Responses API 的渠道
+	ChatBridgeEnabled bool `json:"chat_bridge_enabled"`
+	// 是否在网关保存响应对象 (store: true)，用于 previous_response_id 与 GET /v1/responses/{id}
+	StoreEnabled bool `json:"store_enabled"`
+	// 保存的响应保留天数，0 表示永久保留
+	StoreRetentionDays int `json:"store_retention_days"`
+}
+
+// 默认配置
+var defaultResponsesSettings = ResponsesSettings{
+	ResponsesOnlyModels: []string{
+		"o1-pro",
+		"o3-pro",
+		"o3-deep-research",
+		"o4-mini-deep-research",
+		"codex-mini",
+		"computer-use-preview",
+	},
+	ChatBridgeEnabled:  true,
+	StoreEnabled:       true,
+	StoreRetentionDays: 30,
+}
+
+// 全局实例
+var responsesSettings = defaultResponsesSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("responses", &responsesSettings)
+}
+
+// GetResponsesSettings 获取 Responses API 桥接配置
+func GetResponsesSettings() *ResponsesSettings {
+	return &responsesSettings
+}
+
+// IsResponsesOnlyModel 判断模型是否只能通过 Responses API 调用
+func (s *ResponsesSettings) IsResponsesOnlyModel(model string) bool {
+	for _, prefix := range s.ResponsesOnlyModels {
+		if prefix != "" && strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
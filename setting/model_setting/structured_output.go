@@ -0,0 +1,49 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+	"strings"
+)
+
+// StructuredOutputSettings 定义 Structured Outputs (response_format: json_schema) 模拟的配置
+type StructuredOutputSettings struct {
+	// 是否对所有渠道启用模拟；关闭时仅对开启了渠道设置 structured_output_emulation 的渠道或下列模型生效
+	EmulationEnabled bool `json:"emulation_enabled"`
+	// 需要模拟 Structured Outputs 的模型（前缀匹配）
+	EmulationModels []string `json:"emulation_models"`
+	// 校验失败后携带错误信息重试的最大次数
+	MaxRetries int `json:"max_retries"`
+}
+
+// 默认配置
+var defaultStructuredOutputSettings = StructuredOutputSettings{
+	EmulationEnabled: false,
+	EmulationModels:  []string{},
+	MaxRetries:       2,
+}
+
+// 全局实例
+var structuredOutputSettings = defaultStructuredOutputSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("structured_output", &structuredOutputSettings)
+}
+
+// GetStructuredOutputSettings 获取 Structured Outputs 模拟配置
+func GetStructuredOutputSettings() *StructuredOutputSettings {
+	return &structuredOutputSettings
+}
+
+// IsEmulationModel 判断模型是否需要模拟 Structured Outputs
+func (s *StructuredOutputSettings) IsEmulationModel(model string) bool {
+	if s.EmulationEnabled {
+		return true
+	}
+	for _, prefix := range s.EmulationModels {
+		if prefix != "" && strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
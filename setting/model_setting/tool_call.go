@@ -0,0 +1,40 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+	"strings"
+)
+
+// ToolCallSettings 定义工具调用 (function calling) 模拟的配置
+type ToolCallSettings struct {
+	// 不支持原生函数调用、需要由网关模拟工具调用的模型（前缀匹配）
+	EmulationModels []string `json:"emulation_models"`
+}
+
+// 默认配置
+var defaultToolCallSettings = ToolCallSettings{
+	EmulationModels: []string{},
+}
+
+// 全局实例
+var toolCallSettings = defaultToolCallSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("tool_call", &toolCallSettings)
+}
+
+// GetToolCallSettings 获取工具调用模拟配置
+func GetToolCallSettings() *ToolCallSettings {
+	return &toolCallSettings
+}
+
+// IsEmulationModel 判断模型是否需要模拟工具调用
+func (s *ToolCallSettings) IsEmulationModel(model string) bool {
+	for _, prefix := range s.EmulationModels {
+		if prefix != "" && strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
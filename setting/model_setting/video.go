@@ -0,0 +1,42 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+)
+
+// VideoSettings 定义视频生成任务的计费方式与限制
+type VideoSettings struct {
+	// 按秒计费的模型：模型固定价格视为每秒价格，乘以请求的视频时长；其余模型按条计费
+	PerSecondModels []string `json:"per_second_models"`
+	// 单个视频允许的最大时长（秒），0 表示不限制
+	MaxDuration int `json:"max_duration"`
+}
+
+// 默认配置
+var defaultVideoSettings = VideoSettings{
+	PerSecondModels: []string{},
+	MaxDuration:     60,
+}
+
+// 全局实例
+var videoSettings = defaultVideoSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("video", &videoSettings)
+}
+
+// GetVideoSettings 获取视频生成配置
+func GetVideoSettings() *VideoSettings {
+	return &videoSettings
+}
+
+// IsPerSecondModel 判断模型是否按秒计费
+func (s *VideoSettings) IsPerSecondModel(model string) bool {
+	for _, m := range s.PerSecondModels {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
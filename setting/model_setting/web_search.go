@@ -0,0 +1,65 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+	"strings"
+)
+
+// WebSearchSettings 定义由网关执行的联网搜索工具
+type WebSearchSettings struct {
+	// 是否对请求了联网搜索（web_search_options 或 web_search 工具）的模型由网关执行搜索
+	Enabled bool `json:"enabled"`
+	// 由网关执行搜索的模型（前缀匹配），为空时对除原生搜索模型（*-search-preview）以外的所有模型生效
+	Models []string `json:"models"`
+	// 搜索后端：custom、searxng、tavily、brave
+	Provider string `json:"provider"`
+	// 搜索后端地址，custom 与 searxng 必填，其余为空时使用官方地址
+	BaseURL string `json:"base_url"`
+	// 搜索后端的 API Key
+	APIKey string `json:"api_key"`
+	// 每次搜索返回的最大结果数
+	MaxResults int `json:"max_results"`
+	// 单次请求中模型最多可以发起搜索的轮数，达到后要求模型直接回答
+	MaxRounds int `json:"max_rounds"`
+	// 单次搜索的超时时间（秒）
+	TimeoutSeconds int `json:"timeout_seconds"`
+}
+
+// 默认配置
+var defaultWebSearchSettings = WebSearchSettings{
+	Enabled:        false,
+	Models:         []string{},
+	Provider:       "custom",
+	MaxResults:     5,
+	MaxRounds:      3,
+	TimeoutSeconds: 10,
+}
+
+// 全局实例
+var webSearchSettings = defaultWebSearchSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("web_search", &webSearchSettings)
+}
+
+// GetWebSearchSettings 获取联网搜索配置
+func GetWebSearchSettings() *WebSearchSettings {
+	return &webSearchSettings
+}
+
+// IsWebSearchModel 判断模型是否由网关执行联网搜索
+func (s *WebSearchSettings) IsWebSearchModel(model string) bool {
+	if !s.Enabled {
+		return false
+	}
+	if len(s.Models) == 0 {
+		return !strings.HasSuffix(model, "search-preview")
+	}
+	for _, prefix := range s.Models {
+		if prefix != "" && strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
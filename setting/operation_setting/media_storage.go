@@ -0,0 +1,63 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	MediaStorageBackendLocal = "local"
+	MediaStorageBackendS3    = "s3"
+)
+
+// MediaStorageSetting 生成结果（图片、音频、视频）的持久化存储配置
+type MediaStorageSetting struct {
+	// 是否将生成结果复制到网关存储，并通过带签名的网关地址返回
+	Enabled bool `json:"enabled"`
+	// 存储后端：local 或 s3（兼容 S3 协议的对象存储）
+	Backend string `json:"backend"`
+	// 本地存储目录
+	LocalPath string `json:"local_path"`
+	// S3 兼容存储的访问地址，如 https://s3.us-east-1.amazonaws.com
+	S3Endpoint  string `json:"s3_endpoint"`
+	S3Region    string `json:"s3_region"`
+	S3Bucket    string `json:"s3_bucket"`
+	S3AccessKey string `json:"s3_access_key"`
+	S3SecretKey string `json:"s3_secret_key"`
+	// 是否使用路径风格访问（endpoint/bucket/key），多数自建存储需要开启
+	S3PathStyle bool `json:"s3_path_style"`
+	// 签名地址的有效期（分钟）
+	SignedURLTTLMinutes int `json:"signed_url_ttl_minutes"`
+	// 默认保留天数，用户未设置时使用，0 表示永久保留
+	DefaultRetentionDays int `json:"default_retention_days"`
+	// 用户可设置的最大保留天数，0 表示不限制
+	MaxRetentionDays int `json:"max_retention_days"`
+	// 每个用户的存储空间上限（MB），超出后不再保存新的结果，0 表示不限制
+	UserStorageLimitMB int `json:"user_storage_limit_mb"`
+	// 单个文件的大小上限（MB）
+	MaxFileSizeMB int `json:"max_file_size_mb"`
+	// 是否保存 /v1/images/generations 等图片接口的结果
+	StoreImageGenerations bool `json:"store_image_generations"`
+	// 是否在异步任务（Midjourney、Suno、视频）成功后保存结果
+	StoreTaskResults bool `json:"store_task_results"`
+}
+
+// 默认配置
+var mediaStorageSetting = MediaStorageSetting{
+	Enabled:               false,
+	Backend:               MediaStorageBackendLocal,
+	LocalPath:             "media",
+	S3Region:              "us-east-1",
+	SignedURLTTLMinutes:   60,
+	DefaultRetentionDays:  7,
+	MaxRetentionDays:      30,
+	MaxFileSizeMB:         200,
+	StoreImageGenerations: true,
+	StoreTaskResults:      true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("media_storage", &mediaStorageSetting)
+}
+
+func GetMediaStorageSetting() *MediaStorageSetting {
+	return &mediaStorageSetting
+}
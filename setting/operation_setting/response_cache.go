@@ -0,0 +1,44 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// ResponseCacheSetting 定义确定性请求的精确匹配响应缓存
+type ResponseCacheSetting struct {
+	// 总开关，关闭时任何请求都不会读写缓存
+	Enabled bool `json:"enabled"`
+	// 是否对所有令牌默认启用；关闭时仅对开启了响应缓存的令牌或携带 Cache-Control: max-age 的请求生效
+	DefaultEnabled bool `json:"default_enabled"`
+	// 是否只缓存 temperature 为 0 的请求
+	DeterministicOnly bool `json:"deterministic_only"`
+	// 缓存有效期（秒）
+	TTLSeconds int `json:"ttl_seconds"`
+	// 存储方式：redis 或 disk，未启用 Redis 时使用 disk
+	Storage string `json:"storage"`
+	// 本地磁盘缓存目录
+	DiskPath string `json:"disk_path"`
+	// 单条缓存的最大字节数
+	MaxEntryBytes int `json:"max_entry_bytes"`
+	// 命中缓存时按正常费用乘以该倍率计费
+	CacheRatio float64 `json:"cache_ratio"`
+}
+
+// 默认配置
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:           false,
+	DefaultEnabled:    false,
+	DeterministicOnly: true,
+	TTLSeconds:        86400,
+	Storage:           "redis",
+	DiskPath:          "data/response_cache",
+	MaxEntryBytes:     1 << 20,
+	CacheRatio:        0.1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_cache", &responseCacheSetting)
+}
+
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
@@ -0,0 +1,69 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	SemanticCacheScopeUser   = "user"
+	SemanticCacheScopeToken  = "token"
+	SemanticCacheScopeGlobal = "global"
+)
+
+// SemanticCacheSetting 定义基于向量相似度的语义缓存
+type SemanticCacheSetting struct {
+	// 总开关
+	Enabled bool `json:"enabled"`
+	// 参与语义缓存的模型，为空时对所有对话模型生效
+	Models []string `json:"models"`
+	// 用于计算最后一条用户消息向量的嵌入模型，通过网关自身渠道请求并正常计费
+	EmbeddingModel string `json:"embedding_model"`
+	// 余弦相似度阈值，不低于该值视为命中
+	SimilarityThreshold float64 `json:"similarity_threshold"`
+	// 缓存有效期（秒）
+	TTLSeconds int `json:"ttl_seconds"`
+	// 缓存共享范围：user、token 或 global（同一分组内共享）
+	Scope string `json:"scope"`
+	// 内存索引的最大条目数，超出时淘汰最早的条目
+	MaxEntries int `json:"max_entries"`
+	// 是否把索引持久化到本地磁盘，重启后恢复
+	Persist bool `json:"persist"`
+	// 持久化文件路径
+	PersistPath string `json:"persist_path"`
+	// 命中缓存时按正常费用乘以该倍率计费
+	CacheRatio float64 `json:"cache_ratio"`
+}
+
+// 默认配置
+var semanticCacheSetting = SemanticCacheSetting{
+	Enabled:             false,
+	Models:              []string{},
+	EmbeddingModel:      "text-embedding-3-small",
+	SimilarityThreshold: 0.95,
+	TTLSeconds:          86400,
+	Scope:               SemanticCacheScopeToken,
+	MaxEntries:          10000,
+	Persist:             false,
+	PersistPath:         "data/semantic_cache.json",
+	CacheRatio:          0.1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("semantic_cache", &semanticCacheSetting)
+}
+
+func GetSemanticCacheSetting() *SemanticCacheSetting {
+	return &semanticCacheSetting
+}
+
+// IsSemanticCacheModel 判断模型是否参与语义缓存
+func (s *SemanticCacheSetting) IsSemanticCacheModel(model string) bool {
+	if len(s.Models) == 0 {
+		return true
+	}
+	for _, m := range s.Models {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// SubscriptionSetting 订阅购买相关配置
+type SubscriptionSetting struct {
+	// 在线支付订单的有效期（分钟），超时未支付的订阅会被标记为支付超时
+	PaymentExpireMinutes int `json:"payment_expire_minutes"`
+	// 是否允许使用账户余额购买订阅
+	BalancePaymentEnabled bool `json:"balance_payment_enabled"`
+	// 是否允许用户开启到期自动续费（从账户余额扣费）
+	AutoRenewEnabled bool `json:"auto_renew_enabled"`
+	// 到期前多少小时开始尝试自动续费，余额不足时在此期间内每次调度都会重试
+	RenewBeforeHours int `json:"renew_before_hours"`
+	// 请求预留的订阅配额超过多少分钟仍未结算时由调度器回收
+	ReservationTimeoutMinutes int `json:"reservation_timeout_minutes"`
+	// 是否允许用户升级/降级套餐
+	PlanChangeEnabled bool `json:"plan_change_enabled"`
+	// 变更套餐时原套餐的折算方式：time 按剩余时间，quota 按剩余配额，min 取两者较小值
+	PlanChangeCreditMode string `json:"plan_change_credit_mode"`
+	// 变更套餐时已用配额的处理方式：carry 计入新套餐，reset 新套餐配额全部重新发放
+	PlanChangeUsageMode string `json:"plan_change_usage_mode"`
+	// 折算额度高于新套餐价格时是否将差额退还到账户余额
+	PlanChangeRefundEnabled bool `json:"plan_change_refund_enabled"`
+	// 是否允许用户自行取消订阅
+	CancelEnabled bool `json:"cancel_enabled"`
+	// 用户立即取消订阅时是否按比例退款
+	CancelRefundEnabled bool `json:"cancel_refund_enabled"`
+	// 立即取消时退款的折算方式，取值同 PlanChangeCreditMode
+	CancelRefundMode string `json:"cancel_refund_mode"`
+	// 是否允许用户使用余额购买订阅礼品码赠送他人
+	GiftEnabled bool `json:"gift_enabled"`
+}
+
+// 套餐变更折算方式
+const (
+	PlanChangeCreditByTime  = "time"
+	PlanChangeCreditByQuota = "quota"
+	PlanChangeCreditByMin   = "min"
+)
+
+// 套餐变更时已用配额的处理方式
+const (
+	PlanChangeUsageCarry = "carry"
+	PlanChangeUsageReset = "reset"
+)
+
+// 默认配置
+var subscriptionSetting = SubscriptionSetting{
+	PaymentExpireMinutes:  30,
+	BalancePaymentEnabled: true,
+	AutoRenewEnabled:      true,
+	RenewBeforeHours:      24,
+
+	ReservationTimeoutMinutes: 60,
+
+	PlanChangeEnabled:       true,
+	PlanChangeCreditMode:    PlanChangeCreditByTime,
+	PlanChangeUsageMode:     PlanChangeUsageCarry,
+	PlanChangeRefundEnabled: true,
+
+	CancelEnabled:       true,
+	CancelRefundEnabled: true,
+	CancelRefundMode:    PlanChangeCreditByTime,
+
+	GiftEnabled: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("subscription", &subscriptionSetting)
+}
+
+func GetSubscriptionSetting() *SubscriptionSetting {
+	return &subscriptionSetting
+}
@@ -0,0 +1,61 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"time"
+)
+
+// TaskTimeoutSetting 异步任务超时处理配置，超时未完成的任务会被标记为失败并退还额度
+type TaskTimeoutSetting struct {
+	// 是否启用超时任务的自动失败与退款
+	Enabled bool `json:"enabled"`
+	// 未单独配置的平台使用的超时时间（分钟），0 表示不处理
+	DefaultTimeoutMinutes int `json:"default_timeout_minutes"`
+	// 各平台的超时时间（分钟），键为任务平台，如 suno、mj、kling，0 表示该平台不处理
+	PlatformTimeoutMinutes map[string]int `json:"platform_timeout_minutes"`
+	// 超时退款后是否通知用户
+	NotifyUser bool `json:"notify_user"`
+}
+
+// 默认配置
+var taskTimeoutSetting = TaskTimeoutSetting{
+	Enabled:               true,
+	DefaultTimeoutMinutes: 120,
+	PlatformTimeoutMinutes: map[string]int{
+		"suno": 30,
+		"mj":   60,
+	},
+	NotifyUser: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_timeout", &taskTimeoutSetting)
+}
+
+func GetTaskTimeoutSetting() *TaskTimeoutSetting {
+	return &taskTimeoutSetting
+}
+
+// GetTimeout 返回平台的任务超时时间，0 表示不处理
+func (s *TaskTimeoutSetting) GetTimeout(platform string) time.Duration {
+	minutes, ok := s.PlatformTimeoutMinutes[platform]
+	if !ok {
+		minutes = s.DefaultTimeoutMinutes
+	}
+	if minutes <= 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GetMinTimeout 返回所有已配置超时中最短的一个，用于筛选候选任务
+func (s *TaskTimeoutSetting) GetMinTimeout() time.Duration {
+	minTimeout := s.GetTimeout("")
+	for platform := range s.PlatformTimeoutMinutes {
+		if timeout := s.GetTimeout(platform); timeout > 0 && (minTimeout == 0 || timeout < minTimeout) {
+			minTimeout = timeout
+		}
+	}
+	return minTimeout
+}
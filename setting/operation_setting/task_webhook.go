@@ -0,0 +1,35 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// TaskWebhookSetting 异步任务完成回调的投递配置
+type TaskWebhookSetting struct {
+	// 是否向提交任务时指定的 callback_url（或令牌默认回调地址）推送任务状态
+	Enabled bool `json:"enabled"`
+	// 单次投递的超时时间（秒）
+	TimeoutSeconds int `json:"timeout_seconds"`
+	// 最多投递次数（含首次），达到后标记为失败
+	MaxAttempts int `json:"max_attempts"`
+	// 首次重试的等待时间（秒），之后每次翻倍
+	InitialBackoffSeconds int `json:"initial_backoff_seconds"`
+	// 重试等待时间的上限（秒）
+	MaxBackoffSeconds int `json:"max_backoff_seconds"`
+}
+
+// 默认配置
+var taskWebhookSetting = TaskWebhookSetting{
+	Enabled:               true,
+	TimeoutSeconds:        10,
+	MaxAttempts:           8,
+	InitialBackoffSeconds: 10,
+	MaxBackoffSeconds:     3600,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_webhook", &taskWebhookSetting)
+}
+
+func GetTaskWebhookSetting() *TaskWebhookSetting {
+	return &taskWebhookSetting
+}
@@ -40,7 +40,8 @@ import {
   AutoComplete,
   Checkbox,
   Tabs,
-  TabPane
+  TabPane,
+  InputNumber
 } from '@douyinfe/semi-ui';
 import { IllustrationNoContent, IllustrationNoContentDark } from '@douyinfe/semi-illustrations';
 import {
@@ -104,6 +105,7 @@ const PersonalSetting = () => {
     notificationEmail: '',
     acceptUnsetModelRatioModel: false,
     recordIpLog: false,
+    mediaRetentionDays: 0,
   });
   const [modelsLoading, setModelsLoading] = useState(true);
   const [showWebhookDocs, setShowWebhookDocs] = useState(true);
@@ -149,6 +151,7 @@ const PersonalSetting = () => {
         acceptUnsetModelRatioModel:
           settings.accept_unset_model_ratio_model || false,
         recordIpLog: settings.record_ip_log || false,
+        mediaRetentionDays: settings.media_retention_days || 0,
       });
     }
   }, [userState?.user?.setting]);
@@ -365,6 +368,7 @@ const PersonalSetting = () => {
         accept_unset_model_ratio_model:
           notificationSettings.acceptUnsetModelRatioModel,
         record_ip_log: notificationSettings.recordIpLog,
+        media_retention_days: parseInt(notificationSettings.mediaRetentionDays) || 0,
       });
 
       if (res.data.success) {
@@ -1304,6 +1308,28 @@ const PersonalSetting = () => {
                               </div>
                             </div>
                           </TabPane>
+
+                          <TabPane
+                            tab={t('生成结果保留')}
+                            itemKey='media'
+                          >
+                            <div className="py-4">
+                              <Typography.Text strong className="block mb-2">
+                                {t('生成结果保留天数')}
+                              </Typography.Text>
+                              <InputNumber
+                                value={notificationSettings.mediaRetentionDays}
+                                min={0}
+                                onChange={(val) =>
+                                  handleNotificationSettingChange('mediaRetentionDays', val)
+                                }
+                                className="!rounded-lg"
+                              />
+                              <div className="text-gray-500 text-sm mt-2">
+                                {t('网关保存的图片、音频、视频等生成结果在到期后会被删除，0 表示使用系统默认值')}
+                              </div>
+                            </div>
+                          </TabPane>
                         </Tabs>
 
                         <div className="mt-6 flex justify-end">
@@ -698,6 +698,40 @@ const LogsTable = () => {
     await loadLogs(1, pageSize);
   };
 
+  const [selectedKeys, setSelectedKeys] = useState([]);
+
+  // 仅管理员可以选择未完成的任务进行批量退款
+  const rowSelection = isAdminUser
+    ? {
+      selectedRowKeys: selectedKeys,
+      getCheckboxProps: (record) => ({
+        disabled: record.progress === '100%',
+      }),
+      onChange: (selectedRowKeys) => {
+        setSelectedKeys(selectedRowKeys);
+      },
+    }
+    : undefined;
+
+  const batchRefundTasks = async () => {
+    const res = await API.post('/api/mj/refund', {
+      ids: selectedKeys.map((key) => parseInt(key)),
+    });
+    const { success, message, data } = res.data;
+    if (success) {
+      showSuccess(
+        t('已退款 {{refunded}} 个任务，跳过 {{skipped}} 个', {
+          refunded: data.refunded,
+          skipped: data.skipped,
+        }),
+      );
+      setSelectedKeys([]);
+      await loadLogs(activePage, pageSize);
+    } else {
+      showError(message);
+    }
+  };
+
   const copyText = async (text) => {
     if (await copy(text)) {
       showSuccess(t('已复制：') + text);
@@ -875,7 +909,25 @@ const LogsTable = () => {
 
                   {/* 操作按钮区域 */}
                   <div className="flex justify-between items-center">
-                    <div></div>
+                    <div>
+                      {isAdminUser && (
+                        <Button
+                          theme='light'
+                          type='danger'
+                          disabled={selectedKeys.length === 0}
+                          onClick={() => {
+                            Modal.confirm({
+                              title: t('确定要将所选任务标记为失败并退款吗？'),
+                              content: t('已完成的任务会被跳过，此操作不可逆'),
+                              onOk: () => batchRefundTasks(),
+                            });
+                          }}
+                          className="!rounded-full"
+                        >
+                          {t('批量退款')}
+                        </Button>
+                      )}
+                    </div>
                     <div className="flex gap-2">
                       <Button
                         type='primary'
@@ -922,6 +974,7 @@ const LogsTable = () => {
             columns={getVisibleColumns()}
             dataSource={logs}
             rowKey='key'
+            rowSelection={rowSelection}
             loading={loading}
             scroll={{ x: 'max-content' }}
             className="rounded-xl overflow-hidden"
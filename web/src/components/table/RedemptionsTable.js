@@ -13,7 +13,8 @@ import {
   XCircle,
   Minus,
   HelpCircle,
-  Coins
+  Coins,
+  Gift
 } from 'lucide-react';
 
 import { ITEMS_PER_PAGE } from '../../constants';
@@ -58,7 +59,14 @@ function renderTimestamp(timestamp) {
 const RedemptionsTable = () => {
   const { t } = useTranslation();
 
-  const renderStatus = (status) => {
+  const renderStatus = (status, record) => {
+    if (status === 1 && record.expired_time && record.expired_time * 1000 < Date.now()) {
+      return (
+        <Tag color='orange' size='large' shape='circle' prefixIcon={<XCircle size={14} />}>
+          {t('已过期')}
+        </Tag>
+      );
+    }
     switch (status) {
       case 1:
         return (
@@ -101,13 +109,24 @@ const RedemptionsTable = () => {
       dataIndex: 'status',
       key: 'status',
       render: (text, record, index) => {
-        return <div>{renderStatus(text)}</div>;
+        return <div>{renderStatus(text, record)}</div>;
       },
     },
     {
       title: t('额度'),
       dataIndex: 'quota',
       render: (text, record, index) => {
+        if (record.subscription_plan_id > 0) {
+          return (
+            <div>
+              <Tag size={'large'} color={'violet'} shape='circle' prefixIcon={<Gift size={14} />}>
+                {record.plan_name || `#${record.subscription_plan_id}`}
+                {record.subscription_duration > 0 && ` · ${record.subscription_duration}${t('天')}`}
+                {record.is_gift && ` · ${t('礼品')}`}
+              </Tag>
+            </div>
+          );
+        }
         return (
           <div>
             <Tag size={'large'} color={'grey'} shape='circle' prefixIcon={<Coins size={14} />}>
@@ -124,6 +143,13 @@ const RedemptionsTable = () => {
         return <div>{renderTimestamp(text)}</div>;
       },
     },
+    {
+      title: t('过期时间'),
+      dataIndex: 'expired_time',
+      render: (text, record, index) => {
+        return <div>{text ? renderTimestamp(text) : t('永不过期')}</div>;
+      },
+    },
     {
       title: t('兑换人ID'),
       dataIndex: 'used_user_id',
@@ -1,5 +1,5 @@
 import React, { useEffect, useState } from 'react';
-import { API, showError, showSuccess, renderNumber } from '../../helpers';
+import { API, showError, showSuccess, renderNumber, renderSubscriptionQuota, renderSubscriptionResetPeriod } from '../../helpers';
 
 import {
   Package,
@@ -91,9 +91,11 @@ const SubscriptionPlansTable = () => {
     {
       title: t('模型配额'),
       dataIndex: 'model_quotas',
-      render: (text) => {
+      render: (text, record) => {
         try {
           const quotas = JSON.parse(text || '{}');
+          const units = JSON.parse(record.quota_units || '{}') || {};
+          const periods = JSON.parse(record.reset_periods || '{}') || {};
           const quotaList = Object.entries(quotas);
           if (quotaList.length === 0) return '-';
           
@@ -101,7 +103,10 @@ const SubscriptionPlansTable = () => {
             <Tooltip content={
               <div>
                 {quotaList.map(([model, quota]) => (
-                  <div key={model}>{model}: {quota}次</div>
+                  <div key={model}>
+                    {model}: {renderSubscriptionResetPeriod(periods[model])}{' '}
+                    {renderSubscriptionQuota(quota, units[model])}
+                  </div>
                 ))}
               </div>
             }>
@@ -25,7 +25,10 @@ import {
   Typography,
   Tooltip,
   Progress,
-  Descriptions
+  Descriptions,
+  Select,
+  InputNumber,
+  TextArea
 } from '@douyinfe/semi-ui';
 import {
   IllustrationNoResult,
@@ -50,6 +53,11 @@ const UserSubscriptionsTable = () => {
   const [searchKeyword, setSearchKeyword] = useState('');
   const [showDetail, setShowDetail] = useState(false);
   const [selectedSubscription, setSelectedSubscription] = useState(null);
+  const [cancelingSubscription, setCancelingSubscription] = useState(null);
+  const [cancelQuote, setCancelQuote] = useState(null);
+  const [cancelForm, setCancelForm] = useState({});
+  const [canceling, setCanceling] = useState(false);
+  const [pendingRefunds, setPendingRefunds] = useState([]);
 
   const columns = [
     {
@@ -86,6 +94,10 @@ const UserSubscriptionsTable = () => {
       title: t('有效期'),
       dataIndex: 'start_time',
       render: (startTime, record) => {
+        // 待支付的订阅在激活后才开始计算有效期
+        if (!startTime) {
+          return <Text type='tertiary'>-</Text>;
+        }
         const start = new Date(startTime * 1000);
         const end = new Date(record.end_time * 1000);
         const now = new Date();
@@ -126,9 +138,21 @@ const UserSubscriptionsTable = () => {
         switch (status) {
           case 1:
             return (
-              <Tag color='green' size='large' shape='circle' prefixIcon={<CheckCircle size={14} />}>
-                {t('激活')}
-              </Tag>
+              <Space spacing={4}>
+                <Tag color='green' size='large' shape='circle' prefixIcon={<CheckCircle size={14} />}>
+                  {t('激活')}
+                </Tag>
+                {record.auto_renew && (
+                  <Tag color='cyan' size='large' shape='circle'>
+                    {t('自动续费')}
+                  </Tag>
+                )}
+                {record.cancel_at_period_end && (
+                  <Tag color='orange' size='large' shape='circle'>
+                    {t('到期后取消')}
+                  </Tag>
+                )}
+              </Space>
             );
           case 2:
             return (
@@ -142,6 +166,18 @@ const UserSubscriptionsTable = () => {
                 {t('取消')}
               </Tag>
             );
+          case 4:
+            return (
+              <Tag color='blue' size='large' shape='circle' prefixIcon={<Clock size={14} />}>
+                {t('待支付')}
+              </Tag>
+            );
+          case 5:
+            return (
+              <Tag color='gray' size='large' shape='circle' prefixIcon={<XCircle size={14} />}>
+                {t('支付超时')}
+              </Tag>
+            );
           default:
             return (
               <Tag color='gray' size='large' shape='circle'>
@@ -153,24 +189,18 @@ const UserSubscriptionsTable = () => {
     },
     {
       title: t('配额使用'),
-      dataIndex: 'model_quotas',
-      render: (quotas, record) => {
+      dataIndex: 'quotas',
+      render: (quotas) => {
         try {
-          const remainingQuotas = JSON.parse(quotas || '{}');
-          const usedQuotas = JSON.parse(record.used_quotas || '{}');
-          const planQuotas = JSON.parse(record.subscription_plan?.model_quotas || '{}');
-          
-          const models = Object.keys(planQuotas);
+          const models = quotas || [];
           if (models.length === 0) return '-';
           
           let totalUsed = 0;
           let totalQuota = 0;
           
-          models.forEach(model => {
-            const used = usedQuotas[model] || 0;
-            const total = planQuotas[model] || 0;
-            totalUsed += used;
-            totalQuota += total;
+          models.forEach(quota => {
+            totalUsed += quota.used || 0;
+            totalQuota += quota.total || 0;
           });
           
           const percentage = totalQuota > 0 ? (totalUsed / totalQuota) * 100 : 0;
@@ -178,13 +208,14 @@ const UserSubscriptionsTable = () => {
           return (
             <Tooltip content={
               <div>
-                {models.map(model => {
-                  const used = usedQuotas[model] || 0;
-                  const total = planQuotas[model] || 0;
-                  const remaining = remainingQuotas[model] || 0;
+                {models.map(quota => {
+                  const used = quota.used || 0;
+                  const total = quota.total || 0;
+                  const reserved = quota.reserved || 0;
+                  const remaining = Math.max(total - used - reserved, 0);
                   return (
-                    <div key={model}>
-                      {model}: {used}/{total} (剩余{remaining})
+                    <div key={quota.model_name}>
+                      {quota.model_name}: {used}/{total} (剩余{remaining}{reserved > 0 ? `，${t('预留')}${reserved}` : ''})
                     </div>
                   );
                 })}
@@ -215,16 +246,28 @@ const UserSubscriptionsTable = () => {
       title: t('操作'),
       dataIndex: 'operate',
       render: (text, record) => (
-        <Button
-          theme="borderless"
-          icon={<IconEyeOpened />}
-          onClick={() => {
-            setSelectedSubscription(record);
-            setShowDetail(true);
-          }}
-        >
-          {t('查看详情')}
-        </Button>
+        <Space>
+          <Button
+            theme="borderless"
+            icon={<IconEyeOpened />}
+            onClick={() => {
+              setSelectedSubscription(record);
+              setShowDetail(true);
+            }}
+          >
+            {t('查看详情')}
+          </Button>
+          {record.status === 1 && record.cancel_at_period_end && (
+            <Button theme="borderless" onClick={() => resumeSubscription(record)}>
+              {t('撤销取消')}
+            </Button>
+          )}
+          {record.status === 1 && (
+            <Button theme="borderless" type="danger" onClick={() => openCancel(record)}>
+              {t('取消订阅')}
+            </Button>
+          )}
+        </Space>
       ),
     },
   ];
@@ -242,8 +285,81 @@ const UserSubscriptionsTable = () => {
     setLoading(false);
   };
 
+  const loadPendingRefunds = async () => {
+    const res = await API.get('/api/subscription/admin/cancellations?refund_status=pending&page_size=100');
+    const { success, message, data } = res.data;
+    if (success) {
+      setPendingRefunds(data.cancellations || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const openCancel = async (subscription) => {
+    setCancelingSubscription(subscription);
+    setCancelQuote(null);
+    const res = await API.get(`/api/subscription/admin/users/${subscription.id}/cancel`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setCancelQuote(data);
+      setCancelForm({
+        immediate: true,
+        refund_method: 'balance',
+        refund_money: data.refund_money,
+        reason: ''
+      });
+    } else {
+      showError(message);
+    }
+  };
+
+  const handleCancel = async () => {
+    setCanceling(true);
+    try {
+      const payload = { ...cancelForm };
+      if (payload.refund_method === 'none') {
+        delete payload.refund_money;
+      }
+      const res = await API.post(`/api/subscription/admin/users/${cancelingSubscription.id}/cancel`, payload);
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t(message));
+        setCancelingSubscription(null);
+        loadSubscriptions(activePage);
+        loadPendingRefunds();
+      } else {
+        showError(message);
+      }
+    } finally {
+      setCanceling(false);
+    }
+  };
+
+  const resumeSubscription = async (subscription) => {
+    const res = await API.delete(`/api/subscription/admin/users/${subscription.id}/cancel`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t(message));
+      loadSubscriptions(activePage);
+    } else {
+      showError(message);
+    }
+  };
+
+  const completeRefund = async (record) => {
+    const res = await API.post(`/api/subscription/admin/cancellations/${record.id}/refunded`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t(message));
+      loadPendingRefunds();
+    } else {
+      showError(message);
+    }
+  };
+
   useEffect(() => {
     loadSubscriptions();
+    loadPendingRefunds();
   }, []);
 
   const handlePageChange = (page) => {
@@ -254,12 +370,9 @@ const UserSubscriptionsTable = () => {
   const renderSubscriptionDetail = () => {
     if (!selectedSubscription) return null;
     
-    const { subscription_plan, user, model_quotas, used_quotas } = selectedSubscription;
+    const { subscription_plan, user, quotas } = selectedSubscription;
     
     try {
-      const remainingQuotas = JSON.parse(model_quotas || '{}');
-      const usedQuotasObj = JSON.parse(used_quotas || '{}');
-      const planQuotas = JSON.parse(subscription_plan?.model_quotas || '{}');
       
       return (
         <Space vertical style={{ width: '100%' }} spacing={16}>
@@ -278,16 +391,21 @@ const UserSubscriptionsTable = () => {
           
           <Card title={t('配额详情')} size="small">
             <Space vertical style={{ width: '100%' }}>
-              {Object.entries(planQuotas).map(([model, totalQuota]) => {
-                const used = usedQuotasObj[model] || 0;
-                const remaining = remainingQuotas[model] || 0;
+              {(quotas || []).map((quota) => {
+                const model = quota.model_name;
+                const totalQuota = quota.total || 0;
+                const used = quota.used || 0;
+                const reserved = quota.reserved || 0;
+                const remaining = Math.max(totalQuota - used - reserved, 0);
                 const percentage = totalQuota > 0 ? (used / totalQuota) * 100 : 0;
                 
                 return (
                   <div key={model} style={{ marginBottom: 12 }}>
                     <Space style={{ width: '100%', justifyContent: 'space-between' }}>
                       <Text strong>{model}</Text>
-                      <Text size="small">{used}/{totalQuota} (剩余{remaining})</Text>
+                      <Text size="small">
+                        {used}/{totalQuota} (剩余{remaining}{reserved > 0 ? `，${t('预留')}${reserved}` : ''})
+                      </Text>
                     </Space>
                     <Progress
                       percent={percentage}
@@ -350,6 +468,108 @@ const UserSubscriptionsTable = () => {
         />
       </Card>
 
+      {pendingRefunds.length > 0 && (
+        <Card title={t('待处理的原路退款')} style={{ marginTop: 16 }}>
+          <Table
+            size="small"
+            pagination={false}
+            dataSource={pendingRefunds}
+            rowKey="id"
+            columns={[
+              { title: t('用户ID'), dataIndex: 'user_id' },
+              { title: t('套餐'), dataIndex: 'plan_name' },
+              { title: t('退款金额'), dataIndex: 'refund_money', render: (money) => `¥${money}` },
+              { title: t('取消原因'), dataIndex: 'reason', render: (reason) => reason || '-' },
+              {
+                title: t('取消时间'),
+                dataIndex: 'created_time',
+                render: (time) => new Date(time * 1000).toLocaleString()
+              },
+              {
+                title: t('操作'),
+                dataIndex: 'operate',
+                render: (text, record) => (
+                  <Button theme="borderless" onClick={() => completeRefund(record)}>
+                    {t('确认已退款')}
+                  </Button>
+                )
+              }
+            ]}
+          />
+          <Text size="small" type="tertiary">
+            {t('请先在支付平台完成退款，再确认已退款')}
+          </Text>
+        </Card>
+      )}
+
+      <Modal
+        title={t('取消订阅')}
+        visible={!!cancelingSubscription}
+        onCancel={() => setCancelingSubscription(null)}
+        onOk={handleCancel}
+        okText={t('确认取消')}
+        okButtonProps={{ type: 'danger', disabled: !cancelQuote, loading: canceling }}
+        width={500}
+      >
+        {cancelingSubscription && cancelQuote && (
+          <Space vertical align="start" style={{ width: '100%' }} spacing={12}>
+            <Text>
+              {t('用户')}: <Text strong>{cancelingSubscription.user?.username || cancelingSubscription.user_id}</Text>
+              {'，'}
+              {t('套餐')}: <Text strong>{cancelingSubscription.subscription_plan?.name || '-'}</Text>
+            </Text>
+            <Select
+              style={{ width: '100%' }}
+              value={cancelForm.immediate}
+              onChange={(immediate) => setCancelForm({ ...cancelForm, immediate })}
+              optionList={[
+                { label: t('立即取消'), value: true },
+                { label: t('到期后取消，不再续费'), value: false }
+              ]}
+            />
+            {cancelForm.immediate && (
+              <>
+                <Select
+                  style={{ width: '100%' }}
+                  value={cancelForm.refund_method}
+                  onChange={(refund_method) => setCancelForm({ ...cancelForm, refund_method })}
+                  optionList={[
+                    { label: t('退款到余额'), value: 'balance' },
+                    ...(cancelQuote.refund_methods.includes('provider')
+                      ? [{ label: t('原路退款（需在支付平台处理）'), value: 'provider' }]
+                      : []),
+                    { label: t('已线下手动退款'), value: 'manual' },
+                    { label: t('不退款'), value: 'none' }
+                  ]}
+                />
+                {cancelForm.refund_method !== 'none' && (
+                  <Space>
+                    <Text>{t('退款金额')}</Text>
+                    <InputNumber
+                      prefix="¥"
+                      min={0}
+                      max={cancelingSubscription.purchase_price}
+                      precision={2}
+                      value={cancelForm.refund_money}
+                      onChange={(refund_money) => setCancelForm({ ...cancelForm, refund_money })}
+                    />
+                    <Text size="small" type="tertiary">
+                      {t('按比例可退')}: ¥{cancelQuote.refund_money.toFixed(2)}
+                    </Text>
+                  </Space>
+                )}
+              </>
+            )}
+            <TextArea
+              placeholder={t('取消原因（可选）')}
+              maxCount={200}
+              value={cancelForm.reason}
+              onChange={(reason) => setCancelForm({ ...cancelForm, reason })}
+            />
+          </Space>
+        )}
+      </Modal>
+
       <Modal
         title={t('订阅详情')}
         visible={showDetail}
@@ -125,4 +125,14 @@ export const CHANNEL_OPTIONS = [
     color: 'blue',
     label: 'Coze',
   },
+  {
+    value: 50,
+    color: 'purple',
+    label: '可灵视频',
+  },
+  {
+    value: 51,
+    color: 'purple',
+    label: 'Runway 视频',
+  },
 ];
@@ -867,6 +867,32 @@ export function renderQuota(quota, digits = 2) {
   return renderNumber(quota);
 }
 
+// 按订阅配额的计量单位展示数量：request 为次数，tokens 为 token 数，quota 为额度
+export function renderSubscriptionQuota(amount, unit) {
+  switch (unit) {
+    case 'tokens':
+      return renderNumber(amount) + ' tokens';
+    case 'quota':
+      return renderQuota(amount);
+    default:
+      return amount + i18next.t('次');
+  }
+}
+
+// 订阅配额重置周期的展示文本，不重置时返回空字符串
+export function renderSubscriptionResetPeriod(period) {
+  switch (period) {
+    case 'daily':
+      return i18next.t('每日');
+    case 'weekly':
+      return i18next.t('每周');
+    case 'monthly':
+      return i18next.t('每月');
+    default:
+      return '';
+  }
+}
+
 function isValidGroupRatio(ratio) {
   return Number.isFinite(ratio) && ratio !== -1;
 }
@@ -64,6 +64,8 @@ function type2secretPrompt(type) {
       return '按照如下格式输入：AppId|SecretId|SecretKey';
     case 33:
       return '按照如下格式输入：Ak|Sk|Region';
+    case 50:
+      return '按照如下格式输入：AccessKey|SecretKey';
     default:
       return '请输入渠道对应的鉴权密钥';
   }
@@ -12,8 +12,11 @@ import {
 import {
   AutoComplete,
   Button,
+  DatePicker,
   Input,
+  InputNumber,
   Modal,
+  Select,
   SideSheet,
   Space,
   Spin,
@@ -40,9 +43,14 @@ const EditRedemption = (props) => {
     name: '',
     quota: 100000,
     count: 1,
+    subscription_plan_id: 0,
+    subscription_duration: 0,
+    expired_time: 0,
   };
   const [inputs, setInputs] = useState(originInputs);
-  const { name, quota, count } = inputs;
+  const [plans, setPlans] = useState([]);
+  const { name, quota, count, subscription_plan_id, subscription_duration, expired_time } = inputs;
+  const isSubscription = subscription_plan_id > 0;
 
   const handleCancel = () => {
     props.handleClose();
@@ -64,6 +72,18 @@ const EditRedemption = (props) => {
     setLoading(false);
   };
 
+  const loadPlans = async () => {
+    const res = await API.get('/api/subscription/plans');
+    const { success, data } = res.data;
+    if (success) {
+      setPlans(data || []);
+    }
+  };
+
+  useEffect(() => {
+    loadPlans();
+  }, []);
+
   useEffect(() => {
     if (isEdit) {
       loadRedemption().then(() => {
@@ -78,12 +98,22 @@ const EditRedemption = (props) => {
     let name = inputs.name;
     if (!isEdit && inputs.name === '') {
       // set default name
-      name = renderQuota(quota);
+      // 名称限制为 20 字节，套餐名称过长时使用套餐ID
+      const plan = plans.find((p) => p.id === subscription_plan_id);
+      if (isSubscription) {
+        name = plan && new TextEncoder().encode(plan.name).length <= 20 ? plan.name : `SUB-${subscription_plan_id}`;
+      } else {
+        name = renderQuota(quota);
+      }
     }
     setLoading(true);
     let localInputs = inputs;
     localInputs.count = parseInt(localInputs.count);
-    localInputs.quota = parseInt(localInputs.quota);
+    // 订阅套餐兑换码不增加余额
+    localInputs.quota = isSubscription ? 0 : parseInt(localInputs.quota);
+    localInputs.subscription_plan_id = parseInt(localInputs.subscription_plan_id) || 0;
+    localInputs.subscription_duration = parseInt(localInputs.subscription_duration) || 0;
+    localInputs.expired_time = parseInt(localInputs.expired_time) || 0;
     localInputs.name = name;
     let res;
     if (isEdit) {
@@ -243,30 +273,74 @@ const EditRedemption = (props) => {
 
               <div className="space-y-4">
                 <div>
-                  <div className="flex justify-between mb-2">
-                    <Text strong>{t('额度')}</Text>
-                    <Text type="tertiary">{renderQuotaWithPrompt(quota)}</Text>
-                  </div>
-                  <AutoComplete
-                    placeholder={t('请输入额度')}
-                    onChange={(value) => handleInputChange('quota', value)}
-                    value={quota}
-                    autoComplete="new-password"
-                    type="number"
+                  <Text strong className="block mb-2">{t('兑换内容')}</Text>
+                  <Select
+                    value={subscription_plan_id}
+                    onChange={(value) => handleInputChange('subscription_plan_id', value)}
                     size="large"
                     className="w-full !rounded-lg"
-                    prefix={<IconCreditCard />}
-                    data={[
-                      { value: 500000, label: '1$' },
-                      { value: 5000000, label: '10$' },
-                      { value: 25000000, label: '50$' },
-                      { value: 50000000, label: '100$' },
-                      { value: 250000000, label: '500$' },
-                      { value: 500000000, label: '1000$' },
+                    optionList={[
+                      { value: 0, label: t('账户额度') },
+                      ...plans.map((plan) => ({
+                        value: plan.id,
+                        label: `${t('订阅套餐')}: ${plan.name}${plan.status === 1 ? '' : ` (${t('已禁用')})`}`,
+                      })),
                     ]}
                   />
                 </div>
 
+                {isSubscription ? (
+                  <div>
+                    <Text strong className="block mb-2">{t('订阅有效期（天）')}</Text>
+                    <InputNumber
+                      value={subscription_duration}
+                      min={0}
+                      max={3650}
+                      onChange={(value) => handleInputChange('subscription_duration', value)}
+                      size="large"
+                      className="w-full !rounded-lg"
+                    />
+                    <Text type="tertiary" size="small">{t('为 0 时使用套餐的有效期')}</Text>
+                  </div>
+                ) : (
+                  <div>
+                    <div className="flex justify-between mb-2">
+                      <Text strong>{t('额度')}</Text>
+                      <Text type="tertiary">{renderQuotaWithPrompt(quota)}</Text>
+                    </div>
+                    <AutoComplete
+                      placeholder={t('请输入额度')}
+                      onChange={(value) => handleInputChange('quota', value)}
+                      value={quota}
+                      autoComplete="new-password"
+                      type="number"
+                      size="large"
+                      className="w-full !rounded-lg"
+                      prefix={<IconCreditCard />}
+                      data={[
+                        { value: 500000, label: '1$' },
+                        { value: 5000000, label: '10$' },
+                        { value: 25000000, label: '50$' },
+                        { value: 50000000, label: '100$' },
+                        { value: 250000000, label: '500$' },
+                        { value: 500000000, label: '1000$' },
+                      ]}
+                    />
+                  </div>
+                )}
+
+                <div>
+                  <Text strong className="block mb-2">{t('过期时间')}</Text>
+                  <DatePicker
+                    type="dateTime"
+                    value={expired_time ? expired_time * 1000 : undefined}
+                    onChange={(date) => handleInputChange('expired_time', date ? Math.floor(new Date(date).getTime() / 1000) : 0)}
+                    placeholder={t('永不过期')}
+                    size="large"
+                    className="w-full !rounded-lg"
+                  />
+                </div>
+
                 {!isEdit && (
                   <div>
                     <Text strong className="block mb-2">{t('生成数量')}</Text>
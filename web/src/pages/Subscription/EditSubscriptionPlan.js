@@ -13,7 +13,7 @@ import {
   Tag,
   Modal
 } from '@douyinfe/semi-ui';
-import { Package, DollarSign, Calendar, Settings, Plus, Trash2 } from 'lucide-react';
+import { Package, DollarSign, Calendar, Settings, Plus, Trash2, ShieldCheck } from 'lucide-react';
 import { useTranslation } from 'react-i18next';
 
 const { Text, Title } = Typography;
@@ -23,16 +23,32 @@ const EditSubscriptionPlan = ({ plan, onCancel, onOk }) => {
   const [loading, setLoading] = useState(false);
   const [formApi, setFormApi] = useState();
   const [modelQuotas, setModelQuotas] = useState([]);
+  const [groupOptions, setGroupOptions] = useState([]);
 
   const isEdit = !!plan;
 
+  useEffect(() => {
+    API.get('/api/group/').then((res) => {
+      const { success, data } = res.data;
+      if (success) {
+        setGroupOptions((data || []).map((group) => ({ label: group, value: group })));
+      }
+    });
+  }, []);
+
   useEffect(() => {
     if (plan) {
       // 编辑模式，填充表单数据
       const quotas = JSON.parse(plan.model_quotas || '{}');
+      const units = JSON.parse(plan.quota_units || '{}') || {};
+      const periods = JSON.parse(plan.reset_periods || '{}') || {};
+      const groups = JSON.parse(plan.model_groups || '{}') || {};
       const quotaList = Object.entries(quotas).map(([model, quota]) => ({
         model,
-        quota: parseInt(quota)
+        quota: parseInt(quota),
+        unit: units[model] || 'request',
+        period: periods[model] || 'none',
+        members: groups[model] || []
       }));
       setModelQuotas(quotaList);
       
@@ -42,7 +58,13 @@ const EditSubscriptionPlan = ({ plan, onCancel, onOk }) => {
           description: plan.description,
           price: plan.price,
           duration: plan.duration,
-          status: plan.status
+          status: plan.status,
+          is_trial: plan.is_trial,
+          purchase_limit: plan.purchase_limit,
+          allowed_groups: JSON.parse(plan.allowed_groups || '[]') || [],
+          new_user_days: plan.new_user_days,
+          require_email: plan.require_email,
+          require_oauth: plan.require_oauth
         });
       }
     } else {
@@ -71,13 +93,30 @@ const EditSubscriptionPlan = ({ plan, onCancel, onOk }) => {
     setLoading(true);
     
     const quotasObj = {};
-    modelQuotas.forEach(({ model, quota }) => {
+    const unitsObj = {};
+    const periodsObj = {};
+    const groupsObj = {};
+    modelQuotas.forEach(({ model, quota, unit, period, members }) => {
       quotasObj[model] = quota;
+      unitsObj[model] = unit || 'request';
+      periodsObj[model] = period || 'none';
+      if (members && members.length > 0) {
+        groupsObj[model] = members;
+      }
     });
 
     const data = {
       ...values,
-      model_quotas: quotasObj
+      is_trial: !!values.is_trial,
+      purchase_limit: values.purchase_limit || 0,
+      allowed_groups: values.allowed_groups || [],
+      new_user_days: values.new_user_days || 0,
+      require_email: !!values.require_email,
+      require_oauth: !!values.require_oauth,
+      model_quotas: quotasObj,
+      quota_units: unitsObj,
+      reset_periods: periodsObj,
+      model_groups: groupsObj
     };
 
     try {
@@ -103,7 +142,7 @@ const EditSubscriptionPlan = ({ plan, onCancel, onOk }) => {
   };
 
   const addModelQuota = () => {
-    setModelQuotas([...modelQuotas, { model: '', quota: 100 }]);
+    setModelQuotas([...modelQuotas, { model: '', quota: 100, unit: 'request', period: 'none', members: [] }]);
   };
 
   const removeModelQuota = (index) => {
@@ -117,6 +156,23 @@ const EditSubscriptionPlan = ({ plan, onCancel, onOk }) => {
     setModelQuotas(newQuotas);
   };
 
+  const quotaUnitOptions = [
+    { label: t('按次数'), value: 'request', suffix: t('次') },
+    { label: t('按 Token'), value: 'tokens', suffix: 'tokens' },
+    { label: t('按额度'), value: 'quota', suffix: t('额度') }
+  ];
+
+  // 配置重置周期后，配额数量为每个周期内的额度
+  const resetPeriodOptions = [
+    { label: t('不重置'), value: 'none' },
+    { label: t('每日重置'), value: 'daily' },
+    { label: t('每周重置'), value: 'weekly' },
+    { label: t('每月重置'), value: 'monthly' }
+  ];
+
+  const getQuotaUnitSuffix = (unit) =>
+    (quotaUnitOptions.find((option) => option.value === unit) || quotaUnitOptions[0]).suffix;
+
   const commonModels = [
     'gpt-4',
     'gpt-4-turbo',
@@ -200,6 +256,58 @@ const EditSubscriptionPlan = ({ plan, onCancel, onOk }) => {
             />
           </div>
 
+          <div>
+            <Title heading={6}>
+              <ShieldCheck size={16} style={{ marginRight: 8 }} />
+              {t('购买限制')}
+            </Title>
+            <Divider margin={12} />
+
+            <Form.Checkbox field="is_trial" noLabel>
+              {t('试用套餐：每个用户限购一次，不支持自动续费、赠送和变更为该套餐')}
+            </Form.Checkbox>
+
+            <Form.InputNumber
+              field="purchase_limit"
+              label={t('每个用户限购次数')}
+              extraText={t('0 表示不限，试用套餐固定为 1 次')}
+              min={0}
+              precision={0}
+              style={{ width: '100%' }}
+            />
+
+            <Form.Select
+              field="allowed_groups"
+              label={t('允许购买的用户分组')}
+              placeholder={t('不限')}
+              multiple
+              allowCreate
+              filter
+              optionList={groupOptions}
+              style={{ width: '100%' }}
+            />
+
+            <Form.InputNumber
+              field="new_user_days"
+              label={t('仅限注册天数内的新用户')}
+              extraText={t('0 表示不限')}
+              min={0}
+              precision={0}
+              suffix="天"
+              style={{ width: '100%' }}
+            />
+
+            <Form.Checkbox field="require_email" noLabel>
+              {t('需要已绑定邮箱')}
+            </Form.Checkbox>
+            <Form.Checkbox field="require_oauth" noLabel>
+              {t('需要已绑定第三方账号')}
+            </Form.Checkbox>
+            <Text size="small" type="tertiary">
+              {t('设有购买限制的套餐不支持赠送，管理员生成的兑换码不受限制')}
+            </Text>
+          </div>
+
           <div>
             <Title heading={6}>
               <Settings size={16} style={{ marginRight: 8 }} />
@@ -210,29 +318,54 @@ const EditSubscriptionPlan = ({ plan, onCancel, onOk }) => {
             <Space vertical style={{ width: '100%' }} spacing={12}>
               {modelQuotas.map((quota, index) => (
                 <Card key={index} size="small" style={{ background: '#f8f9fa' }}>
-                  <Space style={{ width: '100%' }}>
+                  <Space vertical align="start" style={{ width: '100%' }} spacing={8}>
+                    <Space style={{ width: '100%' }}>
+                      <Select
+                        placeholder={t('模型、通配符或模型组名')}
+                        value={quota.model}
+                        onChange={(value) => updateModelQuota(index, 'model', value)}
+                        style={{ width: 200 }}
+                        filter
+                        allowCreate
+                        optionList={commonModels.map(model => ({ label: model, value: model }))}
+                      />
+                      <Select
+                        value={quota.unit || 'request'}
+                        onChange={(value) => updateModelQuota(index, 'unit', value)}
+                        style={{ width: 120 }}
+                        optionList={quotaUnitOptions.map(({ label, value }) => ({ label, value }))}
+                      />
+                      <InputNumber
+                        placeholder={t('配额数量')}
+                        value={quota.quota}
+                        onChange={(value) => updateModelQuota(index, 'quota', value)}
+                        min={1}
+                        suffix={getQuotaUnitSuffix(quota.unit)}
+                        style={{ width: 180 }}
+                      />
+                      <Select
+                        value={quota.period || 'none'}
+                        onChange={(value) => updateModelQuota(index, 'period', value)}
+                        style={{ width: 120 }}
+                        optionList={resetPeriodOptions}
+                      />
+                      <Button
+                        type="danger"
+                        theme="borderless"
+                        icon={<Trash2 size={14} />}
+                        onClick={() => removeModelQuota(index)}
+                      />
+                    </Space>
                     <Select
-                      placeholder={t('选择模型')}
-                      value={quota.model}
-                      onChange={(value) => updateModelQuota(index, 'model', value)}
-                      style={{ width: 200 }}
+                      multiple
                       filter
+                      allowCreate
+                      placeholder={t('组内模型（可选），填写后上面的名称作为模型组名，组内模型共享该配额')}
+                      value={quota.members || []}
+                      onChange={(value) => updateModelQuota(index, 'members', value)}
+                      style={{ width: '100%' }}
                       optionList={commonModels.map(model => ({ label: model, value: model }))}
                     />
-                    <InputNumber
-                      placeholder={t('配额数量')}
-                      value={quota.quota}
-                      onChange={(value) => updateModelQuota(index, 'quota', value)}
-                      min={1}
-                      suffix="次"
-                      style={{ width: 150 }}
-                    />
-                    <Button
-                      type="danger"
-                      theme="borderless"
-                      icon={<Trash2 size={14} />}
-                      onClick={() => removeModelQuota(index)}
-                    />
                   </Space>
                 </Card>
               ))}
@@ -245,6 +378,9 @@ const EditSubscriptionPlan = ({ plan, onCancel, onOk }) => {
               >
                 {t('添加模型配额')}
               </Button>
+              <Text size="small" type="tertiary">
+                {t('名称中的 * 匹配任意字符，例如 claude-* 表示所有 Claude 模型共享该配额。一个模型匹配多个配额时，依次使用：同名模型配额、列出该模型的模型组、通配符（越具体越优先），前一个用完后使用下一个')}
+              </Text>
             </Space>
           </div>
         </Space>
@@ -1,5 +1,14 @@
 import React, { useEffect, useState } from 'react';
-import { API, showError, showSuccess } from '../../helpers';
+import {
+  API,
+  copy,
+  renderQuota,
+  renderSubscriptionQuota,
+  renderSubscriptionResetPeriod,
+  showError,
+  showSuccess,
+  timestamp2string
+} from '../../helpers';
 
 import {
   Package,
@@ -9,7 +18,8 @@ import {
   Star,
   Zap,
   Shield,
-  CreditCard
+  CreditCard,
+  Gift
 } from 'lucide-react';
 import {
   Button,
@@ -25,7 +35,9 @@ import {
   Tag,
   Divider,
   List,
-  Badge
+  Badge,
+  Switch,
+  TextArea
 } from '@douyinfe/semi-ui';
 import { useTranslation } from 'react-i18next';
 
@@ -39,6 +51,18 @@ const SubscriptionPurchase = () => {
   const [showPurchaseModal, setShowPurchaseModal] = useState(false);
   const [selectedPlan, setSelectedPlan] = useState(null);
   const [userQuotas, setUserQuotas] = useState({});
+  const [userSubscriptions, setUserSubscriptions] = useState([]);
+  const [changingSubscription, setChangingSubscription] = useState(null);
+  const [changePlanId, setChangePlanId] = useState(null);
+  const [changeQuote, setChangeQuote] = useState(null);
+  const [changing, setChanging] = useState(false);
+  const [cancelingSubscription, setCancelingSubscription] = useState(null);
+  const [cancelQuote, setCancelQuote] = useState(null);
+  const [cancelImmediate, setCancelImmediate] = useState(false);
+  const [refundMethod, setRefundMethod] = useState('balance');
+  const [cancelReason, setCancelReason] = useState('');
+  const [canceling, setCanceling] = useState(false);
+  const [gifts, setGifts] = useState([]);
 
   const loadPlans = async () => {
     try {
@@ -60,15 +84,64 @@ const SubscriptionPurchase = () => {
       const { success, data } = res.data;
       if (success) {
         setUserQuotas(data.quotas || {});
+        setUserSubscriptions(data.subscriptions || []);
       }
     } catch (error) {
       // 用户可能没有订阅，忽略错误
     }
   };
 
+  const loadGifts = async () => {
+    try {
+      const res = await API.get('/api/subscription/gift');
+      const { success, data } = res.data;
+      if (success) {
+        setGifts(data.items || []);
+      }
+    } catch (error) {
+      // 礼品码列表加载失败不影响购买
+    }
+  };
+
+  const copyGiftKey = async (key) => {
+    if (await copy(key)) {
+      showSuccess(t('已复制到剪贴板！'));
+    }
+  };
+
+  const purchaseGift = (plan) => {
+    Modal.confirm({
+      title: t('赠送套餐'),
+      content: `${t('将使用账户余额购买')} ${plan.name} (¥${plan.price})${t('，购买后会生成一个兑换码，对方在钱包页面兑换即可获得该套餐')}`,
+      onOk: async () => {
+        try {
+          const res = await API.post('/api/subscription/gift', { plan_id: plan.id });
+          const { success, message, data } = res.data;
+          if (success) {
+            Modal.success({
+              title: t('购买礼品码成功'),
+              content: (
+                <Space vertical align="start">
+                  <Text>{t('请将以下兑换码发送给对方')}</Text>
+                  <Text strong copyable={{ content: data.key }}>{data.key}</Text>
+                </Space>
+              ),
+              centered: true,
+            });
+            loadGifts();
+          } else {
+            showError(message);
+          }
+        } catch (error) {
+          showError(error.message);
+        }
+      },
+    });
+  };
+
   const loadData = async () => {
     setLoading(true);
-    await Promise.all([loadPlans(), loadUserQuotas()]);
+    await Promise.all([loadPlans(), loadUserQuotas(), loadGifts()]);
     setLoading(false);
   };
 
@@ -76,6 +149,147 @@ const SubscriptionPurchase = () => {
     loadData();
   }, []);
 
+  const updateAutoRenew = async (subscription, autoRenew) => {
+    try {
+      const res = await API.put(`/api/subscription/my/${subscription.id}/auto-renew`, {
+        auto_renew: autoRenew
+      });
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t(message));
+        loadUserQuotas();
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      showError(error.message);
+    }
+  };
+
+  const openChangePlan = (subscription) => {
+    setChangingSubscription(subscription);
+    setChangePlanId(null);
+    setChangeQuote(null);
+  };
+
+  const loadChangeQuote = async (planId) => {
+    setChangePlanId(planId);
+    setChangeQuote(null);
+    try {
+      const res = await API.get(
+        `/api/subscription/my/${changingSubscription.id}/change?plan_id=${planId}`
+      );
+      const { success, message, data } = res.data;
+      if (success) {
+        setChangeQuote(data.change);
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      showError(error.message);
+    }
+  };
+
+  const handleChangePlan = async () => {
+    setChanging(true);
+    try {
+      const res = await API.post(`/api/subscription/my/${changingSubscription.id}/change`, {
+        plan_id: changePlanId
+      });
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('变更套餐成功'));
+        setChangingSubscription(null);
+        loadUserQuotas();
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      showError(error.message);
+    } finally {
+      setChanging(false);
+    }
+  };
+
+  const openCancel = async (subscription) => {
+    setCancelingSubscription(subscription);
+    setCancelQuote(null);
+    setCancelImmediate(false);
+    setRefundMethod('balance');
+    setCancelReason('');
+    try {
+      const res = await API.get(`/api/subscription/my/${subscription.id}/cancel`);
+      const { success, message, data } = res.data;
+      if (success) {
+        setCancelQuote(data);
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      showError(error.message);
+    }
+  };
+
+  const handleCancel = async () => {
+    setCanceling(true);
+    try {
+      const res = await API.post(`/api/subscription/my/${cancelingSubscription.id}/cancel`, {
+        immediate: cancelImmediate,
+        refund_method: refundMethod,
+        reason: cancelReason
+      });
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t(message));
+        setCancelingSubscription(null);
+        loadUserQuotas();
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      showError(error.message);
+    } finally {
+      setCanceling(false);
+    }
+  };
+
+  const resumeSubscription = async (subscription) => {
+    try {
+      const res = await API.delete(`/api/subscription/my/${subscription.id}/cancel`);
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t(message));
+        loadUserQuotas();
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      showError(error.message);
+    }
+  };
+
+  const submitPayment = (url, params) => {
+    let form = document.createElement('form');
+    form.action = url;
+    form.method = 'POST';
+    let isSafari =
+      navigator.userAgent.indexOf('Safari') > -1 &&
+      navigator.userAgent.indexOf('Chrome') < 1;
+    if (!isSafari) {
+      form.target = '_blank';
+    }
+    for (let key in params) {
+      let input = document.createElement('input');
+      input.type = 'hidden';
+      input.name = key;
+      input.value = params[key];
+      form.appendChild(input);
+    }
+    document.body.appendChild(form);
+    form.submit();
+    document.body.removeChild(form);
+  };
+
   const handlePurchase = async (values) => {
     setPurchasing(true);
     try {
@@ -83,11 +297,19 @@ const SubscriptionPurchase = () => {
         plan_id: selectedPlan.id,
         payment_method: values.payment_method
       });
-      const { success, message } = res.data;
+      const { success, message, data } = res.data;
       if (success) {
+        if (data && data.url) {
+          // 在线支付：跳转到支付页面，支付成功后订阅自动激活
+          submitPayment(data.url, data.params);
+          showSuccess(t('订单已创建，请在新页面完成支付'));
+          setShowPurchaseModal(false);
+          return;
+        }
         showSuccess(t('购买成功！'));
         setShowPurchaseModal(false);
         loadUserQuotas(); // 重新加载用户配额
+        loadPlans(); // 刷新购买资格
       } else {
         showError(message);
       }
@@ -100,7 +322,13 @@ const SubscriptionPurchase = () => {
 
   const renderPlanCard = (plan) => {
     const quotas = JSON.parse(plan.model_quotas || '{}');
+    const units = JSON.parse(plan.quota_units || '{}') || {};
+    const periods = JSON.parse(plan.reset_periods || '{}') || {};
     const quotaList = Object.entries(quotas);
+    const eligibility = plan.eligibility;
+    const restricted =
+      plan.is_trial || plan.purchase_limit > 0 || !!plan.allowed_groups || plan.new_user_days > 0 ||
+      plan.require_email || plan.require_oauth;
     
     return (
       <Card
@@ -112,7 +340,11 @@ const SubscriptionPurchase = () => {
           <Space>
             <Package size={20} />
             <Text strong size="large">{plan.name}</Text>
-            <Badge count="热门" type="danger" style={{ marginLeft: 8 }} />
+            {plan.is_trial ? (
+              <Tag color="green" style={{ marginLeft: 8 }}>{t('试用')}</Tag>
+            ) : (
+              <Badge count="热门" type="danger" style={{ marginLeft: 8 }} />
+            )}
           </Space>
         }
       >
@@ -150,7 +382,10 @@ const SubscriptionPurchase = () => {
                 <List.Item style={{ padding: '8px 0' }}>
                   <Space style={{ width: '100%', justifyContent: 'space-between' }}>
                     <Text>{model}</Text>
-                    <Tag color="blue" size="small">{quota}次</Tag>
+                    <Tag color="blue" size="small">
+                      {renderSubscriptionResetPeriod(periods[model])}{' '}
+                      {renderSubscriptionQuota(quota, units[model])}
+                    </Tag>
                   </Space>
                 </List.Item>
               )}
@@ -183,19 +418,39 @@ const SubscriptionPurchase = () => {
             </Space>
           </div>
 
+          {/* 购买资格 */}
+          {eligibility && (!eligibility.eligible || eligibility.remaining >= 0) && (
+            <Text size="small" type={eligibility.eligible ? 'tertiary' : 'danger'} style={{ textAlign: 'center' }}>
+              {eligibility.eligible
+                ? `${t('剩余可购买次数')}: ${eligibility.remaining}`
+                : t(eligibility.reason)}
+            </Text>
+          )}
+
           {/* 购买按钮 */}
           <Button
             type="primary"
             size="large"
             block
             icon={<CreditCard size={16} />}
+            disabled={eligibility && !eligibility.eligible}
             onClick={() => {
               setSelectedPlan(plan);
               setShowPurchaseModal(true);
             }}
           >
-            {t('立即购买')}
+            {plan.price > 0 ? t('立即购买') : plan.is_trial ? t('免费试用') : t('免费开通')}
           </Button>
+          {!restricted && (
+            <Button
+              size="large"
+              block
+              icon={<Gift size={16} />}
+              onClick={() => purchaseGift(plan)}
+            >
+              {t('赠送好友')}
+            </Button>
+          )}
         </Space>
       </Card>
     );
@@ -238,13 +493,74 @@ const SubscriptionPurchase = () => {
                   <Col span={6} key={model}>
                     <Space vertical spacing={4}>
                       <Text size="small" type="secondary">{model}</Text>
-                      <Text strong>{quota.remaining}/{quota.total}</Text>
+                      <Text strong>
+                        {renderSubscriptionQuota(quota.remaining, quota.unit)}/{renderSubscriptionQuota(quota.total, quota.unit)}
+                      </Text>
+                      {quota.period_end > 0 && (
+                        <Text size="small" type="tertiary">
+                          {renderSubscriptionResetPeriod(quota.reset_period)}{t('配额')}，
+                          {t('下次重置')}: {timestamp2string(quota.period_end)}
+                        </Text>
+                      )}
                     </Space>
                   </Col>
                 ))}
               </Row>
             </Card>
           )}
+
+          {/* 当前订阅及自动续费 */}
+          {userSubscriptions.length > 0 && (
+            <Card size="small" style={{ background: '#f8f9fa' }}>
+              <Text strong style={{ marginBottom: 8, display: 'block' }}>
+                {t('我的订阅')}
+              </Text>
+              <List
+                size="small"
+                dataSource={userSubscriptions}
+                renderItem={(subscription) => (
+                  <List.Item
+                    main={
+                      <Space vertical align="start" spacing={4}>
+                        <Text strong>{subscription.plan_name}</Text>
+                        <Text size="small" type="tertiary">
+                          {t('到期时间')}: {timestamp2string(subscription.end_time)}
+                        </Text>
+                        {subscription.cancel_at_period_end && (
+                          <Tag color="orange" size="small">{t('到期后取消')}</Tag>
+                        )}
+                      </Space>
+                    }
+                    extra={
+                      <Space>
+                        <Button size="small" onClick={() => openChangePlan(subscription)}>
+                          {t('变更套餐')}
+                        </Button>
+                        {subscription.cancel_at_period_end ? (
+                          <Button size="small" onClick={() => resumeSubscription(subscription)}>
+                            {t('撤销取消')}
+                          </Button>
+                        ) : (
+                          <Button size="small" type="danger" onClick={() => openCancel(subscription)}>
+                            {t('取消订阅')}
+                          </Button>
+                        )}
+                        <Text size="small">{t('到期自动续费')}</Text>
+                        <Switch
+                          checked={subscription.auto_renew}
+                          disabled={subscription.cancel_at_period_end}
+                          onChange={(checked) => updateAutoRenew(subscription, checked)}
+                        />
+                      </Space>
+                    }
+                  />
+                )}
+              />
+              <Text size="small" type="tertiary">
+                {t('开启后将在到期前从账户余额扣除套餐价格并续期，余额不足时会通知您')}
+              </Text>
+            </Card>
+          )}
         </Space>
       </Card>
 
@@ -257,6 +573,46 @@ const SubscriptionPurchase = () => {
         ))}
       </Row>
 
+      {/* 我购买的礼品码 */}
+      {gifts.length > 0 && (
+        <Card title={t('我的礼品码')}>
+          <List
+            size="small"
+            dataSource={gifts}
+            renderItem={(gift) => (
+              <List.Item
+                main={
+                  <Space vertical align="start" spacing={4}>
+                    <Text strong>{gift.plan_name}</Text>
+                    <Text size="small" type="tertiary">
+                      {t('购买时间')}: {timestamp2string(gift.created_time)}
+                    </Text>
+                  </Space>
+                }
+                extra={
+                  <Space>
+                    {gift.status === 3 ? (
+                      <Tag color="grey">
+                        {t('已被用户兑换')} #{gift.used_user_id} {timestamp2string(gift.redeemed_time)}
+                      </Tag>
+                    ) : gift.status === 2 ? (
+                      <Tag color="red">{t('已禁用')}</Tag>
+                    ) : (
+                      <>
+                        <Tag color="green">{t('未使用')}</Tag>
+                        <Button size="small" onClick={() => copyGiftKey(gift.key)}>
+                          {t('复制兑换码')}
+                        </Button>
+                      </>
+                    )}
+                  </Space>
+                }
+              />
+            )}
+          />
+        </Card>
+      )}
+
       {/* 购买确认弹窗 */}
       <Modal
         title={t('确认购买')}
@@ -286,10 +642,12 @@ const SubscriptionPurchase = () => {
                 label={t('支付方式')}
                 placeholder={t('请选择支付方式')}
                 rules={[{ required: true, message: t('请选择支付方式') }]}
-                optionList={[
+                optionList={selectedPlan.price > 0 ? [
                   { label: t('余额支付'), value: 'balance' },
                   { label: t('微信支付'), value: 'wechat' },
                   { label: t('支付宝'), value: 'alipay' },
+                ] : [
+                  { label: t('免费开通'), value: 'balance' },
                 ]}
               />
 
@@ -311,6 +669,108 @@ const SubscriptionPurchase = () => {
           </Form>
         )}
       </Modal>
+
+      {/* 套餐变更弹窗 */}
+      <Modal
+        title={t('变更套餐')}
+        visible={!!changingSubscription}
+        onCancel={() => setChangingSubscription(null)}
+        onOk={handleChangePlan}
+        okText={t('确认变更')}
+        okButtonProps={{ disabled: !changeQuote, loading: changing }}
+        width={500}
+      >
+        {changingSubscription && (
+          <Space vertical align="start" style={{ width: '100%' }} spacing={16}>
+            <Text>
+              {t('当前套餐')}: <Text strong>{changingSubscription.plan_name}</Text>
+            </Text>
+            <Select
+              style={{ width: '100%' }}
+              placeholder={t('请选择新套餐')}
+              value={changePlanId}
+              onChange={loadChangeQuote}
+              optionList={plans
+                .filter((plan) => plan.id !== changingSubscription.plan_id)
+                .map((plan) => ({ label: `${plan.name} (¥${plan.price})`, value: plan.id }))}
+            />
+            {changeQuote && (
+              <Card size="small" style={{ background: '#f8f9fa', width: '100%' }}>
+                <Space vertical align="start" spacing={8}>
+                  <Text>{t('原套餐折算')}: {renderQuota(changeQuote.credit)}</Text>
+                  <Text>{t('新套餐价格')}: {renderQuota(changeQuote.cost)}</Text>
+                  <Text strong>
+                    {changeQuote.amount >= 0
+                      ? `${t('需补差价')}: ${renderQuota(changeQuote.amount)}`
+                      : `${t('将退还')}: ${renderQuota(-changeQuote.amount)}`}
+                  </Text>
+                  <Text size="small" type="tertiary">
+                    {t('新套餐有效期至')}: {timestamp2string(changeQuote.new_end_time)}
+                  </Text>
+                </Space>
+              </Card>
+            )}
+          </Space>
+        )}
+      </Modal>
+
+      {/* 取消订阅弹窗 */}
+      <Modal
+        title={t('取消订阅')}
+        visible={!!cancelingSubscription}
+        onCancel={() => setCancelingSubscription(null)}
+        onOk={handleCancel}
+        okText={t('确认取消')}
+        okButtonProps={{ type: 'danger', disabled: !cancelQuote, loading: canceling }}
+        width={500}
+      >
+        {cancelingSubscription && cancelQuote && (
+          <Space vertical align="start" style={{ width: '100%' }} spacing={16}>
+            <Text>
+              {t('当前套餐')}: <Text strong>{cancelingSubscription.plan_name}</Text>
+            </Text>
+            <Select
+              style={{ width: '100%' }}
+              value={cancelImmediate}
+              onChange={setCancelImmediate}
+              optionList={[
+                { label: t('到期后取消，不再续费'), value: false },
+                { label: t('立即取消'), value: true }
+              ]}
+            />
+            {cancelImmediate && (
+              <Card size="small" style={{ background: '#f8f9fa', width: '100%' }}>
+                {cancelQuote.refund_enabled ? (
+                  <Space vertical align="start" spacing={8} style={{ width: '100%' }}>
+                    <Select
+                      style={{ width: '100%' }}
+                      value={refundMethod}
+                      onChange={setRefundMethod}
+                      optionList={cancelQuote.refund_methods.map((method) => ({
+                        label: method === 'provider' ? t('原路退款') : t('退款到余额'),
+                        value: method
+                      }))}
+                    />
+                    <Text strong>
+                      {refundMethod === 'provider'
+                        ? `${t('退款金额')}: ¥${cancelQuote.refund_money.toFixed(2)}`
+                        : `${t('退还额度')}: ${renderQuota(cancelQuote.refund_quota)}`}
+                    </Text>
+                  </Space>
+                ) : (
+                  <Text>{t('立即取消后剩余时长不予退款')}</Text>
+                )}
+              </Card>
+            )}
+            <TextArea
+              placeholder={t('取消原因（可选）')}
+              maxCount={200}
+              value={cancelReason}
+              onChange={setCancelReason}
+            />
+          </Space>
+        )}
+      </Modal>
     </Space>
   );
 };
@@ -91,6 +91,8 @@ const SubscriptionStats = () => {
       case 1: return t('激活');
       case 2: return t('过期');
       case 3: return t('取消');
+      case 4: return t('待支付');
+      case 5: return t('支付超时');
       default: return t('未知');
     }
   };
@@ -52,6 +52,7 @@ const EditToken = (props) => {
     model_limits_enabled: false,
     model_limits: [],
     allow_ips: '',
+    callback_url: '',
     group: '',
   };
   const [inputs, setInputs] = useState(originInputs);
@@ -527,6 +528,18 @@ const EditToken = (props) => {
                 <Text type="tertiary" className="mt-1 block text-xs">{t('请勿过度信任此功能，IP可能被伪造')}</Text>
               </div>
 
+              <div>
+                <Text strong className="block mb-2">{t('任务回调地址')}</Text>
+                <Input
+                  placeholder={t('异步任务状态变化时推送到该地址，请求中的 callback_url 优先')}
+                  onChange={(value) => handleInputChange('callback_url', value)}
+                  value={inputs.callback_url}
+                  size="large"
+                  className="!rounded-lg"
+                  showClear
+                />
+              </div>
+
               <div>
                 <div className="flex items-center mb-2">
                   <Checkbox
@@ -7,6 +7,7 @@ import {
   renderQuota,
   renderQuotaWithAmount,
   copy,
+  timestamp2string,
   getQuotaPerUnit
 } from '../../helpers';
 import {
@@ -112,12 +113,15 @@ const TopUp = () => {
       const res = await API.post('/api/user/topup', {
         key: redemptionCode,
       });
-      const { success, message, data } = res.data;
+      const { success, message, data, subscription } = res.data;
       if (success) {
         showSuccess(t('兑换成功！'));
         Modal.success({
           title: t('兑换成功！'),
-          content: t('成功兑换额度：') + renderQuota(data),
+          content: subscription
+            ? t('成功兑换订阅套餐：') + (subscription.subscription_plan?.name || '') +
+              '，' + t('有效期至') + ' ' + timestamp2string(subscription.end_time)
+            : t('成功兑换额度：') + renderQuota(data),
           centered: true,
         });
         setUserQuota((quota) => {